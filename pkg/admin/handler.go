@@ -0,0 +1,383 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/alipay/sofa-mosn/pkg/config"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
+	"github.com/alipay/sofa-mosn/pkg/server"
+	"github.com/alipay/sofa-mosn/pkg/stats"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/rcrowley/go-metrics"
+)
+
+var healthFlags = map[types.HealthFlag]string{
+	types.FAILED_ACTIVE_HC:     "FAILED_ACTIVE_HC",
+	types.FAILED_OUTLIER_CHECK: "FAILED_OUTLIER_CHECK",
+}
+
+// percentiles of histograms and timers in stats
+var percentiles = []float64{0.5, 0.95, 0.99}
+
+type ClusterStatus struct {
+	Name   string       `json:"name"`
+	LbType string       `json:"lb_type"`
+	Hosts  []HostStatus `json:"hosts"`
+}
+
+type HostStatus struct {
+	Address     string   `json:"address"`
+	Hostname    string   `json:"hostname,omitempty"`
+	Priority    uint32   `json:"priority"`
+	Weight      uint32   `json:"weight"`
+	Healthy     bool     `json:"healthy"`
+	HealthFlags []string `json:"health_flags,omitempty"`
+}
+
+type ListenerStatus struct {
+	Name                                  string `json:"name"`
+	Address                               string `json:"address"`
+	BindToPort                            bool   `json:"bind_port"`
+	HandOffRestoredDestinationConnections bool   `json:"handoff_restoreddestination"`
+	FilterChains                          int    `json:"filter_chains"`
+}
+
+// RuntimeStatus shows the runtime values, and the values in each layer
+type RuntimeStatus struct {
+	Layers  []string                `json:"layers"`
+	Entries map[string]RuntimeEntry `json:"entries"`
+}
+
+// RuntimeEntry is a runtime key's value, LayerValues are in the order of Layers,
+// empty if the key is not found in the layer
+type RuntimeEntry struct {
+	FinalValue  string   `json:"final_value"`
+	LayerValues []string `json:"layer_values"`
+}
+
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+func (s *Server) configDump(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	content, err := config.DumpJSON()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
+
+func (s *Server) clusters(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	clusters := []ClusterStatus{}
+	if s.clusterManager != nil {
+		for name, cluster := range s.clusterManager.Clusters() {
+			status := ClusterStatus{
+				Name:   name,
+				LbType: string(cluster.Info().LbType()),
+				Hosts:  []HostStatus{},
+			}
+
+			for _, hostSet := range cluster.PrioritySet().HostSetsByPriority() {
+				for _, host := range hostSet.Hosts() {
+					status.Hosts = append(status.Hosts, newHostStatus(host, hostSet.Priority()))
+				}
+			}
+
+			clusters = append(clusters, status)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+
+	writeJSON(w, clusters)
+}
+
+func newHostStatus(host types.Host, priority uint32) HostStatus {
+	status := HostStatus{
+		Address:  host.AddressString(),
+		Hostname: host.Hostname(),
+		Priority: priority,
+		Weight:   host.Weight(),
+		Healthy:  host.Health(),
+	}
+
+	for flag, name := range healthFlags {
+		if host.ContainHealthFlag(flag) {
+			status.HealthFlags = append(status.HealthFlags, name)
+		}
+	}
+	sort.Strings(status.HealthFlags)
+
+	return status
+}
+
+func (s *Server) listeners(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	listeners := []ListenerStatus{}
+	for _, lc := range server.ListListenerConfigs() {
+		status := ListenerStatus{
+			Name:                                  lc.Name,
+			BindToPort:                            lc.BindToPort,
+			HandOffRestoredDestinationConnections: lc.HandOffRestoredDestinationConnections,
+			FilterChains:                          len(lc.FilterChains),
+		}
+		if lc.Addr != nil {
+			status.Address = lc.Addr.String()
+		}
+
+		listeners = append(listeners, status)
+	}
+
+	sort.Slice(listeners, func(i, j int) bool {
+		return listeners[i].Name < listeners[j].Name
+	})
+
+	writeJSON(w, listeners)
+}
+
+// stats returns all metrics in go-metrics' default registry,
+// query parameter "prefix" can be used to filter metrics by name
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	stats := make(map[string]interface{})
+
+	metrics.DefaultRegistry.Each(func(name string, i interface{}) {
+		if !strings.HasPrefix(name, prefix) {
+			return
+		}
+
+		switch metric := i.(type) {
+		case metrics.Counter:
+			stats[name] = metric.Count()
+		case metrics.Gauge:
+			stats[name] = metric.Value()
+		case metrics.GaugeFloat64:
+			stats[name] = metric.Value()
+		case metrics.Histogram:
+			h := metric.Snapshot()
+			stats[name] = sampleStats(h.Count(), h.Min(), h.Max(), h.Mean(), h.Percentiles(percentiles))
+		case metrics.Timer:
+			t := metric.Snapshot()
+			stats[name] = sampleStats(t.Count(), t.Min(), t.Max(), t.Mean(), t.Percentiles(percentiles))
+		case metrics.Meter:
+			m := metric.Snapshot()
+			stats[name] = map[string]interface{}{
+				"count":  m.Count(),
+				"rate1":  m.Rate1(),
+				"rate5":  m.Rate5(),
+				"rate15": m.Rate15(),
+			}
+		}
+	})
+
+	writeJSON(w, stats)
+}
+
+// metrics returns all metrics in go-metrics' default registry in prometheus text format
+func (s *Server) metrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", stats.PrometheusContentType)
+	stats.WritePrometheus(w, metrics.DefaultRegistry)
+}
+
+func sampleStats(count, min, max int64, mean float64, ps []float64) map[string]interface{} {
+	stats := map[string]interface{}{
+		"count": count,
+		"min":   min,
+		"max":   max,
+		"mean":  mean,
+	}
+
+	for i, p := range percentiles {
+		stats[fmt.Sprintf("p%g", p*100)] = ps[i]
+	}
+
+	return stats
+}
+
+// logging shows the default logger's level on GET,
+// and changes it by query parameter "level" on POST
+func (s *Server) logging(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+
+	if log.DefaultLogger == nil {
+		writeError(w, http.StatusServiceUnavailable, "default logger is not initialized")
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		name := strings.ToUpper(r.URL.Query().Get("level"))
+		level, ok := config.LookupLogLevel(name)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported log level: %s", name))
+			return
+		}
+
+		log.DefaultLogger.SetLevel(level)
+		log.DefaultLogger.Infof("admin api set log level to %s", name)
+	}
+
+	writeJSON(w, LogLevel{
+		Level: config.LogLevelName(log.DefaultLogger.GetLevel()),
+	})
+}
+
+// runtime shows the current runtime snapshot
+func (s *Server) runtime(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSON(w, newRuntimeStatus(runtime.GetSnapshot()))
+}
+
+// runtimeModify merges the query parameters into the admin layer of runtime,
+// a parameter with empty value removes the key from the admin layer
+func (s *Server) runtimeModify(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(r.Form) == 0 {
+		writeError(w, http.StatusBadRequest, "no runtime value to modify")
+		return
+	}
+
+	values := make(map[string]string, len(r.Form))
+	for key := range r.Form {
+		values[key] = r.Form.Get(key)
+	}
+
+	loader := runtime.Default()
+	loader.MergeValues(values)
+	log.DefaultLogger.Infof("admin api modify runtime: %v", values)
+
+	writeJSON(w, newRuntimeStatus(loader.Snapshot()))
+}
+
+func newRuntimeStatus(snapshot types.RuntimeSnapshot) RuntimeStatus {
+	layers := snapshot.Layers()
+	status := RuntimeStatus{
+		Layers:  make([]string, 0, len(layers)),
+		Entries: make(map[string]RuntimeEntry),
+	}
+
+	for i, layer := range layers {
+		status.Layers = append(status.Layers, layer.Name)
+
+		for key := range layer.Values {
+			if _, ok := status.Entries[key]; ok {
+				continue
+			}
+
+			entry := RuntimeEntry{
+				LayerValues: make([]string, len(layers)),
+			}
+			entry.FinalValue, _ = snapshot.Get(key)
+			for j := i; j < len(layers); j++ {
+				entry.LayerValues[j] = layers[j].Values[key]
+			}
+
+			status.Entries[key] = entry
+		}
+	}
+
+	return status
+}
+
+// drainListeners stops all listeners accepting new connections, and drains the established
+// connections, which are closed once their active requests are done
+func (s *Server) drainListeners(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	log.DefaultLogger.Infof("admin api drain listeners")
+	server.DrainListeners()
+
+	writeJSON(w, map[string]string{"status": "draining"})
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	content, _ := json.Marshal(map[string]string{"error": msg})
+	w.Write(content)
+}
@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// Server serves the admin api over http
+type Server struct {
+	address        string
+	clusterManager types.ClusterManager
+
+	mux      sync.Mutex
+	listener net.Listener
+	server   *http.Server
+}
+
+func NewServer(address string, clusterManager types.ClusterManager) *Server {
+	return &Server{
+		address:        address,
+		clusterManager: clusterManager,
+	}
+}
+
+// Start listens on the admin address and serves in a new goroutine
+func (s *Server) Start() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	s.listener = listener
+	s.server = &http.Server{
+		Handler: s.newServeMux(),
+	}
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.DefaultLogger.Errorf("admin server serve error: %v", err)
+		}
+	}(s.server)
+
+	log.DefaultLogger.Infof("admin server started on %s", listener.Addr())
+
+	return nil
+}
+
+// Addr returns the address the admin server listens on, nil if not started
+func (s *Server) Addr() net.Addr {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Addr()
+}
+
+func (s *Server) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.server == nil {
+		return nil
+	}
+
+	err := s.server.Close()
+	s.server = nil
+	s.listener = nil
+
+	return err
+}
+
+func (s *Server) newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/config_dump", s.configDump)
+	mux.HandleFunc("/clusters", s.clusters)
+	mux.HandleFunc("/listeners", s.listeners)
+	mux.HandleFunc("/stats", s.stats)
+	mux.HandleFunc("/metrics", s.metrics)
+	mux.HandleFunc("/logging", s.logging)
+	mux.HandleFunc("/runtime", s.runtime)
+	mux.HandleFunc("/runtime_modify", s.runtimeModify)
+	mux.HandleFunc("/drain_listeners", s.drainListeners)
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
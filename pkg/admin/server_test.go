@@ -0,0 +1,257 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/alipay/sofa-mosn/pkg/upstream/cluster"
+	"github.com/rcrowley/go-metrics"
+)
+
+func init() {
+	log.InitDefaultLogger("", log.INFO)
+}
+
+func startTestServer(t *testing.T) (*Server, string) {
+	clusters := []v2.Cluster{
+		{
+			Name:        "admin_test_cluster",
+			ClusterType: v2.SIMPLE_CLUSTER,
+			LbType:      v2.LB_ROUNDROBIN,
+		},
+	}
+	hosts := map[string][]v2.Host{
+		"admin_test_cluster": {
+			{Address: "127.0.0.1:10001", Hostname: "host1", Weight: 10},
+			{Address: "127.0.0.1:10002", Hostname: "host2"},
+		},
+	}
+
+	cm := cluster.NewClusterManager(nil, clusters, hosts, false, false)
+	srv := NewServer("127.0.0.1:0", cm)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("start admin server failed: %v", err)
+	}
+
+	return srv, fmt.Sprintf("http://%s", srv.Addr())
+}
+
+func request(t *testing.T, method, url string, v interface{}) int {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("new request failed: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(body, v); err != nil {
+			t.Fatalf("%s %s got invalid json %s: %v", method, url, body, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func TestServer_Clusters(t *testing.T) {
+	srv, url := startTestServer(t)
+	defer srv.Close()
+
+	cm := srv.clusterManager
+	host := cm.Clusters()["admin_test_cluster"].PrioritySet().HostSetsByPriority()[0].Hosts()[1]
+	host.SetHealthFlag(types.FAILED_OUTLIER_CHECK)
+
+	var clusters []ClusterStatus
+	if code := request(t, http.MethodGet, url+"/clusters", &clusters); code != http.StatusOK {
+		t.Fatalf("get clusters status code %d", code)
+	}
+
+	if len(clusters) != 1 || clusters[0].Name != "admin_test_cluster" ||
+		clusters[0].LbType != string(types.RoundRobin) || len(clusters[0].Hosts) != 2 {
+		t.Fatalf("get clusters unexpected: %+v", clusters)
+	}
+
+	for _, h := range clusters[0].Hosts {
+		switch h.Address {
+		case "127.0.0.1:10001":
+			if !h.Healthy || h.Weight != 10 || h.Hostname != "host1" || len(h.HealthFlags) != 0 {
+				t.Errorf("host1 status unexpected: %+v", h)
+			}
+		case "127.0.0.1:10002":
+			if h.Healthy || len(h.HealthFlags) != 1 || h.HealthFlags[0] != "FAILED_OUTLIER_CHECK" {
+				t.Errorf("host2 status unexpected: %+v", h)
+			}
+		default:
+			t.Errorf("unexpected host: %+v", h)
+		}
+	}
+}
+
+func TestServer_Stats(t *testing.T) {
+	srv, url := startTestServer(t)
+	defer srv.Close()
+
+	metrics.GetOrRegisterCounter("admin_test.counter", nil).Inc(3)
+	metrics.GetOrRegisterGauge("admin_test.gauge", nil).Update(5)
+	metrics.GetOrRegisterHistogram("admin_test.histogram", nil, metrics.NewUniformSample(100)).Update(7)
+
+	stats := make(map[string]interface{})
+	if code := request(t, http.MethodGet, url+"/stats?prefix=admin_test.", &stats); code != http.StatusOK {
+		t.Fatalf("get stats status code %d", code)
+	}
+
+	if len(stats) != 3 {
+		t.Fatalf("stats should be filtered by prefix, got %v", stats)
+	}
+
+	if stats["admin_test.counter"] != float64(3) || stats["admin_test.gauge"] != float64(5) {
+		t.Errorf("stats unexpected: %v", stats)
+	}
+
+	if histogram, ok := stats["admin_test.histogram"].(map[string]interface{}); !ok ||
+		histogram["count"] != float64(1) || histogram["max"] != float64(7) || histogram["p99"] != float64(7) {
+		t.Errorf("histogram stats unexpected: %v", stats["admin_test.histogram"])
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	srv, url := startTestServer(t)
+	defer srv.Close()
+
+	metrics.GetOrRegisterCounter("cluster.admin_test_cluster.admin_test_total", nil).Inc(2)
+
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("get metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("get metrics unexpected: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if !strings.Contains(string(body), `mosn_cluster_admin_test_total{cluster="admin_test_cluster"} 2`) {
+		t.Errorf("metrics unexpected: %s", body)
+	}
+}
+
+func TestServer_Logging(t *testing.T) {
+	srv, url := startTestServer(t)
+	defer srv.Close()
+	defer func() {
+		log.DefaultLogger.SetLevel(log.INFO)
+	}()
+
+	var level LogLevel
+	if code := request(t, http.MethodGet, url+"/logging", &level); code != http.StatusOK || level.Level != "INFO" {
+		t.Fatalf("get log level unexpected: %d %+v", code, level)
+	}
+
+	if code := request(t, http.MethodPost, url+"/logging?level=debug", &level); code != http.StatusOK || level.Level != "DEBUG" {
+		t.Fatalf("set log level unexpected: %d %+v", code, level)
+	}
+
+	if log.DefaultLogger.GetLevel() != log.DEBUG {
+		t.Errorf("default logger's level should be changed to DEBUG")
+	}
+
+	if code := request(t, http.MethodPost, url+"/logging?level=unknown", nil); code != http.StatusBadRequest {
+		t.Errorf("set unsupported log level should get status code %d, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestServer_Runtime(t *testing.T) {
+	srv, url := startTestServer(t)
+	defer srv.Close()
+	defer runtime.Default().MergeValues(map[string]string{"admin.test.key": ""})
+
+	var status RuntimeStatus
+	if code := request(t, http.MethodPost, url+"/runtime_modify?admin.test.key=10", &status); code != http.StatusOK {
+		t.Fatalf("modify runtime status code %d", code)
+	}
+
+	if code := request(t, http.MethodGet, url+"/runtime", &status); code != http.StatusOK {
+		t.Fatalf("get runtime status code %d", code)
+	}
+
+	entry, ok := status.Entries["admin.test.key"]
+	if !ok || entry.FinalValue != "10" || len(status.Layers) != 2 || status.Layers[1] != runtime.AdminLayer ||
+		len(entry.LayerValues) != 2 || entry.LayerValues[1] != "10" {
+		t.Errorf("get runtime unexpected: %+v", status)
+	}
+
+	if value := runtime.GetSnapshot().GetInteger("admin.test.key", 0); value != 10 {
+		t.Errorf("expected runtime modified, but got %d", value)
+	}
+
+	if code := request(t, http.MethodPost, url+"/runtime_modify", nil); code != http.StatusBadRequest {
+		t.Errorf("modify runtime without values should get status code %d, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestServer_ConfigDumpAndListeners(t *testing.T) {
+	srv, url := startTestServer(t)
+	defer srv.Close()
+
+	dump := make(map[string]interface{})
+	if code := request(t, http.MethodGet, url+"/config_dump", &dump); code != http.StatusOK {
+		t.Fatalf("get config dump status code %d", code)
+	}
+
+	if _, ok := dump["service_registry"]; !ok {
+		t.Errorf("config dump unexpected: %v", dump)
+	}
+
+	var listeners []ListenerStatus
+	if code := request(t, http.MethodGet, url+"/listeners", &listeners); code != http.StatusOK || len(listeners) != 0 {
+		t.Errorf("get listeners unexpected: %d %+v", code, listeners)
+	}
+}
+
+func TestServer_Methods(t *testing.T) {
+	srv, url := startTestServer(t)
+	defer srv.Close()
+
+	if code := request(t, http.MethodGet, url+"/drain_listeners", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("get drain listeners should get status code %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+
+	if code := request(t, http.MethodPost, url+"/drain_listeners", nil); code != http.StatusOK {
+		t.Errorf("post drain listeners status code %d", code)
+	}
+
+	if code := request(t, http.MethodPost, url+"/clusters", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("post clusters should get status code %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+}
@@ -41,13 +41,21 @@ const (
 	STATIC_CLUSTER  ClusterType = "STATIC"
 	SIMPLE_CLUSTER  ClusterType = "SIMPLE"
 	DYNAMIC_CLUSTER ClusterType = "DYNAMIC"
+	// hosts are resolved to all the addresses of their hostnames periodically
+	STRICT_DNS_CLUSTER ClusterType = "STRICT_DNS"
+	// hosts are resolved to the first address of their hostnames periodically
+	LOGICAL_DNS_CLUSTER ClusterType = "LOGICAL_DNS"
 )
 
 type LbType string
 
 const (
-	LB_RANDOM     LbType = "LB_RANDOM"
-	LB_ROUNDROBIN LbType = "LB_ROUNDROBIN"
+	LB_RANDOM             LbType = "LB_RANDOM"
+	LB_ROUNDROBIN         LbType = "LB_ROUNDROBIN"
+	LB_WEIGHTEDROUNDROBIN LbType = "LB_WEIGHTEDROUNDROBIN"
+	LB_LEASTREQUEST       LbType = "LB_LEASTREQUEST"
+	LB_RINGHASH           LbType = "LB_RINGHASH"
+	LB_MAGLEV             LbType = "LB_MAGLEV"
 )
 
 type Cluster struct {
@@ -63,6 +71,8 @@ type Cluster struct {
 	LBSubSetConfig       LBSubsetConfig
 	TLS                  TLSConfig
 	Hosts                []Host
+	// interval to resolve the hostnames of DNS clusters, a shorter TTL of the records takes precedence
+	DNSRefreshRate time.Duration
 }
 
 type CircuitBreakers struct {
@@ -75,6 +85,16 @@ type Thresholds struct {
 	MaxPendingRequests uint32
 	MaxRequests        uint32
 	MaxRetries         uint32
+	// retry budget takes the place of MaxRetries if set
+	RetryBudget *RetryBudget
+}
+
+// RetryBudget limits the concurrent retries of a cluster to a percentage of its active requests
+type RetryBudget struct {
+	// percentage of active requests allowed to be retried concurrently
+	BudgetPercent float64
+	// concurrent retries always allowed, no matter how few requests are active
+	MinRetryConcurrency uint32
 }
 
 type OutlierDetection struct {
@@ -103,6 +123,19 @@ type Host struct {
 	Hostname string
 	Weight   uint32
 	MetaData Metadata
+	// priority of the host in the cluster, 0 is the highest, lower priorities are used
+	// only if the higher ones are not healthy enough
+	Priority uint32
+	// where the host is deployed
+	Locality Locality
+}
+
+// Locality identifies where a host is deployed, the zone is compared with ApplicationInfo.Zone
+// to prefer the hosts in local zone
+type Locality struct {
+	Region  string
+	Zone    string
+	SubZone string
 }
 
 type ListenerConfig struct {
@@ -120,6 +153,7 @@ type ListenerConfig struct {
 	AccessLogs                            []AccessLog
 	DisableConnIo                         bool          // only used in http2 case
 	FilterChains                          []FilterChain // FilterChains
+	StreamFilters                         []Filter      // stream filters of the listener updated by LDS
 }
 
 type AccessLog struct {
@@ -171,6 +205,41 @@ type FaultInject struct {
 	DelayDuration uint64
 }
 
+// LocalRateLimit limits requests by token buckets in process, requests are matched
+// to the descriptors generated by the route's rate limits of the same stage
+type LocalRateLimit struct {
+	// limits of filters with the same name are shared and updated in place on reload
+	Name        string
+	Stage       uint64
+	Descriptors []LocalRateLimitDescriptor
+}
+
+// LocalRateLimitDescriptor is a token bucket for the requests whose descriptor matches Entries,
+// an entry with empty value matches any value, and each value has its own bucket
+type LocalRateLimitDescriptor struct {
+	Entries       []RateLimitDescriptorEntry
+	MaxTokens     uint32
+	TokensPerFill uint32
+	FillInterval  time.Duration
+}
+
+type RateLimitDescriptorEntry struct {
+	Key   string
+	Value string
+}
+
+// GlobalRateLimit asks the rate limit service whether requests should be limited, requests
+// are described by the route's rate limits of the same stage
+type GlobalRateLimit struct {
+	Domain string
+	Stage  uint64
+	// address of the rate limit service speaking envoy's ratelimit grpc api
+	ServiceAddress string
+	Timeout        time.Duration
+	// requests are allowed if the rate limit service fails or times out
+	FailureModeAllow bool
+}
+
 type Proxy struct {
 	DownstreamProtocol  string
 	UpstreamProtocol    string
@@ -178,6 +247,9 @@ type Proxy struct {
 	BasicRoutes         []*BasicServiceRoute
 	VirtualHosts        []*VirtualHost
 	ValidateClusters    bool
+	SubProtocol         string
+	// name of the route config discovered by RDS, VirtualHosts are ignored if it is set
+	RouterConfigName string
 }
 
 type BasicServiceRoute struct {
@@ -190,9 +262,34 @@ type BasicServiceRoute struct {
 
 type RetryPolicy struct {
 	RetryOn      bool
-	RetryTimeout time.Duration
+	RetryTimeout time.Duration // per try timeout
 	NumRetries   uint32
-}
+	// conditions to retry on, 5xx is used if none is set
+	RetryConditions      []RetryCondition
+	RetriableStatusCodes []uint32
+	// retries are delayed by a jittered exponential back off between 0 and
+	// BackOffBaseInterval * 2^(retries-1), which is limited by BackOffMaxInterval
+	BackOffBaseInterval time.Duration
+	BackOffMaxInterval  time.Duration
+}
+
+type RetryCondition string
+
+// Retry conditions:
+//   - 5xx: upstream responds with 5xx, or the request is reset
+//   - gateway-error: upstream responds with 502, 503 or 504, or the request is reset
+//   - connect-failure: upstream connection can not be established
+//   - reset: the request is reset by connection failure, disconnection, remote reset or per try timeout
+//   - retriable-status-codes: upstream responds with one of RetriableStatusCodes
+//   - retriable-sofarpc-status: sofarpc upstream responds with a retriable status, such as server threadpool busy
+const (
+	RetryOn5xx                    RetryCondition = "5xx"
+	RetryOnGatewayError           RetryCondition = "gateway-error"
+	RetryOnConnectFailure         RetryCondition = "connect-failure"
+	RetryOnReset                  RetryCondition = "reset"
+	RetryOnRetriableStatusCodes   RetryCondition = "retriable-status-codes"
+	RetryOnRetriableSofaRPCStatus RetryCondition = "retriable-sofarpc-status"
+)
 
 type HealthCheck struct {
 	Protocol           string
@@ -266,6 +363,8 @@ type VirtualHost struct {
 	Routers         []Router
 	RequireTLS      string
 	VirtualClusters []VirtualCluster
+	RateLimits      []RateLimit
+	Cors            *CorsPolicy
 }
 
 type Router struct {
@@ -300,11 +399,130 @@ type RouteAction struct {
 	MetadataMatch    Metadata
 	Timeout          time.Duration
 	RetryPolicy      *RetryPolicy
+	HashPolicy       []HashPolicy
+	ShadowPolicy     *ShadowPolicy
+	RateLimits       []RateLimit
+	// apply the virtual host's rate limits too, they are applied anyway if the route has no rate limits
+	IncludeVirtualHostRateLimits bool
+	// the route's cors policy takes precedence over the virtual host's
+	Cors *CorsPolicy
+}
+
+// CorsPolicy allows cross origin requests from the matched origins,
+// the header values are set in the responses as they are
+type CorsPolicy struct {
+	AllowOrigins     []OriginMatcher
+	AllowMethods     string
+	AllowHeaders     string
+	ExposeHeaders    string
+	MaxAge           string
+	AllowCredentials bool
+	// disable the policy, e.g. disable the virtual host's policy for a route
+	Disabled bool
+}
+
+// OriginMatcher matches the request's origin, only one of the matchers should be set,
+// exact "*" matches any origin
+type OriginMatcher struct {
+	Exact  string
+	Prefix string
+	Regex  string
+}
+
+// RateLimit generates a descriptor for rate limiting, the descriptor is made up of
+// an entry generated by each action
+type RateLimit struct {
+	Stage uint64
+	// the rate limit is disabled if the runtime key is false
+	DisableKey string
+	Actions    []RateLimitAction
+}
+
+// RateLimitAction generates a descriptor entry, only one of the actions should be set
+type RateLimitAction struct {
+	// ("destination_cluster", "<routed cluster>")
+	DestinationCluster *DestinationClusterAction
+	// ("virtual_host", "<virtual host name>")
+	VirtualHost *VirtualHostAction
+	// ("<descriptor key>", "<header value>"), no descriptor is generated if the header is absent,
+	// header name is used as descriptor key if not set
+	RequestHeaders *RequestHeadersAction
+	// ("remote_address", "<downstream ip>")
+	RemoteAddress *RemoteAddressAction
+	// ("<descriptor key>", "<sofarpc property>"), service name is used if property is not set,
+	// property name is used as descriptor key if not set
+	SofaRPC *SofaRPCAction
+	// ("generic_key", "<descriptor value>")
+	GenericKey *GenericKeyAction
+}
+
+type DestinationClusterAction struct{}
+
+type VirtualHostAction struct{}
+
+type RequestHeadersAction struct {
+	HeaderName    string
+	DescriptorKey string
+}
+
+type RemoteAddressAction struct{}
+
+type SofaRPCAction struct {
+	Property      string
+	DescriptorKey string
+}
+
+type GenericKeyAction struct {
+	DescriptorValue string
+}
+
+// ShadowPolicy mirrors requests to the shadow cluster, responses of the
+// mirrored requests are discarded
+type ShadowPolicy struct {
+	Cluster    string
+	RuntimeKey string
+	// percentage of requests to be mirrored, all requests are mirrored if not set or 0,
+	// remove the policy to stop mirroring
+	Percent uint32
+}
+
+// HashPolicy specifies how to generate the hash key used by
+// consistent hash load balancers, only one of the policies should be set
+type HashPolicy struct {
+	Header               *HeaderHashPolicy
+	Cookie               *CookieHashPolicy
+	ConnectionProperties *ConnectionPropertiesHashPolicy
+	SofaRPC              *SofaRPCHashPolicy
+	// stop evaluating the following policies if this policy generates a hash key
+	Terminal bool
+}
+
+type HeaderHashPolicy struct {
+	Name string
+}
+
+// CookieHashPolicy uses the cookie's value as the hash key,
+// a cookie will be generated and set in the response if not exist and TTL is set
+type CookieHashPolicy struct {
+	Name string
+	Path string
+	TTL  time.Duration
+}
+
+type ConnectionPropertiesHashPolicy struct {
+	SourceIP bool
+}
+
+// SofaRPCHashPolicy uses a sofarpc request property as the hash key,
+// service name is used if property is not set
+type SofaRPCHashPolicy struct {
+	Property string
 }
 
 type WeightedCluster struct {
-	Clusters         ClusterWeight
-	RuntimeKeyPrefix string // not used currently
+	Clusters ClusterWeight
+	// the cluster's weight is overridden by the runtime key "<RuntimeKeyPrefix>.<cluster name>" if set
+	RuntimeKeyPrefix string
 }
 
 type ClusterWeight struct {
@@ -313,6 +531,7 @@ type ClusterWeight struct {
 	MetadataMatch Metadata
 }
 
+// RuntimeUInt32 is an integer read from runtime by the key, DefaultValue is used if the key is not found
 type RuntimeUInt32 struct {
 	DefaultValue uint32
 	RuntimeKey   string
@@ -19,9 +19,11 @@ package config
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/router"
 	"github.com/alipay/sofa-mosn/pkg/server"
 	"github.com/alipay/sofa-mosn/pkg/server/config/proxy"
 	"github.com/alipay/sofa-mosn/pkg/types"
@@ -38,38 +40,119 @@ func SetGlobalStreamFilter(globalStreamFilters []types.StreamFilterChainFactory)
 // todo , no hack
 var streamFilter []types.StreamFilterChainFactory
 
-func (config *MOSNConfig) OnUpdateListeners(listeners []*pb.Listener) error {
-	for _, listener := range listeners {
-		mosnListener := convertListenerConfig(listener)
-		if mosnListener == nil {
-			continue
+// StreamFilterFactoryCreator creates the stream filter factory of the filter type by the config
+type StreamFilterFactoryCreator func(filterType string, config map[string]interface{}) (types.StreamFilterChainFactory, error)
+
+// streamFilterCreator creates the stream filters of the listeners updated by LDS
+var streamFilterCreator StreamFilterFactoryCreator
+
+// SetStreamFilterFactoryCreator sets the creator of the stream filters of the listeners updated by LDS
+func SetStreamFilterFactoryCreator(creator StreamFilterFactoryCreator) {
+	streamFilterCreator = creator
+}
+
+// createStreamFilters creates the stream filter factories from the listener config, so that the filters,
+// such as the limits of rate limit filter, are updated with the listener. Listeners without stream filters
+// use the global ones
+func createStreamFilters(listener *v2.ListenerConfig) ([]types.StreamFilterChainFactory, error) {
+	if len(listener.StreamFilters) == 0 {
+		return streamFilter, nil
+	}
+
+	if streamFilterCreator == nil {
+		return nil, errors.New("stream filter factory creator is not set")
+	}
+
+	factories := make([]types.StreamFilterChainFactory, 0, len(listener.StreamFilters))
+	for _, filter := range listener.StreamFilters {
+		factory, err := streamFilterCreator(filter.Name, filter.Config)
+		if err != nil {
+			return nil, fmt.Errorf("create stream filter %s failed: %v", filter.Name, err)
 		}
+		factories = append(factories, factory)
+	}
+
+	return factories, nil
+}
+
+// names of the listeners added by LDS, listeners in config file are never removed by LDS
+var dynamicListeners = make(map[string]bool)
+
+// names of the clusters added by CDS, clusters in config file are never removed by CDS
+var dynamicClusters = make(map[string]bool)
 
-		var networkFilter *proxy.GenericProxyFilterConfigFactory
+// listenerUpdate is the listener converted from LDS with its filters
+type listenerUpdate struct {
+	listener      *v2.ListenerConfig
+	networkFilter *proxy.GenericProxyFilterConfigFactory
+	streamFilters []types.StreamFilterChainFactory
+}
+
+// convertListenerUpdate converts the listener and creates its filters
+func convertListenerUpdate(listener *pb.Listener) (*listenerUpdate, error) {
+	mosnListener, err := convertListenerConfig(listener)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &listenerUpdate{
+		listener: mosnListener,
+	}
 
-		if !mosnListener.HandOffRestoredDestinationConnections {
-			for _, filterChain := range mosnListener.FilterChains {
-				for _, filter := range filterChain.Filters {
-					if filter.Name == v2.DEFAULT_NETWORK_FILTER {
-						networkFilter = &proxy.GenericProxyFilterConfigFactory{
-							Proxy: ParseProxyFilterJSON(&filter),
-						}
+	if !mosnListener.HandOffRestoredDestinationConnections {
+		for _, filterChain := range mosnListener.FilterChains {
+			for _, filter := range filterChain.Filters {
+				if filter.Name == v2.DEFAULT_NETWORK_FILTER {
+					proxyConfig, err := ParseProxyFilterJSON(&filter)
+					if err != nil {
+						return nil, fmt.Errorf("invalid proxy of listener %s: %v", mosnListener.Name, err)
+					}
+					update.networkFilter = &proxy.GenericProxyFilterConfigFactory{
+						Proxy: proxyConfig,
 					}
 				}
 			}
+		}
 
-			if networkFilter == nil {
-				errMsg := "xds client update listener error: proxy needed in network filters"
-				log.DefaultLogger.Errorf(errMsg)
-				return errors.New(errMsg)
-			}
+		if update.networkFilter == nil {
+			return nil, fmt.Errorf("proxy needed in network filters of listener %s", mosnListener.Name)
+		}
+	}
+
+	if update.streamFilters, err = createStreamFilters(mosnListener); err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
+
+// OnUpdateListeners adds or updates the listeners, and removes the listeners added by LDS before
+// but not in the listeners, as each LDS response contains all the listeners.
+// All the listeners are converted before any of them is updated, so the response is rejected
+// without partial update if any listener is invalid
+func (config *MOSNConfig) OnUpdateListeners(listeners []*pb.Listener) error {
+	updates := make([]*listenerUpdate, 0, len(listeners))
+	for _, listener := range listeners {
+		update, err := convertListenerUpdate(listener)
+		if err != nil {
+			log.DefaultLogger.Errorf("xds client update listener error: %v", err)
+			return err
 		}
+		updates = append(updates, update)
+	}
+
+	updated := make(map[string]bool, len(updates))
+
+	for _, update := range updates {
+		mosnListener := update.listener
+		updated[mosnListener.Name] = true
 
 		if server := server.GetServer(); server == nil {
 			log.DefaultLogger.Fatal("Server is nil and hasn't been initiated at this time")
 		} else {
-			if err := server.AddListenerAndStart(mosnListener, networkFilter, streamFilter); err == nil {
+			if err := server.AddOrUpdateListener(mosnListener, update.networkFilter, update.streamFilters); err == nil {
 				log.DefaultLogger.Debugf("xds client update listener success,listener = %+v\n", mosnListener)
+				dynamicListeners[mosnListener.Name] = true
 			} else {
 				log.DefaultLogger.Errorf("xds client update listener error,listener = %+v\n", mosnListener)
 				return err
@@ -78,46 +161,103 @@ func (config *MOSNConfig) OnUpdateListeners(listeners []*pb.Listener) error {
 
 	}
 
+	for name := range dynamicListeners {
+		if updated[name] {
+			continue
+		}
+
+		if server := server.GetServer(); server != nil {
+			if err := server.RemoveListener(name); err != nil {
+				log.DefaultLogger.Errorf("xds client remove listener %s error: %v", name, err)
+				continue
+			}
+			log.DefaultLogger.Debugf("xds client remove listener success, listener = %s", name)
+		}
+
+		delete(dynamicListeners, name)
+	}
+
 	return nil
 }
 
-func (config *MOSNConfig) OnUpdateClusters(clusters []*pb.Cluster) error {
-	mosnClusters := convertClustersConfig(clusters)
+// OnUpdateClusters adds or updates the clusters, and removes the clusters added by CDS before
+// but not in the clusters, as each CDS response contains all the clusters.
+// The clusters are not updated if any of them fails to convert, and the last error is returned
+// if any cluster fails to update, so the response could be rejected
+func (config *MOSNConfig) OnUpdateClusters(clusters []*pb.Cluster) (err error) {
+	mosnClusters, err := convertClustersConfig(clusters)
+	if err != nil {
+		log.DefaultLogger.Errorf("xds client update cluster error: %v", err)
+		return err
+	}
+	updated := make(map[string]bool, len(mosnClusters))
 
 	for _, cluster := range mosnClusters {
 		log.DefaultLogger.Debugf("cluster: %+v\n", cluster)
-		if err := clusterAdapter.Adap.TriggerClusterUpdate(cluster.Name, cluster.Hosts); err != nil {
+		updated[cluster.Name] = true
+
+		if e := clusterAdapter.Adap.TriggerClusterAddOrUpdate(*cluster); e != nil {
 			log.DefaultLogger.Errorf("xds client update cluster error ,err = %s, clustername = %s , hosts = %+v",
-				err.Error(), cluster.Name, cluster.Hosts)
+				e.Error(), cluster.Name, cluster.Hosts)
+			err = e
 		} else {
 			log.DefaultLogger.Debugf("xds client update cluster success, clustername = %s", cluster.Name)
+			dynamicClusters[cluster.Name] = true
+		}
+
+	}
+
+	for name := range dynamicClusters {
+		if updated[name] {
+			continue
 		}
 
+		clusterAdapter.Adap.TriggerClusterDel(name)
+		log.DefaultLogger.Debugf("xds client remove cluster success, clustername = %s", name)
+
+		delete(dynamicClusters, name)
+	}
+
+	return err
+}
+
+// OnUpdateRoutes rebuilds the route tables, proxies with the route config name use them for new streams
+func (config *MOSNConfig) OnUpdateRoutes(routes []*pb.RouteConfiguration) error {
+	for _, route := range routes {
+		log.DefaultLogger.Debugf("xds client update route: %s", route.GetName())
+
+		if err := router.RouteConfigs.Update(route.GetName(), convertVirtualHosts(route)); err != nil {
+			log.DefaultLogger.Errorf("xds client update route error, route = %s, err = %v", route.GetName(), err)
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (config *MOSNConfig) OnUpdateEndpoints(loadAssignments []*pb.ClusterLoadAssignment) error {
+// OnUpdateEndpoints updates the hosts of the clusters, the last error is returned if any cluster fails to update
+func (config *MOSNConfig) OnUpdateEndpoints(loadAssignments []*pb.ClusterLoadAssignment) (err error) {
 
 	for _, loadAssignment := range loadAssignments {
 		clusterName := loadAssignment.ClusterName
 
+		// hosts of all localities and priorities are updated together, each host carries its locality and priority
+		var hosts []v2.Host
 		for _, endpoints := range loadAssignment.Endpoints {
-			hosts := convertEndpointsConfig(&endpoints)
-
-			for _, host := range hosts {
+			for _, host := range convertEndpointsConfig(&endpoints) {
 				log.DefaultLogger.Debugf("xds client update endpoint: cluster: %s, priority: %d, %+v\n", loadAssignment.ClusterName, endpoints.Priority, host)
+				hosts = append(hosts, host)
 			}
+		}
 
-			if err := clusterAdapter.Adap.TriggerClusterUpdate(clusterName, hosts); err != nil {
-				log.DefaultLogger.Errorf("xds client update Error = %s", err.Error())
-			} else {
-				log.DefaultLogger.Debugf("xds client update host success")
+		if e := clusterAdapter.Adap.TriggerClusterUpdate(clusterName, hosts); e != nil {
+			log.DefaultLogger.Errorf("xds client update Error = %s", e.Error())
+			err = e
+		} else {
+			log.DefaultLogger.Debugf("xds client update host success")
 
-			}
 		}
 	}
 
-	return nil
+	return err
 }
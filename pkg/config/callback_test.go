@@ -0,0 +1,69 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+type testStreamFilterFactory struct {
+	config map[string]interface{}
+}
+
+func (f *testStreamFilterFactory) CreateFilterChain(context context.Context, callbacks types.FilterChainFactoryCallbacks) {
+}
+
+func Test_createStreamFilters(t *testing.T) {
+	defer SetStreamFilterFactoryCreator(nil)
+	SetStreamFilterFactoryCreator(func(filterType string, config map[string]interface{}) (types.StreamFilterChainFactory, error) {
+		if filterType != "local_rate_limit" {
+			return nil, errors.New("unsupport stream filter type")
+		}
+		return &testStreamFilterFactory{config: config}, nil
+	})
+
+	// the factories are rebuilt from the listener config on each update
+	for _, maxTokens := range []float64{100, 200} {
+		listener := &v2.ListenerConfig{
+			StreamFilters: []v2.Filter{{
+				Name:   "local_rate_limit",
+				Config: map[string]interface{}{"max_tokens": maxTokens},
+			}},
+		}
+
+		factories, err := createStreamFilters(listener)
+		if err != nil || len(factories) != 1 {
+			t.Fatalf("createStreamFilters() = %v, %v", factories, err)
+		}
+		if got := factories[0].(*testStreamFilterFactory).config["max_tokens"]; got != maxTokens {
+			t.Errorf("expected max_tokens %v, but got %v", maxTokens, got)
+		}
+	}
+
+	listener := &v2.ListenerConfig{
+		StreamFilters: []v2.Filter{{Name: "unknown"}},
+	}
+	if _, err := createStreamFilters(listener); err == nil {
+		t.Errorf("expected error for unknown stream filter")
+	}
+}
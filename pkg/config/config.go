@@ -119,6 +119,20 @@ type ClusterHealthCheckConfig struct {
 	ServiceName        string         `json:"service_name,omitempty"`
 }
 
+type ClusterOutlierDetectionConfig struct {
+	Consecutive5xx                     uint32         `json:"consecutive_5xx,omitempty"`
+	Interval                           DurationConfig `json:"interval"`
+	BaseEjectionTime                   DurationConfig `json:"base_ejection_time"`
+	MaxEjectionPercent                 uint32         `json:"max_ejection_percent,omitempty"`
+	ConsecutiveGatewayFailure          uint32         `json:"consecutive_gateway_failure,omitempty"`
+	EnforcingConsecutive5xx            uint32         `json:"enforcing_consecutive_5xx,omitempty"`
+	EnforcingConsecutiveGatewayFailure uint32         `json:"enforcing_consecutive_gateway_failure,omitempty"`
+	EnforcingSuccessRate               uint32         `json:"enforcing_success_rate,omitempty"`
+	SuccessRateMinimumHosts            uint32         `json:"success_rate_minimum_hosts,omitempty"`
+	SuccessRateRequestVolume           uint32         `json:"success_rate_request_volume,omitempty"`
+	SuccessRateStdevFactor             uint32         `json:"success_rate_stdev_factor,omitempty"`
+}
+
 type ClusterSpecConfig struct {
 	Subscribes []SubscribeSpecConfig `json:"subscribe,omitempty"`
 }
@@ -134,12 +148,14 @@ type ClusterConfig struct {
 	LbType               string `json:"lb_type"`
 	MaxRequestPerConn    uint32
 	ConnBufferLimitBytes uint32
-	CircuitBreakers      []*CircuitBreakerdConfig `json:"circuit_breakers"`
-	HealthCheck          ClusterHealthCheckConfig `json:"health_check,omitempty"` //v2.HealthCheck
-	ClusterSpecConfig    ClusterSpecConfig        `json:"spec,omitempty"`         //	ClusterSpecConfig
-	Hosts                []v2.Host                `json:"hosts,omitempty"`        //v2.Host
+	CircuitBreakers      []*CircuitBreakerdConfig      `json:"circuit_breakers"`
+	HealthCheck          ClusterHealthCheckConfig      `json:"health_check,omitempty"`      //v2.HealthCheck
+	OutlierDetection     ClusterOutlierDetectionConfig `json:"outlier_detection,omitempty"` //v2.OutlierDetection
+	ClusterSpecConfig    ClusterSpecConfig             `json:"spec,omitempty"`              //	ClusterSpecConfig
+	Hosts                []v2.Host                     `json:"hosts,omitempty"`             //v2.Host
 	LBSubsetConfig       v2.LBSubsetConfig
-	TLS                  TLSConfig `json:"tls_context,omitempty"`
+	TLS                  TLSConfig      `json:"tls_context,omitempty"`
+	DNSRefreshRate       DurationConfig `json:"dns_refresh_rate,omitempty"`
 }
 
 type CircuitBreakerdConfig struct {
@@ -148,6 +164,41 @@ type CircuitBreakerdConfig struct {
 	MaxPendingRequests uint32 `json:"max_pending_requests"`
 	MaxRequests        uint32 `json:"max_requests"`
 	MaxRetries         uint32 `json:"max_retries"`
+	// if set, max_retries is ignored
+	RetryBudget *RetryBudgetConfig `json:"retry_budget,omitempty"`
+}
+
+type RetryBudgetConfig struct {
+	BudgetPercent       float64 `json:"budget_percent"`
+	MinRetryConcurrency uint32  `json:"min_retry_concurrency"`
+}
+
+// LocalRateLimitConfig is the config of local rate limit stream filter
+type LocalRateLimitConfig struct {
+	Name        string                           `json:"name,omitempty"`
+	Stage       uint64                           `json:"stage,omitempty"`
+	Descriptors []LocalRateLimitDescriptorConfig `json:"descriptors"`
+}
+
+type LocalRateLimitDescriptorConfig struct {
+	Entries       []RateLimitDescriptorEntryConfig `json:"entries"`
+	MaxTokens     uint32                           `json:"max_tokens"`
+	TokensPerFill uint32                           `json:"tokens_per_fill,omitempty"`
+	FillInterval  DurationConfig                   `json:"fill_interval"`
+}
+
+type RateLimitDescriptorEntryConfig struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// GlobalRateLimitConfig is the config of rate limit stream filter
+type GlobalRateLimitConfig struct {
+	Domain           string         `json:"domain"`
+	Stage            uint64         `json:"stage,omitempty"`
+	ServiceAddress   string         `json:"service_address"`
+	Timeout          DurationConfig `json:"timeout,omitempty"`
+	FailureModeAllow *bool          `json:"failure_mode_allow,omitempty"`
 }
 
 type ClusterManagerConfig struct {
@@ -167,6 +218,7 @@ type ServiceAppInfoConfig struct {
 	AntShareCloud bool   `json:"ant_share_cloud"`
 	DataCenter    string `json:"data_center,omitempty"`
 	AppName       string `json:"app_name,omitempty"`
+	Zone          string `json:"zone,omitempty"`
 }
 
 type ServicePubInfoConfig struct {
@@ -175,12 +227,33 @@ type ServicePubInfoConfig struct {
 }
 
 type MOSNConfig struct {
-	Servers         []ServerConfig        `json:"servers,omitempty"`         //server config
-	ClusterManager  ClusterManagerConfig  `json:"cluster_manager,omitempty"` //cluster config
-	ServiceRegistry ServiceRegistryConfig `json:"service_registry"`          //service registry config, used by service discovery module
-	//tracing config
-	RawDynamicResources json.RawMessage `json:"dynamic_resources,omitempty"` //dynamic_resources raw message
-	RawStaticResources  json.RawMessage `json:"static_resources,omitempty"`  //static_resources raw message
+	Servers             []ServerConfig        `json:"servers,omitempty"`           //server config
+	ClusterManager      ClusterManagerConfig  `json:"cluster_manager,omitempty"`   //cluster config
+	ServiceRegistry     ServiceRegistryConfig `json:"service_registry"`            //service registry config, used by service discovery module
+	Admin               AdminConfig           `json:"admin,omitempty"`             //admin api config
+	Tracing             TracingConfig         `json:"tracing,omitempty"`           //tracing config
+	Runtime             RuntimeConfig         `json:"runtime,omitempty"`           //runtime config
+	RawDynamicResources json.RawMessage       `json:"dynamic_resources,omitempty"` //dynamic_resources raw message
+	RawStaticResources  json.RawMessage       `json:"static_resources,omitempty"`  //static_resources raw message
+}
+
+// AdminConfig for admin api, admin api is disabled if address is empty
+type AdminConfig struct {
+	Address string `json:"address,omitempty"`
+}
+
+// TracingConfig for tracing, the driver is created with the config if tracing is enabled
+type TracingConfig struct {
+	Enable bool                   `json:"enable,omitempty"`
+	Driver string                 `json:"driver,omitempty"`
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// RuntimeConfig for runtime, values are loaded from the files in root dir if it's set,
+// and reloaded in the refresh interval if it's positive
+type RuntimeConfig struct {
+	RootDir         string         `json:"root_dir,omitempty"`
+	RefreshInterval DurationConfig `json:"refresh_interval,omitempty"`
 }
 
 type Mode uint8
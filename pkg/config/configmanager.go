@@ -42,6 +42,7 @@ func ResetServiceRegistryInfo(appInfo v2.ApplicationInfo, subServiceList []strin
 		AntShareCloud: appInfo.AntShareCloud,
 		DataCenter:    appInfo.DataCenter,
 		AppName:       appInfo.AppName,
+		Zone:          appInfo.Zone,
 	}
 
 	// reset servicePubInfo
@@ -142,10 +143,27 @@ func convertClusterConfig(cluster v2.Cluster) ClusterConfig {
 		MaxRequestPerConn:    cluster.MaxRequestPerConn,
 		ConnBufferLimitBytes: cluster.ConnBufferLimitBytes,
 		HealthCheck:          convertClusterHealthCheck(cluster.HealthCheck),
+		OutlierDetection:     convertClusterOutlierDetection(cluster.OutlierDetection),
 		ClusterSpecConfig:    convertClusterSpec(cluster.Spec),
 	}
 }
 
+func convertClusterOutlierDetection(od v2.OutlierDetection) ClusterOutlierDetectionConfig {
+	return ClusterOutlierDetectionConfig{
+		Consecutive5xx:                     od.Consecutive5xx,
+		Interval:                           DurationConfig{od.Interval},
+		BaseEjectionTime:                   DurationConfig{od.BaseEjectionTime},
+		MaxEjectionPercent:                 od.MaxEjectionPercent,
+		ConsecutiveGatewayFailure:          od.ConsecutiveGatewayFailure,
+		EnforcingConsecutive5xx:            od.EnforcingConsecutive5xx,
+		EnforcingConsecutiveGatewayFailure: od.EnforcingConsecutiveGatewayFailure,
+		EnforcingSuccessRate:               od.EnforcingSuccessRate,
+		SuccessRateMinimumHosts:            od.SuccessRateMinimumHosts,
+		SuccessRateRequestVolume:           od.SuccessRateRequestVolume,
+		SuccessRateStdevFactor:             od.SuccessRateStdevFactor,
+	}
+}
+
 func convertClusterSpec(clusterSpec v2.ClusterSpecInfo) ClusterSpecConfig {
 	var specs []SubscribeSpecConfig
 
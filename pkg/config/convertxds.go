@@ -18,6 +18,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/log"
 	"github.com/alipay/sofa-mosn/pkg/protocol"
+	mosntypes "github.com/alipay/sofa-mosn/pkg/types"
 	xdsxproxy "github.com/alipay/sofa-mosn/pkg/xds-config-model/filter/network/x_proxy/v2"
 	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	xdsauth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
@@ -53,14 +55,21 @@ var httpBaseConfig = map[string]bool{
 	v2.RPC_PROXY:                  true,
 }
 
-func convertListenerConfig(xdsListener *xdsapi.Listener) *v2.ListenerConfig {
-	if !isSupport(xdsListener) {
-		return nil
+// convertListenerConfig converts the xds listener, an error is returned if the listener is not supported
+// or any of its config is invalid, so that the response could be rejected
+func convertListenerConfig(xdsListener *xdsapi.Listener) (*v2.ListenerConfig, error) {
+	if err := checkSupport(xdsListener); err != nil {
+		return nil, err
+	}
+
+	addr := convertAddress(&xdsListener.Address)
+	if addr == nil {
+		return nil, fmt.Errorf("invalid address of listener %s", xdsListener.GetName())
 	}
 
 	listenerConfig := &v2.ListenerConfig{
 		Name:                                  xdsListener.GetName(),
-		Addr:                                  convertAddress(&xdsListener.Address),
+		Addr:                                  addr,
 		BindToPort:                            convertBindToPort(xdsListener.GetDeprecatedV1()),
 		PerConnBufferLimitBytes:               xdsListener.GetPerConnectionBufferLimitBytes().GetValue(),
 		HandOffRestoredDestinationConnections: xdsListener.GetUseOriginalDst().GetValue(),
@@ -71,10 +80,16 @@ func convertListenerConfig(xdsListener *xdsapi.Listener) *v2.ListenerConfig {
 
 	// virtual listener need none filters
 	if listenerConfig.Name == "virtual" {
-		return listenerConfig
+		return listenerConfig, nil
 	}
 
-	listenerConfig.FilterChains = convertFilterChains(xdsListener.GetFilterChains())
+	var err error
+	if listenerConfig.FilterChains, err = convertFilterChains(xdsListener.GetFilterChains()); err != nil {
+		return nil, fmt.Errorf("invalid filter chains of listener %s: %v", listenerConfig.Name, err)
+	}
+	if listenerConfig.StreamFilters, err = convertStreamFilters(xdsListener); err != nil {
+		return nil, fmt.Errorf("invalid stream filters of listener %s: %v", listenerConfig.Name, err)
+	}
 
 	// it must be 1 filechains and 1 networkfilter by design
 	if listenerConfig.FilterChains != nil && len(listenerConfig.FilterChains) == 1 && listenerConfig.FilterChains[0].Filters != nil && len(listenerConfig.FilterChains[0].Filters) == 1 && listenerConfig.FilterChains[0].Filters[0].Config != nil {
@@ -85,15 +100,30 @@ func convertListenerConfig(xdsListener *xdsapi.Listener) *v2.ListenerConfig {
 		}
 	}
 
-	return listenerConfig
+	return listenerConfig, nil
 }
 
-func convertClustersConfig(xdsClusters []*xdsapi.Cluster) []*v2.Cluster {
+// convertClustersConfig converts the xds clusters, an error is returned if any cluster is invalid
+func convertClustersConfig(xdsClusters []*xdsapi.Cluster) ([]*v2.Cluster, error) {
 	if xdsClusters == nil {
-		return nil
+		return nil, nil
 	}
 	clusters := make([]*v2.Cluster, 0, len(xdsClusters))
 	for _, xdsCluster := range xdsClusters {
+		if xdsCluster.GetName() == "" {
+			return nil, errors.New("cluster name is required")
+		}
+
+		hosts, err := convertClusterHosts(xdsCluster.GetHosts())
+		if err != nil {
+			return nil, fmt.Errorf("invalid hosts of cluster %s: %v", xdsCluster.GetName(), err)
+		}
+
+		tls, err := convertTLS(xdsCluster.GetTlsContext())
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls context of cluster %s: %v", xdsCluster.GetName(), err)
+		}
+
 		cluster := &v2.Cluster{
 			Name:                 xdsCluster.GetName(),
 			ClusterType:          convertClusterType(xdsCluster.GetType()),
@@ -104,15 +134,24 @@ func convertClustersConfig(xdsClusters []*xdsapi.Cluster) []*v2.Cluster {
 			HealthCheck:          convertHealthChecks(xdsCluster.GetHealthChecks()),
 			CirBreThresholds:     convertCircuitBreakers(xdsCluster.GetCircuitBreakers()),
 			OutlierDetection:     convertOutlierDetection(xdsCluster.GetOutlierDetection()),
-			Hosts:                convertClusterHosts(xdsCluster.GetHosts()),
+			Hosts:                hosts,
 			Spec:                 convertSpec(xdsCluster),
-			TLS:                  convertTLS(xdsCluster.GetTlsContext()),
+			TLS:                  tls,
+			DNSRefreshRate:       convertDNSRefreshRate(xdsCluster.GetDnsRefreshRate()),
 		}
 
 		clusters = append(clusters, cluster)
 	}
 
-	return clusters
+	return clusters, nil
+}
+
+func convertLocality(xdsLocality *xdscore.Locality) v2.Locality {
+	return v2.Locality{
+		Region:  xdsLocality.GetRegion(),
+		Zone:    xdsLocality.GetZone(),
+		SubZone: xdsLocality.GetSubZone(),
+	}
 }
 
 func convertEndpointsConfig(xdsEndpoint *xdsendpoint.LocalityLbEndpoints) []v2.Host {
@@ -141,7 +180,9 @@ func convertEndpointsConfig(xdsEndpoint *xdsendpoint.LocalityLbEndpoints) []v2.H
 		host := v2.Host{
 			Address:  address,
 			Weight:   xdsHost.GetLoadBalancingWeight().GetValue(),
-			MetaData: convertMeta(xdsHost.Metadata),
+			MetaData: convertLBMetaFields(xdsHost.Metadata),
+			Priority: xdsEndpoint.GetPriority(),
+			Locality: convertLocality(xdsEndpoint.GetLocality()),
 		}
 		hosts = append(hosts, host)
 	}
@@ -149,21 +190,21 @@ func convertEndpointsConfig(xdsEndpoint *xdsendpoint.LocalityLbEndpoints) []v2.H
 }
 
 // todo: more filter type support
-func isSupport(xdsListener *xdsapi.Listener) bool {
+func checkSupport(xdsListener *xdsapi.Listener) error {
 	if xdsListener == nil {
-		return false
+		return errors.New("listener is nil")
 	}
 	if xdsListener.Name == "virtual" {
-		return true
+		return nil
 	}
 	for _, filterChain := range xdsListener.GetFilterChains() {
 		for _, filter := range filterChain.GetFilters() {
 			if value, ok := supportFilter[filter.GetName()]; !ok || !value {
-				return false
+				return fmt.Errorf("unsupported filter %s in listener %s", filter.GetName(), xdsListener.GetName())
 			}
 		}
 	}
-	return true
+	return nil
 }
 
 func convertBindToPort(xdsDeprecatedV1 *xdsapi.Listener_DeprecatedV1) bool {
@@ -233,76 +274,199 @@ func convertAccessLogs(xdsListener *xdsapi.Listener) []v2.AccessLog {
 	return accessLogs
 }
 
-func convertFilterChains(xdsFilterChains []xdslistener.FilterChain) []v2.FilterChain {
+func convertFilterChains(xdsFilterChains []xdslistener.FilterChain) ([]v2.FilterChain, error) {
 	if xdsFilterChains == nil {
-		return nil
+		return nil, nil
 	}
 	filterChains := make([]v2.FilterChain, 0, len(xdsFilterChains))
 	for _, xdsFilterChain := range xdsFilterChains {
+		tls, err := convertTLS(xdsFilterChain.GetTlsContext())
+		if err != nil {
+			return nil, err
+		}
+		filters, err := convertFilters(xdsFilterChain.GetFilters())
+		if err != nil {
+			return nil, err
+		}
 		filterChain := v2.FilterChain{
 			FilterChainMatch: xdsFilterChain.GetFilterChainMatch().String(),
-			TLS:              convertTLS(xdsFilterChain.GetTlsContext()),
-			Filters:          convertFilters(xdsFilterChain.GetFilters()),
+			TLS:              tls,
+			Filters:          filters,
 		}
 		filterChains = append(filterChains, filterChain)
 	}
-	return filterChains
+	return filterChains, nil
 }
 
-func convertFilters(xdsFilters []xdslistener.Filter) []v2.Filter {
+func convertFilters(xdsFilters []xdslistener.Filter) ([]v2.Filter, error) {
 	if xdsFilters == nil {
-		return nil
+		return nil, nil
 	}
 	filters := make([]v2.Filter, 0, len(xdsFilters))
 	for _, xdsFilter := range xdsFilters {
+		config, err := convertFilterConfig(xdsFilter.GetName(), xdsFilter.GetConfig())
+		if err != nil {
+			return nil, fmt.Errorf("invalid config of filter %s: %v", xdsFilter.GetName(), err)
+		}
 		filter := v2.Filter{
 			Name:   v2.DEFAULT_NETWORK_FILTER,
-			Config: convertFilterConfig(xdsFilter.GetName(), xdsFilter.GetConfig()),
+			Config: config,
 		}
 		filters = append(filters, filter)
 	}
-	return filters
+	return filters, nil
 }
 
-// TODO: more filter config support
-func convertFilterConfig(name string, s *types.Struct) map[string]interface{} {
+// prefix of the envoy built-in filter names
+const xdsEnvoyFilterPrefix = "envoy."
+
+// convertStreamFilters converts the http filters of the proxies to the stream filters of the listener,
+// the filter name is the stream filter type of mosn, such as local_rate_limit. The envoy built-in
+// http filters, such as envoy.router, are not stream filters of mosn and ignored
+func convertStreamFilters(xdsListener *xdsapi.Listener) ([]v2.Filter, error) {
+	var filters []v2.Filter
+	addFilter := func(name string, config *types.Struct) {
+		if strings.HasPrefix(name, xdsEnvoyFilterPrefix) {
+			return
+		}
+		filters = append(filters, v2.Filter{
+			Name:   name,
+			Config: convertStruct(config),
+		})
+	}
+
+	for _, xdsFilterChain := range xdsListener.GetFilterChains() {
+		for _, xdsFilter := range xdsFilterChain.GetFilters() {
+			switch xdsFilter.GetName() {
+			case xdsutil.HTTPConnectionManager, v2.RPC_PROXY:
+				filterConfig := &xdshttp.HttpConnectionManager{}
+				if err := xdsutil.StructToMessage(xdsFilter.GetConfig(), filterConfig); err != nil {
+					return nil, err
+				}
+				for _, httpFilter := range filterConfig.GetHttpFilters() {
+					addFilter(httpFilter.GetName(), httpFilter.GetConfig())
+				}
+			case v2.X_PROXY:
+				filterConfig := &xdsxproxy.XProxy{}
+				if err := xdsutil.StructToMessage(xdsFilter.GetConfig(), filterConfig); err != nil {
+					return nil, err
+				}
+				for _, streamFilter := range filterConfig.GetStreamFilters() {
+					addFilter(streamFilter.GetName(), streamFilter.GetConfig())
+				}
+			}
+		}
+	}
+
+	return filters, nil
+}
+
+// convertStruct converts the struct to the map the same as the one decoded from json
+func convertStruct(s *types.Struct) map[string]interface{} {
 	if s == nil {
 		return nil
 	}
+	fields := make(map[string]interface{}, len(s.GetFields()))
+	for key, value := range s.GetFields() {
+		fields[key] = convertStructValue(value)
+	}
+	return fields
+}
+
+func convertStructValue(value *types.Value) interface{} {
+	switch kind := value.GetKind().(type) {
+	case *types.Value_NumberValue:
+		return kind.NumberValue
+	case *types.Value_StringValue:
+		return kind.StringValue
+	case *types.Value_BoolValue:
+		return kind.BoolValue
+	case *types.Value_StructValue:
+		return convertStruct(kind.StructValue)
+	case *types.Value_ListValue:
+		values := make([]interface{}, 0, len(kind.ListValue.GetValues()))
+		for _, v := range kind.ListValue.GetValues() {
+			values = append(values, convertStructValue(v))
+		}
+		return values
+	}
+	return nil
+}
+
+// TODO: more filter config support
+func convertFilterConfig(name string, s *types.Struct) (map[string]interface{}, error) {
+	if s == nil {
+		return nil, nil
+	}
 	if name == xdsutil.HTTPConnectionManager {
 		filterConfig := &xdshttp.HttpConnectionManager{}
-		xdsutil.StructToMessage(s, filterConfig)
+		if err := xdsutil.StructToMessage(s, filterConfig); err != nil {
+			return nil, err
+		}
 		proxyConfig := v2.Proxy{
 			DownstreamProtocol:  string(protocol.HTTP2),
 			UpstreamProtocol:    string(protocol.HTTP2),
 			SupportDynamicRoute: true,
 			VirtualHosts:        convertVirtualHosts(filterConfig.GetRouteConfig()),
+			RouterConfigName:    filterConfig.GetRds().GetRouteConfigName(),
 		}
-		return structs.Map(proxyConfig)
+		return structs.Map(proxyConfig), nil
 	} else if name == v2.RPC_PROXY {
 		filterConfig := &xdshttp.HttpConnectionManager{}
-		xdsutil.StructToMessage(s, filterConfig)
+		if err := xdsutil.StructToMessage(s, filterConfig); err != nil {
+			return nil, err
+		}
 		proxyConfig := v2.Proxy{
 			DownstreamProtocol:  string(protocol.SofaRPC),
 			UpstreamProtocol:    string(protocol.SofaRPC),
 			SupportDynamicRoute: true,
 			VirtualHosts:        convertVirtualHosts(filterConfig.GetRouteConfig()),
+			RouterConfigName:    filterConfig.GetRds().GetRouteConfigName(),
 		}
-		return structs.Map(proxyConfig)
+		return structs.Map(proxyConfig), nil
 	} else if name == v2.X_PROXY {
 		filterConfig := &xdsxproxy.XProxy{}
-		xdsutil.StructToMessage(s, filterConfig)
+		if err := xdsutil.StructToMessage(s, filterConfig); err != nil {
+			return nil, err
+		}
 		proxyConfig := v2.Proxy{
 			DownstreamProtocol:  filterConfig.GetDownstreamProtocol().String(),
 			UpstreamProtocol:    filterConfig.GetUpstreamProtocol().String(),
 			SupportDynamicRoute: true,
 			VirtualHosts:        convertVirtualHosts(filterConfig.GetRouteConfig()),
+			SubProtocol:         filterConfig.GetXProtocol(),
+			RouterConfigName:    filterConfig.GetRds().GetRouteConfigName(),
+		}
+		return structs.Map(proxyConfig), nil
+	}
+
+	return nil, fmt.Errorf("unsupport filter config, filter name: %s", name)
+}
+
+// GetRouteConfigNames returns the names of the route configs to be discovered by RDS in the listeners
+func GetRouteConfigNames(xdsListeners []*xdsapi.Listener) []string {
+	routeNames := make([]string, 0)
+	for _, xdsListener := range xdsListeners {
+		for _, xdsFilterChain := range xdsListener.GetFilterChains() {
+			for _, xdsFilter := range xdsFilterChain.GetFilters() {
+				var routeName string
+				switch xdsFilter.GetName() {
+				case xdsutil.HTTPConnectionManager, v2.RPC_PROXY:
+					filterConfig := &xdshttp.HttpConnectionManager{}
+					xdsutil.StructToMessage(xdsFilter.GetConfig(), filterConfig)
+					routeName = filterConfig.GetRds().GetRouteConfigName()
+				case v2.X_PROXY:
+					filterConfig := &xdsxproxy.XProxy{}
+					xdsutil.StructToMessage(xdsFilter.GetConfig(), filterConfig)
+					routeName = filterConfig.GetRds().GetRouteConfigName()
+				}
+				if routeName != "" {
+					routeNames = append(routeNames, routeName)
+				}
+			}
 		}
-		return structs.Map(proxyConfig)
 	}
-
-	log.DefaultLogger.Errorf("unsupport filter config, filter name: %s", name)
-	return nil
+	return routeNames
 }
 
 func convertVirtualHosts(xdsRouteConfig *xdsapi.RouteConfiguration) []*v2.VirtualHost {
@@ -318,6 +482,7 @@ func convertVirtualHosts(xdsRouteConfig *xdsapi.RouteConfiguration) []*v2.Virtua
 			Routers:         convertRoutes(xdsVirtualHost.GetRoutes()),
 			RequireTLS:      xdsVirtualHost.GetRequireTls().String(),
 			VirtualClusters: convertVirtualClusters(xdsVirtualHost.GetVirtualClusters()),
+			Cors:            convertCorsPolicy(xdsVirtualHost.GetCors()),
 		}
 		virtualHosts = append(virtualHosts, virtualHost)
 	}
@@ -336,7 +501,7 @@ func convertRoutes(xdsRoutes []xdsroute.Route) []v2.Router {
 				Match:     convertRouteMatch(xdsRoute.GetMatch()),
 				Route:     convertRouteAction(xdsRouteAction),
 				Metadata:  convertMeta(xdsRoute.GetMetadata()),
-				Decorator: v2.Decorator(xdsRoute.GetDecorator().String()),
+				Decorator: v2.Decorator(xdsRoute.GetDecorator().GetOperation()),
 			}
 			routes = append(routes, route)
 		} else if xdsRouteAction := xdsRoute.GetRedirect(); xdsRouteAction != nil {
@@ -344,7 +509,7 @@ func convertRoutes(xdsRoutes []xdsroute.Route) []v2.Router {
 				Match:     convertRouteMatch(xdsRoute.GetMatch()),
 				Redirect:  convertRedirectAction(xdsRouteAction),
 				Metadata:  convertMeta(xdsRoute.GetMetadata()),
-				Decorator: v2.Decorator(xdsRoute.GetDecorator().String()),
+				Decorator: v2.Decorator(xdsRoute.GetDecorator().GetOperation()),
 			}
 			routes = append(routes, route)
 		} else {
@@ -403,6 +568,38 @@ func convertMeta(xdsMeta *xdscore.Metadata) v2.Metadata {
 	return meta
 }
 
+// xds metadata key of load balancing metadata
+const xdsLBMetadataKey = "envoy.lb"
+
+// convertLBMetaFields returns the string fields of xds load balancing metadata
+func convertLBMetaFields(xdsMeta *xdscore.Metadata) map[string]interface{} {
+	lbMeta, ok := xdsMeta.GetFilterMetadata()[xdsLBMetadataKey]
+	if !ok {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(lbMeta.GetFields()))
+	for key, value := range lbMeta.GetFields() {
+		if stringValue, ok := value.GetKind().(*types.Value_StringValue); ok {
+			fields[key] = stringValue.StringValue
+		}
+	}
+	return fields
+}
+
+// convertMetadataMatch converts xds load balancing metadata to the metadata match of route,
+// e.g. { "filter_metadata": {"envoy.lb": { "label": "gray"  } } }
+func convertMetadataMatch(xdsMeta *xdscore.Metadata) v2.Metadata {
+	fields := convertLBMetaFields(xdsMeta)
+	if len(fields) == 0 {
+		return nil
+	}
+	return v2.Metadata{
+		mosntypes.RouterMetadataKey: map[string]interface{}{
+			mosntypes.RouterMetadataKeyLb: fields,
+		},
+	}
+}
+
 func convertRouteAction(xdsRouteAction *xdsroute.RouteAction) v2.RouteAction {
 	if xdsRouteAction == nil {
 		return v2.RouteAction{}
@@ -411,9 +608,42 @@ func convertRouteAction(xdsRouteAction *xdsroute.RouteAction) v2.RouteAction {
 		ClusterName:      xdsRouteAction.GetCluster(),
 		ClusterHeader:    xdsRouteAction.GetClusterHeader(),
 		WeightedClusters: convertWeightedClusters(xdsRouteAction.GetWeightedClusters()),
-		MetadataMatch:    convertMeta(xdsRouteAction.GetMetadataMatch()),
+		MetadataMatch:    convertMetadataMatch(xdsRouteAction.GetMetadataMatch()),
 		Timeout:          convertTimeDurPoint2TimeDur(xdsRouteAction.GetTimeout()),
 		RetryPolicy:      convertRetryPolicy(xdsRouteAction.GetRetryPolicy()),
+		HashPolicy:       convertHashPolicy(xdsRouteAction.GetHashPolicy()),
+		ShadowPolicy:     convertShadowPolicy(xdsRouteAction.GetRequestMirrorPolicy()),
+		Cors:             convertCorsPolicy(xdsRouteAction.GetCors()),
+	}
+}
+
+func convertCorsPolicy(xdsCorsPolicy *xdsroute.CorsPolicy) *v2.CorsPolicy {
+	if xdsCorsPolicy == nil {
+		return nil
+	}
+	allowOrigins := make([]v2.OriginMatcher, 0, len(xdsCorsPolicy.GetAllowOrigin()))
+	for _, origin := range xdsCorsPolicy.GetAllowOrigin() {
+		allowOrigins = append(allowOrigins, v2.OriginMatcher{Exact: origin})
+	}
+	return &v2.CorsPolicy{
+		AllowOrigins:     allowOrigins,
+		AllowMethods:     xdsCorsPolicy.GetAllowMethods(),
+		AllowHeaders:     xdsCorsPolicy.GetAllowHeaders(),
+		ExposeHeaders:    xdsCorsPolicy.GetExposeHeaders(),
+		MaxAge:           xdsCorsPolicy.GetMaxAge(),
+		AllowCredentials: xdsCorsPolicy.GetAllowCredentials().GetValue(),
+		// enabled by default
+		Disabled: xdsCorsPolicy.GetEnabled() != nil && !xdsCorsPolicy.GetEnabled().GetValue(),
+	}
+}
+
+func convertShadowPolicy(xdsMirrorPolicy *xdsroute.RouteAction_RequestMirrorPolicy) *v2.ShadowPolicy {
+	if xdsMirrorPolicy == nil || xdsMirrorPolicy.GetCluster() == "" {
+		return nil
+	}
+	return &v2.ShadowPolicy{
+		Cluster:    xdsMirrorPolicy.GetCluster(),
+		RuntimeKey: xdsMirrorPolicy.GetRuntimeKey(),
 	}
 }
 
@@ -446,7 +676,7 @@ func convertWeightedCluster(xdsWeightedCluster *xdsroute.WeightedCluster_Cluster
 	return v2.ClusterWeight{
 		Name:          xdsWeightedCluster.GetName(),
 		Weight:        xdsWeightedCluster.GetWeight().GetValue(),
-		MetadataMatch: convertMeta(xdsWeightedCluster.GetMetadataMatch()),
+		MetadataMatch: convertMetadataMatch(xdsWeightedCluster.GetMetadataMatch()),
 	}
 }
 
@@ -454,13 +684,50 @@ func convertRetryPolicy(xdsRetryPolicy *xdsroute.RouteAction_RetryPolicy) *v2.Re
 	if xdsRetryPolicy == nil {
 		return &v2.RetryPolicy{}
 	}
+	var conditions []v2.RetryCondition
+	for _, on := range strings.Split(xdsRetryPolicy.GetRetryOn(), ",") {
+		if on = strings.TrimSpace(on); on != "" {
+			conditions = append(conditions, v2.RetryCondition(on))
+		}
+	}
 	return &v2.RetryPolicy{
-		RetryOn:      len(xdsRetryPolicy.GetRetryOn()) > 0,
-		RetryTimeout: convertTimeDurPoint2TimeDur(xdsRetryPolicy.GetPerTryTimeout()),
-		NumRetries:   xdsRetryPolicy.GetNumRetries().GetValue(),
+		RetryOn:         len(conditions) > 0,
+		RetryTimeout:    convertTimeDurPoint2TimeDur(xdsRetryPolicy.GetPerTryTimeout()),
+		NumRetries:      xdsRetryPolicy.GetNumRetries().GetValue(),
+		RetryConditions: conditions,
 	}
 }
 
+func convertHashPolicy(xdsHashPolicy []*xdsroute.RouteAction_HashPolicy) []v2.HashPolicy {
+	if len(xdsHashPolicy) == 0 {
+		return nil
+	}
+	hashPolicy := make([]v2.HashPolicy, 0, len(xdsHashPolicy))
+	for _, policy := range xdsHashPolicy {
+		if header := policy.GetHeader(); header != nil {
+			hashPolicy = append(hashPolicy, v2.HashPolicy{
+				Header: &v2.HeaderHashPolicy{
+					Name: header.GetHeaderName(),
+				},
+			})
+		} else if cookie := policy.GetCookie(); cookie != nil {
+			hashPolicy = append(hashPolicy, v2.HashPolicy{
+				Cookie: &v2.CookieHashPolicy{
+					Name: cookie.GetName(),
+					TTL:  convertTimeDurPoint2TimeDur(cookie.GetTtl()),
+				},
+			})
+		} else if connectionProperties := policy.GetConnectionProperties(); connectionProperties != nil {
+			hashPolicy = append(hashPolicy, v2.HashPolicy{
+				ConnectionProperties: &v2.ConnectionPropertiesHashPolicy{
+					SourceIP: connectionProperties.GetSourceIp(),
+				},
+			})
+		}
+	}
+	return hashPolicy
+}
+
 func convertRedirectAction(xdsRedirectAction *xdsroute.RedirectAction) v2.RedirectAction {
 	if xdsRedirectAction == nil {
 		return v2.RedirectAction{}
@@ -518,7 +785,9 @@ func convertClusterType(xdsClusterType xdsapi.Cluster_DiscoveryType) v2.ClusterT
 	case xdsapi.Cluster_STATIC:
 		return v2.STATIC_CLUSTER
 	case xdsapi.Cluster_STRICT_DNS:
+		return v2.STRICT_DNS_CLUSTER
 	case xdsapi.Cluster_LOGICAL_DNS:
+		return v2.LOGICAL_DNS_CLUSTER
 	case xdsapi.Cluster_EDS:
 		return v2.DYNAMIC_CLUSTER
 	case xdsapi.Cluster_ORIGINAL_DST:
@@ -532,11 +801,14 @@ func convertLbPolicy(xdsLbPolicy xdsapi.Cluster_LbPolicy) v2.LbType {
 	case xdsapi.Cluster_ROUND_ROBIN:
 		return v2.LB_ROUNDROBIN
 	case xdsapi.Cluster_LEAST_REQUEST:
+		return v2.LB_LEASTREQUEST
 	case xdsapi.Cluster_RING_HASH:
+		return v2.LB_RINGHASH
 	case xdsapi.Cluster_RANDOM:
 		return v2.LB_RANDOM
 	case xdsapi.Cluster_ORIGINAL_DST_LB:
 	case xdsapi.Cluster_MAGLEV:
+		return v2.LB_MAGLEV
 	}
 	//log.DefaultLogger.Fatalf("unsupported lb policy: %s, exchange to LB_RANDOM", xdsLbPolicy.String())
 	return v2.LB_RANDOM
@@ -618,8 +890,8 @@ func convertOutlierDetection(xdsOutlierDetection *xdscluster.OutlierDetection) v
 		Interval:                           convertDuration(xdsOutlierDetection.GetInterval()),
 		BaseEjectionTime:                   convertDuration(xdsOutlierDetection.GetBaseEjectionTime()),
 		MaxEjectionPercent:                 xdsOutlierDetection.GetMaxEjectionPercent().GetValue(),
-		ConsecutiveGatewayFailure:          xdsOutlierDetection.GetEnforcingConsecutive_5Xx().GetValue(),
-		EnforcingConsecutive5xx:            xdsOutlierDetection.GetConsecutive_5Xx().GetValue(),
+		ConsecutiveGatewayFailure:          xdsOutlierDetection.GetConsecutiveGatewayFailure().GetValue(),
+		EnforcingConsecutive5xx:            xdsOutlierDetection.GetEnforcingConsecutive_5Xx().GetValue(),
 		EnforcingConsecutiveGatewayFailure: xdsOutlierDetection.GetEnforcingConsecutiveGatewayFailure().GetValue(),
 		EnforcingSuccessRate:               xdsOutlierDetection.GetEnforcingSuccessRate().GetValue(),
 		SuccessRateMinimumHosts:            xdsOutlierDetection.GetSuccessRateMinimumHosts().GetValue(),
@@ -642,18 +914,35 @@ func convertSpec(xdsCluster *xdsapi.Cluster) v2.ClusterSpecInfo {
 	}
 }
 
-func convertClusterHosts(xdsHosts []*xdscore.Address) []v2.Host {
+// convertClusterHosts keeps the hostnames of the hosts, they are resolved by the cluster.
+// Only socket addresses with port value are supported
+func convertClusterHosts(xdsHosts []*xdscore.Address) ([]v2.Host, error) {
 	if xdsHosts == nil {
-		return nil
+		return nil, nil
 	}
 	hostsWithMetaData := make([]v2.Host, 0, len(xdsHosts))
 	for _, xdsHost := range xdsHosts {
+		addr, ok := xdsHost.GetAddress().(*xdscore.Address_SocketAddress)
+		if !ok {
+			return nil, errors.New("only SocketAddress supported")
+		}
+		port, ok := addr.SocketAddress.GetPortSpecifier().(*xdscore.SocketAddress_PortValue)
+		if !ok {
+			return nil, errors.New("only port value supported")
+		}
 		hostWithMetaData := v2.Host{
-			Address: convertAddress(xdsHost).String(),
+			Address: net.JoinHostPort(addr.SocketAddress.GetAddress(), fmt.Sprintf("%d", port.PortValue)),
 		}
 		hostsWithMetaData = append(hostsWithMetaData, hostWithMetaData)
 	}
-	return hostsWithMetaData
+	return hostsWithMetaData, nil
+}
+
+func convertDNSRefreshRate(refreshRate *time.Duration) time.Duration {
+	if refreshRate == nil {
+		return 0
+	}
+	return *refreshRate
 }
 
 func convertDuration(p *types.Duration) time.Duration {
@@ -669,13 +958,13 @@ func convertDuration(p *types.Duration) time.Duration {
 	return d
 }
 
-func convertTLS(xdsTLSContext interface{}) v2.TLSConfig {
+func convertTLS(xdsTLSContext interface{}) (v2.TLSConfig, error) {
 	var config v2.TLSConfig
 	var isDownstream bool
 	var common *xdsauth.CommonTlsContext
 
 	if xdsTLSContext == nil {
-		return config
+		return config, nil
 	}
 	if context, ok := xdsTLSContext.(*xdsauth.DownstreamTlsContext); ok {
 		if context.GetRequireClientCertificate() != nil {
@@ -689,7 +978,7 @@ func convertTLS(xdsTLSContext interface{}) v2.TLSConfig {
 		isDownstream = false
 	}
 	if common == nil {
-		return config
+		return config, nil
 	}
 	// Currently only a single certificate is supported
 	if common.GetTlsCertificates() != nil {
@@ -720,12 +1009,10 @@ func convertTLS(xdsTLSContext interface{}) v2.TLSConfig {
 	}
 
 	if isDownstream && (config.CertChain == "" || config.PrivateKey == "") {
-		log.DefaultLogger.Fatalf("tls_certificates are required in downstream tls_context")
-		config.Status = false
-		return config
+		return config, errors.New("tls_certificates are required in downstream tls_context")
 	}
 
 	config.Status = true
 	config.Inspector = true
-	return config
+	return config, nil
 }
@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	xdscore "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	xdslistener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
+	xdsroute "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	xdshttp "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
+	xdsutil "github.com/envoyproxy/go-control-plane/pkg/util"
+	"github.com/gogo/protobuf/types"
+)
+
+func Test_convertWeightedClusters(t *testing.T) {
+	xdsWeightedClusters := &xdsroute.WeightedCluster{
+		Clusters: []*xdsroute.WeightedCluster_ClusterWeight{
+			{
+				Name:   "v1",
+				Weight: &types.UInt32Value{Value: 90},
+			},
+			{
+				Name:   "v2",
+				Weight: &types.UInt32Value{Value: 10},
+				MetadataMatch: &xdscore.Metadata{
+					FilterMetadata: map[string]*types.Struct{
+						"envoy.lb": {
+							Fields: map[string]*types.Value{
+								"version": {Kind: &types.Value_StringValue{StringValue: "v2"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	want := []v2.WeightedCluster{
+		{Clusters: v2.ClusterWeight{Name: "v1", Weight: 90}},
+		{Clusters: v2.ClusterWeight{
+			Name:   "v2",
+			Weight: 10,
+			MetadataMatch: v2.Metadata{
+				"filter_metadata": map[string]interface{}{
+					"mosn.lb": map[string]interface{}{"version": "v2"},
+				},
+			},
+		}},
+	}
+
+	if got := convertWeightedClusters(xdsWeightedClusters); !reflect.DeepEqual(got, want) {
+		t.Errorf("convertWeightedClusters() = %v, want %v", got, want)
+	}
+}
+
+func Test_convertCorsPolicy(t *testing.T) {
+	xdsCorsPolicy := &xdsroute.CorsPolicy{
+		AllowOrigin:      []string{"*"},
+		AllowMethods:     "GET",
+		MaxAge:           "600",
+		AllowCredentials: &types.BoolValue{Value: true},
+		Enabled:          &types.BoolValue{Value: false},
+	}
+
+	want := &v2.CorsPolicy{
+		AllowOrigins:     []v2.OriginMatcher{{Exact: "*"}},
+		AllowMethods:     "GET",
+		MaxAge:           "600",
+		AllowCredentials: true,
+		Disabled:         true,
+	}
+
+	if got := convertCorsPolicy(xdsCorsPolicy); !reflect.DeepEqual(got, want) {
+		t.Errorf("convertCorsPolicy() = %v, want %v", got, want)
+	}
+
+	if got := convertCorsPolicy(nil); got != nil {
+		t.Errorf("convertCorsPolicy(nil) = %v, want nil", got)
+	}
+}
+
+func Test_convertStreamFilters(t *testing.T) {
+	rateLimitConfig := &types.Struct{
+		Fields: map[string]*types.Value{
+			"name": {Kind: &types.Value_StringValue{StringValue: "local"}},
+			"descriptors": {Kind: &types.Value_ListValue{ListValue: &types.ListValue{
+				Values: []*types.Value{{Kind: &types.Value_StructValue{StructValue: &types.Struct{
+					Fields: map[string]*types.Value{
+						"max_tokens": {Kind: &types.Value_NumberValue{NumberValue: 100}},
+						"disabled":   {Kind: &types.Value_BoolValue{BoolValue: false}},
+					},
+				}}}},
+			}}},
+		},
+	}
+
+	managerConfig, err := xdsutil.MessageToStruct(&xdshttp.HttpConnectionManager{
+		HttpFilters: []*xdshttp.HttpFilter{
+			{Name: "local_rate_limit", Config: rateLimitConfig},
+			{Name: xdsutil.Router},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xdsListener := &xdsapi.Listener{
+		Name: "test",
+		FilterChains: []xdslistener.FilterChain{{
+			Filters: []xdslistener.Filter{{
+				Name:   xdsutil.HTTPConnectionManager,
+				Config: managerConfig,
+			}},
+		}},
+	}
+
+	want := []v2.Filter{{
+		Name: "local_rate_limit",
+		Config: map[string]interface{}{
+			"name": "local",
+			"descriptors": []interface{}{
+				map[string]interface{}{"max_tokens": float64(100), "disabled": false},
+			},
+		},
+	}}
+
+	if got, err := convertStreamFilters(xdsListener); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("convertStreamFilters() = %v, %v, want %v", got, err, want)
+	}
+}
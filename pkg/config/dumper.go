@@ -35,8 +35,7 @@ func Dump(dirty bool) {
 		//log.DefaultLogger.Println("dump config to: ", ConfigPath)
 		log.DefaultLogger.Debugf("dump config content: %+v", config)
 
-		//todo: ignore zero values in config struct @boqin
-		content, err := json.MarshalIndent(config, "", "  ")
+		content, err := dumpJSON()
 		if err == nil {
 			err = ioutil.WriteFile(ConfigPath, content, 0644)
 		}
@@ -48,3 +47,16 @@ func Dump(dirty bool) {
 		log.DefaultLogger.Infof("config is clean no needed to dump")
 	}
 }
+
+// DumpJSON returns the effective config in json, the same as the dumped config file
+func DumpJSON() ([]byte, error) {
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+
+	return dumpJSON()
+}
+
+func dumpJSON() ([]byte, error) {
+	//todo: ignore zero values in config struct @boqin
+	return json.MarshalIndent(config, "", "  ")
+}
@@ -19,6 +19,8 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -36,6 +38,7 @@ var ProtocolsSupported = map[string]bool{
 	string(protocol.HTTP2):     true,
 	string(protocol.HTTP1):     true,
 	string(protocol.Xprotocol): true,
+	string(protocol.Dubbo):     true,
 }
 
 // callback when corresponding module parsed
@@ -70,13 +73,19 @@ var (
 	}
 
 	clusterTypeMap = map[string]v2.ClusterType{
-		"SIMPLE":  v2.SIMPLE_CLUSTER,
-		"DYNAMIC": v2.DYNAMIC_CLUSTER,
+		"SIMPLE":      v2.SIMPLE_CLUSTER,
+		"DYNAMIC":     v2.DYNAMIC_CLUSTER,
+		"STRICT_DNS":  v2.STRICT_DNS_CLUSTER,
+		"LOGICAL_DNS": v2.LOGICAL_DNS_CLUSTER,
 	}
 
 	lbTypeMap = map[string]v2.LbType{
-		"LB_RANDOM":     v2.LB_RANDOM,
-		"LB_ROUNDROBIN": v2.LB_ROUNDROBIN,
+		"LB_RANDOM":             v2.LB_RANDOM,
+		"LB_ROUNDROBIN":         v2.LB_ROUNDROBIN,
+		"LB_WEIGHTEDROUNDROBIN": v2.LB_WEIGHTEDROUNDROBIN,
+		"LB_LEASTREQUEST":       v2.LB_LEASTREQUEST,
+		"LB_RINGHASH":           v2.LB_RINGHASH,
+		"LB_MAGLEV":             v2.LB_MAGLEV,
 	}
 )
 
@@ -92,6 +101,23 @@ func ParseLogLevel(level string) log.Level {
 	return log.INFO
 }
 
+// LookupLogLevel returns the log level by name, ok is false for unsupported log level
+func LookupLogLevel(level string) (logLevel log.Level, ok bool) {
+	logLevel, ok = logLevelMap[level]
+	return
+}
+
+// LogLevelName returns the name of log level
+func LogLevelName(level log.Level) string {
+	for name, logLevel := range logLevelMap {
+		if logLevel == level {
+			return name
+		}
+	}
+
+	return ""
+}
+
 func ParseServerConfig(c *ServerConfig) *server.Config {
 	sc := &server.Config{
 		LogPath:         c.DefaultLogPath,
@@ -103,29 +129,35 @@ func ParseServerConfig(c *ServerConfig) *server.Config {
 	return sc
 }
 
-func ParseProxyFilterJSON(c *v2.Filter) *v2.Proxy {
+// ParseProxyFilterJSON parses the proxy network filter config, an error is returned if the config is invalid
+func ParseProxyFilterJSON(c *v2.Filter) (*v2.Proxy, error) {
 
 	proxyConfig := &v2.Proxy{}
 
 	if data, err := json.Marshal(c.Config); err == nil {
-		json.Unmarshal(data, &proxyConfig)
+		if err := json.Unmarshal(data, &proxyConfig); err != nil {
+			return nil, fmt.Errorf("Parsing Proxy Network Fitler Error: %v", err)
+		}
 	} else {
-		log.StartLogger.Fatal("Parsing Proxy Network Fitler Error")
+		return nil, fmt.Errorf("Parsing Proxy Network Fitler Error: %v", err)
 	}
 
 	if proxyConfig.DownstreamProtocol == "" || proxyConfig.UpstreamProtocol == "" {
-		log.StartLogger.Fatal("Protocol in String Needed in Proxy Network Fitler")
+		return nil, errors.New("Protocol in String Needed in Proxy Network Fitler")
 	} else if _, ok := ProtocolsSupported[proxyConfig.DownstreamProtocol]; !ok {
-		log.StartLogger.Fatal("Invalid Downstream Protocol = ", proxyConfig.DownstreamProtocol)
+		return nil, fmt.Errorf("Invalid Downstream Protocol = %s", proxyConfig.DownstreamProtocol)
 	} else if _, ok := ProtocolsSupported[proxyConfig.UpstreamProtocol]; !ok {
-		log.StartLogger.Fatal("Invalid Upstream Protocol = ", proxyConfig.UpstreamProtocol)
+		return nil, fmt.Errorf("Invalid Upstream Protocol = %s", proxyConfig.UpstreamProtocol)
 	}
 
 	if !proxyConfig.SupportDynamicRoute {
 		log.StartLogger.Warnf("Mesh Doesn't Support Dynamic Router")
 	}
 
-	if len(proxyConfig.VirtualHosts) == 0 {
+	if proxyConfig.RouterConfigName != "" {
+		log.StartLogger.Debugf("VirtualHosts Discovered by RDS, Route Config Name = %s", proxyConfig.RouterConfigName)
+
+	} else if len(proxyConfig.VirtualHosts) == 0 {
 		log.StartLogger.Warnf("No VirtualHosts Founded")
 
 	} else {
@@ -140,7 +172,7 @@ func ParseProxyFilterJSON(c *v2.Filter) *v2.Proxy {
 
 	proxyConfig.BasicRoutes = ParseBasicFilter(proxyConfig)
 
-	return proxyConfig
+	return proxyConfig, nil
 }
 
 func GetServiceFromHeader(router *v2.Router) *v2.BasicServiceRoute {
@@ -416,6 +448,110 @@ func ParseHealthcheckFilter(config map[string]interface{}) *v2.HealthCheckFilter
 	return healthcheck
 }
 
+// ParseLocalRateLimitFilter parses the local rate limit filter config, an error is returned
+// if the config is invalid, so that the config from LDS could be rejected instead of exiting
+func ParseLocalRateLimitFilter(config map[string]interface{}) (*v2.LocalRateLimit, error) {
+	rateLimitConfig := &LocalRateLimitConfig{}
+
+	if data, err := json.Marshal(config); err == nil {
+		if err := json.Unmarshal(data, rateLimitConfig); err != nil {
+			return nil, fmt.Errorf("parsing local rate limit filter config error: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("parsing local rate limit filter config error: %v", err)
+	}
+
+	if len(rateLimitConfig.Descriptors) == 0 {
+		return nil, errors.New("[descriptors] is required in local rate limit filter config")
+	}
+
+	rateLimit := &v2.LocalRateLimit{
+		Name:  rateLimitConfig.Name,
+		Stage: rateLimitConfig.Stage,
+	}
+
+	for _, dc := range rateLimitConfig.Descriptors {
+		if len(dc.Entries) == 0 {
+			return nil, errors.New("[entries] is required in local rate limit descriptor")
+		}
+
+		if dc.MaxTokens == 0 {
+			return nil, errors.New("[max_tokens] in local rate limit descriptor should be greater than 0")
+		}
+
+		if dc.FillInterval.Duration <= 0 {
+			return nil, errors.New("[fill_interval] in local rate limit descriptor should be greater than 0")
+		}
+
+		descriptor := v2.LocalRateLimitDescriptor{
+			MaxTokens:     dc.MaxTokens,
+			TokensPerFill: dc.TokensPerFill,
+			FillInterval:  dc.FillInterval.Duration,
+		}
+
+		// fill one token per interval by default
+		if descriptor.TokensPerFill == 0 {
+			descriptor.TokensPerFill = 1
+		}
+
+		for _, entry := range dc.Entries {
+			if entry.Key == "" {
+				return nil, errors.New("[key] is required in local rate limit descriptor entry")
+			}
+
+			descriptor.Entries = append(descriptor.Entries, v2.RateLimitDescriptorEntry{
+				Key:   entry.Key,
+				Value: entry.Value,
+			})
+		}
+
+		rateLimit.Descriptors = append(rateLimit.Descriptors, descriptor)
+	}
+
+	return rateLimit, nil
+}
+
+const defaultRateLimitServiceTimeout = 20 * time.Millisecond
+
+// ParseGlobalRateLimitFilter parses the rate limit filter config, an error is returned if the config is invalid
+func ParseGlobalRateLimitFilter(config map[string]interface{}) (*v2.GlobalRateLimit, error) {
+	rateLimitConfig := &GlobalRateLimitConfig{}
+
+	if data, err := json.Marshal(config); err == nil {
+		if err := json.Unmarshal(data, rateLimitConfig); err != nil {
+			return nil, fmt.Errorf("parsing rate limit filter config error: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("parsing rate limit filter config error: %v", err)
+	}
+
+	if rateLimitConfig.Domain == "" {
+		return nil, errors.New("[domain] is required in rate limit filter config")
+	}
+
+	if rateLimitConfig.ServiceAddress == "" {
+		return nil, errors.New("[service_address] is required in rate limit filter config")
+	}
+
+	rateLimit := &v2.GlobalRateLimit{
+		Domain:           rateLimitConfig.Domain,
+		Stage:            rateLimitConfig.Stage,
+		ServiceAddress:   rateLimitConfig.ServiceAddress,
+		Timeout:          rateLimitConfig.Timeout.Duration,
+		FailureModeAllow: true,
+	}
+
+	if rateLimit.Timeout <= 0 {
+		rateLimit.Timeout = defaultRateLimitServiceTimeout
+	}
+
+	if rateLimitConfig.FailureModeAllow != nil {
+		rateLimit.FailureModeAllow = *rateLimitConfig.FailureModeAllow
+	}
+
+	return rateLimit, nil
+}
+
 func ParseListenerConfig(c *ListenerConfig, inheritListeners []*v2.ListenerConfig) *v2.ListenerConfig {
 	if c.Name == "" {
 		log.StartLogger.Fatalln("[name] is required in listener config")
@@ -522,11 +658,13 @@ func ParseClusterConfig(clusters []ClusterConfig) ([]v2.Cluster, map[string][]v2
 			ConnBufferLimitBytes: c.ConnBufferLimitBytes,
 
 			HealthCheck:      ParseClusterHealthCheckConf(&c.HealthCheck),
+			OutlierDetection: ParseClusterOutlierDetectionConf(&c.OutlierDetection),
 			CirBreThresholds: ParseCircuitBreakers(c.CircuitBreakers),
 
 			Spec:           ParseConfigSpecConfig(&clusterSpec),
 			LBSubSetConfig: c.LBSubsetConfig,
 			TLS:            ParseTLSConfig(&c.TLS),
+			DNSRefreshRate: c.DNSRefreshRate.Duration,
 		}
 
 		clustersV2 = append(clustersV2, clusterV2)
@@ -568,6 +706,26 @@ func ParseClusterHealthCheckConf(c *ClusterHealthCheckConfig) v2.HealthCheck {
 	return healthcheckInstance
 }
 
+func ParseClusterOutlierDetectionConf(c *ClusterOutlierDetectionConfig) v2.OutlierDetection {
+	if c.MaxEjectionPercent > 100 {
+		log.StartLogger.Fatalln("[outlier_detection.max_ejection_percent] should not be greater than 100")
+	}
+
+	return v2.OutlierDetection{
+		Consecutive5xx:                     c.Consecutive5xx,
+		Interval:                           c.Interval.Duration,
+		BaseEjectionTime:                   c.BaseEjectionTime.Duration,
+		MaxEjectionPercent:                 c.MaxEjectionPercent,
+		ConsecutiveGatewayFailure:          c.ConsecutiveGatewayFailure,
+		EnforcingConsecutive5xx:            c.EnforcingConsecutive5xx,
+		EnforcingConsecutiveGatewayFailure: c.EnforcingConsecutiveGatewayFailure,
+		EnforcingSuccessRate:               c.EnforcingSuccessRate,
+		SuccessRateMinimumHosts:            c.SuccessRateMinimumHosts,
+		SuccessRateRequestVolume:           c.SuccessRateRequestVolume,
+		SuccessRateStdevFactor:             c.SuccessRateStdevFactor,
+	}
+}
+
 func ParseCircuitBreakers(cbcs []*CircuitBreakerdConfig) v2.CircuitBreakers {
 	var cb v2.CircuitBreakers
 	var rp v2.RoutingPriority
@@ -580,7 +738,7 @@ func ParseCircuitBreakers(cbcs []*CircuitBreakerdConfig) v2.CircuitBreakers {
 		}
 
 		if 0 == cbc.MaxConnections || 0 == cbc.MaxPendingRequests ||
-			0 == cbc.MaxRequests || 0 == cbc.MaxRetries {
+			0 == cbc.MaxRequests || (0 == cbc.MaxRetries && cbc.RetryBudget == nil) {
 			log.StartLogger.Warnf("zero is set in circuitBreakers' config")
 		}
 
@@ -592,6 +750,13 @@ func ParseCircuitBreakers(cbcs []*CircuitBreakerdConfig) v2.CircuitBreakers {
 			MaxRetries:         cbc.MaxRetries,
 		}
 
+		if cbc.RetryBudget != nil {
+			threshold.RetryBudget = &v2.RetryBudget{
+				BudgetPercent:       cbc.RetryBudget.BudgetPercent,
+				MinRetryConcurrency: cbc.RetryBudget.MinRetryConcurrency,
+			}
+		}
+
 		cb.Thresholds = append(cb.Thresholds, threshold)
 	}
 
@@ -642,6 +807,7 @@ func ParseServiceRegistry(src ServiceRegistryConfig) {
 		AntShareCloud: src.ServiceAppInfo.AntShareCloud,
 		DataCenter:    src.ServiceAppInfo.DataCenter,
 		AppName:       src.ServiceAppInfo.AppName,
+		Zone:          src.ServiceAppInfo.Zone,
 	}
 
 	var SrvPubInfoArray []v2.PublishInfo
@@ -79,3 +79,98 @@ func TestParseClusterHealthCheckConf(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLocalRateLimitFilter(t *testing.T) {
+	filterConfigStr := `{
+		"name": "local",
+		"stage": 1,
+		"descriptors": [
+			{
+				"entries": [
+					{"key": "destination_cluster", "value": "foo"},
+					{"key": "remote_address"}
+				],
+				"max_tokens": 100,
+				"fill_interval": "1s"
+			}
+		]
+	}`
+
+	var conf map[string]interface{}
+	if err := json.Unmarshal([]byte(filterConfigStr), &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &v2.LocalRateLimit{
+		Name:  "local",
+		Stage: 1,
+		Descriptors: []v2.LocalRateLimitDescriptor{
+			{
+				Entries: []v2.RateLimitDescriptorEntry{
+					{Key: "destination_cluster", Value: "foo"},
+					{Key: "remote_address"},
+				},
+				MaxTokens:     100,
+				TokensPerFill: 1,
+				FillInterval:  time.Second,
+			},
+		},
+	}
+
+	if got, err := ParseLocalRateLimitFilter(conf); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLocalRateLimitFilter() = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestParseLocalRateLimitFilterInvalid(t *testing.T) {
+	for _, filterConfigStr := range []string{
+		`{"name": "local"}`,
+		`{"descriptors": [{"max_tokens": 100, "fill_interval": "1s"}]}`,
+		`{"descriptors": [{"entries": [{"key": "remote_address"}], "fill_interval": "1s"}]}`,
+		`{"descriptors": [{"entries": [{"key": "remote_address"}], "max_tokens": 100}]}`,
+		`{"descriptors": [{"entries": [{"value": "foo"}], "max_tokens": 100, "fill_interval": "1s"}]}`,
+		`{"descriptors": "foo"}`,
+	} {
+		var conf map[string]interface{}
+		if err := json.Unmarshal([]byte(filterConfigStr), &conf); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := ParseLocalRateLimitFilter(conf); err == nil {
+			t.Errorf("ParseLocalRateLimitFilter(%s) = %+v, want error", filterConfigStr, got)
+		}
+	}
+}
+
+func TestParseGlobalRateLimitFilter(t *testing.T) {
+	conf := map[string]interface{}{
+		"domain":          "mosn",
+		"service_address": "127.0.0.1:8081",
+	}
+
+	want := &v2.GlobalRateLimit{
+		Domain:           "mosn",
+		ServiceAddress:   "127.0.0.1:8081",
+		Timeout:          defaultRateLimitServiceTimeout,
+		FailureModeAllow: true,
+	}
+	if got, err := ParseGlobalRateLimitFilter(conf); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseGlobalRateLimitFilter() = %+v, %v, want %+v", got, err, want)
+	}
+
+	conf["stage"] = 1
+	conf["timeout"] = "100ms"
+	conf["failure_mode_allow"] = false
+
+	want.Stage = 1
+	want.Timeout = 100 * time.Millisecond
+	want.FailureModeAllow = false
+	if got, err := ParseGlobalRateLimitFilter(conf); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseGlobalRateLimitFilter() = %+v, %v, want %+v", got, err, want)
+	}
+
+	delete(conf, "service_address")
+	if got, err := ParseGlobalRateLimitFilter(conf); err == nil {
+		t.Errorf("ParseGlobalRateLimitFilter() = %+v, want error without service address", got)
+	}
+}
@@ -18,8 +18,12 @@
 package filter
 
 import (
+	"fmt"
+
+	"github.com/alipay/sofa-mosn/pkg/filter/stream/cors"
 	"github.com/alipay/sofa-mosn/pkg/filter/stream/faultinject"
 	"github.com/alipay/sofa-mosn/pkg/filter/stream/healthcheck/sofarpc"
+	"github.com/alipay/sofa-mosn/pkg/filter/stream/ratelimit"
 	"github.com/alipay/sofa-mosn/pkg/log"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
@@ -31,6 +35,9 @@ func init() {
 	//reg
 	Register("fault_inject", faultinject.CreateFaultInjectFilterFactory)
 	Register("healthcheck", sofarpc.CreateHealthCheckFilterFactory)
+	Register("local_rate_limit", ratelimit.CreateLocalRateLimitFilterFactory)
+	Register("rate_limit", ratelimit.CreateRateLimitFilterFactory)
+	Register("cors", cors.CreateCorsFilterFactory)
 }
 
 func Register(filterType string, creator StreamFilterFactoryCreator) {
@@ -38,17 +45,21 @@ func Register(filterType string, creator StreamFilterFactoryCreator) {
 }
 
 func CreateStreamFilterChainFactory(filterType string, config map[string]interface{}) types.StreamFilterChainFactory {
+	sfcf, err := NewStreamFilterChainFactory(filterType, config)
+	if err != nil {
+		log.StartLogger.Fatalln("create stream filter chain factory failed: ", err)
+	}
 
-	if cf, ok := creatorFactory[filterType]; ok {
-		sfcf, err := cf(config)
-
-		if err != nil {
-			log.StartLogger.Fatalln("create stream filter chain factory failed: ", err)
-		}
+	return sfcf
+}
 
-		return sfcf
+// NewStreamFilterChainFactory is CreateStreamFilterChainFactory returning the error
+// instead of exiting, it is used to create the stream filters of listeners updated at runtime
+func NewStreamFilterChainFactory(filterType string, config map[string]interface{}) (types.StreamFilterChainFactory, error) {
+	cf, ok := creatorFactory[filterType]
+	if !ok {
+		return nil, fmt.Errorf("unsupport stream filter type: %s", filterType)
 	}
 
-	log.StartLogger.Fatalln("unsupport stream filter type: ", filterType)
-	return nil
+	return cf(config)
 }
@@ -0,0 +1,190 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cors
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// http header names are lower case in headers map
+const (
+	headerOrigin                     = "origin"
+	headerAccessControlRequestMethod = "access-control-request-method"
+	headerAccessControlAllowOrigin   = "access-control-allow-origin"
+	headerAccessControlAllowCreds    = "access-control-allow-credentials"
+	headerAccessControlAllowMethods  = "access-control-allow-methods"
+	headerAccessControlAllowHeaders  = "access-control-allow-headers"
+	headerAccessControlExposeHeaders = "access-control-expose-headers"
+	headerAccessControlMaxAge        = "access-control-max-age"
+)
+
+// corsFilter answers preflight requests by the route's cors policy,
+// and sets cors headers in the responses of allowed cross origin requests
+// types.StreamReceiverFilter
+// types.StreamSenderFilter
+type corsFilter struct {
+	context context.Context
+
+	// request properties
+	policy    types.CorsPolicy
+	origin    string
+	preflight bool
+
+	// callbacks
+	decoderCb types.StreamReceiverFilterCallbacks
+	encoderCb types.StreamSenderFilterCallbacks
+}
+
+func newCorsFilter(context context.Context) *corsFilter {
+	return &corsFilter{
+		context: context,
+	}
+}
+
+func (f *corsFilter) OnDecodeHeaders(headers map[string]string, endStream bool) types.FilterHeadersStatus {
+	origin, ok := headers[headerOrigin]
+	if !ok || origin == "" {
+		return types.FilterHeadersStatusContinue
+	}
+
+	policy := f.corsPolicy()
+	if policy == nil || !policy.Enabled() || !policy.AllowOrigin(origin) {
+		return types.FilterHeadersStatusContinue
+	}
+
+	f.policy = policy
+	f.origin = origin
+
+	if headers[types.HeaderMethod] != http.MethodOptions || headers[headerAccessControlRequestMethod] == "" {
+		return types.FilterHeadersStatusContinue
+	}
+
+	log.ByContext(f.context).Debugf("[Cors] reply preflight request from origin %s", origin)
+
+	f.preflight = true
+	f.decoderCb.AppendHeaders(f.preflightHeaders(), true)
+
+	return types.FilterHeadersStatusStopIteration
+}
+
+func (f *corsFilter) OnDecodeData(buf types.IoBuffer, endStream bool) types.FilterDataStatus {
+	if f.preflight {
+		return types.FilterDataStatusStopIterationNoBuffer
+	}
+
+	return types.FilterDataStatusContinue
+}
+
+func (f *corsFilter) OnDecodeTrailers(trailers map[string]string) types.FilterTrailersStatus {
+	if f.preflight {
+		return types.FilterTrailersStatusStopIteration
+	}
+
+	return types.FilterTrailersStatusContinue
+}
+
+func (f *corsFilter) SetDecoderFilterCallbacks(cb types.StreamReceiverFilterCallbacks) {
+	f.decoderCb = cb
+}
+
+func (f *corsFilter) AppendHeaders(headers interface{}, endStream bool) types.FilterHeadersStatus {
+	// preflight reply is complete, and requests not allowed get no cors headers
+	if f.preflight || f.policy == nil {
+		return types.FilterHeadersStatusContinue
+	}
+
+	if headerMap, ok := headers.(map[string]string); ok {
+		headerMap[headerAccessControlAllowOrigin] = f.origin
+
+		if f.policy.AllowCredentials() {
+			headerMap[headerAccessControlAllowCreds] = "true"
+		}
+
+		if exposeHeaders := f.policy.ExposeHeaders(); exposeHeaders != "" {
+			headerMap[headerAccessControlExposeHeaders] = exposeHeaders
+		}
+	}
+
+	return types.FilterHeadersStatusContinue
+}
+
+func (f *corsFilter) AppendData(buf types.IoBuffer, endStream bool) types.FilterDataStatus {
+	return types.FilterDataStatusContinue
+}
+
+func (f *corsFilter) AppendTrailers(trailers map[string]string) types.FilterTrailersStatus {
+	return types.FilterTrailersStatusContinue
+}
+
+func (f *corsFilter) SetEncoderFilterCallbacks(cb types.StreamSenderFilterCallbacks) {
+	f.encoderCb = cb
+}
+
+func (f *corsFilter) OnDestroy() {}
+
+// corsPolicy returns the route's cors policy, which is the virtual host's if the route has none
+func (f *corsFilter) corsPolicy() types.CorsPolicy {
+	route := f.decoderCb.Route()
+	if route == nil || route.RouteRule() == nil || route.RouteRule().Policy() == nil {
+		return nil
+	}
+
+	return route.RouteRule().Policy().CorsPolicy()
+}
+
+func (f *corsFilter) preflightHeaders() map[string]string {
+	headers := map[string]string{
+		types.HeaderStatus:             strconv.Itoa(http.StatusOK),
+		headerAccessControlAllowOrigin: f.origin,
+	}
+
+	if f.policy.AllowCredentials() {
+		headers[headerAccessControlAllowCreds] = "true"
+	}
+
+	if allowMethods := f.policy.AllowMethods(); allowMethods != "" {
+		headers[headerAccessControlAllowMethods] = allowMethods
+	}
+
+	if allowHeaders := f.policy.AllowHeaders(); allowHeaders != "" {
+		headers[headerAccessControlAllowHeaders] = allowHeaders
+	}
+
+	if maxAge := f.policy.MaxAga(); maxAge != "" {
+		headers[headerAccessControlMaxAge] = maxAge
+	}
+
+	return headers
+}
+
+// ~~ factory
+type FilterConfigFactory struct{}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks types.FilterChainFactoryCallbacks) {
+	filter := newCorsFilter(context)
+	callbacks.AddStreamReceiverFilter(filter)
+	callbacks.AddStreamSenderFilter(filter)
+}
+
+func CreateCorsFilterFactory(conf map[string]interface{}) (types.StreamFilterChainFactory, error) {
+	return &FilterConfigFactory{}, nil
+}
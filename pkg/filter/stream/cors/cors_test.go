@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cors
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/router"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+type mockPolicy struct {
+	types.Policy
+	corsPolicy types.CorsPolicy
+}
+
+func (p *mockPolicy) CorsPolicy() types.CorsPolicy {
+	return p.corsPolicy
+}
+
+type mockRouteRule struct {
+	types.RouteRule
+	policy *mockPolicy
+}
+
+func (r *mockRouteRule) Policy() types.Policy {
+	return r.policy
+}
+
+type mockRoute struct {
+	types.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() types.RouteRule {
+	return r.rule
+}
+
+// mockReceiverFilterCallbacks records the replied headers
+type mockReceiverFilterCallbacks struct {
+	types.StreamReceiverFilterCallbacks
+	route   types.Route
+	replied map[string]string
+}
+
+func (cb *mockReceiverFilterCallbacks) Route() types.Route {
+	return cb.route
+}
+
+func (cb *mockReceiverFilterCallbacks) AppendHeaders(headers interface{}, endStream bool) {
+	cb.replied = headers.(map[string]string)
+}
+
+func newTestFilter() (*corsFilter, *mockReceiverFilterCallbacks) {
+	policy := router.NewCorsPolicyImpl(&v2.CorsPolicy{
+		AllowOrigins:     []v2.OriginMatcher{{Prefix: "http://allowed"}},
+		AllowMethods:     "GET, POST",
+		AllowHeaders:     "content-type",
+		ExposeHeaders:    "x-custom",
+		MaxAge:           "600",
+		AllowCredentials: true,
+	})
+
+	cb := &mockReceiverFilterCallbacks{
+		route: &mockRoute{rule: &mockRouteRule{policy: &mockPolicy{corsPolicy: policy}}},
+	}
+
+	filter := newCorsFilter(context.Background())
+	filter.SetDecoderFilterCallbacks(cb)
+
+	return filter, cb
+}
+
+func TestCorsPreflight(t *testing.T) {
+	filter, cb := newTestFilter()
+
+	headers := map[string]string{
+		types.HeaderMethod:               http.MethodOptions,
+		headerOrigin:                     "http://allowed.com",
+		headerAccessControlRequestMethod: http.MethodPost,
+	}
+	if filter.OnDecodeHeaders(headers, true) != types.FilterHeadersStatusStopIteration {
+		t.Fatal("preflight request should be answered by filter")
+	}
+
+	want := map[string]string{
+		types.HeaderStatus:              "200",
+		headerAccessControlAllowOrigin:  "http://allowed.com",
+		headerAccessControlAllowCreds:   "true",
+		headerAccessControlAllowMethods: "GET, POST",
+		headerAccessControlAllowHeaders: "content-type",
+		headerAccessControlMaxAge:       "600",
+	}
+	if len(cb.replied) != len(want) {
+		t.Fatalf("unexpected preflight reply %v", cb.replied)
+	}
+	for k, v := range want {
+		if cb.replied[k] != v {
+			t.Errorf("preflight reply header %s should be %s, got %s", k, v, cb.replied[k])
+		}
+	}
+}
+
+func TestCorsRequest(t *testing.T) {
+	filter, cb := newTestFilter()
+
+	headers := map[string]string{
+		types.HeaderMethod: http.MethodGet,
+		headerOrigin:       "http://allowed.com",
+	}
+	if filter.OnDecodeHeaders(headers, true) != types.FilterHeadersStatusContinue || cb.replied != nil {
+		t.Fatal("normal cors request should be continued")
+	}
+
+	respHeaders := map[string]string{}
+	filter.AppendHeaders(respHeaders, true)
+
+	if respHeaders[headerAccessControlAllowOrigin] != "http://allowed.com" ||
+		respHeaders[headerAccessControlAllowCreds] != "true" ||
+		respHeaders[headerAccessControlExposeHeaders] != "x-custom" {
+		t.Errorf("unexpected response headers %v", respHeaders)
+	}
+}
+
+func TestCorsOriginNotAllowed(t *testing.T) {
+	filter, cb := newTestFilter()
+
+	// preflight of origin not allowed is proxied to upstream
+	headers := map[string]string{
+		types.HeaderMethod:               http.MethodOptions,
+		headerOrigin:                     "http://denied.com",
+		headerAccessControlRequestMethod: http.MethodPost,
+	}
+	if filter.OnDecodeHeaders(headers, true) != types.FilterHeadersStatusContinue || cb.replied != nil {
+		t.Fatal("request from origin not allowed should be continued")
+	}
+
+	respHeaders := map[string]string{}
+	filter.AppendHeaders(respHeaders, true)
+
+	if len(respHeaders) != 0 {
+		t.Errorf("no cors header should be set, got %v", respHeaders)
+	}
+}
@@ -27,9 +27,16 @@ import (
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/config"
 	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
+// runtime keys overriding the configured delay, the duration is in milliseconds
+const (
+	runtimeDelayPercent  = "fault.http.delay.fixed_delay_percent"
+	runtimeDelayDuration = "fault.http.delay.fixed_duration_ms"
+)
+
 type faultInjectFilter struct {
 	context context.Context
 
@@ -105,15 +112,13 @@ func (f *faultInjectFilter) tryInjectDelay() {
 }
 
 func (f *faultInjectFilter) getDelayDuration() uint64 {
-	if f.delayPercent == 0 {
-		return 0
-	}
+	snapshot := runtime.GetSnapshot()
 
-	if uint32(rand.Intn(100))+1 > f.delayPercent {
+	if !snapshot.FeatureEnabled(runtimeDelayPercent, uint64(f.delayPercent), rand.Uint64()) {
 		return 0
 	}
 
-	return f.delayDuration
+	return snapshot.GetInteger(runtimeDelayDuration, f.delayDuration)
 }
 
 // ~~ factory
@@ -0,0 +1,233 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/config"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// types.StreamReceiverFilter
+type rateLimitFilter struct {
+	context context.Context
+	config  *v2.GlobalRateLimit
+	client  types.RateLimitClient
+
+	// the callbacks are called without the lock held, as replying destroys the stream synchronously,
+	// which calls OnDestroy
+	mux sync.Mutex
+	// request properties
+	headers   map[string]string
+	endStream bool
+	intercept bool
+	// the rate limit service is being called
+	calling bool
+	// OnDecodeHeaders handles the result if the call completes before it returns
+	initiating bool
+	completed  bool
+	destroyed  bool
+	cancel     context.CancelFunc
+
+	// callbacks
+	cb types.StreamReceiverFilterCallbacks
+}
+
+func NewRateLimitFilter(context context.Context, config *v2.GlobalRateLimit, client types.RateLimitClient) types.StreamReceiverFilter {
+	return &rateLimitFilter{
+		context: context,
+		config:  config,
+		client:  client,
+	}
+}
+
+func (f *rateLimitFilter) OnDecodeHeaders(headers map[string]string, endStream bool) types.FilterHeadersStatus {
+	if !runtime.FeatureEnabled(runtimeFilterEnabled, 100) {
+		return types.FilterHeadersStatusContinue
+	}
+
+	descriptors := populateDescriptors(f.cb, f.config.Stage, headers)
+	if len(descriptors) == 0 {
+		return types.FilterHeadersStatusContinue
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.config.Timeout)
+
+	f.mux.Lock()
+	f.headers = headers
+	f.endStream = endStream
+	f.calling = true
+	f.initiating = true
+	f.cancel = cancel
+	f.mux.Unlock()
+
+	go f.callService(ctx, descriptors)
+
+	f.mux.Lock()
+	f.initiating = false
+	completed, intercept, endStream := f.completed, f.intercept, f.endStream
+	f.mux.Unlock()
+
+	// the call completed inline
+	if completed && !intercept {
+		return types.FilterHeadersStatusContinue
+	}
+
+	if completed && intercept && endStream {
+		sendRateLimitedReply(f.cb, headers)
+	}
+
+	return types.FilterHeadersStatusStopIteration
+}
+
+func (f *rateLimitFilter) OnDecodeData(buf types.IoBuffer, endStream bool) types.FilterDataStatus {
+	f.mux.Lock()
+	f.endStream = endStream
+	intercept, calling, headers := f.intercept, f.calling, f.headers
+	f.mux.Unlock()
+
+	if intercept {
+		if endStream {
+			sendRateLimitedReply(f.cb, headers)
+		}
+
+		return types.FilterDataStatusStopIterationNoBuffer
+	}
+
+	if calling {
+		return types.FilterDataStatusStopIterationAndBuffer
+	}
+
+	return types.FilterDataStatusContinue
+}
+
+func (f *rateLimitFilter) OnDecodeTrailers(trailers map[string]string) types.FilterTrailersStatus {
+	f.mux.Lock()
+	f.endStream = true
+	intercept, calling, headers := f.intercept, f.calling, f.headers
+	f.mux.Unlock()
+
+	if intercept {
+		sendRateLimitedReply(f.cb, headers)
+
+		return types.FilterTrailersStatusStopIteration
+	}
+
+	if calling {
+		return types.FilterTrailersStatusStopIteration
+	}
+
+	return types.FilterTrailersStatusContinue
+}
+
+func (f *rateLimitFilter) callService(ctx context.Context, descriptors []types.Descriptor) {
+	status, err := f.client.ShouldRateLimit(ctx, f.config.Domain, descriptors)
+
+	f.mux.Lock()
+
+	f.cancel()
+	f.calling = false
+	f.completed = true
+
+	if f.destroyed {
+		f.mux.Unlock()
+		return
+	}
+
+	switch status {
+	case types.OverLimit:
+		if !runtime.FeatureEnabled(runtimeFilterEnforcing, 100) {
+			log.ByContext(f.context).Debugf("[RateLimit] request is over limit but not enforced, headers = %v", f.headers)
+			break
+		}
+
+		log.ByContext(f.context).Debugf("[RateLimit] request is rate limited, headers = %v", f.headers)
+
+		f.cb.RequestInfo().SetResponseFlag(types.RateLimited)
+		f.intercept = true
+
+	case types.Error:
+		log.ByContext(f.context).Errorf("[RateLimit] call rate limit service failed: %v", err)
+
+		f.cb.RequestInfo().SetResponseFlag(types.RateLimitServiceError)
+		f.intercept = !f.config.FailureModeAllow
+	}
+
+	// OnDecodeHeaders continues or replies by the result
+	if f.initiating {
+		f.mux.Unlock()
+		return
+	}
+
+	intercept, endStream, headers := f.intercept, f.endStream, f.headers
+	f.mux.Unlock()
+
+	if !intercept {
+		f.cb.ContinueDecoding()
+	} else if endStream {
+		sendRateLimitedReply(f.cb, headers)
+	}
+}
+
+func (f *rateLimitFilter) SetDecoderFilterCallbacks(cb types.StreamReceiverFilterCallbacks) {
+	f.cb = cb
+}
+
+func (f *rateLimitFilter) OnDestroy() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.destroyed = true
+	if f.calling {
+		f.cancel()
+	}
+}
+
+// ~~ factory
+type RateLimitFilterConfigFactory struct {
+	FilterConfig *v2.GlobalRateLimit
+	Client       types.RateLimitClient
+}
+
+func (f *RateLimitFilterConfigFactory) CreateFilterChain(context context.Context, callbacks types.FilterChainFactoryCallbacks) {
+	filter := NewRateLimitFilter(context, f.FilterConfig, f.Client)
+	callbacks.AddStreamReceiverFilter(filter)
+}
+
+// CreateRateLimitFilterFactory creates the filter factory calling the rate limit service by grpc,
+// other clients can be plugged in by creating RateLimitFilterConfigFactory directly
+func CreateRateLimitFilterFactory(conf map[string]interface{}) (types.StreamFilterChainFactory, error) {
+	filterConfig, err := config.ParseGlobalRateLimitFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := GetOrCreateGRPCClient(filterConfig.ServiceAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RateLimitFilterConfigFactory{
+		FilterConfig: filterConfig,
+		Client:       client,
+	}, nil
+}
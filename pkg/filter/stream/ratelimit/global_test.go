@@ -0,0 +1,230 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/network"
+	"github.com/alipay/sofa-mosn/pkg/router"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+type mockPolicy struct {
+	types.Policy
+	rateLimitPolicy types.RateLimitPolicy
+}
+
+func (p *mockPolicy) RateLimitPolicy() types.RateLimitPolicy {
+	return p.rateLimitPolicy
+}
+
+type mockRouteRule struct {
+	types.RouteRule
+	policy *mockPolicy
+}
+
+func (r *mockRouteRule) Policy() types.Policy {
+	return r.policy
+}
+
+type mockRoute struct {
+	types.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() types.RouteRule {
+	return r.rule
+}
+
+// mockReceiverFilterCallbacks records the continued or replied request
+type mockReceiverFilterCallbacks struct {
+	types.StreamReceiverFilterCallbacks
+	// destroyed on reply like the stream does
+	filter      types.StreamReceiverFilter
+	route       types.Route
+	requestInfo types.RequestInfo
+	continued   chan struct{}
+	replied     chan map[string]string
+}
+
+func newMockReceiverFilterCallbacks() *mockReceiverFilterCallbacks {
+	return &mockReceiverFilterCallbacks{
+		route: &mockRoute{rule: &mockRouteRule{policy: &mockPolicy{
+			rateLimitPolicy: router.NewRateLimitPolicyImpl([]v2.RateLimit{
+				{Actions: []v2.RateLimitAction{{RequestHeaders: &v2.RequestHeadersAction{HeaderName: "user"}}}},
+			}),
+		}}},
+		requestInfo: network.NewRequestInfo(),
+		continued:   make(chan struct{}, 1),
+		replied:     make(chan map[string]string, 1),
+	}
+}
+
+func (cb *mockReceiverFilterCallbacks) Connection() types.Connection {
+	return nil
+}
+
+func (cb *mockReceiverFilterCallbacks) Route() types.Route {
+	return cb.route
+}
+
+func (cb *mockReceiverFilterCallbacks) RequestInfo() types.RequestInfo {
+	return cb.requestInfo
+}
+
+func (cb *mockReceiverFilterCallbacks) ContinueDecoding() {
+	cb.continued <- struct{}{}
+}
+
+// AppendHeaders destroys the filter synchronously, as the stream is ended and cleaned
+// when the reply ends the stream
+func (cb *mockReceiverFilterCallbacks) AppendHeaders(headers interface{}, endStream bool) {
+	if endStream && cb.filter != nil {
+		cb.filter.OnDestroy()
+	}
+
+	cb.replied <- headers.(map[string]string)
+}
+
+// mockRateLimitClient limits the requests of user "limited", or fails if err is set
+type mockRateLimitClient struct {
+	err error
+}
+
+func (c *mockRateLimitClient) ShouldRateLimit(ctx context.Context, domain string, descriptors []types.Descriptor) (types.LimitStatus, error) {
+	// make sure the result is handled after OnDecodeHeaders returns
+	time.Sleep(10 * time.Millisecond)
+
+	if c.err != nil {
+		return types.Error, c.err
+	}
+
+	for _, descriptor := range descriptors {
+		if descriptor.Entries[0].Value == "limited" {
+			return types.OverLimit, nil
+		}
+	}
+
+	return types.OK, nil
+}
+
+func TestRateLimitFilter(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	tests := []struct {
+		name             string
+		user             string
+		err              error
+		failureModeAllow bool
+		limited          bool
+		flag             types.ResponseFlag
+	}{
+		{"ok", "normal", nil, true, false, 0},
+		{"over limit", "limited", nil, true, true, types.RateLimited},
+		{"failure mode allow", "normal", errors.New("unavailable"), true, false, types.RateLimitServiceError},
+		{"failure mode deny", "normal", errors.New("unavailable"), false, true, types.RateLimitServiceError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := newMockReceiverFilterCallbacks()
+			config := &v2.GlobalRateLimit{Domain: "mosn", Timeout: time.Second, FailureModeAllow: tt.failureModeAllow}
+			filter := NewRateLimitFilter(context.Background(), config, &mockRateLimitClient{err: tt.err})
+			filter.SetDecoderFilterCallbacks(cb)
+			cb.filter = filter
+
+			status := filter.OnDecodeHeaders(map[string]string{"user": tt.user}, true)
+			if status != types.FilterHeadersStatusStopIteration {
+				t.Fatalf("request should be stopped while calling rate limit service")
+			}
+
+			select {
+			case <-cb.continued:
+				if tt.limited {
+					t.Errorf("request should be limited")
+				}
+			case headers := <-cb.replied:
+				if !tt.limited {
+					t.Errorf("request should not be limited")
+				}
+				if headers[types.HeaderStatus] != strconv.Itoa(types.RateLimitedCode) {
+					t.Errorf("unexpected reply status %s", headers[types.HeaderStatus])
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("request is neither continued nor replied")
+			}
+
+			if tt.flag != 0 && !cb.requestInfo.GetResponseFlag(tt.flag) {
+				t.Errorf("response flag %x should be set", tt.flag)
+			}
+		})
+	}
+}
+
+func TestRateLimitFilterWithoutDescriptors(t *testing.T) {
+	cb := newMockReceiverFilterCallbacks()
+	config := &v2.GlobalRateLimit{Domain: "mosn", Timeout: time.Second}
+	filter := NewRateLimitFilter(context.Background(), config, &mockRateLimitClient{})
+	filter.SetDecoderFilterCallbacks(cb)
+
+	// no descriptor is generated without the header, the service is not called
+	if filter.OnDecodeHeaders(map[string]string{}, true) != types.FilterHeadersStatusContinue {
+		t.Errorf("request without descriptors should be continued")
+	}
+}
+
+func TestRateLimitFilterReplyOnData(t *testing.T) {
+	cb := newMockReceiverFilterCallbacks()
+	config := &v2.GlobalRateLimit{Domain: "mosn", Timeout: time.Second}
+	filter := NewRateLimitFilter(context.Background(), config, &mockRateLimitClient{})
+	filter.SetDecoderFilterCallbacks(cb)
+	cb.filter = filter
+
+	filter.OnDecodeHeaders(map[string]string{"user": "limited"}, false)
+
+	// wait for the result, the request is not replied until the stream ends
+	for i := 0; i < 100 && filter.OnDecodeData(nil, false) != types.FilterDataStatusStopIterationNoBuffer; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	done := make(chan types.FilterDataStatus)
+	go func() {
+		done <- filter.OnDecodeData(nil, true)
+	}()
+
+	select {
+	case status := <-done:
+		if status != types.FilterDataStatusStopIterationNoBuffer {
+			t.Errorf("limited request should be stopped, got %v", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("replying on data is blocked")
+	}
+
+	select {
+	case <-cb.replied:
+	default:
+		t.Errorf("limited request should be replied")
+	}
+}
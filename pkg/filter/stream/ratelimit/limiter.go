@@ -0,0 +1,289 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+var (
+	limitersMutex sync.Mutex
+	limiters      = make(map[string]*Limiter)
+)
+
+// GetOrUpdateLimiter returns the limiter of the config's name, limits of the existing limiter
+// are updated in place, so that filters created before share the new limits.
+// A new limiter is returned every time if the name is empty
+func GetOrUpdateLimiter(config *v2.LocalRateLimit) *Limiter {
+	if config.Name == "" {
+		return NewLimiter(config)
+	}
+
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+
+	if limiter, ok := limiters[config.Name]; ok {
+		limiter.Update(config)
+		return limiter
+	}
+
+	limiter := NewLimiter(config)
+	limiters[config.Name] = limiter
+
+	return limiter
+}
+
+// Limiter limits requests by token buckets of descriptors
+type Limiter struct {
+	mux    sync.RWMutex
+	stage  uint64
+	limits []*descriptorLimit
+}
+
+func NewLimiter(config *v2.LocalRateLimit) *Limiter {
+	limiter := &Limiter{}
+	limiter.Update(config)
+
+	return limiter
+}
+
+// Update replaces the limits by config, buckets of the unchanged descriptors keep their tokens
+func (l *Limiter) Update(config *v2.LocalRateLimit) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	var limits []*descriptorLimit
+
+	for _, descriptor := range config.Descriptors {
+		limit := l.findLimit(descriptor.Entries)
+
+		if limit == nil {
+			limit = newDescriptorLimit(descriptor)
+		} else {
+			limit.update(descriptor)
+		}
+
+		limits = append(limits, limit)
+	}
+
+	l.stage = config.Stage
+	l.limits = limits
+}
+
+func (l *Limiter) findLimit(entries []v2.RateLimitDescriptorEntry) *descriptorLimit {
+	for _, limit := range l.limits {
+		if limit.sameEntries(entries) {
+			return limit
+		}
+	}
+
+	return nil
+}
+
+// Stage returns the stage of route rate limits applied by the limiter
+func (l *Limiter) Stage() uint64 {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	return l.stage
+}
+
+// ShouldRateLimit takes a token from the bucket of each descriptor, OverLimit is returned
+// at the first empty bucket and no more tokens are taken, descriptors matching no limit are not limited
+func (l *Limiter) ShouldRateLimit(descriptors []types.Descriptor) types.LimitStatus {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	now := time.Now()
+
+	for _, descriptor := range descriptors {
+		for _, limit := range l.limits {
+			if bucket := limit.bucket(descriptor); bucket != nil {
+				if !bucket.take(now) {
+					return types.OverLimit
+				}
+
+				break
+			}
+		}
+	}
+
+	return types.OK
+}
+
+// maxBucketsPerLimit bounds the buckets kept for the values of a wildcard entry
+const maxBucketsPerLimit = 10000
+
+// descriptorLimit holds token buckets of the descriptors matching the entries,
+// an entry with empty value matches any value, and each value has its own bucket.
+// At most maxBuckets buckets are kept, the least recently used one is evicted
+// when a new value comes, and the value starts over with a full bucket if seen again
+type descriptorLimit struct {
+	entries []v2.RateLimitDescriptorEntry
+
+	mux        sync.Mutex
+	config     v2.LocalRateLimitDescriptor
+	maxBuckets int
+	buckets    map[string]*list.Element
+	// elements of tokenBucket, the most recently used at front
+	lru *list.List
+}
+
+func newDescriptorLimit(config v2.LocalRateLimitDescriptor) *descriptorLimit {
+	return &descriptorLimit{
+		entries:    config.Entries,
+		config:     config,
+		maxBuckets: maxBucketsPerLimit,
+		buckets:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (dl *descriptorLimit) sameEntries(entries []v2.RateLimitDescriptorEntry) bool {
+	if len(dl.entries) != len(entries) {
+		return false
+	}
+
+	for i := range entries {
+		if dl.entries[i] != entries[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (dl *descriptorLimit) update(config v2.LocalRateLimitDescriptor) {
+	dl.mux.Lock()
+	defer dl.mux.Unlock()
+
+	dl.config = config
+	for e := dl.lru.Front(); e != nil; e = e.Next() {
+		e.Value.(*tokenBucket).update(config)
+	}
+}
+
+// bucket returns the token bucket of the descriptor, nil is returned if not matched
+func (dl *descriptorLimit) bucket(descriptor types.Descriptor) *tokenBucket {
+	if len(dl.entries) != len(descriptor.Entries) {
+		return nil
+	}
+
+	var values []string
+
+	for i, entry := range dl.entries {
+		if entry.Key != descriptor.Entries[i].Key {
+			return nil
+		}
+
+		if entry.Value == "" {
+			values = append(values, descriptor.Entries[i].Value)
+		} else if entry.Value != descriptor.Entries[i].Value {
+			return nil
+		}
+	}
+
+	key := strings.Join(values, "|")
+
+	dl.mux.Lock()
+	defer dl.mux.Unlock()
+
+	if e, ok := dl.buckets[key]; ok {
+		dl.lru.MoveToFront(e)
+		return e.Value.(*tokenBucket)
+	}
+
+	if dl.lru.Len() >= dl.maxBuckets {
+		oldest := dl.lru.Back()
+		dl.lru.Remove(oldest)
+		delete(dl.buckets, oldest.Value.(*tokenBucket).key)
+	}
+
+	bucket := newTokenBucket(key, dl.config)
+	dl.buckets[key] = dl.lru.PushFront(bucket)
+
+	return bucket
+}
+
+// tokenBucket is filled lazily when tokens are taken
+type tokenBucket struct {
+	key string
+
+	mux           sync.Mutex
+	maxTokens     uint32
+	tokensPerFill uint32
+	fillInterval  time.Duration
+	tokens        uint32
+	lastFill      time.Time
+}
+
+func newTokenBucket(key string, config v2.LocalRateLimitDescriptor) *tokenBucket {
+	return &tokenBucket{
+		key:           key,
+		maxTokens:     config.MaxTokens,
+		tokensPerFill: config.TokensPerFill,
+		fillInterval:  config.FillInterval,
+		tokens:        config.MaxTokens,
+		lastFill:      time.Now(),
+	}
+}
+
+func (b *tokenBucket) update(config v2.LocalRateLimitDescriptor) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.maxTokens = config.MaxTokens
+	b.tokensPerFill = config.TokensPerFill
+	b.fillInterval = config.FillInterval
+
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.fillInterval > 0 && now.Sub(b.lastFill) >= b.fillInterval {
+		fills := uint64(now.Sub(b.lastFill) / b.fillInterval)
+		b.lastFill = b.lastFill.Add(time.Duration(fills) * b.fillInterval)
+
+		// the bucket is full if filled more than max tokens times, avoid overflow
+		if fills >= uint64(b.maxTokens) {
+			b.tokens = b.maxTokens
+		} else if tokens := uint64(b.tokens) + fills*uint64(b.tokensPerFill); tokens < uint64(b.maxTokens) {
+			b.tokens = uint32(tokens)
+		} else {
+			b.tokens = b.maxTokens
+		}
+	}
+
+	if b.tokens == 0 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
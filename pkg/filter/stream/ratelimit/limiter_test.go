@@ -0,0 +1,218 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+func newDescriptor(kvs ...string) types.Descriptor {
+	descriptor := types.Descriptor{}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		descriptor.Entries = append(descriptor.Entries, types.DescriptorEntry{Key: kvs[i], Value: kvs[i+1]})
+	}
+
+	return descriptor
+}
+
+func newConfig(name string, maxTokens uint32, entries ...v2.RateLimitDescriptorEntry) *v2.LocalRateLimit {
+	return &v2.LocalRateLimit{
+		Name: name,
+		Descriptors: []v2.LocalRateLimitDescriptor{
+			{
+				Entries:       entries,
+				MaxTokens:     maxTokens,
+				TokensPerFill: 1,
+				FillInterval:  time.Hour,
+			},
+		},
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := &tokenBucket{
+		maxTokens:     2,
+		tokensPerFill: 1,
+		fillInterval:  time.Second,
+		tokens:        2,
+		lastFill:      now,
+	}
+
+	if !bucket.take(now) || !bucket.take(now) {
+		t.Fatal("tokens in a full bucket should be taken")
+	}
+	if bucket.take(now) {
+		t.Fatal("token should not be taken from an empty bucket")
+	}
+
+	// one token is filled after one interval
+	now = now.Add(time.Second)
+	if !bucket.take(now) {
+		t.Fatal("token should be taken after fill")
+	}
+	if bucket.take(now) {
+		t.Fatal("only one token should be filled")
+	}
+
+	// tokens never exceed max tokens
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if !bucket.take(now) {
+			t.Fatal("bucket should be full after a long time")
+		}
+	}
+	if bucket.take(now) {
+		t.Fatal("tokens should not exceed max tokens")
+	}
+}
+
+func TestLimiterMatchDescriptors(t *testing.T) {
+	limiter := NewLimiter(newConfig("", 1,
+		v2.RateLimitDescriptorEntry{Key: "destination_cluster", Value: "foo"},
+	))
+
+	if limiter.ShouldRateLimit([]types.Descriptor{newDescriptor("destination_cluster", "bar")}) != types.OK {
+		t.Error("descriptor not matching any limit should not be limited")
+	}
+
+	foo := []types.Descriptor{newDescriptor("destination_cluster", "foo")}
+	if limiter.ShouldRateLimit(foo) != types.OK {
+		t.Error("first request should not be limited")
+	}
+	if limiter.ShouldRateLimit(foo) != types.OverLimit {
+		t.Error("second request should be limited")
+	}
+}
+
+func TestLimiterWildcardValue(t *testing.T) {
+	limiter := NewLimiter(newConfig("", 1,
+		v2.RateLimitDescriptorEntry{Key: "remote_address"},
+	))
+
+	// each value has its own bucket
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2"} {
+		descriptors := []types.Descriptor{newDescriptor("remote_address", addr)}
+
+		if limiter.ShouldRateLimit(descriptors) != types.OK {
+			t.Errorf("first request from %s should not be limited", addr)
+		}
+		if limiter.ShouldRateLimit(descriptors) != types.OverLimit {
+			t.Errorf("second request from %s should be limited", addr)
+		}
+	}
+}
+
+func TestLimiterEvictsLeastRecentlyUsedBucket(t *testing.T) {
+	limiter := NewLimiter(newConfig("", 1,
+		v2.RateLimitDescriptorEntry{Key: "remote_address"},
+	))
+	limit := limiter.limits[0]
+	limit.maxBuckets = 2
+
+	first := []types.Descriptor{newDescriptor("remote_address", "10.0.0.1")}
+	second := []types.Descriptor{newDescriptor("remote_address", "10.0.0.2")}
+	limiter.ShouldRateLimit(first)
+	limiter.ShouldRateLimit(second)
+	// first is used again, so second is the least recently used
+	if limiter.ShouldRateLimit(first) != types.OverLimit {
+		t.Fatal("second request from 10.0.0.1 should be limited")
+	}
+
+	limiter.ShouldRateLimit([]types.Descriptor{newDescriptor("remote_address", "10.0.0.3")})
+	if len(limit.buckets) != 2 || limit.lru.Len() != 2 {
+		t.Fatalf("expected 2 buckets kept, got %d", len(limit.buckets))
+	}
+	if _, ok := limit.buckets["10.0.0.2"]; ok {
+		t.Error("expected the least recently used bucket evicted")
+	}
+	if limiter.ShouldRateLimit(first) != types.OverLimit {
+		t.Error("bucket of 10.0.0.1 should be kept")
+	}
+}
+
+func TestLimiterStopsAtFirstOverLimit(t *testing.T) {
+	limiter := NewLimiter(&v2.LocalRateLimit{
+		Descriptors: []v2.LocalRateLimitDescriptor{
+			{
+				Entries:       []v2.RateLimitDescriptorEntry{{Key: "generic_key", Value: "a"}},
+				MaxTokens:     1,
+				TokensPerFill: 1,
+				FillInterval:  time.Hour,
+			},
+			{
+				Entries:       []v2.RateLimitDescriptorEntry{{Key: "generic_key", Value: "b"}},
+				MaxTokens:     2,
+				TokensPerFill: 1,
+				FillInterval:  time.Hour,
+			},
+		},
+	})
+
+	a := newDescriptor("generic_key", "a")
+	b := newDescriptor("generic_key", "b")
+	if limiter.ShouldRateLimit([]types.Descriptor{a}) != types.OK {
+		t.Fatal("first request should not be limited")
+	}
+
+	// a is empty, no token should be taken from b
+	if limiter.ShouldRateLimit([]types.Descriptor{a, b}) != types.OverLimit {
+		t.Fatal("request should be limited by a")
+	}
+	for i := 0; i < 2; i++ {
+		if limiter.ShouldRateLimit([]types.Descriptor{b}) != types.OK {
+			t.Fatalf("tokens of b should be kept, request %d limited", i)
+		}
+	}
+}
+
+func TestGetOrUpdateLimiter(t *testing.T) {
+	entry := v2.RateLimitDescriptorEntry{Key: "generic_key", Value: "reload"}
+	descriptors := []types.Descriptor{newDescriptor("generic_key", "reload")}
+
+	limiter := GetOrUpdateLimiter(newConfig("test_reload", 3, entry))
+	for i := 0; i < 2; i++ {
+		if limiter.ShouldRateLimit(descriptors) != types.OK {
+			t.Fatal("requests within max tokens should not be limited")
+		}
+	}
+
+	// the limiter is shared and updated in place, tokens of the bucket are kept
+	if reloaded := GetOrUpdateLimiter(newConfig("test_reload", 5, entry)); reloaded != limiter {
+		t.Fatal("limiter with the same name should be reused")
+	}
+	if limiter.ShouldRateLimit(descriptors) != types.OK {
+		t.Fatal("the token left should be taken")
+	}
+	if limiter.ShouldRateLimit(descriptors) != types.OverLimit {
+		t.Fatal("bucket should not be refilled on update")
+	}
+
+	// the bucket of a changed descriptor is created again
+	GetOrUpdateLimiter(newConfig("test_reload", 1, v2.RateLimitDescriptorEntry{Key: "generic_key"}))
+	if limiter.ShouldRateLimit(descriptors) != types.OK {
+		t.Fatal("request should not be limited by the new descriptor's bucket")
+	}
+
+	if GetOrUpdateLimiter(newConfig("", 1, entry)) == GetOrUpdateLimiter(newConfig("", 1, entry)) {
+		t.Error("limiters without name should not be shared")
+	}
+}
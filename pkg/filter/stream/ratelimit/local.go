@@ -0,0 +1,132 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/config"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// types.StreamReceiverFilter
+type localRateLimitFilter struct {
+	context context.Context
+	limiter *Limiter
+
+	// request properties
+	headers   map[string]string
+	intercept bool
+
+	// callbacks
+	cb types.StreamReceiverFilterCallbacks
+}
+
+func NewLocalRateLimitFilter(context context.Context, limiter *Limiter) types.StreamReceiverFilter {
+	return &localRateLimitFilter{
+		context: context,
+		limiter: limiter,
+	}
+}
+
+func (f *localRateLimitFilter) OnDecodeHeaders(headers map[string]string, endStream bool) types.FilterHeadersStatus {
+	if !runtime.FeatureEnabled(runtimeLocalFilterEnabled, 100) {
+		return types.FilterHeadersStatusContinue
+	}
+
+	if f.limiter.ShouldRateLimit(populateDescriptors(f.cb, f.limiter.Stage(), headers)) == types.OverLimit {
+		if runtime.FeatureEnabled(runtimeLocalFilterEnforcing, 100) {
+			log.ByContext(f.context).Debugf("[LocalRateLimit] request is rate limited, headers = %v", headers)
+
+			f.cb.RequestInfo().SetResponseFlag(types.RateLimited)
+			f.headers = headers
+			f.intercept = true
+		} else {
+			log.ByContext(f.context).Debugf("[LocalRateLimit] request is over limit but not enforced, headers = %v", headers)
+		}
+	}
+
+	if endStream && f.intercept {
+		f.handleIntercept()
+	}
+
+	if f.intercept {
+		return types.FilterHeadersStatusStopIteration
+	}
+
+	return types.FilterHeadersStatusContinue
+}
+
+func (f *localRateLimitFilter) OnDecodeData(buf types.IoBuffer, endStream bool) types.FilterDataStatus {
+	if endStream && f.intercept {
+		f.handleIntercept()
+	}
+
+	if f.intercept {
+		return types.FilterDataStatusStopIterationNoBuffer
+	}
+
+	return types.FilterDataStatusContinue
+}
+
+func (f *localRateLimitFilter) OnDecodeTrailers(trailers map[string]string) types.FilterTrailersStatus {
+	if f.intercept {
+		f.handleIntercept()
+
+		return types.FilterTrailersStatusStopIteration
+	}
+
+	return types.FilterTrailersStatusContinue
+}
+
+func (f *localRateLimitFilter) handleIntercept() {
+	sendRateLimitedReply(f.cb, f.headers)
+}
+
+func (f *localRateLimitFilter) SetDecoderFilterCallbacks(cb types.StreamReceiverFilterCallbacks) {
+	f.cb = cb
+}
+
+func (f *localRateLimitFilter) OnDestroy() {}
+
+// ~~ factory
+type LocalRateLimitFilterConfigFactory struct {
+	FilterConfig *v2.LocalRateLimit
+	limiter      *Limiter
+}
+
+func (f *LocalRateLimitFilterConfigFactory) CreateFilterChain(context context.Context, callbacks types.FilterChainFactoryCallbacks) {
+	filter := NewLocalRateLimitFilter(context, f.limiter)
+	callbacks.AddStreamReceiverFilter(filter)
+}
+
+// CreateLocalRateLimitFilterFactory creates the filter factory, factories with the same name
+// share the limiter, whose limits are updated by the latest config
+func CreateLocalRateLimitFilterFactory(conf map[string]interface{}) (types.StreamFilterChainFactory, error) {
+	filterConfig, err := config.ParseLocalRateLimitFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalRateLimitFilterConfigFactory{
+		FilterConfig: filterConfig,
+		limiter:      GetOrUpdateLimiter(filterConfig),
+	}, nil
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+// messages of envoy's ratelimit grpc api, see envoy/service/ratelimit/v2/rls.proto,
+// only the fields used by the filter are declared
+
+type RateLimitRequest struct {
+	Domain      string                 `protobuf:"bytes,1,opt,name=domain,proto3"`
+	Descriptors []*RateLimitDescriptor `protobuf:"bytes,2,rep,name=descriptors,proto3"`
+	HitsAddend  uint32                 `protobuf:"varint,3,opt,name=hits_addend,proto3"`
+}
+
+func (m *RateLimitRequest) Reset()         { *m = RateLimitRequest{} }
+func (m *RateLimitRequest) String() string { return proto.CompactTextString(m) }
+func (*RateLimitRequest) ProtoMessage()    {}
+
+type RateLimitDescriptor struct {
+	Entries []*RateLimitDescriptor_Entry `protobuf:"bytes,1,rep,name=entries,proto3"`
+}
+
+func (m *RateLimitDescriptor) Reset()         { *m = RateLimitDescriptor{} }
+func (m *RateLimitDescriptor) String() string { return proto.CompactTextString(m) }
+func (*RateLimitDescriptor) ProtoMessage()    {}
+
+type RateLimitDescriptor_Entry struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *RateLimitDescriptor_Entry) Reset()         { *m = RateLimitDescriptor_Entry{} }
+func (m *RateLimitDescriptor_Entry) String() string { return proto.CompactTextString(m) }
+func (*RateLimitDescriptor_Entry) ProtoMessage()    {}
+
+// RateLimitResponse_Code is the status of the request or a descriptor
+type RateLimitResponse_Code int32
+
+const (
+	RateLimitResponse_UNKNOWN    RateLimitResponse_Code = 0
+	RateLimitResponse_OK         RateLimitResponse_Code = 1
+	RateLimitResponse_OVER_LIMIT RateLimitResponse_Code = 2
+)
+
+type RateLimitResponse struct {
+	OverallCode int32                                 `protobuf:"varint,1,opt,name=overall_code,proto3"`
+	Statuses    []*RateLimitResponse_DescriptorStatus `protobuf:"bytes,2,rep,name=statuses,proto3"`
+}
+
+func (m *RateLimitResponse) Reset()         { *m = RateLimitResponse{} }
+func (m *RateLimitResponse) String() string { return proto.CompactTextString(m) }
+func (*RateLimitResponse) ProtoMessage()    {}
+
+type RateLimitResponse_DescriptorStatus struct {
+	Code           int32  `protobuf:"varint,1,opt,name=code,proto3"`
+	LimitRemaining uint32 `protobuf:"varint,3,opt,name=limit_remaining,proto3"`
+}
+
+func (m *RateLimitResponse_DescriptorStatus) Reset()         { *m = RateLimitResponse_DescriptorStatus{} }
+func (m *RateLimitResponse_DescriptorStatus) String() string { return proto.CompactTextString(m) }
+func (*RateLimitResponse_DescriptorStatus) ProtoMessage()    {}
+
+const shouldRateLimitMethod = "/envoy.service.ratelimit.v2.RateLimitService/ShouldRateLimit"
+
+var (
+	grpcClientsMutex sync.Mutex
+	grpcClients      = make(map[string]types.RateLimitClient)
+)
+
+// GetOrCreateGRPCClient returns the client of the rate limit service at address,
+// clients are shared by filter factories so that each address is dialed only once
+func GetOrCreateGRPCClient(address string) (types.RateLimitClient, error) {
+	grpcClientsMutex.Lock()
+	defer grpcClientsMutex.Unlock()
+
+	if client, ok := grpcClients[address]; ok {
+		return client, nil
+	}
+
+	client, err := NewGRPCClient(address)
+	if err != nil {
+		return nil, err
+	}
+	grpcClients[address] = client
+
+	return client, nil
+}
+
+// grpcClient is the types.RateLimitClient calling envoy's ratelimit grpc service
+type grpcClient struct {
+	conn *grpc.ClientConn
+}
+
+// NewGRPCClient creates a client of the rate limit service at address, the connection
+// is established in background
+func NewGRPCClient(address string) (types.RateLimitClient, error) {
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcClient{
+		conn: conn,
+	}, nil
+}
+
+func (c *grpcClient) ShouldRateLimit(ctx context.Context, domain string, descriptors []types.Descriptor) (types.LimitStatus, error) {
+	request := &RateLimitRequest{
+		Domain: domain,
+	}
+
+	for _, descriptor := range descriptors {
+		d := &RateLimitDescriptor{}
+		for _, entry := range descriptor.Entries {
+			d.Entries = append(d.Entries, &RateLimitDescriptor_Entry{Key: entry.Key, Value: entry.Value})
+		}
+
+		request.Descriptors = append(request.Descriptors, d)
+	}
+
+	response := &RateLimitResponse{}
+	if err := c.conn.Invoke(ctx, shouldRateLimitMethod, request, response); err != nil {
+		return types.Error, err
+	}
+
+	switch RateLimitResponse_Code(response.OverallCode) {
+	case RateLimitResponse_OK:
+		return types.OK, nil
+	case RateLimitResponse_OVER_LIMIT:
+		return types.OverLimit, nil
+	}
+
+	return types.Error, fmt.Errorf("unknown rate limit response code %d", response.OverallCode)
+}
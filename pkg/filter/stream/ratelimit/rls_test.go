@@ -0,0 +1,202 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"google.golang.org/grpc"
+)
+
+// rateLimitServiceServer is the server api of envoy's ratelimit grpc service, used by the mock service
+type rateLimitServiceServer interface {
+	ShouldRateLimit(context.Context, *RateLimitRequest) (*RateLimitResponse, error)
+}
+
+func registerRateLimitServiceServer(s *grpc.Server, srv rateLimitServiceServer) {
+	s.RegisterService(&rateLimitServiceDesc, srv)
+}
+
+func shouldRateLimitHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RateLimitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(rateLimitServiceServer).ShouldRateLimit(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: shouldRateLimitMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(rateLimitServiceServer).ShouldRateLimit(ctx, req.(*RateLimitRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+var rateLimitServiceDesc = grpc.ServiceDesc{
+	ServiceName: "envoy.service.ratelimit.v2.RateLimitService",
+	HandlerType: (*rateLimitServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ShouldRateLimit",
+			Handler:    shouldRateLimitHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// mockRateLimitService limits the requests with the descriptor entry ("user", "limited")
+type mockRateLimitService struct {
+	delay time.Duration
+
+	mux     sync.Mutex
+	request *RateLimitRequest
+}
+
+// lastRequest returns the last request received by the service
+func (s *mockRateLimitService) lastRequest() *RateLimitRequest {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.request
+}
+
+func (s *mockRateLimitService) ShouldRateLimit(ctx context.Context, request *RateLimitRequest) (*RateLimitResponse, error) {
+	s.mux.Lock()
+	s.request = request
+	s.mux.Unlock()
+	time.Sleep(s.delay)
+
+	for _, descriptor := range request.Descriptors {
+		for _, entry := range descriptor.Entries {
+			if entry.Key == "user" && entry.Value == "limited" {
+				return &RateLimitResponse{OverallCode: int32(RateLimitResponse_OVER_LIMIT)}, nil
+			}
+		}
+	}
+
+	return &RateLimitResponse{OverallCode: int32(RateLimitResponse_OK)}, nil
+}
+
+func startMockRateLimitService(t *testing.T, service *mockRateLimitService) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	server := grpc.NewServer()
+	registerRateLimitServiceServer(server, service)
+	go server.Serve(ln)
+
+	return ln.Addr().String(), server.Stop
+}
+
+func TestGRPCClient(t *testing.T) {
+	service := &mockRateLimitService{}
+	addr, stop := startMockRateLimitService(t, service)
+	defer stop()
+
+	client, err := NewGRPCClient(addr)
+	if err != nil {
+		t.Fatalf("create client failed: %v", err)
+	}
+
+	tests := []struct {
+		user string
+		want types.LimitStatus
+	}{
+		{"normal", types.OK},
+		{"limited", types.OverLimit},
+	}
+
+	for _, tt := range tests {
+		descriptors := []types.Descriptor{
+			{Entries: []types.DescriptorEntry{{Key: "generic_key", Value: "test"}, {Key: "user", Value: tt.user}}},
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		status, err := client.ShouldRateLimit(ctx, "mosn", descriptors)
+		cancel()
+
+		if status != tt.want || err != nil {
+			t.Errorf("user %s should get %s, got %s, %v", tt.user, tt.want, status, err)
+		}
+	}
+
+	// descriptors are sent to the service
+	request := service.lastRequest()
+	if request.Domain != "mosn" || len(request.Descriptors) != 1 ||
+		len(request.Descriptors[0].Entries) != 2 ||
+		request.Descriptors[0].Entries[1].Value != "limited" {
+		t.Errorf("unexpected request received by service, %v", request)
+	}
+}
+
+func TestGetOrCreateGRPCClient(t *testing.T) {
+	first, err := GetOrCreateGRPCClient("127.0.0.1:8081")
+	if err != nil {
+		t.Fatalf("create client failed: %v", err)
+	}
+
+	if second, _ := GetOrCreateGRPCClient("127.0.0.1:8081"); second != first {
+		t.Error("client of the same address should be shared")
+	}
+	if other, _ := GetOrCreateGRPCClient("127.0.0.1:8082"); other == first {
+		t.Error("client of another address should not be shared")
+	}
+}
+
+func TestGRPCClientError(t *testing.T) {
+	service := &mockRateLimitService{delay: 100 * time.Millisecond}
+	addr, stop := startMockRateLimitService(t, service)
+
+	client, err := NewGRPCClient(addr)
+	if err != nil {
+		t.Fatalf("create client failed: %v", err)
+	}
+
+	descriptors := []types.Descriptor{{Entries: []types.DescriptorEntry{{Key: "user", Value: "normal"}}}}
+
+	// time out
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	status, err := client.ShouldRateLimit(ctx, "mosn", descriptors)
+	cancel()
+
+	if status != types.Error || err == nil {
+		t.Errorf("timed out call should get error, got %s", status)
+	}
+
+	// service unavailable
+	stop()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	status, err = client.ShouldRateLimit(ctx, "mosn", descriptors)
+	cancel()
+
+	if status != types.Error || err == nil {
+		t.Errorf("call to stopped service should get error, got %s", status)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"strconv"
+
+	"github.com/alipay/sofa-mosn/pkg/runtime"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// runtime keys of the percentages of requests checked and limited, a request is checked
+// but not limited if it's not enforced, which could be used to try out new limits
+const (
+	runtimeFilterEnabled        = "ratelimit.http_filter_enabled"
+	runtimeFilterEnforcing      = "ratelimit.http_filter_enforcing"
+	runtimeLocalFilterEnabled   = "ratelimit.local_filter_enabled"
+	runtimeLocalFilterEnforcing = "ratelimit.local_filter_enforcing"
+)
+
+// populateDescriptors generates descriptors by the route's rate limits of the stage
+func populateDescriptors(cb types.StreamReceiverFilterCallbacks, stage uint64, headers map[string]string) []types.Descriptor {
+	route := cb.Route()
+	if route == nil || route.RouteRule() == nil || route.RouteRule().Policy() == nil {
+		return nil
+	}
+
+	policy := route.RouteRule().Policy().RateLimitPolicy()
+	if policy == nil || !policy.Enabled() {
+		return nil
+	}
+
+	var remoteAddr string
+	if conn := cb.Connection(); conn != nil && conn.RemoteAddr() != nil {
+		remoteAddr = conn.RemoteAddr().String()
+	}
+
+	var descriptors []types.Descriptor
+	for _, entry := range policy.GetApplicableRateLimit(stage) {
+		// the route's rate limit could be disabled by runtime
+		if key := entry.DisableKey(); key != "" && !runtime.FeatureEnabled("ratelimit."+key+".http_filter_enabled", 100) {
+			continue
+		}
+
+		descriptors = entry.PopulateDescriptors(route.RouteRule(), descriptors, "", headers, remoteAddr)
+	}
+
+	return descriptors
+}
+
+// sendRateLimitedReply replies the rate limited status, which is converted to
+// the protocol's status by stream layer, e.g. 429 for http
+func sendRateLimitedReply(cb types.StreamReceiverFilterCallbacks, headers map[string]string) {
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+
+	headers[types.HeaderStatus] = strconv.Itoa(types.RateLimitedCode)
+	cb.AppendHeaders(headers, true)
+}
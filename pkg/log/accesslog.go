@@ -46,6 +46,9 @@ func init() {
 		types.LogDownstreamLocalAddress:     DownstreamLocalAddressGetter,
 		types.LogDownstreamRemoteAddress:    DownstreamRemoteAddressGetter,
 		types.LogUpstreamHostSelectedGetter: UpstreamHostSelectedGetter,
+		types.LogUpstreamRequestTimeP50:     UpstreamRequestTimePercentileGetter(0.5),
+		types.LogUpstreamRequestTimeP95:     UpstreamRequestTimePercentileGetter(0.95),
+		types.LogUpstreamRequestTimeP99:     UpstreamRequestTimePercentileGetter(0.99),
 	}
 }
 
@@ -278,9 +281,40 @@ func DurationGetter(info types.RequestInfo) string {
 	return info.Duration().String()
 }
 
-// get request's response flag
+// short names of response flags in access log
+var responseFlagNames = []struct {
+	flag types.ResponseFlag
+	name string
+}{
+	{types.NoHealthyUpstream, "UH"},
+	{types.UpstreamRequestTimeout, "UT"},
+	{types.UpstreamLocalReset, "LR"},
+	{types.UpstreamRemoteReset, "UR"},
+	{types.UpstreamConnectionFailure, "UF"},
+	{types.UpstreamConnectionTermination, "UC"},
+	{types.UpstreamOverflow, "UO"},
+	{types.NoRouteFound, "NR"},
+	{types.DelayInjected, "DI"},
+	{types.FaultInjected, "FI"},
+	{types.RateLimited, "RL"},
+	{types.RateLimitServiceError, "RLSE"},
+}
+
+// get request's response flags, joined by ",", e.g. "UO,RL", or "-" if no flag is set
 func GetResponseFlagGetter(info types.RequestInfo) string {
-	return strconv.FormatBool(info.GetResponseFlag(0))
+	var names []string
+
+	for _, f := range responseFlagNames {
+		if info.GetResponseFlag(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+
+	if len(names) == 0 {
+		return "-"
+	}
+
+	return strings.Join(names, ",")
 }
 
 // get upstream's local address
@@ -314,3 +348,14 @@ func UpstreamHostSelectedGetter(info types.RequestInfo) string {
 	}
 	return "nil"
 }
+
+// get the percentile of the upstream request time in microseconds in the selected host's cluster
+func UpstreamRequestTimePercentileGetter(p float64) func(info types.RequestInfo) string {
+	return func(info types.RequestInfo) string {
+		if info.UpstreamHost() != nil && info.UpstreamHost().ClusterInfo() != nil {
+			histogram := info.UpstreamHost().ClusterInfo().Stats().UpstreamRequestTime
+			return strconv.FormatFloat(histogram.Percentile(p), 'f', 0, 64)
+		}
+		return "nil"
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/alipay/sofa-mosn/pkg/types"
 	"github.com/hashicorp/go-syslog"
@@ -52,7 +53,7 @@ func init() {
 	//use console  as start logger
 	StartLogger = &logger{
 		Output:  "",
-		Level:   DEBUG,
+		level:   uint32(DEBUG),
 		Roller:  DefaultRoller(),
 		fileMux: new(sync.RWMutex),
 	}
@@ -64,8 +65,9 @@ func init() {
 type logger struct {
 	*log.Logger
 
-	Output  string
-	Level   Level
+	Output string
+	// level is accessed atomically, it may be changed at runtime
+	level   uint32
 	Roller  *Roller
 	writer  io.Writer
 	fileMux *sync.RWMutex
@@ -74,7 +76,7 @@ type logger struct {
 func InitDefaultLogger(output string, level Level) error {
 	DefaultLogger = &logger{
 		Output:  output,
-		Level:   level,
+		level:   uint32(level),
 		Roller:  DefaultRoller(),
 		fileMux: new(sync.RWMutex),
 	}
@@ -100,7 +102,7 @@ func ByContext(ctx context.Context) Logger {
 
 func GetLoggerInstance(output string, level Level) (Logger, error) {
 	for _, logger := range loggers {
-		if logger.Output == output && logger.Level == level {
+		if logger.Output == output && logger.GetLevel() == level {
 			return logger, nil
 		}
 	}
@@ -111,7 +113,7 @@ func GetLoggerInstance(output string, level Level) (Logger, error) {
 func NewLogger(output string, level Level) (Logger, error) {
 	logger := &logger{
 		Output:  output,
-		Level:   level,
+		level:   uint32(level),
 		Roller:  DefaultRoller(),
 		fileMux: new(sync.RWMutex),
 	}
@@ -184,38 +186,48 @@ func (l *logger) Printf(format string, args ...interface{}) {
 	l.fileMux.RUnlock()
 }
 
+// GetLevel returns the level of the logger
+func (l *logger) GetLevel() Level {
+	return Level(atomic.LoadUint32(&l.level))
+}
+
+// SetLevel changes the level of the logger, it is safe to call while logging
+func (l *logger) SetLevel(level Level) {
+	atomic.StoreUint32(&l.level, uint32(level))
+}
+
 func (l *logger) Infof(format string, args ...interface{}) {
-	if l.Level >= INFO {
+	if l.GetLevel() >= INFO {
 		l.Printf(InfoPre+format, args...)
 	}
 }
 
 func (l *logger) Debugf(format string, args ...interface{}) {
-	if l.Level >= DEBUG {
+	if l.GetLevel() >= DEBUG {
 		l.Printf(DebugPre+format, args...)
 	}
 }
 
 func (l *logger) Warnf(format string, args ...interface{}) {
-	if l.Level >= WARN {
+	if l.GetLevel() >= WARN {
 		l.Printf(WarnPre+format, args...)
 	}
 }
 
 func (l *logger) Errorf(format string, args ...interface{}) {
-	if l.Level >= ERROR {
+	if l.GetLevel() >= ERROR {
 		l.Printf(ErrorPre+format, args...)
 	}
 }
 
 func (l *logger) Tracef(format string, args ...interface{}) {
-	if l.Level >= TRACE {
+	if l.GetLevel() >= TRACE {
 		l.Printf(TracePre+format, args...)
 	}
 }
 
 func (l *logger) Fatalf(format string, args ...interface{}) {
-	if l.Level >= FATAL {
+	if l.GetLevel() >= FATAL {
 		l.Printf(FatalPre+format, args...)
 	}
 }
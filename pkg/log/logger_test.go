@@ -0,0 +1,47 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLoggerSetLevel(t *testing.T) {
+	created, err := NewLogger("", INFO)
+	if err != nil {
+		t.Fatalf("create logger failed: %v", err)
+	}
+	l := created.(*logger)
+
+	// level is changed while logging
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			l.Debugf("set level test %d", i)
+		}
+	}()
+	l.SetLevel(ERROR)
+	wg.Wait()
+
+	if level := l.GetLevel(); level != ERROR {
+		t.Errorf("expected level ERROR, got %d", level)
+	}
+}
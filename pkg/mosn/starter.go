@@ -24,12 +24,15 @@ import (
 	"strconv"
 	"sync"
 
+	"github.com/alipay/sofa-mosn/pkg/admin"
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/config"
 	"github.com/alipay/sofa-mosn/pkg/filter"
 	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
 	"github.com/alipay/sofa-mosn/pkg/server"
 	"github.com/alipay/sofa-mosn/pkg/server/config/proxy"
+	"github.com/alipay/sofa-mosn/pkg/trace"
 	"github.com/alipay/sofa-mosn/pkg/types"
 	"github.com/alipay/sofa-mosn/pkg/upstream/cluster"
 	"github.com/alipay/sofa-mosn/pkg/xds"
@@ -37,6 +40,8 @@ import (
 
 type Mosn struct {
 	servers []server.Server
+	admin   *admin.Server
+	tracing bool
 }
 
 func NewMosn(c *config.MOSNConfig) *Mosn {
@@ -67,6 +72,10 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 	//get inherit fds
 	inheritListeners := getInheritListeners()
 
+	// stream filters of the listeners updated by LDS
+	config.SetStreamFilterFactoryCreator(filter.NewStreamFilterChainFactory)
+
+	var cm types.ClusterManager
 	for _, serverConfig := range c.Servers {
 
 		//1. server config prepare
@@ -79,7 +88,7 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 		var srv server.Server
 		if mode == config.Xds {
 			cmf := &clusterManagerFilter{}
-			cm := cluster.NewClusterManager(nil, nil, nil, true, false)
+			cm = cluster.NewClusterManager(nil, nil, nil, true, false)
 			srv = server.NewServer(sc, cmf, cm)
 
 		} else {
@@ -93,7 +102,7 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 			clusters, clusterMap = config.ParseClusterConfig(c.ClusterManager.Clusters)
 
 			//create cluster manager
-			cm := cluster.NewClusterManager(nil, clusters, clusterMap, c.ClusterManager.AutoDiscovery, c.ClusterManager.RegistryUseHealthCheck)
+			cm = cluster.NewClusterManager(nil, clusters, clusterMap, c.ClusterManager.AutoDiscovery, c.ClusterManager.RegistryUseHealthCheck)
 			//initialize server instance
 			srv = server.NewServer(sc, cmf, cm)
 
@@ -122,9 +131,37 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 		}
 		m.servers = append(m.servers, srv)
 	}
+	//zone aware routing prefers the upstream hosts in the zone of the application
+	config.RegisterConfigParsedListener(config.ParseCallbackKeyServiceRgtInfo, func(data interface{}, endParsing bool) error {
+		if info, ok := data.(v2.ServiceRegistryInfo); ok {
+			cluster.SetLocalZone(info.ServiceAppInfo.Zone)
+		}
+		return nil
+	})
+
 	//parse service registry info
 	config.ParseServiceRegistry(c.ServiceRegistry)
 
+	//tracing
+	if c.Tracing.Enable {
+		if err := trace.Init(c.Tracing.Driver, c.Tracing.Config); err != nil {
+			log.StartLogger.Fatalln("init tracing driver failed:", err)
+		}
+		m.tracing = true
+	}
+
+	//runtime
+	if c.Runtime.RootDir != "" {
+		if err := runtime.Init(c.Runtime.RootDir, c.Runtime.RefreshInterval.Duration); err != nil {
+			log.StartLogger.Fatalln("load runtime failed:", err)
+		}
+	}
+
+	//admin api
+	if c.Admin.Address != "" {
+		m.admin = admin.NewServer(c.Admin.Address, cm)
+	}
+
 	//close legacy listeners
 	for _, ln := range inheritListeners {
 		if !ln.Remain {
@@ -139,11 +176,28 @@ func (m *Mosn) Start() {
 	for _, srv := range m.servers {
 		go srv.Start()
 	}
+
+	if m.admin != nil {
+		if err := m.admin.Start(); err != nil {
+			log.DefaultLogger.Errorf("start admin server failed: %v", err)
+		}
+	}
 }
 func (m *Mosn) Close() {
 	for _, srv := range m.servers {
 		srv.Close()
 	}
+
+	if m.admin != nil {
+		m.admin.Close()
+	}
+
+	runtime.Stop()
+
+	// flush the buffered spans
+	if m.tracing {
+		trace.Disable()
+	}
 }
 
 func Start(c *config.MOSNConfig, serviceCluster string, serviceNode string) {
@@ -152,10 +206,13 @@ func Start(c *config.MOSNConfig, serviceCluster string, serviceNode string) {
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 
-	go func() {
-		// pprof server
-		http.ListenAndServe("0.0.0.0:9090", nil)
-	}()
+	// pprof is served by admin api if admin address is set
+	if c.Admin.Address == "" {
+		go func() {
+			// pprof server
+			http.ListenAndServe("0.0.0.0:9090", nil)
+		}()
+	}
 
 	Mosn := NewMosn(c)
 	Mosn.Start()
@@ -175,8 +232,13 @@ func GetNetworkFilter(c *v2.FilterChain) types.NetworkFilterChainFactory {
 		log.StartLogger.Fatalln("Currently, only Proxy Network Filter Needed!")
 	}
 
+	proxyConfig, err := config.ParseProxyFilterJSON(&c.Filters[0])
+	if err != nil {
+		log.StartLogger.Fatalln("parse proxy network filter failed: ", err)
+	}
+
 	return &proxy.GenericProxyFilterConfigFactory{
-		Proxy: config.ParseProxyFilterJSON(&c.Filters[0]),
+		Proxy: proxyConfig,
 	}
 }
 
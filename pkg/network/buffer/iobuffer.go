@@ -369,7 +369,7 @@ func (b *IoBuffer) Clone() types.IoBuffer {
 	copied := make([]byte, b.Len())
 	copy(copied, b.Bytes())
 
-	return NewIoBuffer(b.Len())
+	return NewIoBufferBytes(copied)
 }
 
 func makeSlice(n int) []byte {
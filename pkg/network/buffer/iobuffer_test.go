@@ -49,3 +49,19 @@ func Test_read(t *testing.T) {
 		t.Fatal("err read content")
 	}
 }
+
+func Test_clone(t *testing.T) {
+	str := "clone_test"
+	buffer := NewIoBufferString(str)
+
+	copied := buffer.Clone()
+	if copied.String() != str {
+		t.Fatalf("expected %s cloned, got %s", str, copied.String())
+	}
+
+	// the copy is not changed with the buffer
+	buffer.Drain(buffer.Len())
+	if copied.String() != str {
+		t.Fatal("err clone content")
+	}
+}
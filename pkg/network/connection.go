@@ -19,6 +19,7 @@ package network
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net"
 	"runtime"
@@ -36,6 +37,14 @@ import (
 const (
 	ConnectionCloseDebugMsg = "Close connection %d, event %s, type %s, data read %d, data write %d"
 	DefaultBufferCapacity   = 1 << 17
+
+	// interval to check whether the connection is ready to be transferred
+	transferCheckInterval = 100 * time.Millisecond
+)
+
+var (
+	ErrTransferNotStarted = errors.New("connection io loops not started")
+	ErrTransferClosed     = errors.New("connection closed before transferring")
 )
 
 var idCounter uint64
@@ -78,8 +87,16 @@ type connection struct {
 	lastBytesSizeRead  int64
 	lastWriteSizeWrite int64
 
-	closed    uint32
-	startOnce sync.Once
+	closed       uint32
+	startOnce    sync.Once
+	readLoopDone chan struct{}
+
+	// hand over the connection to another process
+	transferring  uint32
+	transferReady func() bool
+	transferFunc  func(rawc net.Conn, unread []byte) error
+	// result of transferring, sent by the read loop
+	transferDone chan error
 
 	logger log.Logger
 }
@@ -96,6 +113,7 @@ func NewServerConnection(rawc net.Conn, stopChan chan struct{}, logger log.Logge
 		readEnabled:      true,
 		readEnabledChan:  make(chan bool, 1),
 		internalStopChan: make(chan struct{}),
+		readLoopDone:     make(chan struct{}),
 		writeBufferChan:  make(chan bool, 1),
 		readerBufferPool: readerBufferPool,
 		writeBufferPool:  writeBufferPool,
@@ -114,6 +132,19 @@ func NewServerConnection(rawc net.Conn, stopChan chan struct{}, logger log.Logge
 	return conn
 }
 
+// NewTransferredServerConnection creates a server connection handed over by another process,
+// the unread bytes are dispatched to the read filters on start
+func NewTransferredServerConnection(rawc net.Conn, unread []byte, stopChan chan struct{}, logger log.Logger) types.Connection {
+	conn := NewServerConnection(rawc, stopChan, logger).(*connection)
+
+	if len(unread) > 0 {
+		conn.readBuffer = conn.readerBufferPool.Take(rawc)
+		conn.readBuffer.Br.Write(unread)
+	}
+
+	return conn
+}
+
 // watermark listener
 func (c *connection) OnHighWatermark() {
 	c.aboveHighWatermark = true
@@ -134,6 +165,8 @@ func (c *connection) Start(lctx context.Context) {
 		c.internalLoopStarted = true
 
 		go func() {
+			defer close(c.readLoopDone)
+
 			defer func() {
 				if p := recover(); p != nil {
 					c.logger.Errorf("panic %v", p)
@@ -144,6 +177,11 @@ func (c *connection) Start(lctx context.Context) {
 				}
 			}()
 
+			// dispatch the bytes handed over with the connection
+			if c.readBuffer != nil && c.readBuffer.Br.Len() > 0 {
+				c.onRead(int64(c.readBuffer.Br.Len()))
+			}
+
 			c.startReadLoop()
 		}()
 
@@ -172,6 +210,18 @@ func (c *connection) startReadLoop() {
 		default:
 		}
 
+		if atomic.LoadUint32(&c.transferring) == 1 {
+			err := c.doTransfer()
+			atomic.StoreUint32(&c.transferring, 0)
+			c.transferDone <- err
+
+			// the connection goes on if it's not transferred
+			if err == nil || atomic.LoadUint32(&c.closed) == 1 {
+				return
+			}
+			continue
+		}
+
 		select {
 		case <-c.stopChan:
 			return
@@ -183,6 +233,14 @@ func (c *connection) startReadLoop() {
 				err := c.doRead()
 
 				if err != nil {
+					// woken up by the read deadline to transfer the connection, the deadline may be
+					// left by a transferring failed, which is cleared
+					if te, ok := err.(net.Error); ok && te.Timeout() {
+						if atomic.LoadUint32(&c.transferring) == 0 {
+							c.rawConnection.SetReadDeadline(time.Time{})
+						}
+						continue
+					}
 
 					if err == io.EOF {
 						c.Close(types.NoFlush, types.RemoteClose)
@@ -207,6 +265,102 @@ func (c *connection) startReadLoop() {
 	}
 }
 
+// Transfer must be called once, it blocks until the read loop hands over the connection or exits.
+// The connection is closed only if it's transferred, it goes on serving if transferring fails.
+func (c *connection) Transfer(ready func() bool, transfer func(rawc net.Conn, unread []byte) error) error {
+	if !c.internalLoopStarted {
+		return ErrTransferNotStarted
+	}
+
+	c.transferReady = ready
+	c.transferFunc = transfer
+	c.transferDone = make(chan error, 1)
+	atomic.StoreUint32(&c.transferring, 1)
+
+	for {
+		// wake up the read loop blocked on reading, the deadline may be reset by a reading in progress,
+		// so set it until the loop handles the transferring
+		c.rawConnection.SetReadDeadline(time.Now())
+
+		select {
+		case err := <-c.transferDone:
+			return err
+		case <-c.readLoopDone:
+			// the result is sent before the loop exits
+			select {
+			case err := <-c.transferDone:
+				return err
+			default:
+				return ErrTransferClosed
+			}
+		case <-time.After(transferCheckInterval):
+		}
+	}
+}
+
+// doTransfer is called in the read loop, new requests are left in the socket for the process
+// the connection is transferred to. The connection can't be closed while transferring,
+// and it's restored if the transfer function fails.
+func (c *connection) doTransfer() error {
+	for !c.transferReady() {
+		select {
+		case <-c.stopChan:
+			return ErrTransferClosed
+		case <-c.internalStopChan:
+			return ErrTransferClosed
+		case <-time.After(transferCheckInterval):
+		}
+	}
+
+	if !atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		return ErrTransferClosed
+	}
+
+	// flush the responses written
+	for c.writeBufLen() > 0 {
+		if _, err := c.doWrite(); err != nil {
+			if te, ok := err.(net.Error); !(ok && te.Timeout()) {
+				break
+			}
+		}
+	}
+
+	var unread []byte
+	if c.readBuffer != nil {
+		unread = c.readBuffer.Br.Bytes()
+	}
+
+	if err := c.transferFunc(c.rawConnection, unread); err != nil {
+		atomic.StoreUint32(&c.closed, 0)
+		c.logger.Errorf("Transfer connection %d failed, keep serving it: %v", c.id, err)
+
+		return err
+	}
+
+	// stop the write loop
+	close(c.internalStopChan)
+
+	if c.readBuffer != nil {
+		c.readerBufferPool.Give(c.readBuffer)
+		c.readBuffer = nil
+	}
+
+	// the socket is kept open by the process it is transferred to, so never shutdown it
+	c.rawConnection.Close()
+
+	c.logger.Debugf("Transfer connection %d, data read %d, data write %d", c.id,
+		c.stats.ReadTotal.Count(), c.stats.WriteTotal.Count())
+
+	c.updateReadBufStats(0, 0)
+	c.updateWriteBuffStats(0, 0)
+
+	for _, cb := range c.connCallbacks {
+		cb.OnEvent(types.LocalClose)
+	}
+
+	return nil
+}
+
 func (c *connection) doRead() (err error) {
 	if c.readBuffer == nil {
 		c.readBuffer = c.readerBufferPool.Take(c.rawConnection)
@@ -614,6 +768,7 @@ func NewClientConnection(sourceAddr net.Addr, tlsMng types.TLSContextManager, re
 			readEnabled:      true,
 			readEnabledChan:  make(chan bool, 1),
 			internalStopChan: make(chan struct{}),
+			readLoopDone:     make(chan struct{}),
 			writeBufferChan:  make(chan bool, 1),
 			readerBufferPool: readerBufferPool,
 			writeBufferPool:  writeBufferPool,
@@ -18,11 +18,14 @@
 package network
 
 import (
+	"sync"
+
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
 type filterManager struct {
 	upstreamFilters   []*activeReadFilter
+	upstreamMux       sync.RWMutex
 	downstreamFilters []types.WriteFilter
 	conn              types.Connection
 	host              types.HostInfo
@@ -42,8 +45,12 @@ func (fm *filterManager) AddReadFilter(rf types.ReadFilter) {
 		filterManager: fm,
 	}
 
-	rf.InitializeReadFilterCallbacks(newArf)
+	// added before initializing, HTTP/1 and HTTP/2 proxies serve the connection until it is closed on initializing
+	fm.upstreamMux.Lock()
 	fm.upstreamFilters = append(fm.upstreamFilters, newArf)
+	fm.upstreamMux.Unlock()
+
+	rf.InitializeReadFilterCallbacks(newArf)
 }
 
 func (fm *filterManager) AddWriteFilter(wf types.WriteFilter) {
@@ -51,6 +58,9 @@ func (fm *filterManager) AddWriteFilter(wf types.WriteFilter) {
 }
 
 func (fm *filterManager) ListReadFilter() []types.ReadFilter {
+	fm.upstreamMux.RLock()
+	defer fm.upstreamMux.RUnlock()
+
 	var readFilters []types.ReadFilter
 
 	for _, uf := range fm.upstreamFilters {
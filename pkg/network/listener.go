@@ -21,6 +21,7 @@ import (
 	"context"
 	"net"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
@@ -41,6 +42,7 @@ type listener struct {
 	rawl                                  *net.TCPListener
 	logger                                log.Logger
 	tlsMng                                types.TLSContextManager
+	mux                                   sync.RWMutex
 }
 
 func NewListener(lc *v2.ListenerConfig, logger log.Logger) types.Listener {
@@ -65,6 +67,18 @@ func NewListener(lc *v2.ListenerConfig, logger log.Logger) types.Listener {
 	return l
 }
 
+func (l *listener) Update(lc *v2.ListenerConfig) {
+	tlsMng := tls.NewTLSServerContextManager(lc.FilterChains, l, l.logger)
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.listenerTag = lc.ListenerTag
+	l.perConnBufferLimitBytes = lc.PerConnBufferLimitBytes
+	l.handOffRestoredDestinationConnections = lc.HandOffRestoredDestinationConnections
+	l.tlsMng = tlsMng
+}
+
 func (l *listener) Name() string {
 	return l.name
 }
@@ -77,7 +91,7 @@ func (l *listener) Start(lctx context.Context) {
 
 	if l.bindToPort {
 		//call listen if not inherit
-		if l.rawl == nil {
+		if l.rawListener() == nil {
 			if err := l.listen(lctx); err != nil {
 				// TODO: notify listener callbacks
 				log.StartLogger.Fatalln(l.name, " listen failed, ", err)
@@ -111,15 +125,18 @@ func (l *listener) Start(lctx context.Context) {
 }
 
 func (l *listener) Stop() {
-	l.rawl.SetDeadline(time.Now())
+	l.rawListener().SetDeadline(time.Now())
 }
 
 func (l *listener) ListenerTag() uint64 {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
 	return l.listenerTag
 }
 
 func (l *listener) ListenerFD() (uintptr, error) {
-	file, err := l.rawl.File()
+	file, err := l.rawListener().File()
 	if err != nil {
 		l.logger.Errorf(" listener %s fd not found : %v", l.name, err)
 		return 0, err
@@ -129,6 +146,9 @@ func (l *listener) ListenerFD() (uintptr, error) {
 }
 
 func (l *listener) PerConnBufferLimitBytes() uint32 {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
 	return l.perConnBufferLimitBytes
 }
 
@@ -138,7 +158,22 @@ func (l *listener) SetListenerCallbacks(cb types.ListenerEventListener) {
 
 func (l *listener) Close(lctx context.Context) error {
 	l.cb.OnClose()
-	return l.rawl.Close()
+
+	rawl := l.rawListener()
+
+	// not started
+	if rawl == nil {
+		return nil
+	}
+
+	return rawl.Close()
+}
+
+func (l *listener) rawListener() *net.TCPListener {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	return l.rawl
 }
 
 func (l *listener) listen(lctx context.Context) error {
@@ -149,18 +184,25 @@ func (l *listener) listen(lctx context.Context) error {
 		return err
 	}
 
+	l.mux.Lock()
 	l.rawl = rawl
+	l.mux.Unlock()
 
 	return nil
 }
 
 func (l *listener) accept(lctx context.Context) error {
-	rawc, err := l.rawl.Accept()
+	rawc, err := l.rawListener().Accept()
 
 	if err != nil {
 		return err
 	}
 
+	l.mux.RLock()
+	tlsMng := l.tlsMng
+	handOffRestoredDestinationConnections := l.handOffRestoredDestinationConnections
+	l.mux.RUnlock()
+
 	// TODO: use thread pool
 	go func() {
 		defer func() {
@@ -171,11 +213,11 @@ func (l *listener) accept(lctx context.Context) error {
 			}
 		}()
 
-		if l.tlsMng != nil && l.tlsMng.Enabled() {
-			rawc = l.tlsMng.Conn(rawc)
+		if tlsMng != nil && tlsMng.Enabled() {
+			rawc = tlsMng.Conn(rawc)
 		}
 
-		l.cb.OnAccept(rawc, l.handOffRestoredDestinationConnections, nil)
+		l.cb.OnAccept(rawc, handOffRestoredDestinationConnections, nil)
 	}()
 
 	return nil
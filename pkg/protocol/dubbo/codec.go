@@ -0,0 +1,181 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/alipay/sofa-mosn/pkg/protocol/xprotocol/subprotocol"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+func init() {
+	subprotocol.Register(SubProtocol, &codecFactory{})
+}
+
+type codecFactory struct{}
+
+func (f *codecFactory) CreateSubProtocolCodec(context context.Context) types.Multiplexing {
+	return &codec{}
+}
+
+// types.Multiplexing
+// types.Tracing
+// types.RouteHeaders
+// types.Heartbeat
+type codec struct{}
+
+func (c *codec) SplitFrame(data []byte) ([][]byte, error) {
+	var frames [][]byte
+
+	for len(data) >= 2 {
+		if data[0] != MagicHigh || data[1] != MagicLow {
+			// the stream is out of sync, nothing after it can be decoded
+			return frames, ErrInvalidMagic
+		}
+		if len(data) < HeaderLen {
+			break
+		}
+
+		bodyLen := binary.BigEndian.Uint32(data[bodyLenIdx:])
+		if bodyLen > MaxBodyLen {
+			return frames, ErrFrameTooLarge
+		}
+
+		frameLen := HeaderLen + int(bodyLen)
+		if len(data) < frameLen {
+			break
+		}
+
+		frames = append(frames, data[:frameLen])
+		data = data[frameLen:]
+	}
+
+	return frames, nil
+}
+
+func (c *codec) GetStreamID(data []byte) string {
+	return strconv.FormatUint(RequestID(data), 10)
+}
+
+func (c *codec) SetStreamID(data []byte, streamID string) []byte {
+	id, err := strconv.ParseUint(streamID, 10, 64)
+	if err != nil {
+		return data
+	}
+
+	binary.BigEndian.PutUint64(data[requestIDIdx:], id)
+	return data
+}
+
+func (c *codec) GetServiceName(data []byte) string {
+	return c.GetRouteHeaders(data)[subprotocol.HeaderServiceName]
+}
+
+func (c *codec) GetMethodName(data []byte) string {
+	return c.GetRouteHeaders(data)[subprotocol.HeaderMethodName]
+}
+
+// GetRouteHeaders decodes dubbo version, service interface, service version and method from the request body.
+// Only hessian2 serialized requests are decoded.
+func (c *codec) GetRouteHeaders(data []byte) map[string]string {
+	if len(data) < HeaderLen {
+		return nil
+	}
+
+	flag := data[flagIdx]
+	if flag&FlagRequest == 0 || flag&FlagEvent != 0 || flag&SerializationMask != SerializationHessian2 {
+		return nil
+	}
+
+	keys := []string{HeaderDubboVersion, subprotocol.HeaderServiceName, HeaderServiceVersion, subprotocol.HeaderMethodName}
+	headers := make(map[string]string, len(keys))
+
+	body := data[HeaderLen:]
+	for _, key := range keys {
+		value, n, err := decodeHessianString(body)
+		if err != nil {
+			return headers
+		}
+		if value != "" {
+			headers[key] = value
+		}
+		body = body[n:]
+	}
+
+	return headers
+}
+
+func (c *codec) IsOneWayRequest(data []byte) bool {
+	return len(data) >= HeaderLen && data[flagIdx]&FlagRequest != 0 && data[flagIdx]&FlagTwoWay == 0
+}
+
+func (c *codec) IsHeartbeatRequest(data []byte) bool {
+	return IsHeartbeat(data) && data[flagIdx]&FlagRequest != 0
+}
+
+func (c *codec) HeartbeatResponse(data []byte) []byte {
+	if data[flagIdx]&FlagTwoWay == 0 {
+		return nil
+	}
+
+	return newFrame(data[flagIdx]&SerializationMask|FlagEvent, ResponseStatusOK, RequestID(data), []byte{'N'})
+}
+
+// NewHeartbeat returns a heartbeat request frame
+func NewHeartbeat(requestID uint64) []byte {
+	return newFrame(FlagRequest|FlagTwoWay|FlagEvent|SerializationHessian2, 0, requestID, []byte{'N'})
+}
+
+// IsHeartbeat returns whether the frame is a heartbeat request or response
+func IsHeartbeat(data []byte) bool {
+	return len(data) >= HeaderLen && data[flagIdx]&FlagEvent != 0
+}
+
+// RequestID returns the request id of the frame
+func RequestID(data []byte) uint64 {
+	if len(data) < HeaderLen {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(data[requestIDIdx:])
+}
+
+// ResponseStatus returns the status of a response frame
+func ResponseStatus(data []byte) byte {
+	if len(data) < HeaderLen {
+		return 0
+	}
+
+	return data[statusIdx]
+}
+
+func newFrame(flag byte, status byte, requestID uint64, body []byte) []byte {
+	frame := make([]byte, HeaderLen+len(body))
+	frame[0] = MagicHigh
+	frame[1] = MagicLow
+	frame[flagIdx] = flag
+	frame[statusIdx] = status
+	binary.BigEndian.PutUint64(frame[requestIDIdx:], requestID)
+	binary.BigEndian.PutUint32(frame[bodyLenIdx:], uint32(len(body)))
+	copy(frame[HeaderLen:], body)
+
+	return frame
+}
@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/protocol/xprotocol/subprotocol"
+)
+
+func encodeHessianString(s string) []byte {
+	chars := len([]rune(s))
+	switch {
+	case chars <= 0x1f:
+		return append([]byte{byte(chars)}, s...)
+	case chars <= 0x3ff:
+		return append([]byte{byte(0x30 + chars>>8), byte(chars)}, s...)
+	default:
+		return append([]byte{'S', byte(chars >> 8), byte(chars)}, s...)
+	}
+}
+
+func newRequest(id uint64, service, version, method string) []byte {
+	var body []byte
+	for _, s := range []string{"2.0.2", service, version, method, "Ljava/lang/String;"} {
+		body = append(body, encodeHessianString(s)...)
+	}
+	return newFrame(FlagRequest|FlagTwoWay|SerializationHessian2, 0, id, body)
+}
+
+func Test_codec_SplitFrame(t *testing.T) {
+	c := &codec{}
+	first := newRequest(1, "com.test.Service", "1.0", "sayHello")
+	second := newRequest(2, "com.test.Service", "1.0", "sayBye")
+
+	data := append(append([]byte{}, first...), second[:HeaderLen+3]...)
+	frames, err := c.SplitFrame(data)
+	if err != nil || len(frames) != 1 || !bytes.Equal(frames[0], first) {
+		t.Fatalf("expected only the complete frame, got %d frames, error %v", len(frames), err)
+	}
+
+	frames, err = c.SplitFrame(append(append([]byte{}, first...), second...))
+	if err != nil || len(frames) != 2 || !bytes.Equal(frames[1], second) {
+		t.Fatalf("expected two frames, got %d, error %v", len(frames), err)
+	}
+}
+
+func Test_codec_SplitFrameInvalidMagic(t *testing.T) {
+	c := &codec{}
+	first := newRequest(1, "com.test.Service", "1.0", "sayHello")
+
+	frames, err := c.SplitFrame([]byte(strings.Repeat("x", 32)))
+	if err != ErrInvalidMagic || len(frames) != 0 {
+		t.Errorf("expected invalid magic error without frames, got %d frames, error %v", len(frames), err)
+	}
+
+	// a short garbage tail is detected before a full header is read
+	frames, err = c.SplitFrame(append(append([]byte{}, first...), 'x', 'x'))
+	if err != ErrInvalidMagic || len(frames) != 1 || !bytes.Equal(frames[0], first) {
+		t.Errorf("expected the valid frame and invalid magic error, got %d frames, error %v", len(frames), err)
+	}
+}
+
+func Test_codec_SplitFrameTooLarge(t *testing.T) {
+	c := &codec{}
+	header := newFrame(FlagRequest|FlagTwoWay|SerializationHessian2, 0, 1, nil)
+	binary.BigEndian.PutUint32(header[bodyLenIdx:], MaxBodyLen+1)
+
+	frames, err := c.SplitFrame(header)
+	if err != ErrFrameTooLarge || len(frames) != 0 {
+		t.Errorf("expected frame too large error without frames, got %d frames, error %v", len(frames), err)
+	}
+
+	binary.BigEndian.PutUint32(header[bodyLenIdx:], MaxBodyLen)
+	if frames, err := c.SplitFrame(header); err != nil || len(frames) != 0 {
+		t.Errorf("expected a frame of max length to wait for more data, got %d frames, error %v", len(frames), err)
+	}
+}
+
+func Test_codec_StreamID(t *testing.T) {
+	c := &codec{}
+	frame := newRequest(1<<40, "com.test.Service", "", "sayHello")
+
+	if id := c.GetStreamID(frame); id != "1099511627776" {
+		t.Errorf("expected request id 1099511627776, got %s", id)
+	}
+
+	frame = c.SetStreamID(frame, "42")
+	if id := c.GetStreamID(frame); id != "42" {
+		t.Errorf("expected request id 42 after rewrite, got %s", id)
+	}
+}
+
+func Test_codec_GetRouteHeaders(t *testing.T) {
+	c := &codec{}
+	service := "com.test." + strings.Repeat("a", 40)
+	headers := c.GetRouteHeaders(newRequest(1, service, "1.0.0", "sayHello"))
+
+	expected := map[string]string{
+		HeaderDubboVersion:            "2.0.2",
+		subprotocol.HeaderServiceName: service,
+		HeaderServiceVersion:          "1.0.0",
+		subprotocol.HeaderMethodName:  "sayHello",
+	}
+	for k, v := range expected {
+		if headers[k] != v {
+			t.Errorf("expected header %s = %s, got %s", k, v, headers[k])
+		}
+	}
+
+	// responses and heartbeats carry no route headers
+	if headers := c.GetRouteHeaders(NewHeartbeat(1)); len(headers) != 0 {
+		t.Errorf("expected no headers for heartbeat, got %v", headers)
+	}
+}
+
+func Test_decodeHessianString(t *testing.T) {
+	// 'R' chunk followed by the final 'S' chunk, with multi-byte characters
+	data := []byte{'R', 0, 2}
+	data = append(data, "中a"...)
+	data = append(data, 'S', 0, 1)
+	data = append(data, "b"...)
+	data = append(data, 'N')
+
+	s, n, err := decodeHessianString(data)
+	if err != nil || s != "中ab" || n != len(data)-1 {
+		t.Fatalf("unexpected decode result %q %d %v", s, n, err)
+	}
+
+	if _, _, err := decodeHessianString([]byte{0x05, 'a'}); err == nil {
+		t.Error("expected error for truncated string")
+	}
+}
+
+func Test_codec_Heartbeat(t *testing.T) {
+	c := &codec{}
+	hb := NewHeartbeat(7)
+
+	if !c.IsHeartbeatRequest(hb) {
+		t.Fatal("expected heartbeat request")
+	}
+	if c.IsHeartbeatRequest(newRequest(7, "com.test.Service", "", "sayHello")) {
+		t.Error("expected normal request not to be heartbeat")
+	}
+
+	resp := c.HeartbeatResponse(hb)
+	if c.IsHeartbeatRequest(resp) || !IsHeartbeat(resp) {
+		t.Error("expected heartbeat response")
+	}
+	if RequestID(resp) != 7 || ResponseStatus(resp) != ResponseStatusOK {
+		t.Errorf("unexpected heartbeat response id %d status %d", RequestID(resp), ResponseStatus(resp))
+	}
+
+	// one-way heartbeat expects no response
+	hb[flagIdx] &^= FlagTwoWay
+	if resp := c.HeartbeatResponse(hb); resp != nil {
+		t.Error("expected no response for one-way heartbeat")
+	}
+}
+
+func Test_codec_OneWay(t *testing.T) {
+	c := &codec{}
+	req := newRequest(7, "com.test.Service", "", "sayHello")
+
+	if c.IsOneWayRequest(req) {
+		t.Error("expected two-way request")
+	}
+
+	req[flagIdx] &^= FlagTwoWay
+	if !c.IsOneWayRequest(req) {
+		t.Error("expected one-way request")
+	}
+
+	// responses are never one-way requests
+	resp := newFrame(SerializationHessian2, ResponseStatusOK, 7, nil)
+	if c.IsOneWayRequest(resp) {
+		t.Error("expected response not to be one-way request")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errHessianString = errors.New("invalid hessian2 string")
+
+// decodeHessianString decodes a hessian2 string at the beginning of data,
+// it returns the string and the number of bytes read.
+// Only strings are needed to route dubbo requests, so other types are not supported.
+func decodeHessianString(data []byte) (string, int, error) {
+	if len(data) == 0 {
+		return "", 0, errHessianString
+	}
+
+	var str []byte
+	offset := 0
+	for {
+		if offset >= len(data) {
+			return "", 0, errHessianString
+		}
+
+		tag := data[offset]
+		final := true
+		var length int
+
+		switch {
+		case tag == 'N':
+			// null
+			return "", offset + 1, nil
+		case tag <= 0x1f:
+			length = int(tag)
+			offset++
+		case tag >= 0x30 && tag <= 0x33:
+			if offset+2 > len(data) {
+				return "", 0, errHessianString
+			}
+			length = int(tag-0x30)<<8 | int(data[offset+1])
+			offset += 2
+		case tag == 'S' || tag == 'R':
+			if offset+3 > len(data) {
+				return "", 0, errHessianString
+			}
+			length = int(binary.BigEndian.Uint16(data[offset+1:]))
+			final = tag == 'S'
+			offset += 3
+		default:
+			return "", 0, errHessianString
+		}
+
+		n, ok := utf8Len(data[offset:], length)
+		if !ok {
+			return "", 0, errHessianString
+		}
+		str = append(str, data[offset:offset+n]...)
+		offset += n
+
+		if final {
+			return string(str), offset, nil
+		}
+	}
+}
+
+// utf8Len returns the byte length of chars utf-16 chars, which is how hessian counts string length
+func utf8Len(data []byte, chars int) (int, bool) {
+	n := 0
+	for chars > 0 {
+		if n >= len(data) {
+			return 0, false
+		}
+
+		c := data[n]
+		switch {
+		case c < 0x80:
+			n++
+		case c < 0xe0:
+			n += 2
+		case c < 0xf0:
+			n += 3
+		default:
+			// supplementary characters are surrogate pairs in java
+			n += 4
+			chars--
+		}
+		chars--
+	}
+
+	if n > len(data) {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"errors"
+
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// SubProtocol is the xprotocol sub protocol name of dubbo
+const SubProtocol types.SubProtocol = "dubbo"
+
+// dubbo 2.x frame header:
+// | magic(2) | flag(1) | status(1) | request id(8) | body length(4) |
+const (
+	HeaderLen = 16
+
+	MagicHigh byte = 0xda
+	MagicLow  byte = 0xbb
+
+	FlagRequest       byte = 0x80
+	FlagTwoWay        byte = 0x40
+	FlagEvent         byte = 0x20
+	SerializationMask byte = 0x1f
+
+	SerializationHessian2 byte = 2
+
+	// MaxBodyLen is the default payload limit of dubbo, 8MB
+	MaxBodyLen = 8 * 1024 * 1024
+)
+
+// frame errors, the connection is closed on them
+var (
+	ErrInvalidMagic  = errors.New("dubbo: invalid magic")
+	ErrFrameTooLarge = errors.New("dubbo: frame too large")
+)
+
+// response status
+const (
+	ResponseStatusOK                byte = 20
+	ResponseStatusClientTimeout     byte = 30
+	ResponseStatusServerTimeout     byte = 31
+	ResponseStatusBadRequest        byte = 40
+	ResponseStatusBadResponse       byte = 50
+	ResponseStatusServiceNotFound   byte = 60
+	ResponseStatusServiceError      byte = 70
+	ResponseStatusServerError       byte = 80
+	ResponseStatusClientError       byte = 90
+	ResponseStatusThreadpoolExhaust byte = 100
+)
+
+// route headers decoded from request body
+const (
+	HeaderDubboVersion   = "dubbo"
+	HeaderServiceVersion = "version"
+)
+
+const (
+	flagIdx      = 2
+	statusIdx    = 3
+	requestIDIdx = 4
+	bodyLenIdx   = 12
+)
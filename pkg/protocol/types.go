@@ -24,6 +24,7 @@ const (
 	HTTP1     types.Protocol = "Http1"
 	HTTP2     types.Protocol = "Http2"
 	Xprotocol types.Protocol = "X"
+	Dubbo     types.Protocol = "Dubbo"
 )
 
 const (
@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package subprotocol
+
+import (
+	"context"
+
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// Route headers set from frames of sub protocols implementing types.Tracing
+const (
+	HeaderServiceName = types.SofaRouteMatchKey
+	HeaderMethodName  = "method"
+)
+
+// CodecFactory creates the codec of a sub protocol
+type CodecFactory interface {
+	CreateSubProtocolCodec(context context.Context) types.Multiplexing
+}
+
+var subProtocolFactories map[types.SubProtocol]CodecFactory
+
+func init() {
+	subProtocolFactories = make(map[types.SubProtocol]CodecFactory)
+}
+
+// Register registers the codec factory of a sub protocol, it is usually called in init
+func Register(prot types.SubProtocol, factory CodecFactory) {
+	subProtocolFactories[prot] = factory
+}
+
+// CreateSubProtocolCodec returns a codec of the sub protocol, or nil if the sub protocol is not registered
+func CreateSubProtocolCodec(context context.Context, prot types.SubProtocol) types.Multiplexing {
+	if factory, ok := subProtocolFactories[prot]; ok {
+		return factory.CreateSubProtocolCodec(context)
+	}
+
+	return nil
+}
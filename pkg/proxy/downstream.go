@@ -20,7 +20,9 @@ package proxy
 import (
 	"container/list"
 	"fmt"
+	"math/rand"
 	"net"
+	"net/http"
 	"reflect"
 	"strconv"
 	"sync"
@@ -30,7 +32,7 @@ import (
 	"github.com/alipay/sofa-mosn/pkg/log"
 	"github.com/alipay/sofa-mosn/pkg/network"
 	"github.com/alipay/sofa-mosn/pkg/network/buffer"
-	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/stats"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
@@ -64,10 +66,20 @@ type downStream struct {
 	downstreamReqDataBuf  types.IoBuffer
 	downstreamReqTrailers map[string]string
 
+	// ~~~ request mirroring, set if the request is sampled to be mirrored
+	shadowPolicy types.ShadowPolicy
+	// headers received, as the upstream request may change the downstream request headers
+	shadowReqHeaders map[string]string
+
+	// ~~~ tracing, set if tracing is enabled
+	span types.Span
+
 	// ~~~ downstream response buf
 	downstreamRespHeaders  interface{}
 	downstreamRespDataBuf  types.IoBuffer
 	downstreamRespTrailers map[string]string
+	// cookie generated by route's hash policy, set in downstream response
+	downstreamRespCookie string
 
 	// ~~~ state
 	// starts to send back downstream response, set on upstream response detected
@@ -139,10 +151,10 @@ func (s *downStream) endStream() {
 
 // Clean up on the very end of the stream: end stream or reset stream
 // Resources to clean up / reset:
-// 	+ upstream request
-// 	+ all timers
-// 	+ all filters
-//  + remove stream in proxy context
+//   - upstream request
+//   - all timers
+//   - all filters
+//   - remove stream in proxy context
 func (s *downStream) cleanStream() {
 	if !atomic.CompareAndSwapUint32(&s.downstreamCleaned, 0, 1) {
 		return
@@ -151,6 +163,7 @@ func (s *downStream) cleanStream() {
 	// reset corresponding upstream stream
 	if s.upstreamRequest != nil {
 		s.upstreamRequest.resetStream()
+		s.upstreamRequest.finishSpan()
 	}
 
 	// clean up timers
@@ -168,6 +181,10 @@ func (s *downStream) cleanStream() {
 	// countdown metrics
 	s.proxy.stats.DownstreamRequestActive().Dec(1)
 	s.proxy.listenerStats.DownstreamRequestActive().Dec(1)
+	stats.UpdateDuration(s.requestInfo.Duration(), s.proxy.stats.DownstreamRequestTime(),
+		s.proxy.listenerStats.DownstreamRequestTime())
+
+	s.finishSpan()
 
 	// access log
 	if s.proxy != nil && s.proxy.accessLogs != nil {
@@ -210,9 +227,21 @@ func (s *downStream) OnReceiveHeaders(headers map[string]string, endStream bool)
 	s.downstreamRecvDone = endStream
 	s.downstreamReqHeaders = headers
 
+	s.startSpan(headers)
 	s.doReceiveHeaders(nil, headers, endStream)
 }
 
+// getRoute matches the route of the request once, so that the route is
+// available to stream filters before the request is proxied
+func (s *downStream) getRoute() types.Route {
+	if s.route == nil && s.downstreamReqHeaders != nil {
+		// the random value is used to select a weighted cluster
+		s.route = s.proxy.routers.Route(s.downstreamReqHeaders, rand.Uint64())
+	}
+
+	return s.route
+}
+
 func (s *downStream) doReceiveHeaders(filter *activeStreamReceiverFilter, headers map[string]string, endStream bool) {
 	if s.runReceiveHeadersFilters(filter, headers, endStream) {
 		return
@@ -220,7 +249,7 @@ func (s *downStream) doReceiveHeaders(filter *activeStreamReceiverFilter, header
 
 	//Get some route by service name
 	log.StartLogger.Tracef("before active stream route")
-	route := s.proxy.routers.Route(headers, 1)
+	route := s.getRoute()
 
 	if route == nil || route.RouteRule() == nil {
 		// no route
@@ -233,9 +262,14 @@ func (s *downStream) doReceiveHeaders(filter *activeStreamReceiverFilter, header
 	}
 	log.StartLogger.Tracef("get route : %v,clusterName=%v", route, route.RouteRule().ClusterName())
 
-	s.route = route
-
 	s.requestInfo.SetRouteEntry(route.RouteRule())
+
+	if s.span != nil {
+		if decorator := route.TraceDecorator(); decorator != nil {
+			decorator.Apply(s.span)
+		}
+	}
+
 	s.requestInfo.SetDownstreamLocalAddress(s.proxy.readCallbacks.Connection().LocalAddr())
 	// todo: detect remote addr
 	s.requestInfo.SetDownstreamRemoteAddress(s.proxy.readCallbacks.Connection().RemoteAddr())
@@ -252,6 +286,10 @@ func (s *downStream) doReceiveHeaders(filter *activeStreamReceiverFilter, header
 	log.StartLogger.Tracef("after initializeUpstreamConnectionPool")
 	s.timeout = parseProxyTimeout(route, headers)
 	s.retryState = newRetryState(route.RouteRule().Policy().RetryPolicy(), headers, s.cluster)
+	s.shadowPolicy = sampleShadowPolicy(route)
+	if s.shadowPolicy != nil {
+		s.shadowReqHeaders = copyHeaders(headers)
+	}
 
 	//Build Request
 	s.upstreamRequest = &upstreamRequest{
@@ -260,6 +298,10 @@ func (s *downStream) doReceiveHeaders(filter *activeStreamReceiverFilter, header
 		connPool:   pool,
 	}
 
+	if endStream {
+		s.sendShadowRequest()
+	}
+
 	//Call upstream's append header method to build upstream's request
 	s.upstreamRequest.appendHeaders(headers, endStream)
 
@@ -288,7 +330,7 @@ func (s *downStream) doReceiveData(filter *activeStreamReceiverFilter, data type
 	}
 
 	shouldBufData := false
-	if s.retryState != nil && s.retryState.retryOn {
+	if (s.retryState != nil && s.retryState.retryOn) || s.shadowPolicy != nil {
 		shouldBufData = true
 
 		// todo: set a buf limit
@@ -310,6 +352,10 @@ func (s *downStream) doReceiveData(filter *activeStreamReceiverFilter, data type
 			s.downstreamReqDataBuf.ReadFrom(data)
 		}
 
+		if endStream {
+			s.sendShadowRequest()
+		}
+
 		// use a copy when we need to reuse buffer later
 		s.upstreamRequest.appendData(copied, endStream)
 	} else {
@@ -359,6 +405,7 @@ func (s *downStream) doReceiveTrailers(filter *activeStreamReceiverFilter, trail
 	}
 
 	s.downstreamReqTrailers = trailers
+	s.sendShadowRequest()
 	s.onUpstreamRequestSent()
 	s.upstreamRequest.appendTrailers(trailers)
 
@@ -398,6 +445,7 @@ func (s *downStream) onResponseTimeout() {
 			s.upstreamRequest.host.HostStats().UpstreamRequestTimeout.Inc(1)
 		}
 
+		s.upstreamRequest.putOutlierResult(types.OutlierResultTimeout)
 		s.upstreamRequest.resetStream()
 	}
 
@@ -412,7 +460,7 @@ func (s *downStream) setupPerReqTimeout() {
 			s.perRetryTimer.stop()
 		}
 
-		s.perRetryTimer = newTimer(s.onPerReqTimeout, timeout.TryTimeout*time.Second)
+		s.perRetryTimer = newTimer(s.onPerReqTimeout, timeout.TryTimeout)
 		s.perRetryTimer.start()
 	}
 }
@@ -429,6 +477,7 @@ func (s *downStream) onPerReqTimeout() {
 			s.upstreamRequest.host.HostStats().UpstreamRequestTimeout.Inc(1)
 		}
 
+		s.upstreamRequest.putOutlierResult(types.OutlierResultTimeout)
 		s.upstreamRequest.resetStream()
 		s.requestInfo.SetResponseFlag(types.UpstreamRequestTimeout)
 		s.onUpstreamReset(UpstreamPerTryTimeout, types.StreamLocalReset)
@@ -450,21 +499,7 @@ func (s *downStream) initializeUpstreamConnectionPool(clusterName string, lbCtx
 	}
 
 	s.cluster = clusterSnapshot.ClusterInfo()
-	var connPool types.ConnectionPool
-
-	// todo: refactor
-	switch types.Protocol(s.proxy.config.UpstreamProtocol) {
-	case protocol.SofaRPC:
-		connPool = s.proxy.clusterManager.SofaRPCConnPoolForCluster(lbCtx, clusterName)
-	case protocol.HTTP2:
-		connPool = s.proxy.clusterManager.HTTPConnPoolForCluster(lbCtx, clusterName, protocol.HTTP2)
-	case protocol.HTTP1:
-		connPool = s.proxy.clusterManager.HTTPConnPoolForCluster(lbCtx, clusterName, protocol.HTTP1)
-	case protocol.Xprotocol:
-		connPool = s.proxy.clusterManager.XprotocolConnPoolForCluster(nil, clusterName, protocol.Xprotocol)
-	default:
-		connPool = s.proxy.clusterManager.HTTPConnPoolForCluster(lbCtx, clusterName, protocol.HTTP2)
-	}
+	connPool := s.proxy.connPoolForCluster(lbCtx, clusterName)
 
 	if connPool == nil {
 		s.requestInfo.SetResponseFlag(types.NoHealthyUpstream)
@@ -478,10 +513,26 @@ func (s *downStream) initializeUpstreamConnectionPool(clusterName string, lbCtx
 	return connPool, nil
 }
 
+// sendShadowRequest mirrors the downstream request received to the shadow cluster,
+// the mirrored request is sent asynchronously, so it never affects the downstream request
+func (s *downStream) sendShadowRequest() {
+	if s.shadowPolicy == nil {
+		return
+	}
+
+	clusterName := s.shadowPolicy.ClusterName()
+	s.shadowPolicy = nil
+
+	request := newShadowRequest(s.proxy, s.shadowReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers)
+	s.shadowReqHeaders = nil
+	go request.send(clusterName)
+}
+
 // ~~~ active stream sender wrapper
 
 func (s *downStream) appendHeaders(headers map[string]string, endStream bool) {
 	s.upstreamProcessDone = endStream
+	setSpanResponse(s.span, headers)
 	s.doAppendHeaders(nil, headers, endStream)
 }
 
@@ -544,7 +595,7 @@ func (s *downStream) onUpstreamReset(urtype UpstreamResetType, reason types.Stre
 
 	// see if we need a retry
 	if urtype != UpstreamGlobalTimeout &&
-		s.downstreamResponseStarted && s.retryState != nil {
+		!s.downstreamResponseStarted && s.retryState != nil {
 		retryCheck := s.retryState.retry(nil, reason, s.doRetry)
 
 		if retryCheck == types.ShouldRetry && s.setupRetry(true) {
@@ -614,6 +665,15 @@ func (s *downStream) onUpstreamHeaders(headers map[string]string, endStream bool
 		s.onUpstreamResponseRecvFinished()
 	}
 
+	if s.downstreamRespCookie != "" {
+		// keep the cookies set by upstream, values of a header are joined by comma
+		if cookies := headers[headerSetCookie]; cookies != "" {
+			headers[headerSetCookie] = cookies + "," + s.downstreamRespCookie
+		} else {
+			headers[headerSetCookie] = s.downstreamRespCookie
+		}
+	}
+
 	// todo: insert proxy headers
 	s.appendHeaders(headers, endStream)
 }
@@ -665,7 +725,13 @@ func (s *downStream) setupRetry(endStream bool) bool {
 
 // Note: retry-timer MUST be stopped before active stream got recycled, otherwise resetting stream's properties will cause panic here
 func (s *downStream) doRetry() {
-	pool, err := s.initializeUpstreamConnectionPool(s.cluster.Name(), nil)
+	// prefer hosts other than the failed one
+	if s.upstreamRequest != nil && s.upstreamRequest.host != nil {
+		s.retryState.onHostAttempted(s.upstreamRequest.host)
+	}
+	atomic.StoreUint32(&s.upstreamReset, 0)
+
+	pool, err := s.initializeUpstreamConnectionPool(s.cluster.Name(), s)
 
 	if err != nil {
 		s.sendHijackReply(types.NoHealthUpstreamCode, s.downstreamReqHeaders)
@@ -680,7 +746,7 @@ func (s *downStream) doRetry() {
 	}
 
 	s.upstreamRequest.appendHeaders(s.downstreamReqHeaders,
-		s.downstreamReqDataBuf != nil && s.downstreamReqTrailers != nil)
+		s.downstreamReqDataBuf == nil && s.downstreamReqTrailers == nil)
 
 	if s.upstreamRequest != nil {
 		if s.downstreamReqDataBuf != nil {
@@ -772,12 +838,16 @@ func (s *downStream) reset() {
 	s.element = nil
 	s.timeout = nil
 	s.retryState = nil
+	s.shadowPolicy = nil
+	s.shadowReqHeaders = nil
+	s.span = nil
 	s.requestInfo = nil
 	s.responseSender = nil
 	s.upstreamRequest.downStream = nil
 	s.upstreamRequest.requestSender = nil
 	s.upstreamRequest.proxy = nil
 	s.upstreamRequest.upstreamRespHeaders = nil
+	s.upstreamRequest.span = nil
 	s.upstreamRequest = nil
 	s.perRetryTimer = nil
 	s.responseTimer = nil
@@ -792,10 +862,34 @@ func (s *downStream) reset() {
 }
 
 // types.LoadBalancerContext
-// no use currently
+// compute hash key by route's hash policy, used by consistent hash load balancers
 func (s *downStream) ComputeHashKey() types.HashedValue {
-	//return [16]byte{}
-	return ""
+	if s.route == nil || s.route.RouteRule() == nil || s.route.RouteRule().Policy() == nil {
+		return ""
+	}
+
+	lbPolicy := s.route.RouteRule().Policy().LoadBalancerPolicy()
+	if lbPolicy == nil || lbPolicy.HashPolicy() == nil {
+		return ""
+	}
+
+	var downstreamAddress string
+	if addr := s.requestInfo.DownstreamRemoteAddress(); addr != nil {
+		downstreamAddress = addr.String()
+	}
+
+	return lbPolicy.HashPolicy().GenerateHash(downstreamAddress, s.downstreamReqHeaders, s.addHashCookie)
+}
+
+func (s *downStream) addHashCookie(name string, value string, path string, ttl time.Duration) {
+	cookie := &http.Cookie{
+		Name:   name,
+		Value:  value,
+		Path:   path,
+		MaxAge: int(ttl.Seconds()),
+	}
+
+	s.downstreamRespCookie = cookie.String()
 }
 
 func (s *downStream) MetadataMatchCriteria() types.MetadataMatchCriteria {
@@ -813,3 +907,7 @@ func (s *downStream) DownstreamConnection() net.Conn {
 func (s *downStream) DownstreamHeaders() map[string]string {
 	return s.downstreamReqHeaders
 }
+
+func (s *downStream) ShouldSelectAnotherHost(host types.Host) bool {
+	return s.retryState != nil && s.retryState.isHostAttempted(host)
+}
@@ -0,0 +1,52 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/network"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+type mockResponseSender struct {
+	types.StreamSender
+	headers map[string]string
+}
+
+func (s *mockResponseSender) AppendHeaders(headers interface{}, endStream bool) error {
+	s.headers = headers.(map[string]string)
+	return nil
+}
+
+func TestDownstream_HashCookieKeepsUpstreamCookie(t *testing.T) {
+	sender := &mockResponseSender{}
+	s := &downStream{
+		requestInfo:    network.NewRequestInfo(),
+		responseSender: sender,
+	}
+	s.addHashCookie("route", "abc", "/", 0)
+
+	s.onUpstreamHeaders(map[string]string{headerSetCookie: "session=123; Path=/"}, false)
+
+	cookies := sender.headers[headerSetCookie]
+	if !strings.Contains(cookies, "session=123; Path=/") || !strings.Contains(cookies, "route=abc") {
+		t.Errorf("expected both upstream and hash cookies, got %s", cookies)
+	}
+}
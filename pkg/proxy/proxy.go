@@ -21,9 +21,12 @@ import (
 	"container/list"
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/network/buffer"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/dubbo"
 	"github.com/alipay/sofa-mosn/pkg/router"
 	"github.com/alipay/sofa-mosn/pkg/stream"
 	"github.com/alipay/sofa-mosn/pkg/types"
@@ -42,7 +45,10 @@ func init() {
 }
 
 // types.ReadFilter
+// types.DrainFilter
+// types.TransferFilter
 // types.ServerStreamConnectionEventListener
+// types.ServerStreamConnectionServeListener
 type proxy struct {
 	config              *v2.Proxy
 	clusterManager      types.ClusterManager
@@ -70,10 +76,15 @@ type proxy struct {
 
 	// access logs
 	accessLogs []types.AccessLog
+
+	draining uint32
 }
 
 func NewProxy(ctx context.Context, config *v2.Proxy, clusterManager types.ClusterManager) Proxy {
 	ctx = context.WithValue(ctx, types.ContextKeyConnectionCodecMapPool, codecHeadersBufPool)
+	if config.SubProtocol != "" {
+		ctx = context.WithValue(ctx, types.ContextSubProtocol, types.SubProtocol(config.SubProtocol))
+	}
 
 	proxy := &proxy{
 		config:         config,
@@ -140,7 +151,44 @@ func (p *proxy) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
 	p.stats.DownstreamConnectionActive().Inc(1)
 
 	p.readCallbacks.Connection().AddConnectionEventListener(p.downstreamCallbacks)
-	p.serverCodec = stream.CreateServerStreamConnection(p.context, types.Protocol(p.config.DownstreamProtocol), p.readCallbacks.Connection(), p)
+	serverCodec := stream.CreateServerStreamConnection(p.context, types.Protocol(p.config.DownstreamProtocol), p.readCallbacks.Connection(), p)
+
+	p.asMux.Lock()
+	p.serverCodec = serverCodec
+	p.asMux.Unlock()
+}
+
+// types.ServerStreamConnectionServeListener
+func (p *proxy) OnServe(conn types.ServerStreamConnection) {
+	p.asMux.Lock()
+	p.serverCodec = conn
+	p.asMux.Unlock()
+}
+
+// types.DrainFilter
+func (p *proxy) Drain() {
+	if !atomic.CompareAndSwapUint32(&p.draining, 0, 1) {
+		return
+	}
+
+	p.asMux.RLock()
+	serverCodec := p.serverCodec
+	idle := p.activeSteams.Len() == 0
+	p.asMux.RUnlock()
+
+	if idle {
+		p.readCallbacks.Connection().Close(types.FlushWrite, types.LocalClose)
+	} else if serverCodec != nil {
+		serverCodec.GoAway()
+	}
+}
+
+// types.TransferFilter
+func (p *proxy) Idle() bool {
+	p.asMux.RLock()
+	defer p.asMux.RUnlock()
+
+	return p.activeSteams.Len() == 0
 }
 
 func (p *proxy) OnGoAway() {}
@@ -184,6 +232,25 @@ func (p *proxy) streamResetReasonToResponseFlag(reason types.StreamResetReason)
 	return 0
 }
 
+// connPoolForCluster returns the connection pool of upstream protocol to a host chosen from the cluster
+func (p *proxy) connPoolForCluster(lbCtx types.LoadBalancerContext, clusterName string) types.ConnectionPool {
+	// todo: refactor
+	switch types.Protocol(p.config.UpstreamProtocol) {
+	case protocol.SofaRPC:
+		return p.clusterManager.SofaRPCConnPoolForCluster(lbCtx, clusterName)
+	case protocol.HTTP2:
+		return p.clusterManager.HTTPConnPoolForCluster(lbCtx, clusterName, protocol.HTTP2)
+	case protocol.HTTP1:
+		return p.clusterManager.HTTPConnPoolForCluster(lbCtx, clusterName, protocol.HTTP1)
+	case protocol.Xprotocol:
+		return p.clusterManager.XprotocolConnPoolForCluster(lbCtx, clusterName, types.SubProtocol(p.config.SubProtocol))
+	case protocol.Dubbo:
+		return p.clusterManager.XprotocolConnPoolForCluster(lbCtx, clusterName, dubbo.SubProtocol)
+	default:
+		return p.clusterManager.HTTPConnPoolForCluster(lbCtx, clusterName, protocol.HTTP2)
+	}
+}
+
 func (p *proxy) deleteActiveStream(s *downStream) {
 	// reuse decode map
 	if p.resueCodecMaps {
@@ -22,17 +22,29 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
+const defaultNumRetries = 3
+
+// sofarpc response status that can be retried safely on another host
+var retriableSofaRPCStatus = map[int16]bool{
+	sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY: true,
+}
+
 type retryState struct {
 	retryPolicy     types.RetryPolicy
 	requestHeaders  map[string]string
 	cluster         types.ClusterInfo
 	retryOn         bool
 	retiesRemaining uint32
+	retries         uint32
 	retryFunc       func()
 	retryTimer      *timer
+	// hosts already tried, retries prefer other hosts
+	attemptedHosts []types.Host
 }
 
 func newRetryState(retryPolicy types.RetryPolicy,
@@ -42,10 +54,10 @@ func newRetryState(retryPolicy types.RetryPolicy,
 		requestHeaders:  requestHeaders,
 		cluster:         cluster,
 		retryOn:         retryPolicy.RetryOn(),
-		retiesRemaining: 3,
+		retiesRemaining: defaultNumRetries,
 	}
 
-	if retryPolicy.NumRetries() > rs.retiesRemaining {
+	if retryPolicy.NumRetries() > 0 {
 		rs.retiesRemaining = retryPolicy.NumRetries()
 	}
 
@@ -67,17 +79,23 @@ func (r *retryState) retry(headers map[string]string, reason types.StreamResetRe
 }
 
 func (r *retryState) shouldRetry(headers map[string]string, reason types.StreamResetReason) types.RetryCheckStatus {
-	if r.retiesRemaining == 0 {
+	if !r.doRetryCheck(headers, reason) {
+		// a retried request gets a response needn't retry
+		if reason == "" && r.retries > 0 {
+			r.cluster.Stats().UpstreamRequestRetrySuccess.Inc(1)
+		}
+
 		return types.NoRetry
 	}
 
-	r.retiesRemaining--
-
-	if !r.doRetryCheck(headers, reason) {
+	if r.retiesRemaining == 0 {
 		return types.NoRetry
 	}
 
-	if r.cluster.ResourceManager().Retries().CanCreate() {
+	r.retiesRemaining--
+
+	// retries are limited by the cluster's retry budget
+	if !r.cluster.ResourceManager().Retries().CanCreate() {
 		r.cluster.Stats().UpstreamRequestRetryOverflow.Inc(1)
 
 		return types.RetryOverflow
@@ -88,30 +106,103 @@ func (r *retryState) shouldRetry(headers map[string]string, reason types.StreamR
 
 func (r *retryState) scheduleRetry(doRetry func()) *timer {
 	r.retryFunc = doRetry
+	r.retries++
 	r.cluster.ResourceManager().Retries().Increase()
 	r.cluster.Stats().UpstreamRequestRetry.Inc(1)
+	r.cluster.Stats().UpstreamRequestRetryActive.Inc(1)
 
-	// todo: use backoff alth
-	timeout := rand.Intn(10)
-	timer := newTimer(doRetry, time.Duration(timeout)*time.Second)
+	timer := newTimer(doRetry, r.backOff())
 	timer.start()
 
 	return timer
 }
 
+// backOff returns a jittered exponential back off for the current retry,
+// which is a random duration in [0, base * 2^(retries-1)), limited by max
+func (r *retryState) backOff() time.Duration {
+	base := r.retryPolicy.BackOffBaseInterval()
+	max := r.retryPolicy.BackOffMaxInterval()
+
+	if base <= 0 {
+		return 0
+	}
+
+	interval := base
+	for i := uint32(1); i < r.retries && interval < max; i++ {
+		interval *= 2
+	}
+
+	if max > 0 && interval > max {
+		interval = max
+	}
+
+	return time.Duration(rand.Int63n(int64(interval)))
+}
+
 func (r *retryState) doRetryCheck(headers map[string]string, reason types.StreamResetReason) bool {
-	if reason == types.StreamOverflow {
+	if reason == types.StreamOverflow || !r.retryOn {
 		return false
 	}
 
-	if r.retryOn {
-		if code, ok := headers[types.HeaderStatus]; ok {
-			codeValue, _ := strconv.Atoi(code)
+	if reason != "" {
+		return r.retryOnReset(reason)
+	}
+
+	return r.retryOnHeaders(headers)
+}
+
+func (r *retryState) retryOnReset(reason types.StreamResetReason) bool {
+	if reason == types.StreamConnectionFailed && r.retryPolicy.RetryOnCondition(v2.RetryOnConnectFailure) {
+		return true
+	}
+
+	return r.retryPolicy.RetryOnCondition(v2.RetryOnReset) ||
+		r.retryPolicy.RetryOnCondition(v2.RetryOn5xx) ||
+		r.retryPolicy.RetryOnCondition(v2.RetryOnGatewayError)
+}
+
+func (r *retryState) retryOnHeaders(headers map[string]string) bool {
+	if code, ok := headers[types.HeaderStatus]; ok {
+		if status, err := strconv.Atoi(code); err == nil {
+			if status >= 500 && r.retryPolicy.RetryOnCondition(v2.RetryOn5xx) {
+				return true
+			}
+
+			if (status == 502 || status == 503 || status == 504) && r.retryPolicy.RetryOnCondition(v2.RetryOnGatewayError) {
+				return true
+			}
+
+			if r.retryPolicy.RetryOnCondition(v2.RetryOnRetriableStatusCodes) {
+				for _, retriable := range r.retryPolicy.RetriableStatusCodes() {
+					if uint32(status) == retriable {
+						return true
+					}
+				}
+			}
+		}
+	}
 
-			return codeValue >= 500
+	if r.retryPolicy.RetryOnCondition(v2.RetryOnRetriableSofaRPCStatus) {
+		if code, ok := headers[sofarpc.SofaPropertyHeader(sofarpc.HeaderRespStatus)]; ok {
+			if status, err := strconv.ParseInt(code, 10, 16); err == nil {
+				return retriableSofaRPCStatus[int16(status)]
+			}
 		}
+	}
+
+	return false
+}
 
-		// todo: more conditions
+// onHostAttempted records the host a request is sent to
+func (r *retryState) onHostAttempted(host types.Host) {
+	r.attemptedHosts = append(r.attemptedHosts, host)
+}
+
+func (r *retryState) isHostAttempted(host types.Host) bool {
+	for _, h := range r.attemptedHosts {
+		if h == host {
+			return true
+		}
 	}
 
 	return false
@@ -120,6 +211,12 @@ func (r *retryState) doRetryCheck(headers map[string]string, reason types.Stream
 func (r *retryState) reset() {
 	if r.retryFunc != nil {
 		r.cluster.ResourceManager().Retries().Decrease()
+		r.cluster.Stats().UpstreamRequestRetryActive.Dec(1)
 		r.retryFunc = nil
 	}
+
+	if r.retryTimer != nil {
+		r.retryTimer.stop()
+		r.retryTimer = nil
+	}
 }
@@ -0,0 +1,184 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc"
+	"github.com/alipay/sofa-mosn/pkg/router"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/alipay/sofa-mosn/pkg/upstream/cluster"
+)
+
+func newTestRetryState(policy *v2.RetryPolicy) *retryState {
+	return newRetryState(router.NewRetryPolicyImpl(policy), nil, nil)
+}
+
+func TestRetryState_DoRetryCheck(t *testing.T) {
+	sofaStatus := sofarpc.SofaPropertyHeader(sofarpc.HeaderRespStatus)
+
+	cases := []struct {
+		conditions []v2.RetryCondition
+		headers    map[string]string
+		reason     types.StreamResetReason
+		want       bool
+	}{
+		{nil, map[string]string{types.HeaderStatus: "500"}, "", true},
+		{nil, map[string]string{types.HeaderStatus: "200"}, "", false},
+		{nil, nil, types.StreamConnectionFailed, true},
+		{nil, nil, types.StreamOverflow, false},
+		{[]v2.RetryCondition{v2.RetryOnGatewayError}, map[string]string{types.HeaderStatus: "500"}, "", false},
+		{[]v2.RetryCondition{v2.RetryOnGatewayError}, map[string]string{types.HeaderStatus: "503"}, "", true},
+		{[]v2.RetryCondition{v2.RetryOnConnectFailure}, nil, types.StreamConnectionFailed, true},
+		{[]v2.RetryCondition{v2.RetryOnConnectFailure}, nil, types.StreamRemoteReset, false},
+		{[]v2.RetryCondition{v2.RetryOnReset}, nil, types.StreamRemoteReset, true},
+		{[]v2.RetryCondition{v2.RetryOnRetriableStatusCodes}, map[string]string{types.HeaderStatus: "409"}, "", true},
+		{[]v2.RetryCondition{v2.RetryOnRetriableSofaRPCStatus}, map[string]string{sofaStatus: "4"}, "", true},
+		{[]v2.RetryCondition{v2.RetryOnRetriableSofaRPCStatus}, map[string]string{sofaStatus: "1"}, "", false},
+	}
+
+	for i, c := range cases {
+		rs := newTestRetryState(&v2.RetryPolicy{
+			RetryOn:              true,
+			RetryConditions:      c.conditions,
+			RetriableStatusCodes: []uint32{409},
+		})
+
+		if got := rs.doRetryCheck(c.headers, c.reason); got != c.want {
+			t.Errorf("case %d: want %v, got %v", i, c.want, got)
+		}
+	}
+
+	rs := newTestRetryState(&v2.RetryPolicy{})
+	if rs.doRetryCheck(map[string]string{types.HeaderStatus: "500"}, "") {
+		t.Errorf("should not retry if retry is disabled")
+	}
+}
+
+func TestRetryState_BackOff(t *testing.T) {
+	rs := newTestRetryState(&v2.RetryPolicy{
+		RetryOn:             true,
+		BackOffBaseInterval: 10 * time.Millisecond,
+		BackOffMaxInterval:  40 * time.Millisecond,
+	})
+
+	for retries, limit := range []time.Duration{10, 10, 20, 40, 40, 40} {
+		rs.retries = uint32(retries)
+		for i := 0; i < 20; i++ {
+			if d := rs.backOff(); d < 0 || d >= limit*time.Millisecond {
+				t.Errorf("retries %d: back off %v out of [0, %v)", retries, d, limit*time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestRetryState_AttemptedHosts(t *testing.T) {
+	rs := newTestRetryState(nil)
+
+	if rs.retiesRemaining != defaultNumRetries {
+		t.Errorf("want %d retries by default, got %d", defaultNumRetries, rs.retiesRemaining)
+	}
+
+	host := &mockHost{}
+	if rs.isHostAttempted(host) {
+		t.Errorf("host should not be attempted yet")
+	}
+
+	rs.onHostAttempted(host)
+	if !rs.isHostAttempted(host) || rs.isHostAttempted(&mockHost{}) {
+		t.Errorf("only the recorded host should be attempted")
+	}
+}
+
+type mockHost struct {
+	types.Host
+}
+
+// a failure storm: every active request of an upstream fails and asks for a retry,
+// the retries in flight are limited by the cluster's retry budget
+func TestRetryState_RetryBudget(t *testing.T) {
+	clusterInfo := cluster.NewCluster(v2.Cluster{
+		Name:        "retry_budget_test",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		CirBreThresholds: v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{{
+				MaxConnections:     100,
+				MaxPendingRequests: 100,
+				MaxRequests:        100,
+				RetryBudget: &v2.RetryBudget{
+					BudgetPercent:       20,
+					MinRetryConcurrency: 3,
+				},
+			}},
+		},
+	}, nil, false).Info()
+
+	requests := clusterInfo.ResourceManager().Requests()
+	for i := 0; i < 100; i++ {
+		requests.Increase()
+	}
+
+	policy := router.NewRetryPolicyImpl(&v2.RetryPolicy{
+		RetryOn:    true,
+		NumRetries: 3,
+	})
+	failure := map[string]string{types.HeaderStatus: "503"}
+
+	var states []*retryState
+	retried, overflowed := 0, 0
+
+	for i := 0; i < 100; i++ {
+		rs := newRetryState(policy, nil, clusterInfo)
+		states = append(states, rs)
+
+		switch rs.retry(failure, "", func() {}) {
+		case types.ShouldRetry:
+			retried++
+		case types.RetryOverflow:
+			overflowed++
+		}
+	}
+
+	if retried != 20 || overflowed != 80 {
+		t.Errorf("want 20 retries and 80 overflows, got %d and %d", retried, overflowed)
+	}
+
+	stats := clusterInfo.Stats()
+	if stats.UpstreamRequestRetry.Count() != 20 || stats.UpstreamRequestRetryOverflow.Count() != 80 ||
+		stats.UpstreamRequestRetryActive.Count() != 20 {
+		t.Errorf("unexpected retry stats, retry %d, overflow %d, active %d", stats.UpstreamRequestRetry.Count(),
+			stats.UpstreamRequestRetryOverflow.Count(), stats.UpstreamRequestRetryActive.Count())
+	}
+
+	// retried requests succeed, the budget is released
+	for _, rs := range states[:20] {
+		rs.retry(map[string]string{types.HeaderStatus: "200"}, "", func() {})
+	}
+
+	if stats.UpstreamRequestRetryActive.Count() != 0 || stats.UpstreamRequestRetrySuccess.Count() != 20 {
+		t.Errorf("unexpected retry stats, active %d, success %d", stats.UpstreamRequestRetryActive.Count(),
+			stats.UpstreamRequestRetrySuccess.Count())
+	}
+
+	if !clusterInfo.ResourceManager().Retries().CanCreate() {
+		t.Errorf("retry budget should be released")
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"math/rand"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// sampleShadowPolicy returns the route's shadow policy if the request is sampled to be mirrored
+func sampleShadowPolicy(route types.Route) types.ShadowPolicy {
+	policy := route.RouteRule().Policy()
+	if policy == nil {
+		return nil
+	}
+
+	shadowPolicy := policy.ShadowPolicy()
+	if shadowPolicy == nil || shadowPolicy.ClusterName() == "" {
+		return nil
+	}
+
+	// the percentage could be overridden by the runtime key
+	if shadowPolicy.RuntimeKey() != "" {
+		if !runtime.FeatureEnabled(shadowPolicy.RuntimeKey(), uint64(shadowPolicy.Percent())) {
+			return nil
+		}
+	} else if uint32(rand.Intn(100)) >= shadowPolicy.Percent() {
+		return nil
+	}
+
+	return shadowPolicy
+}
+
+// types.StreamEventListener
+// types.StreamReceiver
+// types.PoolEventListener
+// shadowRequest is a fire-and-forget copy of a downstream request, its response is discarded
+type shadowRequest struct {
+	proxy    *proxy
+	headers  map[string]string
+	data     types.IoBuffer
+	trailers map[string]string
+}
+
+// newShadowRequest copies the request body and trailers, as they may be changed during proxying.
+// The headers are copied by the caller before they are sent to upstream
+func newShadowRequest(proxy *proxy, headers map[string]string, data types.IoBuffer, trailers map[string]string) *shadowRequest {
+	r := &shadowRequest{
+		proxy:   proxy,
+		headers: headers,
+	}
+
+	if data != nil {
+		r.data = data.Clone()
+	}
+
+	if trailers != nil {
+		r.trailers = copyHeaders(trailers)
+	}
+
+	return r
+}
+
+func (r *shadowRequest) send(clusterName string) {
+	connPool := r.proxy.connPoolForCluster(nil, clusterName)
+	if connPool == nil {
+		log.DefaultLogger.Debugf("no healthy upstream in shadow cluster %s, mirrored request is dropped", clusterName)
+		return
+	}
+
+	connPool.NewStream(r.proxy.context, r.headers[types.HeaderStreamID], r, r)
+}
+
+// types.PoolEventListener
+func (r *shadowRequest) OnFailure(streamID string, reason types.PoolFailureReason, host types.Host) {
+	log.DefaultLogger.Debugf("mirrored request %s failed, reason = %v", streamID, reason)
+}
+
+func (r *shadowRequest) OnReady(streamID string, sender types.StreamSender, host types.Host) {
+	sender.GetStream().AddEventListener(r)
+
+	sender.AppendHeaders(r.headers, r.data == nil && r.trailers == nil)
+
+	if r.data != nil {
+		sender.AppendData(r.data, r.trailers == nil)
+	}
+
+	if r.trailers != nil {
+		sender.AppendTrailers(r.trailers)
+	}
+}
+
+// types.StreamEventListener
+func (r *shadowRequest) OnResetStream(reason types.StreamResetReason) {}
+
+// types.StreamReceiver
+func (r *shadowRequest) OnReceiveHeaders(headers map[string]string, endStream bool) {}
+
+func (r *shadowRequest) OnReceiveData(data types.IoBuffer, endStream bool) {}
+
+func (r *shadowRequest) OnReceiveTrailers(trailers map[string]string) {}
+
+func (r *shadowRequest) OnDecodeError(err error, headers map[string]string) {}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+
+	return copied
+}
@@ -167,8 +167,6 @@ func (s *downStream) runReceiveHeadersFilters(filter *activeStreamReceiverFilter
 		}
 
 		f.headersContinued = true
-
-		return false
 	}
 
 	return false
@@ -284,7 +282,7 @@ func (f *activeStreamFilter) ResetStream() {
 }
 
 func (f *activeStreamFilter) Route() types.Route {
-	return f.activeStream.route
+	return f.activeStream.getRoute()
 }
 
 func (f *activeStreamFilter) StreamID() string {
@@ -0,0 +1,143 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc/models"
+	"github.com/alipay/sofa-mosn/pkg/trace"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+const defaultOperationName = "ingress"
+
+// startSpan starts the span of the downstream request if tracing is enabled,
+// the trace context propagated by the downstream is extracted from the request headers
+func (s *downStream) startSpan(headers map[string]string) {
+	driver := trace.Driver()
+	if driver == nil {
+		return
+	}
+
+	s.span = driver.Start(headers, spanOperationName(headers), s.requestInfo.StartTime())
+	s.span.SetTag(trace.TagProtocol, s.proxy.config.DownstreamProtocol)
+
+	if addr := s.proxy.readCallbacks.Connection().RemoteAddr(); addr != nil {
+		s.span.SetTag(trace.TagDownstreamAddress, addr.String())
+	}
+}
+
+func (s *downStream) finishSpan() {
+	if s.span == nil {
+		return
+	}
+
+	if flags := log.GetResponseFlagGetter(s.requestInfo); flags != "-" {
+		s.span.SetTag(trace.TagResponseFlags, flags)
+	}
+
+	s.span.FinishSpan()
+}
+
+// startSpan spawns the span of the upstream attempt, each retry has its own span
+func (r *upstreamRequest) startSpan() {
+	parent := r.downStream.span
+	if parent == nil {
+		return
+	}
+
+	clusterName := r.downStream.cluster.Name()
+	r.span = parent.SpawnChild(clusterName, time.Now())
+	r.span.SetTag(trace.TagProtocol, r.proxy.config.UpstreamProtocol)
+	r.span.SetTag(trace.TagUpstreamCluster, clusterName)
+}
+
+// injectSpanContext returns a copy of the request headers with the trace context of the attempt,
+// so that the downstream request headers are kept for retries
+func (r *upstreamRequest) injectSpanContext(headers map[string]string, host types.Host) map[string]string {
+	if r.span == nil {
+		return headers
+	}
+
+	r.span.SetTag(trace.TagUpstreamHost, host.AddressString())
+
+	copied := make(map[string]string, len(headers)+5)
+	for k, v := range headers {
+		copied[k] = v
+	}
+	r.span.InjectContext(types.Protocol(r.proxy.config.UpstreamProtocol), copied)
+
+	return copied
+}
+
+func (r *upstreamRequest) finishSpan() {
+	if r.span == nil {
+		return
+	}
+
+	r.span.FinishSpan()
+	r.span = nil
+}
+
+func (r *upstreamRequest) finishSpanOnReset(reason types.StreamResetReason) {
+	if r.span == nil {
+		return
+	}
+
+	r.span.SetTag(trace.TagResetReason, string(reason))
+	r.span.SetTag(trace.TagError, "true")
+	r.finishSpan()
+}
+
+// setSpanResponse tags the span with the status code of the http or sofarpc response
+func setSpanResponse(span types.Span, headers map[string]string) {
+	if span == nil {
+		return
+	}
+
+	if status, ok := headers[types.HeaderStatus]; ok {
+		span.SetTag(trace.TagResponseCode, status)
+	} else if status, ok := headers[sofarpc.SofaPropertyHeader(sofarpc.HeaderRespStatus)]; ok {
+		span.SetTag(trace.TagResponseCode, status)
+	}
+
+	if outlierResultFromHeaders(headers) != types.OutlierResultSuccess {
+		span.SetTag(trace.TagError, "true")
+	}
+}
+
+// spanOperationName is the service for sofarpc requests and the path for http requests,
+// the operation name may be overwritten by the route's decorator
+func spanOperationName(headers map[string]string) string {
+	if service, ok := headers[models.SERVICE_KEY]; ok && service != "" {
+		return service
+	}
+
+	if service, ok := headers[models.TARGET_SERVICE_KEY]; ok && service != "" {
+		return service
+	}
+
+	if path, ok := headers[protocol.MosnHeaderPathKey]; ok && path != "" {
+		return path
+	}
+
+	return defaultOperationName
+}
@@ -61,3 +61,6 @@ const (
 	UpstreamGlobalTimeout UpstreamResetType = "UpstreamGlobalTimeout"
 	UpstreamPerTryTimeout UpstreamResetType = "UpstreamPerTryTimeout"
 )
+
+// http header names are lower case in headers map
+const headerSetCookie = "set-cookie"
@@ -19,8 +19,10 @@ package proxy
 
 import (
 	"container/list"
+	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/stats"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
@@ -38,6 +40,12 @@ type upstreamRequest struct {
 	// ~~~ upstream response buf
 	upstreamRespHeaders map[string]string
 
+	// ~~~ span of the attempt, set if tracing is enabled
+	span types.Span
+
+	// ~~~ time the request is sent to the connection pool, for latency stats
+	startTime time.Time
+
 	//~~~ state
 	sendComplete bool
 	dataSent     bool
@@ -55,6 +63,7 @@ func (r *upstreamRequest) resetStream() {
 	if r.requestSender != nil {
 		r.requestSender.GetStream().RemoveEventListener(r)
 		r.requestSender.GetStream().ResetStream(types.StreamLocalReset)
+		r.finishSpanOnReset(types.StreamLocalReset)
 	}
 }
 
@@ -62,6 +71,11 @@ func (r *upstreamRequest) resetStream() {
 // Called by stream layer normally
 func (r *upstreamRequest) OnResetStream(reason types.StreamResetReason) {
 	r.requestSender = nil
+	r.finishSpanOnReset(reason)
+
+	if reason == types.StreamRemoteReset || reason == types.StreamConnectionTermination {
+		r.putOutlierResult(types.OutlierResultReset)
+	}
 
 	// todo: check if we get a reset on encode request headers. e.g. send failed
 	r.downStream.onUpstreamReset(UpstreamReset, reason)
@@ -71,18 +85,48 @@ func (r *upstreamRequest) OnResetStream(reason types.StreamResetReason) {
 // Method to decode upstream's response message
 func (r *upstreamRequest) OnReceiveHeaders(headers map[string]string, endStream bool) {
 	r.upstreamRespHeaders = headers
+	r.putOutlierResult(outlierResultFromHeaders(headers))
+	r.recordFirstByte()
+
+	setSpanResponse(r.span, headers)
+	if endStream {
+		r.recordRequestTime()
+		r.finishSpan()
+	}
+
 	r.downStream.onUpstreamHeaders(headers, endStream)
 }
 
 func (r *upstreamRequest) OnReceiveData(data types.IoBuffer, endStream bool) {
+	// the request is detached on retry or timeout, the rest of its response is dropped
+	if r.requestSender == nil {
+		return
+	}
+
+	if endStream {
+		r.recordRequestTime()
+		r.finishSpan()
+	}
+
 	r.downStream.onUpstreamData(data, endStream)
 }
 
 func (r *upstreamRequest) OnReceiveTrailers(trailers map[string]string) {
+	if r.requestSender == nil {
+		return
+	}
+
+	r.recordRequestTime()
+	r.finishSpan()
+
 	r.downStream.onUpstreamTrailers(trailers)
 }
 
 func (r *upstreamRequest) OnDecodeError(err error, headers map[string]string) {
+	if r.requestSender == nil {
+		return
+	}
+
 	r.OnResetStream(types.StreamLocalReset)
 }
 
@@ -96,6 +140,9 @@ func (r *upstreamRequest) appendHeaders(headers map[string]string, endStream boo
 		streamID = streamid
 	}
 
+	r.startSpan()
+	r.startTime = time.Now()
+
 	log.StartLogger.Tracef("upstream request before conn pool new stream")
 	r.connPool.NewStream(r.proxy.context, streamID, r, r)
 }
@@ -123,6 +170,11 @@ func (r *upstreamRequest) OnFailure(streamID string, reason types.PoolFailureRea
 		resetReason = types.StreamOverflow
 	case types.ConnectionFailure:
 		resetReason = types.StreamConnectionFailed
+		r.host = host
+
+		if host != nil && host.OutlierDetector() != nil {
+			host.OutlierDetector().PutResult(types.OutlierResultConnectFailed)
+		}
 	}
 
 	r.OnResetStream(resetReason)
@@ -130,13 +182,38 @@ func (r *upstreamRequest) OnFailure(streamID string, reason types.PoolFailureRea
 
 func (r *upstreamRequest) OnReady(streamID string, sender types.StreamSender, host types.Host) {
 	r.requestSender = sender
+	r.host = host
 	r.requestSender.GetStream().AddEventListener(r)
 
 	endStream := r.sendComplete && !r.dataSent && !r.trailerSent
-	r.requestSender.AppendHeaders(r.downStream.downstreamReqHeaders, endStream)
+	headers := r.injectSpanContext(r.downStream.downstreamReqHeaders, host)
+	r.requestSender.AppendHeaders(headers, endStream)
 
 	r.downStream.requestInfo.OnUpstreamHostSelected(host)
 	r.downStream.requestInfo.SetUpstreamLocalAddress(host.Address())
 
 	// todo: check if we get a reset on send headers
 }
+
+// report request result to the upstream host's outlier detector
+func (r *upstreamRequest) putOutlierResult(result types.OutlierResult) {
+	if r.host != nil && r.host.OutlierDetector() != nil {
+		r.host.OutlierDetector().PutResult(result)
+	}
+}
+
+// record the time to first byte of the response in the stats of the upstream host and its cluster
+func (r *upstreamRequest) recordFirstByte() {
+	if r.host != nil {
+		stats.UpdateDuration(time.Since(r.startTime), r.host.HostStats().UpstreamRequestTimeToFirstByte,
+			r.host.ClusterInfo().Stats().UpstreamRequestTimeToFirstByte)
+	}
+}
+
+// record the time until the response is completely received in the stats of the upstream host and its cluster
+func (r *upstreamRequest) recordRequestTime() {
+	if r.host != nil {
+		stats.UpdateDuration(time.Since(r.startTime), r.host.HostStats().UpstreamRequestTime,
+			r.host.ClusterInfo().Stats().UpstreamRequestTime)
+	}
+}
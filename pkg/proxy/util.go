@@ -21,6 +21,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
@@ -31,28 +32,64 @@ func parseProxyTimeout(route types.Route, headers map[string]string) *Timeout {
 	timeout.GlobalTimeout = route.RouteRule().GlobalTimeout()
 	timeout.TryTimeout = route.RouteRule().Policy().RetryPolicy().TryTimeout()
 
-	// todo: check global timeout in request headers
-	// todo: check per try timeout in request headers
-
+	// timeouts in request headers are in milliseconds
 	if tto, ok := headers[types.HeaderTryTimeout]; ok {
 		if trytimeout, err := strconv.ParseInt(tto, 10, bitSize64); err == nil {
-			timeout.TryTimeout = time.Duration(trytimeout)
+			timeout.TryTimeout = time.Duration(trytimeout) * time.Millisecond
 		}
 	}
 
 	if gto, ok := headers[types.HeaderGlobalTimeout]; ok {
 		if globaltimeout, err := strconv.ParseInt(gto, 10, bitSize64); err == nil {
-			timeout.GlobalTimeout = time.Duration(globaltimeout)
+			timeout.GlobalTimeout = time.Duration(globaltimeout) * time.Millisecond
 		}
 	}
 
-	if timeout.TryTimeout >= timeout.GlobalTimeout {
+	if timeout.GlobalTimeout > 0 && timeout.TryTimeout >= timeout.GlobalTimeout {
 		timeout.TryTimeout = 0
 	}
 
 	return timeout
 }
 
+// parse upstream response headers to the result used by outlier detection
+func outlierResultFromHeaders(headers map[string]string) types.OutlierResult {
+	if status, ok := headers[types.HeaderStatus]; ok {
+		code, err := strconv.Atoi(status)
+
+		if err != nil || code < 500 {
+			return types.OutlierResultSuccess
+		}
+
+		switch code {
+		case 502, 503, 504:
+			return types.OutlierResultGatewayFailure
+		default:
+			return types.OutlierResultServerError
+		}
+	}
+
+	if status, ok := headers[sofarpc.SofaPropertyHeader(sofarpc.HeaderRespStatus)]; ok {
+		code, err := strconv.ParseInt(status, 10, 16)
+
+		if err != nil {
+			return types.OutlierResultSuccess
+		}
+
+		switch int16(code) {
+		case sofarpc.RESPONSE_STATUS_SUCCESS:
+			return types.OutlierResultSuccess
+		case sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY, sofarpc.RESPONSE_STATUS_TIMEOUT,
+			sofarpc.RESPONSE_STATUS_ERROR_COMM, sofarpc.RESPONSE_STATUS_CONNECTION_CLOSED:
+			return types.OutlierResultGatewayFailure
+		default:
+			return types.OutlierResultServerError
+		}
+	}
+
+	return types.OutlierResultSuccess
+}
+
 type timer struct {
 	callback func()
 	interval time.Duration
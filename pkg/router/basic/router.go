@@ -80,9 +80,7 @@ func (rc *Routers) AddRouter(routerName string) {
 	} else {
 		br.globalTimeout = types.GlobalTimeout
 		br.policy = &routerPolicy{
-			retryOn:      false,
-			retryTimeout: 0,
-			numRetries:   0,
+			retryPolicy: router.NewRetryPolicyImpl(nil),
 		}
 	}
 
@@ -127,21 +125,9 @@ func NewRouters(config interface{}) (types.Routers, error) {
 				service:       r.Service,
 				cluster:       r.Cluster,
 				globalTimeout: r.GlobalTimeout,
-			}
-
-			if r.RetryPolicy != nil {
-				router.policy = &routerPolicy{
-					retryOn:      r.RetryPolicy.RetryOn,
-					retryTimeout: r.RetryPolicy.RetryTimeout,
-					numRetries:   r.RetryPolicy.NumRetries,
-				}
-			} else {
-				// default
-				router.policy = &routerPolicy{
-					retryOn:      false,
-					retryTimeout: 0,
-					numRetries:   0,
-				}
+				policy: &routerPolicy{
+					retryPolicy: router.NewRetryPolicyImpl(r.RetryPolicy),
+				},
 			}
 
 			routers = append(routers, router)
@@ -210,25 +196,11 @@ func (srr *basicRouter) GetRouterName() string {
 }
 
 type routerPolicy struct {
-	retryOn      bool
-	retryTimeout time.Duration
-	numRetries   uint32
-}
-
-func (p *routerPolicy) RetryOn() bool {
-	return p.retryOn
-}
-
-func (p *routerPolicy) TryTimeout() time.Duration {
-	return p.retryTimeout
-}
-
-func (p *routerPolicy) NumRetries() uint32 {
-	return p.numRetries
+	retryPolicy types.RetryPolicy
 }
 
 func (p *routerPolicy) RetryPolicy() types.RetryPolicy {
-	return p
+	return p.retryPolicy
 }
 
 func (p *routerPolicy) ShadowPolicy() types.ShadowPolicy {
@@ -242,3 +214,7 @@ func (p *routerPolicy) CorsPolicy() types.CorsPolicy {
 func (p *routerPolicy) LoadBalancerPolicy() types.LoadBalancerPolicy {
 	return nil
 }
+
+func (p *routerPolicy) RateLimitPolicy() types.RateLimitPolicy {
+	return nil
+}
@@ -149,8 +149,17 @@ func (mmcti *MetadataMatchCriteriaImpl) MetadataMatchCriteria() []types.Metadata
 	return mmcti.MatchCriteriaArray
 }
 
+// MergeMatchCriteria returns a new criteria with the metadata matches merged,
+// values in metadataMatches take precedence
 func (mmcti *MetadataMatchCriteriaImpl) MergeMatchCriteria(metadataMatches map[string]interface{}) types.MetadataMatchCriteria {
-	return nil
+	return mmcti.mergeMatchCriteria(metadataMatches)
+}
+
+func (mmcti *MetadataMatchCriteriaImpl) mergeMatchCriteria(metadataMatches map[string]interface{}) *MetadataMatchCriteriaImpl {
+	merged := &MetadataMatchCriteriaImpl{}
+	merged.extractMetadataMatchCriteria(mmcti, metadataMatches)
+
+	return merged
 }
 
 // used to generate metadata match criteria from config
@@ -0,0 +1,88 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"sync"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// RouteConfigs holds the route tables discovered by RDS
+var RouteConfigs = routeConfigs{
+	routers: make(map[string]types.Routers),
+}
+
+type routeConfigs struct {
+	routers map[string]types.Routers // key: route config name
+	mux     sync.RWMutex
+}
+
+// Update rebuilds the route table of the route config, the streams created after it are routed with the new table
+func (rc *routeConfigs) Update(name string, virtualHosts []*v2.VirtualHost) error {
+	routers, err := NewRouteMatcher(&v2.Proxy{
+		VirtualHosts: virtualHosts,
+	})
+	if err != nil {
+		return err
+	}
+
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+
+	rc.routers[name] = routers
+	log.DefaultLogger.Debugf("[RouteConfigs]route config %s updated", name)
+
+	return nil
+}
+
+// Get returns the current route table of the route config
+func (rc *routeConfigs) Get(name string) types.Routers {
+	rc.mux.RLock()
+	defer rc.mux.RUnlock()
+
+	return rc.routers[name]
+}
+
+// dynamicRouters routes with the latest route table of the route config
+type dynamicRouters struct {
+	name string
+}
+
+func newDynamicRouters(name string) types.Routers {
+	return &dynamicRouters{
+		name: name,
+	}
+}
+
+func (dr *dynamicRouters) Route(headers map[string]string, randomValue uint64) types.Route {
+	routers := RouteConfigs.Get(dr.name)
+
+	if routers == nil {
+		log.DefaultLogger.Errorf("No Route Config Found when Routing, Route Config Name = %s", dr.name)
+		return nil
+	}
+
+	return routers.Route(headers, randomValue)
+}
+
+func (dr *dynamicRouters) AddRouter(routerName string) {}
+
+func (dr *dynamicRouters) DelRouter(routerName string) {}
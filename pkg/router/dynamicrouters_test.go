@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+)
+
+func newRDSVirtualHosts(clusterName string) []*v2.VirtualHost {
+	return []*v2.VirtualHost{
+		{
+			Name:    "rds_vhost",
+			Domains: []string{"*"},
+			Routers: []v2.Router{
+				{
+					Match: v2.RouterMatch{Prefix: "/"},
+					Route: v2.RouteAction{ClusterName: clusterName},
+				},
+			},
+		},
+	}
+}
+
+func TestDynamicRouters(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	routers, err := CreateRouteConfig(protocol.HTTP1, &v2.Proxy{
+		DownstreamProtocol: string(protocol.HTTP1),
+		RouterConfigName:   "rds_route",
+	})
+	if err != nil {
+		t.Fatalf("create route config failed: %v", err)
+	}
+
+	headers := map[string]string{strings.ToLower(protocol.MosnHeaderPathKey): "/test"}
+
+	if route := routers.Route(headers, 1); route != nil {
+		t.Errorf("expected no route before the route config is discovered")
+	}
+
+	RouteConfigs.Update("rds_route", newRDSVirtualHosts("cluster_v1"))
+	if route := routers.Route(headers, 1); route == nil || route.RouteRule().ClusterName() != "cluster_v1" {
+		t.Fatalf("expected routing to cluster_v1, but got %v", route)
+	}
+
+	// routers created before are routing with the updated route table
+	RouteConfigs.Update("rds_route", newRDSVirtualHosts("cluster_v2"))
+	if route := routers.Route(headers, 1); route == nil || route.RouteRule().ClusterName() != "cluster_v2" {
+		t.Errorf("expected routing to cluster_v2, but got %v", route)
+	}
+
+	if RouteConfigs.Get("not_found") != nil {
+		t.Errorf("expected no route table for unknown route config")
+	}
+}
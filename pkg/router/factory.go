@@ -20,6 +20,7 @@ package router
 import (
 	"fmt"
 
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
@@ -38,6 +39,11 @@ func RegisterRouterConfigFactory(port types.Protocol, factory configFactory) {
 }
 
 func CreateRouteConfig(port types.Protocol, config interface{}) (types.Routers, error) {
+	// route table discovered by RDS is looked up on routing, so that the updated one is used without reconnecting
+	if proxyConfig, ok := config.(*v2.Proxy); ok && proxyConfig.RouterConfigName != "" {
+		return newDynamicRouters(proxyConfig.RouterConfigName), nil
+	}
+
 	if factory, ok := routerConfigFactories[port]; ok {
 		return factory(config) //call NewBasicRoute
 	}
@@ -31,6 +31,7 @@ func init() {
 	RegisterRouterConfigFactory(protocol.HTTP2, NewRouteMatcher)
 	RegisterRouterConfigFactory(protocol.HTTP1, NewRouteMatcher)
 	RegisterRouterConfigFactory(protocol.Xprotocol, NewRouteMatcher)
+	RegisterRouterConfigFactory(protocol.Dubbo, NewRouteMatcher)
 }
 
 func NewRouteMatcher(config interface{}) (types.Routers, error) {
@@ -61,7 +62,7 @@ func NewRouteMatcher(config interface{}) (types.Routers, error) {
 					routerMatcher.wildcardVirtualHostSuffixes[len(domain)-1] = domainMap
 
 				} else if _, ok := routerMatcher.virtualHosts[domain]; ok {
-					log.StartLogger.Fatal("Only unique values for domains are permitted, get duplicate domain = %s", domain)
+					log.StartLogger.Fatalf("Only unique values for domains are permitted, get duplicate domain = %s", domain)
 				} else {
 					routerMatcher.virtualHosts[domain] = vh
 				}
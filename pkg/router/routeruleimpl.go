@@ -28,21 +28,56 @@ import (
 	//"github.com/alipay/sofa-mosn/pkg/protocol"
 	"github.com/alipay/sofa-mosn/pkg/protocol"
 	httpmosn "github.com/alipay/sofa-mosn/pkg/protocol/http"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
+// runtime percentage of matchRoute is checked by randomValue % runtimeFractionBase
+const runtimeFractionBase = 100
+
 func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) RouteRuleImplBase {
 	routeRuleImplBase := RouteRuleImplBase{
-		vHost:        vHost,
-		routerMatch:  route.Match,
-		routerAction: route.Route,
+		vHost:                       vHost,
+		routerMatch:                 route.Match,
+		routerAction:                route.Route,
+		runtime:                     route.Match.Runtime,
+		includeVirtualHostRateLimit: route.Route.IncludeVirtualHostRateLimits,
+		decorator:                   NewDecoratorImpl(route.Decorator),
 		policy: &routerPolicy{
-			retryOn:      false,
-			retryTimeout: 0,
-			numRetries:   0,
+			retryPolicy: NewRetryPolicyImpl(route.Route.RetryPolicy),
 		},
 	}
 
+	if len(route.Route.HashPolicy) > 0 {
+		routeRuleImplBase.policy.hashPolicy = NewHashPolicyImpl(route.Route.HashPolicy)
+	}
+
+	if route.Route.ShadowPolicy != nil && route.Route.ShadowPolicy.Cluster != "" {
+		routeRuleImplBase.shadowPolicy = NewShadowPolicyImpl(route.Route.ShadowPolicy)
+		routeRuleImplBase.policy.shadowPolicy = routeRuleImplBase.shadowPolicy
+	}
+
+	// the virtual host's rate limits are applied if the route has none or asks to include them
+	if len(route.Route.RateLimits) > 0 {
+		routeRuleImplBase.rateLimitPolicy = NewRateLimitPolicyImpl(route.Route.RateLimits)
+	}
+
+	if vHost != nil && vHost.rateLimitPolicy != nil &&
+		(routeRuleImplBase.rateLimitPolicy == nil || routeRuleImplBase.includeVirtualHostRateLimit) {
+		routeRuleImplBase.policy.rateLimitPolicy = routeRuleImplBase.rateLimitPolicy.merge(vHost.rateLimitPolicy)
+	} else {
+		routeRuleImplBase.policy.rateLimitPolicy = routeRuleImplBase.rateLimitPolicy
+	}
+
+	// the route's cors policy takes precedence over the virtual host's
+	if route.Route.Cors != nil {
+		corsPolicy := NewCorsPolicyImpl(route.Route.Cors)
+		routeRuleImplBase.corsPolicy = corsPolicy
+		routeRuleImplBase.policy.corsPolicy = corsPolicy
+	} else if vHost != nil {
+		routeRuleImplBase.policy.corsPolicy = vHost.corsPolicy
+	}
+
 	// generate metadata match criteria from router's metadata
 	if len(route.Route.MetadataMatch) > 0 {
 		envoyLBMetaData := GetMosnLBMetaData(route)
@@ -51,6 +86,27 @@ func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) RouteRuleImp
 		routeRuleImplBase.metaData = GetClusterMosnLBMetaDataMap(route.Route.MetadataMatch)
 	}
 
+	// weighted clusters' metadata match criteria are merged into the route's,
+	// and their weights could be overridden by runtime if the runtime key prefix is set
+	for _, weightedCluster := range route.Route.WeightedClusters {
+		entry := &WeightedClusterEntry{
+			clusterName:   weightedCluster.Clusters.Name,
+			clusterWeight: uint64(weightedCluster.Clusters.Weight),
+		}
+
+		if weightedCluster.RuntimeKeyPrefix != "" {
+			entry.runtimeKey = weightedCluster.RuntimeKeyPrefix + "." + weightedCluster.Clusters.Name
+		}
+
+		if metadataMatch := getMosnLBMetaData(weightedCluster.Clusters.MetadataMatch); len(metadataMatch) > 0 {
+			entry.clusterMetadataMatchCriteria = routeRuleImplBase.metadataMatchCriteria.mergeMatchCriteria(metadataMatch)
+		}
+
+		routeRuleImplBase.weightedClusters = append(routeRuleImplBase.weightedClusters, entry)
+		routeRuleImplBase.totalClusterWeight += entry.clusterWeight
+		routeRuleImplBase.weightedClustersRuntime = routeRuleImplBase.weightedClustersRuntime || entry.runtimeKey != ""
+	}
+
 	return routeRuleImplBase
 }
 
@@ -60,7 +116,7 @@ type RouteRuleImplBase struct {
 	prefixRewrite               string
 	hostRewrite                 string
 	includeVirtualHostRateLimit bool
-	corsPolicy                  types.CorsPolicy //todo
+	corsPolicy                  types.CorsPolicy
 	vHost                       *VirtualHostImpl
 
 	autoHostRewrite             bool
@@ -85,7 +141,8 @@ type RouteRuleImplBase struct {
 	configQueryParameters []types.QueryParameterMatcher
 	weightedClusters      []*WeightedClusterEntry
 	totalClusterWeight    uint64
-	hashPolicy            HashPolicyImpl
+	// weights of the weighted clusters are read from runtime
+	weightedClustersRuntime bool
 
 	metadataMatchCriteria *MetadataMatchCriteriaImpl
 	metaData              types.RouteMetaData
@@ -95,7 +152,7 @@ type RouteRuleImplBase struct {
 
 	opaqueConfig multimap.MultiMap
 
-	decorator          *types.Decorator
+	decorator          *DecoratorImpl
 	directResponseCode httpmosn.Code
 	directResponseBody string
 	policy             *routerPolicy
@@ -120,13 +177,16 @@ func (rri *RouteRuleImplBase) RouteRule() types.RouteRule {
 }
 
 func (rri *RouteRuleImplBase) TraceDecorator() types.TraceDecorator {
+	if rri.decorator == nil {
+		return nil
+	}
 
-	return nil
+	return rri.decorator
 }
 
 // types.RouteRule
 // Select Cluster for Routing
-// todo support weighted cluster
+// the route of weighted clusters returns the cluster selected on routing, see weightedClusterRoute
 func (rri *RouteRuleImplBase) ClusterName() string {
 
 	return rri.routerAction.ClusterName
@@ -165,19 +225,67 @@ func (rri *RouteRuleImplBase) MetadataMatchCriteria() types.MetadataMatchCriteri
 	return rri.metadataMatchCriteria
 }
 
+// routeWithCluster returns the matched route, if weighted clusters are configured,
+// a cluster is selected by randomValue according to the weights in the current runtime
+func (rri *RouteRuleImplBase) routeWithCluster(route types.Route, randomValue uint64) types.Route {
+	if len(rri.weightedClusters) == 0 {
+		return route
+	}
+
+	snapshot := runtime.GetSnapshot()
+	totalWeight := rri.totalClusterWeight
+
+	if rri.weightedClustersRuntime {
+		totalWeight = 0
+		for _, entry := range rri.weightedClusters {
+			totalWeight += entry.weight(snapshot)
+		}
+	}
+
+	if totalWeight == 0 {
+		return route
+	}
+
+	// the low digits decided the runtime percentage, the cluster is selected by the rest,
+	// otherwise the matched requests would be biased to the first clusters
+	if rri.runtime.RuntimeKey != "" {
+		randomValue /= runtimeFractionBase
+	}
+
+	selected := randomValue % totalWeight
+	var end uint64
+
+	for _, entry := range rri.weightedClusters {
+		end += entry.weight(snapshot)
+		if selected < end {
+			return &weightedClusterRoute{
+				RouteRuleImplBase: rri,
+				entry:             entry,
+			}
+		}
+	}
+
+	return route
+}
+
 // todo
 func (rri *RouteRuleImplBase) finalizePathHeader(headers map[string]string, matchedPath string) {
 
 }
 
 func (rri *RouteRuleImplBase) matchRoute(headers map[string]string, randomValue uint64) bool {
-	// todo check runtime
-	// 1. match headers' KV
+	// 1. match a percentage of requests if the runtime is set
+	if rri.runtime.RuntimeKey != "" &&
+		!runtime.GetSnapshot().FeatureEnabled(rri.runtime.RuntimeKey, uint64(rri.runtime.DefaultValue), randomValue) {
+		return false
+	}
+
+	// 2. match headers' KV
 	if !ConfigUtilityInst.MatchHeaders(headers, rri.configHeaders) {
 		return false
 	}
 
-	// 2. match query parameters
+	// 3. match query parameters
 	var queryParams types.QueryParams
 
 	if QueryString, ok := headers[types.HeaderQueryString]; ok {
@@ -210,7 +318,7 @@ func (srri *SofaRouteRuleImpl) Match(headers map[string]string, randomValue uint
 	if value, ok := headers[types.SofaRouteMatchKey]; ok {
 		if value == srri.matchValue || srri.matchValue == ".*" {
 			log.DefaultLogger.Debugf("Sofa router matches success")
-			return srri
+			return srri.routeWithCluster(srri, randomValue)
 		}
 
 		log.DefaultLogger.Warnf(" Sofa router matches failure, service name = %s", value)
@@ -247,11 +355,11 @@ func (prri *PathRouteRuleImpl) Match(headers map[string]string, randomValue uint
 			if prri.caseSensitive {
 				if headerPathValue == prri.path {
 					log.DefaultLogger.Debugf("path route rule match success in caseSensitive scene")
-					return prri
+					return prri.routeWithCluster(prri, randomValue)
 				}
 			} else if strings.EqualFold(headerPathValue, prri.path) {
 				log.DefaultLogger.Debugf("path route rule match success with exact matching ")
-				return prri
+				return prri.routeWithCluster(prri, randomValue)
 			}
 		}
 	}
@@ -290,7 +398,7 @@ func (prei *PrefixRouteRuleImpl) Match(headers map[string]string, randomValue ui
 			if strings.HasPrefix(headerPathValue, prei.prefix) {
 				log.DefaultLogger.Warnf("prefix route rule match success")
 
-				return prei
+				return prei.routeWithCluster(prei, randomValue)
 			}
 		}
 	}
@@ -327,7 +435,7 @@ func (rrei *RegexRouteRuleImpl) Match(headers map[string]string, randomValue uin
 			if rrei.regexPattern.MatchString(headerPathValue) {
 				log.DefaultLogger.Warnf("regex route rule match success")
 
-				return rrei
+				return rrei.routeWithCluster(rrei, randomValue)
 			}
 		}
 	}
@@ -0,0 +1,352 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc"
+	"github.com/alipay/sofa-mosn/pkg/runtime"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+func lbMetadata(kvs map[string]interface{}) v2.Metadata {
+	return v2.Metadata{
+		types.RouterMetadataKey: map[string]interface{}{
+			types.RouterMetadataKeyLb: kvs,
+		},
+	}
+}
+
+func TestWeightedClusterRoute(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	route := &v2.Router{
+		Match: v2.RouterMatch{
+			Headers: []v2.HeaderMatcher{{Name: types.SofaRouteMatchKey, Value: ".*"}},
+		},
+		Route: v2.RouteAction{
+			MetadataMatch: lbMetadata(map[string]interface{}{"zone": "a", "version": "1.0"}),
+			WeightedClusters: []v2.WeightedCluster{
+				{Clusters: v2.ClusterWeight{Name: "stable", Weight: 90}},
+				{Clusters: v2.ClusterWeight{
+					Name:          "canary",
+					Weight:        10,
+					MetadataMatch: lbMetadata(map[string]interface{}{"version": "2.0"}),
+				}},
+			},
+		},
+	}
+
+	rule := &SofaRouteRuleImpl{
+		RouteRuleImplBase: NewRouteRuleImplBase(nil, route),
+		matchValue:        ".*",
+	}
+	headers := map[string]string{types.SofaRouteMatchKey: "test"}
+
+	counts := make(map[string]int)
+	for i := uint64(0); i < 1000; i++ {
+		counts[rule.Match(headers, i).RouteRule().ClusterName()]++
+	}
+
+	if counts["stable"] != 900 || counts["canary"] != 100 {
+		t.Errorf("unexpected weighted selection, %v", counts)
+	}
+
+	// canary's metadata is merged into the route's
+	criteria := rule.Match(headers, 95).RouteRule().MetadataMatchCriteria().MetadataMatchCriteria()
+	want := map[string]string{"version": "2.0", "zone": "a"}
+	if len(criteria) != len(want) {
+		t.Fatalf("want %d criteria, got %d", len(want), len(criteria))
+	}
+	for _, c := range criteria {
+		if c.MetadataValue() != types.GenerateHashedValue(want[c.MetadataKeyName()]) {
+			t.Errorf("unexpected criterion %s", c.MetadataKeyName())
+		}
+	}
+
+	// stable uses the route's metadata
+	criteria = rule.Match(headers, 0).RouteRule().MetadataMatchCriteria().MetadataMatchCriteria()
+	for _, c := range criteria {
+		if c.MetadataKeyName() == "version" && c.MetadataValue() != types.GenerateHashedValue("1.0") {
+			t.Errorf("stable cluster should match the route's version")
+		}
+	}
+}
+
+func TestRouteWithoutWeightedClusters(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	route := &v2.Router{
+		Route: v2.RouteAction{ClusterName: "single"},
+	}
+
+	rule := &SofaRouteRuleImpl{
+		RouteRuleImplBase: NewRouteRuleImplBase(nil, route),
+		matchValue:        ".*",
+	}
+
+	got := rule.Match(map[string]string{types.SofaRouteMatchKey: "test"}, 7)
+	if got != rule || got.RouteRule().ClusterName() != "single" {
+		t.Errorf("route without weighted clusters should be the rule itself")
+	}
+}
+
+func TestRouteRuntime(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	route := &v2.Router{
+		Match: v2.RouterMatch{
+			Prefix:  "/",
+			Runtime: v2.RuntimeUInt32{DefaultValue: 30, RuntimeKey: "route.test.percent"},
+		},
+		Route: v2.RouteAction{
+			WeightedClusters: []v2.WeightedCluster{
+				{Clusters: v2.ClusterWeight{Name: "stable", Weight: 90}, RuntimeKeyPrefix: "route.test.weight"},
+				{Clusters: v2.ClusterWeight{Name: "canary", Weight: 10}, RuntimeKeyPrefix: "route.test.weight"},
+			},
+		},
+	}
+
+	rule := &PrefixRouteRuleImpl{
+		RouteRuleImplBase: NewRouteRuleImplBase(nil, route),
+		prefix:            "/",
+	}
+	headers := map[string]string{strings.ToLower(protocol.MosnHeaderPathKey): "/test"}
+
+	loader := runtime.Default()
+	defer loader.MergeValues(map[string]string{
+		"route.test.percent":       "",
+		"route.test.weight.stable": "",
+		"route.test.weight.canary": "",
+	})
+
+	// the matched requests are still split by the weights
+	counts := make(map[string]int)
+	for i := uint64(0); i < 10000; i++ {
+		if matched := rule.Match(headers, i); matched != nil {
+			counts[matched.RouteRule().ClusterName()]++
+		}
+	}
+	if counts["stable"] != 2700 || counts["canary"] != 300 {
+		t.Errorf("expected 30 percent matched and split by weights, but got %v", counts)
+	}
+
+	loader.MergeValues(map[string]string{"route.test.percent": "100"})
+	if !rule.matchRoute(headers, 99) {
+		t.Errorf("expected all requests matched by runtime")
+	}
+
+	// canary takes all requests
+	loader.MergeValues(map[string]string{"route.test.weight.stable": "0", "route.test.weight.canary": "1"})
+
+	for i := uint64(0); i < 10; i++ {
+		if name := rule.Match(headers, i).RouteRule().ClusterName(); name != "canary" {
+			t.Fatalf("expected weights overridden by runtime, but got %s", name)
+		}
+	}
+}
+
+func TestRouteRateLimitPolicy(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	routeLimit := v2.RateLimit{
+		Actions: []v2.RateLimitAction{
+			{DestinationCluster: &v2.DestinationClusterAction{}},
+			{RequestHeaders: &v2.RequestHeadersAction{HeaderName: "X-User", DescriptorKey: "user"}},
+		},
+	}
+	vHostLimit := v2.RateLimit{
+		Actions: []v2.RateLimitAction{
+			{VirtualHost: &v2.VirtualHostAction{}},
+			{RemoteAddress: &v2.RemoteAddressAction{}},
+		},
+	}
+	sofaLimit := v2.RateLimit{
+		Stage:   1,
+		Actions: []v2.RateLimitAction{{SofaRPC: &v2.SofaRPCAction{}}},
+	}
+
+	vHost := NewVirtualHostImpl(&v2.VirtualHost{
+		Name:       "test_vhost",
+		RateLimits: []v2.RateLimit{vHostLimit},
+		Routers: []v2.Router{
+			{
+				Match: v2.RouterMatch{Prefix: "/own"},
+				Route: v2.RouteAction{ClusterName: "own", RateLimits: []v2.RateLimit{routeLimit, sofaLimit}},
+			},
+			{
+				Match: v2.RouterMatch{Prefix: "/include"},
+				Route: v2.RouteAction{ClusterName: "include", RateLimits: []v2.RateLimit{routeLimit},
+					IncludeVirtualHostRateLimits: true},
+			},
+			{
+				Match: v2.RouterMatch{Prefix: "/none"},
+				Route: v2.RouteAction{ClusterName: "none"},
+			},
+		},
+	}, false)
+
+	headers := map[string]string{"x-user": "alice"}
+	headers[sofarpc.SofaPropertyHeader("service")] = "com.test.Service"
+	remoteAddr := "127.0.0.1:12345"
+
+	populate := func(rule types.RouteRule, stage uint64) []types.Descriptor {
+		var descriptors []types.Descriptor
+		for _, entry := range rule.Policy().RateLimitPolicy().GetApplicableRateLimit(stage) {
+			descriptors = entry.PopulateDescriptors(rule, descriptors, "", headers, remoteAddr)
+		}
+
+		return descriptors
+	}
+
+	own := vHost.routes[0].RouteRule()
+	if descriptors := populate(own, 0); len(descriptors) != 1 ||
+		descriptors[0].Entries[0] != (types.DescriptorEntry{Key: "destination_cluster", Value: "own"}) ||
+		descriptors[0].Entries[1] != (types.DescriptorEntry{Key: "user", Value: "alice"}) {
+		t.Errorf("unexpected descriptors of route's own rate limits, %v", descriptors)
+	}
+
+	if descriptors := populate(own, 1); len(descriptors) != 1 ||
+		descriptors[0].Entries[0] != (types.DescriptorEntry{Key: "service", Value: "com.test.Service"}) {
+		t.Errorf("unexpected descriptors of stage 1, %v", descriptors)
+	}
+
+	vHostDescriptor := types.DescriptorEntry{Key: "remote_address", Value: "127.0.0.1"}
+	if descriptors := populate(vHost.routes[1].RouteRule(), 0); len(descriptors) != 2 ||
+		descriptors[1].Entries[1] != vHostDescriptor {
+		t.Errorf("virtual host's rate limits should be included, %v", descriptors)
+	}
+
+	if descriptors := populate(vHost.routes[2].RouteRule(), 0); len(descriptors) != 1 ||
+		descriptors[0].Entries[0] != (types.DescriptorEntry{Key: "virtual_host", Value: "test_vhost"}) {
+		t.Errorf("virtual host's rate limits should be applied to route without rate limits, %v", descriptors)
+	}
+
+	// no descriptor is generated if the header is absent
+	delete(headers, "x-user")
+	if descriptors := populate(own, 0); len(descriptors) != 0 {
+		t.Errorf("no descriptor should be generated without header, %v", descriptors)
+	}
+}
+
+func TestRouteCorsPolicy(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	vHost := NewVirtualHostImpl(&v2.VirtualHost{
+		Name: "test_vhost",
+		Cors: &v2.CorsPolicy{
+			AllowOrigins: []v2.OriginMatcher{
+				{Exact: "http://a.example.com"},
+				{Prefix: "https://"},
+				{Regex: `^http://.*\.test\.com$`},
+			},
+			AllowMethods: "GET, POST",
+		},
+		Routers: []v2.Router{
+			{
+				Match: v2.RouterMatch{Prefix: "/vhost"},
+				Route: v2.RouteAction{ClusterName: "vhost"},
+			},
+			{
+				Match: v2.RouterMatch{Prefix: "/any"},
+				Route: v2.RouteAction{ClusterName: "any", Cors: &v2.CorsPolicy{
+					AllowOrigins: []v2.OriginMatcher{{Exact: "*"}},
+				}},
+			},
+			{
+				Match: v2.RouterMatch{Prefix: "/disabled"},
+				Route: v2.RouteAction{ClusterName: "disabled", Cors: &v2.CorsPolicy{Disabled: true}},
+			},
+		},
+	}, false)
+
+	// route without cors policy uses the virtual host's
+	policy := vHost.routes[0].RouteRule().Policy().CorsPolicy()
+	if policy == nil || !policy.Enabled() || policy.AllowMethods() != "GET, POST" {
+		t.Fatalf("virtual host's cors policy should be used")
+	}
+
+	origins := map[string]bool{
+		"http://a.example.com":  true,
+		"http://b.example.com":  false,
+		"https://b.example.com": true,
+		"http://b.test.com":     true,
+		"http://b.test.com.cn":  false,
+	}
+	for origin, allowed := range origins {
+		if policy.AllowOrigin(origin) != allowed {
+			t.Errorf("origin %s allowed should be %v", origin, allowed)
+		}
+	}
+
+	if policy := vHost.routes[1].RouteRule().Policy().CorsPolicy(); !policy.AllowOrigin("http://b.example.com") {
+		t.Errorf("route's cors policy should take precedence")
+	}
+
+	if policy := vHost.routes[2].RouteRule().Policy().CorsPolicy(); policy.Enabled() {
+		t.Errorf("route's cors policy should be disabled")
+	}
+}
+
+type mockSpan struct {
+	types.Span
+	operation string
+}
+
+func (s *mockSpan) SetOperation(operation string) {
+	s.operation = operation
+}
+
+func TestRouteTraceDecorator(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	vHost := NewVirtualHostImpl(&v2.VirtualHost{
+		Name: "test_vhost",
+		Routers: []v2.Router{
+			{
+				Match:     v2.RouterMatch{Prefix: "/decorated"},
+				Route:     v2.RouteAction{ClusterName: "decorated"},
+				Decorator: "decorated-operation",
+			},
+			{
+				Match: v2.RouterMatch{Prefix: "/"},
+				Route: v2.RouteAction{ClusterName: "default"},
+			},
+		},
+	}, false)
+
+	decorator := vHost.routes[0].TraceDecorator()
+	if decorator == nil || decorator.Operation() != "decorated-operation" {
+		t.Fatalf("route's decorator should be set")
+	}
+
+	span := &mockSpan{operation: "ingress"}
+	decorator.Apply(span)
+	if span.operation != "decorated-operation" {
+		t.Errorf("expected operation decorated-operation, but got %s", span.operation)
+	}
+
+	if vHost.routes[1].TraceDecorator() != nil {
+		t.Errorf("route without decorator should return nil")
+	}
+}
@@ -18,11 +18,17 @@
 package router
 
 import (
+	"hash/fnv"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
 
@@ -46,9 +52,27 @@ type RouteBase interface {
 	Info
 }
 
+const maxShadowPercent = 100
+
 type ShadowPolicyImpl struct {
 	cluster    string
 	runtimeKey string
+	percent    uint32
+}
+
+// NewShadowPolicyImpl creates the shadow policy, percent 0 means all requests are mirrored
+// as the request mirror policy of xds has no percentage, remove the policy to stop mirroring
+func NewShadowPolicyImpl(policy *v2.ShadowPolicy) *ShadowPolicyImpl {
+	percent := policy.Percent
+	if percent == 0 || percent > maxShadowPercent {
+		percent = maxShadowPercent
+	}
+
+	return &ShadowPolicyImpl{
+		cluster:    policy.Cluster,
+		runtimeKey: policy.RuntimeKey,
+		percent:    percent,
+	}
 }
 
 func (spi *ShadowPolicyImpl) ClusterName() string {
@@ -59,6 +83,10 @@ func (spi *ShadowPolicyImpl) RuntimeKey() string {
 	return spi.runtimeKey
 }
 
+func (spi *ShadowPolicyImpl) Percent() uint32 {
+	return spi.percent
+}
+
 type LowerCaseString struct {
 	str string
 }
@@ -75,25 +103,149 @@ func (lcs *LowerCaseString) Get() string {
 	return lcs.str
 }
 
+// HashPolicyImpl generates the hash key by the route's hash methods,
+// keys of multiple methods are joined
 type HashPolicyImpl struct {
 	hashImpl []*HashMethod
 }
 
+func NewHashPolicyImpl(policies []v2.HashPolicy) *HashPolicyImpl {
+	hashPolicy := &HashPolicyImpl{}
+
+	for _, policy := range policies {
+		if method := newHashMethod(policy); method != nil {
+			hashPolicy.hashImpl = append(hashPolicy.hashImpl, method)
+		}
+	}
+
+	return hashPolicy
+}
+
+func (hp *HashPolicyImpl) GenerateHash(downstreamAddress string, headers map[string]string,
+	addCookieCb types.AddCookieCallback) types.HashedValue {
+	var keys []string
+
+	for _, method := range hp.hashImpl {
+		if key := method.evaluate(downstreamAddress, headers, addCookieCb); key != "" {
+			keys = append(keys, key)
+
+			if method.terminal {
+				break
+			}
+		}
+	}
+
+	return types.HashedValue(strings.Join(keys, hashKeySeparator))
+}
+
+const (
+	hashKeySeparator = "|"
+	// http header names are lower case in headers map
+	headerCookie = "cookie"
+	// default sofarpc property for hash key
+	sofaRPCPropertyService = "service"
+)
+
+// HashMethod generates a hash key from one of
+// header, cookie, downstream address or sofarpc property
 type HashMethod struct {
+	policy   v2.HashPolicy
+	terminal bool
+}
+
+func newHashMethod(policy v2.HashPolicy) *HashMethod {
+	if policy.Header == nil && policy.Cookie == nil &&
+		policy.ConnectionProperties == nil && policy.SofaRPC == nil {
+		log.DefaultLogger.Errorf("hash policy is empty, ignore it")
+		return nil
+	}
+
+	return &HashMethod{
+		policy:   policy,
+		terminal: policy.Terminal,
+	}
+}
+
+func (hm *HashMethod) evaluate(downstreamAddress string, headers map[string]string,
+	addCookieCb types.AddCookieCallback) string {
+	switch {
+	case hm.policy.Header != nil:
+		return headers[strings.ToLower(hm.policy.Header.Name)]
+
+	case hm.policy.Cookie != nil:
+		cookie := hm.policy.Cookie
+		if value := getCookie(headers, cookie.Name); value != "" {
+			return value
+		}
+
+		// generate a cookie from downstream address, so that following requests
+		// from the client have the same hash key
+		if cookie.TTL > 0 && addCookieCb != nil && downstreamAddress != "" {
+			h := fnv.New64a()
+			h.Write([]byte(downstreamAddress))
+			value := strconv.FormatUint(h.Sum64(), 16)
+			addCookieCb(cookie.Name, value, cookie.Path, cookie.TTL)
+
+			return value
+		}
+
+	case hm.policy.ConnectionProperties != nil:
+		if hm.policy.ConnectionProperties.SourceIP && downstreamAddress != "" {
+			if ip, _, err := net.SplitHostPort(downstreamAddress); err == nil {
+				return ip
+			}
+
+			return downstreamAddress
+		}
+
+	case hm.policy.SofaRPC != nil:
+		property := hm.policy.SofaRPC.Property
+		if property == "" {
+			property = sofaRPCPropertyService
+		}
+
+		return headers[sofarpc.SofaPropertyHeader(property)]
+	}
+
+	return ""
+}
+
+func getCookie(headers map[string]string, name string) string {
+	value, ok := headers[headerCookie]
+	if !ok {
+		return ""
+	}
+
+	request := http.Request{Header: http.Header{"Cookie": []string{value}}}
+	if cookie, err := request.Cookie(name); err == nil {
+		return cookie.Value
+	}
+
+	return ""
 }
 
 type DecoratorImpl struct {
-	Operation string
+	operation string
+}
+
+func NewDecoratorImpl(decorator v2.Decorator) *DecoratorImpl {
+	if decorator == "" {
+		return nil
+	}
+
+	return &DecoratorImpl{
+		operation: string(decorator),
+	}
 }
 
-func (di *DecoratorImpl) apply(span types.Span) {
-	if di.Operation != "" {
-		span.SetOperation(di.Operation)
+func (di *DecoratorImpl) Apply(span types.Span) {
+	if di.operation != "" {
+		span.SetOperation(di.operation)
 	}
 }
 
-func (di *DecoratorImpl) getOperation() string {
-	return di.Operation
+func (di *DecoratorImpl) Operation() string {
+	return di.operation
 }
 
 type RateLimitPolicyImpl struct {
@@ -101,20 +253,110 @@ type RateLimitPolicyImpl struct {
 	maxStageNumber   uint64
 }
 
-func (rp *RateLimitPolicyImpl) Enabled() bool {
+func NewRateLimitPolicyImpl(rateLimits []v2.RateLimit) *RateLimitPolicyImpl {
+	policy := &RateLimitPolicyImpl{}
 
-	return true
+	for _, rateLimit := range rateLimits {
+		if entry := NewRateLimitPolicyEntryImpl(rateLimit); entry != nil {
+			policy.appendEntry(entry)
+		}
+	}
+
+	return policy
 }
 
-func (rp *RateLimitPolicyImpl) GetApplicableRateLimit(stage string) []types.RateLimitPolicyEntry {
+func (rp *RateLimitPolicyImpl) appendEntry(entry types.RateLimitPolicyEntry) {
+	rp.rateLimitEntries = append(rp.rateLimitEntries, entry)
 
-	return rp.rateLimitEntries
+	if entry.Stage() > rp.maxStageNumber {
+		rp.maxStageNumber = entry.Stage()
+	}
 }
 
+// merge returns a policy with entries of both policies, either of them may be nil
+func (rp *RateLimitPolicyImpl) merge(other *RateLimitPolicyImpl) *RateLimitPolicyImpl {
+	merged := &RateLimitPolicyImpl{}
+
+	for _, p := range []*RateLimitPolicyImpl{rp, other} {
+		if p == nil {
+			continue
+		}
+
+		for _, entry := range p.rateLimitEntries {
+			merged.appendEntry(entry)
+		}
+	}
+
+	return merged
+}
+
+func (rp *RateLimitPolicyImpl) Enabled() bool {
+	return len(rp.rateLimitEntries) > 0
+}
+
+func (rp *RateLimitPolicyImpl) GetApplicableRateLimit(stage uint64) []types.RateLimitPolicyEntry {
+	if stage > rp.maxStageNumber {
+		return nil
+	}
+
+	var entries []types.RateLimitPolicyEntry
+	for _, entry := range rp.rateLimitEntries {
+		if entry.Stage() == stage {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+const (
+	defaultBackOffBaseInterval = 25 * time.Millisecond
+	// max interval is 10 times of the base interval by default
+	defaultBackOffMaxIntervalFactor = 10
+)
+
 type RetryPolicyImpl struct {
-	retryOn      bool
-	retryTimeout time.Duration
-	numRetries   uint32
+	retryOn              bool
+	retryTimeout         time.Duration
+	numRetries           uint32
+	retryConditions      map[v2.RetryCondition]bool
+	retriableStatusCodes []uint32
+	backOffBaseInterval  time.Duration
+	backOffMaxInterval   time.Duration
+}
+
+func NewRetryPolicyImpl(policy *v2.RetryPolicy) *RetryPolicyImpl {
+	p := &RetryPolicyImpl{
+		retryConditions:     make(map[v2.RetryCondition]bool),
+		backOffBaseInterval: defaultBackOffBaseInterval,
+	}
+
+	if policy != nil {
+		p.retryOn = policy.RetryOn
+		p.retryTimeout = policy.RetryTimeout
+		p.numRetries = policy.NumRetries
+		p.retriableStatusCodes = policy.RetriableStatusCodes
+
+		for _, condition := range policy.RetryConditions {
+			p.retryConditions[condition] = true
+		}
+
+		if policy.BackOffBaseInterval > 0 {
+			p.backOffBaseInterval = policy.BackOffBaseInterval
+		}
+		p.backOffMaxInterval = policy.BackOffMaxInterval
+	}
+
+	// keep retrying on 5xx by default
+	if len(p.retryConditions) == 0 {
+		p.retryConditions[v2.RetryOn5xx] = true
+	}
+
+	if p.backOffMaxInterval < p.backOffBaseInterval {
+		p.backOffMaxInterval = p.backOffBaseInterval * defaultBackOffMaxIntervalFactor
+	}
+
+	return p
 }
 
 func (p *RetryPolicyImpl) RetryOn() bool {
@@ -129,7 +371,116 @@ func (p *RetryPolicyImpl) NumRetries() uint32 {
 	return p.numRetries
 }
 
-// todo implement CorsPolicy
+func (p *RetryPolicyImpl) RetryOnCondition(condition v2.RetryCondition) bool {
+	return p.retryConditions[condition]
+}
+
+func (p *RetryPolicyImpl) RetriableStatusCodes() []uint32 {
+	return p.retriableStatusCodes
+}
+
+func (p *RetryPolicyImpl) BackOffBaseInterval() time.Duration {
+	return p.backOffBaseInterval
+}
+
+func (p *RetryPolicyImpl) BackOffMaxInterval() time.Duration {
+	return p.backOffMaxInterval
+}
+
+type originMatcher struct {
+	exact  string
+	prefix string
+	regex  *regexp.Regexp
+}
+
+func (om *originMatcher) match(origin string) bool {
+	switch {
+	case om.exact != "":
+		return om.exact == "*" || om.exact == origin
+	case om.prefix != "":
+		return strings.HasPrefix(origin, om.prefix)
+	case om.regex != nil:
+		return om.regex.MatchString(origin)
+	}
+
+	return false
+}
+
+type CorsPolicyImpl struct {
+	allowOrigins     []*originMatcher
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	maxAge           string
+	allowCredentials bool
+	enabled          bool
+}
+
+func NewCorsPolicyImpl(policy *v2.CorsPolicy) *CorsPolicyImpl {
+	p := &CorsPolicyImpl{
+		allowMethods:     policy.AllowMethods,
+		allowHeaders:     policy.AllowHeaders,
+		exposeHeaders:    policy.ExposeHeaders,
+		maxAge:           policy.MaxAge,
+		allowCredentials: policy.AllowCredentials,
+		enabled:          !policy.Disabled,
+	}
+
+	for _, origin := range policy.AllowOrigins {
+		matcher := &originMatcher{
+			exact:  origin.Exact,
+			prefix: origin.Prefix,
+		}
+
+		if origin.Regex != "" {
+			regex, err := regexp.Compile(origin.Regex)
+			if err != nil {
+				log.DefaultLogger.Errorf("compile cors origin regex %s failed: %v", origin.Regex, err)
+				continue
+			}
+
+			matcher.regex = regex
+		}
+
+		p.allowOrigins = append(p.allowOrigins, matcher)
+	}
+
+	return p
+}
+
+func (p *CorsPolicyImpl) AllowOrigin(origin string) bool {
+	for _, matcher := range p.allowOrigins {
+		if matcher.match(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *CorsPolicyImpl) AllowMethods() string {
+	return p.allowMethods
+}
+
+func (p *CorsPolicyImpl) AllowHeaders() string {
+	return p.allowHeaders
+}
+
+func (p *CorsPolicyImpl) ExposeHeaders() string {
+	return p.exposeHeaders
+}
+
+func (p *CorsPolicyImpl) MaxAga() string {
+	return p.maxAge
+}
+
+func (p *CorsPolicyImpl) AllowCredentials() bool {
+	return p.allowCredentials
+}
+
+func (p *CorsPolicyImpl) Enabled() bool {
+	return p.enabled
+}
 
 type RuntimeData struct {
 	key          string
@@ -139,7 +490,27 @@ type RuntimeData struct {
 type RateLimitPolicyEntryImpl struct {
 	stage      uint64
 	disableKey string
-	actions    RateLimitAction
+	actions    []RateLimitAction
+}
+
+func NewRateLimitPolicyEntryImpl(rateLimit v2.RateLimit) *RateLimitPolicyEntryImpl {
+	entry := &RateLimitPolicyEntryImpl{
+		stage:      rateLimit.Stage,
+		disableKey: rateLimit.DisableKey,
+	}
+
+	for _, config := range rateLimit.Actions {
+		if action := newRateLimitAction(config); action != nil {
+			entry.actions = append(entry.actions, action)
+		}
+	}
+
+	if len(entry.actions) == 0 {
+		log.DefaultLogger.Errorf("rate limit has no valid action, ignore it")
+		return nil
+	}
+
+	return entry
 }
 
 func (rpei *RateLimitPolicyEntryImpl) Stage() uint64 {
@@ -151,50 +522,188 @@ func (rpei *RateLimitPolicyEntryImpl) DisableKey() string {
 }
 
 func (rpei *RateLimitPolicyEntryImpl) PopulateDescriptors(route types.RouteRule, descriptors []types.Descriptor, localSrvCluster string,
-	headers map[string]string, remoteAddr string) {
+	headers map[string]string, remoteAddr string) []types.Descriptor {
+	descriptor := types.Descriptor{}
+
+	for _, action := range rpei.actions {
+		entry, ok := action.populateDescriptorEntry(route, localSrvCluster, headers, remoteAddr)
+		if !ok {
+			return descriptors
+		}
+
+		descriptor.Entries = append(descriptor.Entries, entry)
+	}
+
+	return append(descriptors, descriptor)
 }
 
-type RateLimitAction interface{}
+const (
+	descriptorKeyDestinationCluster = "destination_cluster"
+	descriptorKeyVirtualHost        = "virtual_host"
+	descriptorKeyRemoteAddress      = "remote_address"
+	descriptorKeyGenericKey         = "generic_key"
+)
+
+// RateLimitAction generates a descriptor entry of the request,
+// false is returned if the entry can not be generated
+type RateLimitAction interface {
+	populateDescriptorEntry(route types.RouteRule, localSrvCluster string,
+		headers map[string]string, remoteAddr string) (types.DescriptorEntry, bool)
+}
+
+type rateLimitActionImpl struct {
+	action v2.RateLimitAction
+}
+
+func newRateLimitAction(action v2.RateLimitAction) RateLimitAction {
+	if action.DestinationCluster == nil && action.VirtualHost == nil && action.RequestHeaders == nil &&
+		action.RemoteAddress == nil && action.SofaRPC == nil && action.GenericKey == nil {
+		log.DefaultLogger.Errorf("rate limit action is empty, ignore it")
+		return nil
+	}
+
+	return &rateLimitActionImpl{
+		action: action,
+	}
+}
+
+func (ra *rateLimitActionImpl) populateDescriptorEntry(route types.RouteRule, localSrvCluster string,
+	headers map[string]string, remoteAddr string) (types.DescriptorEntry, bool) {
+	var key, value string
+
+	switch {
+	case ra.action.DestinationCluster != nil:
+		key, value = descriptorKeyDestinationCluster, route.ClusterName()
+
+	case ra.action.VirtualHost != nil:
+		key = descriptorKeyVirtualHost
+		if vHost := route.VirtualHost(); vHost != nil {
+			value = vHost.Name()
+		}
+
+	case ra.action.RequestHeaders != nil:
+		headerName := strings.ToLower(ra.action.RequestHeaders.HeaderName)
+
+		key = ra.action.RequestHeaders.DescriptorKey
+		if key == "" {
+			key = headerName
+		}
+		value = headers[headerName]
+
+	case ra.action.RemoteAddress != nil:
+		key, value = descriptorKeyRemoteAddress, remoteAddr
+		if ip, _, err := net.SplitHostPort(remoteAddr); err == nil {
+			value = ip
+		}
+
+	case ra.action.SofaRPC != nil:
+		property := ra.action.SofaRPC.Property
+		if property == "" {
+			property = sofaRPCPropertyService
+		}
+
+		key = ra.action.SofaRPC.DescriptorKey
+		if key == "" {
+			key = property
+		}
+		value = headers[sofarpc.SofaPropertyHeader(property)]
+
+	case ra.action.GenericKey != nil:
+		key, value = descriptorKeyGenericKey, ra.action.GenericKey.DescriptorValue
+	}
+
+	if value == "" {
+		return types.DescriptorEntry{}, false
+	}
+
+	return types.DescriptorEntry{Key: key, Value: value}, true
+}
 
 type WeightedClusterEntry struct {
+	clusterName                  string
 	runtimeKey                   string
-	loader                       types.Loader
 	clusterWeight                uint64
 	clusterMetadataMatchCriteria *MetadataMatchCriteriaImpl
 }
 
-type routerPolicy struct {
-	retryOn      bool
-	retryTimeout time.Duration
-	numRetries   uint32
+// weight returns the cluster's weight in runtime, the configured weight is the default
+func (wce *WeightedClusterEntry) weight(snapshot types.RuntimeSnapshot) uint64 {
+	if wce.runtimeKey == "" {
+		return wce.clusterWeight
+	}
+
+	return snapshot.GetInteger(wce.runtimeKey, wce.clusterWeight)
 }
 
-func (p *routerPolicy) RetryOn() bool {
-	return p.retryOn
+// weightedClusterRoute is the route of a weighted cluster selected on request,
+// the cluster name and metadata match criteria are the weighted cluster's
+type weightedClusterRoute struct {
+	*RouteRuleImplBase
+	entry *WeightedClusterEntry
 }
 
-func (p *routerPolicy) TryTimeout() time.Duration {
-	return p.retryTimeout
+func (wcr *weightedClusterRoute) RouteRule() types.RouteRule {
+	return wcr
 }
 
-func (p *routerPolicy) NumRetries() uint32 {
-	return p.numRetries
+func (wcr *weightedClusterRoute) ClusterName() string {
+	return wcr.entry.clusterName
+}
+
+func (wcr *weightedClusterRoute) MetadataMatchCriteria() types.MetadataMatchCriteria {
+	if wcr.entry.clusterMetadataMatchCriteria == nil {
+		return wcr.RouteRuleImplBase.MetadataMatchCriteria()
+	}
+
+	return wcr.entry.clusterMetadataMatchCriteria
+}
+
+type routerPolicy struct {
+	retryPolicy     *RetryPolicyImpl
+	hashPolicy      *HashPolicyImpl
+	shadowPolicy    *ShadowPolicyImpl
+	rateLimitPolicy *RateLimitPolicyImpl
+	corsPolicy      *CorsPolicyImpl
 }
 
 func (p *routerPolicy) RetryPolicy() types.RetryPolicy {
-	return p
+	return p.retryPolicy
 }
 
 func (p *routerPolicy) ShadowPolicy() types.ShadowPolicy {
-	return nil
+	if p.shadowPolicy == nil {
+		return nil
+	}
+
+	return p.shadowPolicy
 }
 
 func (p *routerPolicy) CorsPolicy() types.CorsPolicy {
-	return nil
+	if p.corsPolicy == nil {
+		return nil
+	}
+
+	return p.corsPolicy
 }
 
 func (p *routerPolicy) LoadBalancerPolicy() types.LoadBalancerPolicy {
-	return nil
+	if p.hashPolicy == nil {
+		return nil
+	}
+
+	return p
+}
+
+func (p *routerPolicy) HashPolicy() types.HashPolicy {
+	return p.hashPolicy
+}
+
+func (p *routerPolicy) RateLimitPolicy() types.RateLimitPolicy {
+	if p.rateLimitPolicy == nil {
+		return nil
+	}
+
+	return p.rateLimitPolicy
 }
 
 // e.g. metadata =  { "filter_metadata": {"mosn.lb": { "label": "gray"  } } }
@@ -223,7 +732,11 @@ func GetClusterMosnLBMetaDataMap(metadata v2.Metadata) types.RouteMetaData {
 
 // get mosn lb metadata from config
 func GetMosnLBMetaData(route *v2.Router) map[string]interface{} {
-	if metadataInterface, ok := route.Route.MetadataMatch[types.RouterMetadataKey]; ok {
+	return getMosnLBMetaData(route.Route.MetadataMatch)
+}
+
+func getMosnLBMetaData(metadata v2.Metadata) map[string]interface{} {
+	if metadataInterface, ok := metadata[types.RouterMetadataKey]; ok {
 		if value, ok := metadataInterface.(map[string]interface{}); ok {
 			if mosnLbInterface, ok := value[types.RouterMetadataKeyLb]; ok {
 				if mosnLb, ok := mosnLbInterface.(map[string]interface{}); ok {
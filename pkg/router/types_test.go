@@ -20,6 +20,7 @@ package router
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/types"
@@ -95,3 +96,136 @@ func TestGetEnvoyLBMetaData(t *testing.T) {
 		})
 	}
 }
+
+func TestHashPolicyImpl_GenerateHash(t *testing.T) {
+	headers := map[string]string{
+		"x-user-id": "user1",
+		"cookie":    "session=abc; lang=en",
+		"service":   "com.alipay.test.TestService:1.0",
+		"appname":   "testapp",
+	}
+
+	tests := []struct {
+		name     string
+		policies []v2.HashPolicy
+		want     types.HashedValue
+	}{
+		{
+			name:     "header",
+			policies: []v2.HashPolicy{{Header: &v2.HeaderHashPolicy{Name: "X-User-Id"}}},
+			want:     "user1",
+		},
+		{
+			name:     "cookie",
+			policies: []v2.HashPolicy{{Cookie: &v2.CookieHashPolicy{Name: "session"}}},
+			want:     "abc",
+		},
+		{
+			name:     "source ip",
+			policies: []v2.HashPolicy{{ConnectionProperties: &v2.ConnectionPropertiesHashPolicy{SourceIP: true}}},
+			want:     "10.1.1.1",
+		},
+		{
+			name:     "sofarpc service",
+			policies: []v2.HashPolicy{{SofaRPC: &v2.SofaRPCHashPolicy{}}},
+			want:     "com.alipay.test.TestService:1.0",
+		},
+		{
+			name:     "sofarpc property",
+			policies: []v2.HashPolicy{{SofaRPC: &v2.SofaRPCHashPolicy{Property: "appname"}}},
+			want:     "testapp",
+		},
+		{
+			name: "multiple",
+			policies: []v2.HashPolicy{
+				{Header: &v2.HeaderHashPolicy{Name: "not-exist"}},
+				{Header: &v2.HeaderHashPolicy{Name: "x-user-id"}},
+				{Cookie: &v2.CookieHashPolicy{Name: "lang"}},
+			},
+			want: "user1|en",
+		},
+		{
+			name: "terminal",
+			policies: []v2.HashPolicy{
+				{Header: &v2.HeaderHashPolicy{Name: "x-user-id"}, Terminal: true},
+				{Cookie: &v2.CookieHashPolicy{Name: "lang"}},
+			},
+			want: "user1",
+		},
+		{
+			name:     "not found",
+			policies: []v2.HashPolicy{{Cookie: &v2.CookieHashPolicy{Name: "not-exist"}}},
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := NewHashPolicyImpl(tt.policies)
+			if got := hp.GenerateHash("10.1.1.1:5678", headers, nil); got != tt.want {
+				t.Errorf("GenerateHash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashPolicyImpl_GenerateCookie(t *testing.T) {
+	hp := NewHashPolicyImpl([]v2.HashPolicy{
+		{Cookie: &v2.CookieHashPolicy{Name: "session", Path: "/", TTL: time.Hour}},
+	})
+
+	var cookieName, cookieValue string
+	addCookieCb := func(name string, value string, path string, ttl time.Duration) {
+		cookieName = name
+		cookieValue = value
+	}
+
+	got := hp.GenerateHash("10.1.1.1:5678", map[string]string{}, addCookieCb)
+	if got == "" || cookieName != "session" || string(got) != cookieValue {
+		t.Fatalf("cookie should be generated, got hash key %v, cookie %s=%s", got, cookieName, cookieValue)
+	}
+
+	if again := hp.GenerateHash("10.1.1.1:5678", map[string]string{"cookie": "session=" + cookieValue}, nil); again != got {
+		t.Errorf("hash key from generated cookie should be %v, got %v", got, again)
+	}
+}
+
+func TestNewRetryPolicyImpl(t *testing.T) {
+	p := NewRetryPolicyImpl(nil)
+	if p.RetryOn() || !p.RetryOnCondition(v2.RetryOn5xx) {
+		t.Errorf("retry should be disabled and retry on 5xx by default")
+	}
+	if p.BackOffBaseInterval() != defaultBackOffBaseInterval ||
+		p.BackOffMaxInterval() != defaultBackOffBaseInterval*defaultBackOffMaxIntervalFactor {
+		t.Errorf("unexpected default back off, base %v, max %v", p.BackOffBaseInterval(), p.BackOffMaxInterval())
+	}
+
+	p = NewRetryPolicyImpl(&v2.RetryPolicy{
+		RetryOn:             true,
+		NumRetries:          2,
+		RetryConditions:     []v2.RetryCondition{v2.RetryOnConnectFailure},
+		BackOffBaseInterval: 10 * time.Millisecond,
+		BackOffMaxInterval:  50 * time.Millisecond,
+	})
+	if !p.RetryOn() || p.NumRetries() != 2 {
+		t.Errorf("retry on or num retries is not set")
+	}
+	if p.RetryOnCondition(v2.RetryOn5xx) || !p.RetryOnCondition(v2.RetryOnConnectFailure) {
+		t.Errorf("configured conditions should replace the default one")
+	}
+	if p.BackOffBaseInterval() != 10*time.Millisecond || p.BackOffMaxInterval() != 50*time.Millisecond {
+		t.Errorf("unexpected back off, base %v, max %v", p.BackOffBaseInterval(), p.BackOffMaxInterval())
+	}
+}
+
+func TestNewShadowPolicyImpl(t *testing.T) {
+	p := NewShadowPolicyImpl(&v2.ShadowPolicy{Cluster: "shadow"})
+	if p.ClusterName() != "shadow" || p.Percent() != 100 {
+		t.Errorf("all requests should be mirrored by default, got %d", p.Percent())
+	}
+
+	p = NewShadowPolicyImpl(&v2.ShadowPolicy{Cluster: "shadow", Percent: 10})
+	if p.Percent() != 10 {
+		t.Errorf("want 10 percent of requests mirrored, got %d", p.Percent())
+	}
+}
@@ -38,6 +38,15 @@ func NewVirtualHostImpl(virtualHost *v2.VirtualHost, validateClusters bool) *Vir
 		virtualHostImpl.sslRequirements = types.NONE
 	}
 
+	// rate limits and cors policy must be set before routes, which may include them
+	if len(virtualHost.RateLimits) > 0 {
+		virtualHostImpl.rateLimitPolicy = NewRateLimitPolicyImpl(virtualHost.RateLimits)
+	}
+
+	if virtualHost.Cors != nil {
+		virtualHostImpl.corsPolicy = NewCorsPolicyImpl(virtualHost.Cors)
+	}
+
 	for _, route := range virtualHost.Routers {
 
 		if route.Match.Prefix != "" {
@@ -103,7 +112,8 @@ type VirtualHostImpl struct {
 	routes                []RouteBase //route impl
 	virtualClusters       []VirtualClusterEntry
 	sslRequirements       types.SslRequirements
-	corsPolicy            types.CorsPolicy
+	corsPolicy            *CorsPolicyImpl
+	rateLimitPolicy       *RateLimitPolicyImpl
 	globalRouteConfig     *ConfigImpl
 	requestHeadersParser  *HeaderParser
 	responseHeadersParser *HeaderParser
@@ -115,13 +125,19 @@ func (vh *VirtualHostImpl) Name() string {
 }
 
 func (vh *VirtualHostImpl) CorsPolicy() types.CorsPolicy {
+	if vh.corsPolicy == nil {
+		return nil
+	}
 
-	return nil
+	return vh.corsPolicy
 }
 
 func (vh *VirtualHostImpl) RateLimitPolicy() types.RateLimitPolicy {
+	if vh.rateLimitPolicy == nil {
+		return nil
+	}
 
-	return nil
+	return vh.rateLimitPolicy
 }
 
 func (vh *VirtualHostImpl) GetRouteFromEntries(headers map[string]string, randomValue uint64) types.Route {
@@ -0,0 +1,249 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+const (
+	// values are loaded from the runtime files
+	DiskLayer = "disk"
+	// values are set by admin api, which take precedence over the files
+	AdminLayer = "admin"
+
+	// dirs deeper than it are not loaded, in case of symbolic link loops
+	maxDirDepth = 16
+)
+
+var (
+	// the loader used by the package level functions, holds a *loader
+	defaultLoader atomic.Value
+
+	// serializes initializing and stopping the default loader
+	mux sync.Mutex
+	// stops reloading the runtime files of the default loader
+	stopChan chan bool
+)
+
+func init() {
+	defaultLoader.Store(newLoader(""))
+}
+
+// Init loads the runtime files in the root dir by a new default loader, the files are reloaded
+// in the refresh interval if it's positive
+func Init(rootDir string, refreshInterval time.Duration) error {
+	l := newLoader(rootDir)
+	if err := l.Reload(); err != nil {
+		return err
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	stopRefresh()
+	defaultLoader.Store(l)
+
+	if refreshInterval > 0 {
+		stopChan = make(chan bool)
+		go refresh(l, refreshInterval, stopChan)
+	}
+
+	log.DefaultLogger.Infof("runtime loaded from %s", rootDir)
+
+	return nil
+}
+
+// Stop stops reloading the runtime files of the default loader
+func Stop() {
+	mux.Lock()
+	defer mux.Unlock()
+
+	stopRefresh()
+}
+
+func stopRefresh() {
+	if stopChan != nil {
+		close(stopChan)
+		stopChan = nil
+	}
+}
+
+func refresh(l *loader, interval time.Duration, stop chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := l.Reload(); err != nil {
+				log.DefaultLogger.Errorf("reload runtime from %s failed: %v", l.rootDir, err)
+			}
+		}
+	}
+}
+
+// Default returns the default loader
+func Default() types.Loader {
+	return defaultLoader.Load().(*loader)
+}
+
+// GetSnapshot returns the current snapshot of the default loader
+func GetSnapshot() types.RuntimeSnapshot {
+	return Default().Snapshot()
+}
+
+// FeatureEnabled returns whether a feature is enabled in the current snapshot with a random value
+func FeatureEnabled(key string, defaultPercent uint64) bool {
+	return GetSnapshot().FeatureEnabled(key, defaultPercent, rand.Uint64())
+}
+
+// types.Loader
+type loader struct {
+	rootDir string
+
+	// serializes loading and modifying the layers
+	mux        sync.Mutex
+	diskLayer  map[string]string
+	adminLayer map[string]string
+
+	// holds a *snapshot
+	snapshot atomic.Value
+}
+
+// NewLoader creates a loader with the runtime files in the root dir, the loader has
+// only the admin layer if the root dir is empty
+func NewLoader(rootDir string) (types.Loader, error) {
+	l := newLoader(rootDir)
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func newLoader(rootDir string) *loader {
+	l := &loader{
+		rootDir:    rootDir,
+		diskLayer:  make(map[string]string),
+		adminLayer: make(map[string]string),
+	}
+	l.snapshot.Store(newSnapshot())
+
+	return l
+}
+
+func (l *loader) Snapshot() types.RuntimeSnapshot {
+	return l.snapshot.Load().(*snapshot)
+}
+
+func (l *loader) Reload() error {
+	values := make(map[string]string)
+
+	if l.rootDir != "" {
+		if err := loadDir(l.rootDir, "", 0, values); err != nil {
+			return err
+		}
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.diskLayer = values
+	l.swapSnapshot()
+
+	return nil
+}
+
+func (l *loader) MergeValues(values map[string]string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for key, value := range values {
+		if value == "" {
+			delete(l.adminLayer, key)
+		} else {
+			l.adminLayer[key] = value
+		}
+	}
+
+	l.swapSnapshot()
+}
+
+// swapSnapshot is called with the lock held, the layers are copied into the snapshot
+func (l *loader) swapSnapshot() {
+	l.snapshot.Store(newSnapshot(
+		types.RuntimeLayer{Name: DiskLayer, Values: l.diskLayer},
+		types.RuntimeLayer{Name: AdminLayer, Values: l.adminLayer},
+	))
+}
+
+// loadDir loads the files in the dir recursively, a file's key is its path relative to the root dir
+// with separators replaced by ".", and its value is the trimmed content. Symbolic links are followed,
+// and hidden files are skipped, such as the "..data" link of a kubernetes config map volume
+func loadDir(dir string, prefix string, depth int, values map[string]string) error {
+	if depth > maxDirDepth {
+		return fmt.Errorf("runtime dir %s is too deep", dir)
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		if info, err = os.Stat(path); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if err := loadDir(path, prefix+name+".", depth+1, values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		values[prefix+name] = strings.TrimSpace(string(content))
+	}
+
+	return nil
+}
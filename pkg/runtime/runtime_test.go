@@ -0,0 +1,164 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+)
+
+func writeRuntimeFile(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeRuntimeFile(t, dir, "route.canary", "10\n")
+	writeRuntimeFile(t, dir, "fault/http/delay/fixed_delay_percent", " 50 ")
+	writeRuntimeFile(t, dir, ".hidden/key", "1")
+
+	l, err := NewLoader(dir)
+	if err != nil {
+		t.Fatalf("load runtime failed: %v", err)
+	}
+
+	snapshot := l.Snapshot()
+	if value, ok := snapshot.Get("route.canary"); !ok || value != "10" {
+		t.Errorf("expected value trimmed, but got %q", value)
+	}
+	if value := snapshot.GetInteger("fault.http.delay.fixed_delay_percent", 0); value != 50 {
+		t.Errorf("expected key of nested file joined by dots, but got %d", value)
+	}
+	if _, ok := snapshot.Get("hidden.key"); ok {
+		t.Errorf("expected hidden dir skipped")
+	}
+	if value := snapshot.GetInteger("not_found", 7); value != 7 {
+		t.Errorf("expected default value, but got %d", value)
+	}
+
+	// values of admin layer take precedence, the snapshot got before is not changed
+	l.MergeValues(map[string]string{"route.canary": "20", "admin.only": "abc"})
+	if value := l.Snapshot().GetInteger("route.canary", 0); value != 20 {
+		t.Errorf("expected value of admin layer, but got %d", value)
+	}
+	if value := l.Snapshot().GetInteger("admin.only", 3); value != 3 {
+		t.Errorf("expected default value for non integer, but got %d", value)
+	}
+	if value := snapshot.GetInteger("route.canary", 0); value != 10 {
+		t.Errorf("expected old snapshot not changed, but got %d", value)
+	}
+
+	// files are reloaded, admin layer is kept
+	writeRuntimeFile(t, dir, "route.canary", "30")
+	writeRuntimeFile(t, dir, "route.new", "1")
+	if err := l.Reload(); err != nil {
+		t.Fatalf("reload runtime failed: %v", err)
+	}
+	if value := l.Snapshot().GetInteger("route.new", 0); value != 1 {
+		t.Errorf("expected new file loaded, but got %d", value)
+	}
+	if layers := l.Snapshot().Layers(); len(layers) != 2 || layers[0].Values["route.canary"] != "30" {
+		t.Errorf("expected disk layer reloaded, but got %v", layers)
+	}
+
+	l.MergeValues(map[string]string{"route.canary": ""})
+	if value := l.Snapshot().GetInteger("route.canary", 0); value != 30 {
+		t.Errorf("expected value of disk layer after admin value removed, but got %d", value)
+	}
+
+	// current snapshot is kept if loading fails
+	os.RemoveAll(dir)
+	if err := l.Reload(); err == nil {
+		t.Errorf("expected reload failed")
+	}
+	if value := l.Snapshot().GetInteger("route.new", 0); value != 1 {
+		t.Errorf("expected snapshot kept, but got %d", value)
+	}
+}
+
+func TestFeatureEnabled(t *testing.T) {
+	l, _ := NewLoader("")
+	snapshot := l.Snapshot()
+
+	if !snapshot.FeatureEnabled("feature", 30, 129) || snapshot.FeatureEnabled("feature", 30, 130) {
+		t.Errorf("expected requests enabled by default percent")
+	}
+
+	l.MergeValues(map[string]string{"feature": "0"})
+	if l.Snapshot().FeatureEnabled("feature", 30, 0) {
+		t.Errorf("expected feature disabled by runtime")
+	}
+
+	l.MergeValues(map[string]string{"feature": "1000"})
+	if !l.Snapshot().FeatureEnabled("feature", 0, 99) {
+		t.Errorf("expected feature enabled for all requests")
+	}
+}
+
+func TestInit(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	dir, err := ioutil.TempDir("", "runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeRuntimeFile(t, dir, "key", "1")
+
+	if err := Init(dir, 10*time.Millisecond); err != nil {
+		t.Fatalf("init runtime failed: %v", err)
+	}
+	defer Stop()
+
+	if value := GetSnapshot().GetInteger("key", 0); value != 1 {
+		t.Fatalf("expected runtime loaded, but got %d", value)
+	}
+
+	writeRuntimeFile(t, dir, "key", "2")
+	for i := 0; i < 50 && GetSnapshot().GetInteger("key", 0) != 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if value := GetSnapshot().GetInteger("key", 0); value != 2 {
+		t.Errorf("expected runtime refreshed, but got %d", value)
+	}
+
+	if err := Init(filepath.Join(dir, "not_found"), 0); err == nil {
+		t.Errorf("expected init failed with a missing dir")
+	}
+	if value := GetSnapshot().GetInteger("key", 0); value != 2 {
+		t.Errorf("expected default loader kept if init failed, but got %d", value)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+const maxFeaturePercent = 100
+
+// types.RuntimeSnapshot
+type snapshot struct {
+	values map[string]string
+	layers []types.RuntimeLayer
+}
+
+// newSnapshot merges the layers into a snapshot, values of latter layers take precedence
+func newSnapshot(layers ...types.RuntimeLayer) *snapshot {
+	s := &snapshot{
+		values: make(map[string]string),
+	}
+
+	for _, layer := range layers {
+		values := make(map[string]string, len(layer.Values))
+		for key, value := range layer.Values {
+			values[key] = value
+			s.values[key] = value
+		}
+
+		s.layers = append(s.layers, types.RuntimeLayer{
+			Name:   layer.Name,
+			Values: values,
+		})
+	}
+
+	return s
+}
+
+func (s *snapshot) Get(key string) (string, bool) {
+	value, ok := s.values[key]
+
+	return value, ok
+}
+
+func (s *snapshot) GetInteger(key string, defaultValue uint64) uint64 {
+	value, ok := s.values[key]
+	if !ok {
+		return defaultValue
+	}
+
+	integer, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+
+	return integer
+}
+
+func (s *snapshot) FeatureEnabled(key string, defaultPercent uint64, randomValue uint64) bool {
+	percent := s.GetInteger(key, defaultPercent)
+	if percent > maxFeaturePercent {
+		percent = maxFeaturePercent
+	}
+
+	return randomValue%maxFeaturePercent < percent
+}
+
+func (s *snapshot) Layers() []types.RuntimeLayer {
+	return s.layers
+}
@@ -20,8 +20,11 @@ package server
 import (
 	"container/list"
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,8 +43,14 @@ import (
 type connHandler struct {
 	numConnections int64
 	listeners      []*activeListener
+	listenersMux   sync.RWMutex
 	clusterManager types.ClusterManager
 	logger         log.Logger
+
+	// closed when all connections are closed after draining
+	draining  uint32
+	drainDone chan struct{}
+	drainOnce sync.Once
 }
 
 func NewHandler(clusterManagerFilter types.ClusterManagerFilter, clMng types.ClusterManager, logger log.Logger) types.ConnectionHandler {
@@ -50,6 +59,7 @@ func NewHandler(clusterManagerFilter types.ClusterManagerFilter, clMng types.Clu
 		clusterManager: clMng,
 		listeners:      make([]*activeListener, 0),
 		logger:         logger,
+		drainDone:      make(chan struct{}),
 	}
 
 	clusterManagerFilter.OnCreated(ch, ch)
@@ -58,13 +68,20 @@ func NewHandler(clusterManagerFilter types.ClusterManagerFilter, clMng types.Clu
 }
 
 // ClusterConfigFactoryCb
+// clusters are the full set, clusters added via API but not in it are removed
 func (ch *connHandler) UpdateClusterConfig(clusters []v2.Cluster) error {
+	updated := make(map[string]bool, len(clusters))
 
 	for _, cluster := range clusters {
 		ch.clusterManager.AddOrUpdatePrimaryCluster(cluster)
+		updated[cluster.Name] = true
 	}
 
-	// TODO: remove cluster
+	for name, cluster := range ch.clusterManager.Clusters() {
+		if !updated[name] && cluster.Info().AddedViaAPI() {
+			ch.clusterManager.RemovePrimaryCluster(name)
+		}
+	}
 
 	return nil
 }
@@ -84,10 +101,7 @@ func (ch *connHandler) AddListener(lc *v2.ListenerConfig, networkFiltersFactory
 	//TODO: connection level stop-chan usage confirm
 	listenerStopChan := make(chan struct{})
 
-	//use default listener path
-	if lc.LogPath == "" {
-		lc.LogPath = MosnLogBasePath + string(os.PathSeparator) + lc.Name + ".log"
-	}
+	setDefaultLogPath(lc)
 
 	logger, err := log.NewLogger(lc.LogPath, log.Level(lc.LogLevel))
 	if err != nil {
@@ -95,6 +109,71 @@ func (ch *connHandler) AddListener(lc *v2.ListenerConfig, networkFiltersFactory
 	}
 
 	//initialize access log
+	als, err := newAccessLogs(lc)
+	if err != nil {
+		log.StartLogger.Fatalf("initialize listener access logger failed : %v", err)
+	}
+
+	l := network.NewListener(lc, logger)
+
+	al := newActiveListener(l, logger, als, networkFiltersFactory, streamFiltersFactories, ch, listenerStopChan, lc.DisableConnIo)
+	al.config = copyListenerConfig(lc)
+	l.SetListenerCallbacks(al)
+
+	ch.listenersMux.Lock()
+	ch.listeners = append(ch.listeners, al)
+	ch.listenersMux.Unlock()
+
+	return al
+}
+
+func (ch *connHandler) UpdateListener(lc *v2.ListenerConfig, networkFiltersFactory types.NetworkFilterChainFactory,
+	streamFiltersFactories []types.StreamFilterChainFactory) error {
+	al := ch.findActiveListenerByName(lc.Name)
+	if al == nil {
+		return fmt.Errorf("listener %s not found", lc.Name)
+	}
+
+	if al.listener.Addr().String() != lc.Addr.String() {
+		return fmt.Errorf("listener %s address changed from %s to %s", lc.Name, al.listener.Addr(), lc.Addr)
+	}
+
+	setDefaultLogPath(lc)
+
+	// LDS pushes all listeners in every response, the unchanged ones are not drained
+	if al.unchanged(lc, networkFiltersFactory, streamFiltersFactories) {
+		ch.logger.Debugf("listener %s is not changed", lc.Name)
+		return nil
+	}
+
+	als, err := newAccessLogs(lc)
+	if err != nil {
+		return err
+	}
+
+	al.listener.Update(lc)
+	al.update(copyListenerConfig(lc), als, networkFiltersFactory, streamFiltersFactories)
+
+	// the connections accepted before are drained, so that the clients reconnect with the new config
+	al.drainConnections()
+
+	return nil
+}
+
+// use default listener log path
+func setDefaultLogPath(lc *v2.ListenerConfig) {
+	if lc.LogPath == "" {
+		lc.LogPath = MosnLogBasePath + string(os.PathSeparator) + lc.Name + ".log"
+	}
+}
+
+// copyListenerConfig copies the config kept by the active listener, as the caller may reuse it
+func copyListenerConfig(lc *v2.ListenerConfig) *v2.ListenerConfig {
+	config := *lc
+	return &config
+}
+
+func newAccessLogs(lc *v2.ListenerConfig) ([]types.AccessLog, error) {
 	var als []types.AccessLog
 
 	for _, alConfig := range lc.AccessLogs {
@@ -104,25 +183,30 @@ func (ch *connHandler) AddListener(lc *v2.ListenerConfig, networkFiltersFactory
 			alConfig.Path = MosnLogBasePath + string(os.PathSeparator) + lc.Name + "_access.log"
 		}
 
-		if al, err := log.NewAccessLog(alConfig.Path, nil, alConfig.Format); err == nil {
-			als = append(als, al)
-		} else {
-			log.StartLogger.Fatalln("initialize listener access logger %s failed : %v", alConfig.Path, err)
+		al, err := log.NewAccessLog(alConfig.Path, nil, alConfig.Format)
+		if err != nil {
+			return nil, fmt.Errorf("initialize listener access logger %s failed : %v", alConfig.Path, err)
 		}
+
+		als = append(als, al)
 	}
 
-	l := network.NewListener(lc, logger)
+	return als, nil
+}
 
-	al := newActiveListener(l, logger, als, networkFiltersFactory, streamFiltersFactories, ch, listenerStopChan, lc.DisableConnIo)
-	l.SetListenerCallbacks(al)
+// activeListeners returns a copy of the listeners, which may be added or removed at runtime
+func (ch *connHandler) activeListeners() []*activeListener {
+	ch.listenersMux.RLock()
+	defer ch.listenersMux.RUnlock()
 
-	ch.listeners = append(ch.listeners, al)
+	listeners := make([]*activeListener, len(ch.listeners))
+	copy(listeners, ch.listeners)
 
-	return al
+	return listeners
 }
 
 func (ch *connHandler) StartListener(lctx context.Context, listenerTag uint64) {
-	for _, l := range ch.listeners {
+	for _, l := range ch.activeListeners() {
 		if l.listener.ListenerTag() == listenerTag {
 			// TODO: use goroutine pool
 			go l.listener.Start(nil)
@@ -131,7 +215,7 @@ func (ch *connHandler) StartListener(lctx context.Context, listenerTag uint64) {
 }
 
 func (ch *connHandler) StartListeners(lctx context.Context) {
-	for _, l := range ch.listeners {
+	for _, l := range ch.activeListeners() {
 		// start goroutine
 		go l.listener.Start(nil)
 	}
@@ -148,6 +232,9 @@ func (ch *connHandler) FindListenerByAddress(addr net.Addr) types.Listener {
 }
 
 func (ch *connHandler) RemoveListeners(listenerTag uint64) {
+	ch.listenersMux.Lock()
+	defer ch.listenersMux.Unlock()
+
 	for i, l := range ch.listeners {
 		if l.listener.ListenerTag() == listenerTag {
 			ch.listeners = append(ch.listeners[:i], ch.listeners[i+1:]...)
@@ -155,8 +242,33 @@ func (ch *connHandler) RemoveListeners(listenerTag uint64) {
 	}
 }
 
+func (ch *connHandler) RemoveListener(name string) error {
+	ch.listenersMux.Lock()
+	var al *activeListener
+	for i, l := range ch.listeners {
+		if l.listener.Name() == name {
+			al = l
+			ch.listeners = append(ch.listeners[:i], ch.listeners[i+1:]...)
+			break
+		}
+	}
+	ch.listenersMux.Unlock()
+
+	if al == nil {
+		return fmt.Errorf("listener %s not found", name)
+	}
+
+	if err := al.listener.Close(nil); err != nil {
+		al.logger.Errorf("close listener %s failed: %v", name, err)
+	}
+
+	al.drainConnections()
+
+	return nil
+}
+
 func (ch *connHandler) StopListener(lctx context.Context, listenerTag uint64) {
-	for _, l := range ch.listeners {
+	for _, l := range ch.activeListeners() {
 		if l.listener.ListenerTag() == listenerTag {
 			// stop goroutine
 			l.listener.Stop()
@@ -165,7 +277,7 @@ func (ch *connHandler) StopListener(lctx context.Context, listenerTag uint64) {
 }
 
 func (ch *connHandler) StopListeners(lctx context.Context, close bool) {
-	for _, l := range ch.listeners {
+	for _, l := range ch.activeListeners() {
 		// stop goroutine
 		if close {
 			l.listener.Close(lctx)
@@ -176,9 +288,10 @@ func (ch *connHandler) StopListeners(lctx context.Context, close bool) {
 }
 
 func (ch *connHandler) ListListenersFD(lctx context.Context) []uintptr {
-	fds := make([]uintptr, len(ch.listeners))
+	listeners := ch.activeListeners()
+	fds := make([]uintptr, len(listeners))
 
-	for idx, l := range ch.listeners {
+	for idx, l := range listeners {
 		fd, err := l.listener.ListenerFD()
 		if err != nil {
 			log.DefaultLogger.Errorf("fail to get listener %s file descriptor: %v", l.listener.Name(), err)
@@ -189,8 +302,57 @@ func (ch *connHandler) ListListenersFD(lctx context.Context) []uintptr {
 	return fds
 }
 
+func (ch *connHandler) DrainConnections() <-chan struct{} {
+	if atomic.CompareAndSwapUint32(&ch.draining, 0, 1) {
+		for _, l := range ch.activeListeners() {
+			l.drainConnections()
+		}
+
+		ch.checkDrained()
+	}
+
+	return ch.drainDone
+}
+
+func (ch *connHandler) TransferConnections(transfer func(rawc net.Conn, unread []byte) error) {
+	for _, l := range ch.activeListeners() {
+		l.transferConnections(transfer)
+	}
+}
+
+func (ch *connHandler) ResumeConnection(rawc net.Conn, unread []byte) error {
+	l := ch.findActiveListenerByLocalAddress(rawc.LocalAddr())
+
+	if l == nil {
+		return errors.New("no listener found for " + rawc.LocalAddr().String())
+	}
+
+	l.resumeConnection(rawc, unread)
+
+	return nil
+}
+
+// checkDrained closes drainDone once all connections are closed after draining
+func (ch *connHandler) checkDrained() {
+	if atomic.LoadUint32(&ch.draining) == 1 && atomic.LoadInt64(&ch.numConnections) == 0 {
+		ch.drainOnce.Do(func() {
+			close(ch.drainDone)
+		})
+	}
+}
+
+func (ch *connHandler) findActiveListenerByName(name string) *activeListener {
+	for _, l := range ch.activeListeners() {
+		if l.listener.Name() == name {
+			return l
+		}
+	}
+
+	return nil
+}
+
 func (ch *connHandler) findActiveListenerByAddress(addr net.Addr) *activeListener {
-	for _, l := range ch.listeners {
+	for _, l := range ch.activeListeners() {
 		if l.listener != nil {
 			if l.listener.Addr().Network() == addr.Network() &&
 				l.listener.Addr().String() == addr.String() {
@@ -202,6 +364,24 @@ func (ch *connHandler) findActiveListenerByAddress(addr net.Addr) *activeListene
 	return nil
 }
 
+// findActiveListenerByLocalAddress finds the listener accepting connections on the local address,
+// which may listen on the unspecified address
+func (ch *connHandler) findActiveListenerByLocalAddress(addr net.Addr) *activeListener {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+
+	for _, l := range ch.activeListeners() {
+		if la, ok := l.listener.Addr().(*net.TCPAddr); ok && la.Port == tcpAddr.Port &&
+			(la.IP.IsUnspecified() || la.IP.Equal(tcpAddr.IP)) {
+			return l
+		}
+	}
+
+	return nil
+}
+
 // ListenerEventListener
 type activeListener struct {
 	disableConnIo          bool
@@ -218,6 +398,9 @@ type activeListener struct {
 	stats                  *ListenerStats
 	logger                 log.Logger
 	accessLogs             []types.AccessLog
+	// the config the listener is added or updated with
+	config    *v2.ListenerConfig
+	configMux sync.RWMutex
 }
 
 func newActiveListener(listener types.Listener, logger log.Logger, accessLoggers []types.AccessLog,
@@ -246,7 +429,7 @@ func newActiveListener(listener types.Listener, logger log.Logger, accessLoggers
 
 	al.listenIP = listenIP
 	al.listenPort = listenPort
-	al.statsNamespace = types.ListenerStatsPrefix + strconv.Itoa(listenPort)
+	al.statsNamespace = fmt.Sprintf(types.ListenerStatsPrefix, listenPort)
 	al.stats = newListenerStats(al.statsNamespace)
 
 	return al
@@ -265,6 +448,42 @@ func (al *activeListener) OnAccept(rawc net.Conn, handOffRestoredDestinationConn
 		log.DefaultLogger.Infof("accept connection from:%s", al.listener.Addr().String())
 	}
 
+	ctx := al.newContext()
+	if oriRemoteAddr != nil {
+		ctx = context.WithValue(ctx, types.ContextOriRemoteAddr, oriRemoteAddr)
+	}
+	arc.ContinueFilterChain(ctx, true)
+}
+
+// update the config of the listener, which is used by the connections accepted after
+func (al *activeListener) update(lc *v2.ListenerConfig, accessLogs []types.AccessLog, networkFiltersFactory types.NetworkFilterChainFactory,
+	streamFiltersFactories []types.StreamFilterChainFactory) {
+	al.configMux.Lock()
+	defer al.configMux.Unlock()
+
+	al.config = lc
+	al.accessLogs = accessLogs
+	al.networkFiltersFactory = networkFiltersFactory
+	al.streamFiltersFactories = streamFiltersFactories
+	al.disableConnIo = lc.DisableConnIo
+}
+
+// unchanged returns whether the config and filters are the same as the listener's,
+// filters are compared by their configs as they are created again on every update
+func (al *activeListener) unchanged(lc *v2.ListenerConfig, networkFiltersFactory types.NetworkFilterChainFactory,
+	streamFiltersFactories []types.StreamFilterChainFactory) bool {
+	al.configMux.RLock()
+	defer al.configMux.RUnlock()
+
+	return reflect.DeepEqual(al.config, lc) &&
+		reflect.DeepEqual(al.networkFiltersFactory, networkFiltersFactory) &&
+		reflect.DeepEqual(al.streamFiltersFactories, streamFiltersFactories)
+}
+
+func (al *activeListener) newContext() context.Context {
+	al.configMux.RLock()
+	defer al.configMux.RUnlock()
+
 	ctx := context.WithValue(context.Background(), types.ContextKeyListenerPort, al.listenPort)
 	ctx = context.WithValue(ctx, types.ContextKeyListenerName, al.listener.Name())
 	ctx = context.WithValue(ctx, types.ContextKeyListenerStatsNameSpace, al.statsNamespace)
@@ -272,19 +491,36 @@ func (al *activeListener) OnAccept(rawc net.Conn, handOffRestoredDestinationConn
 	ctx = context.WithValue(ctx, types.ContextKeyStreamFilterChainFactories, al.streamFiltersFactories)
 	ctx = context.WithValue(ctx, types.ContextKeyLogger, al.logger)
 	ctx = context.WithValue(ctx, types.ContextKeyAccessLogs, al.accessLogs)
-	if oriRemoteAddr != nil {
-		ctx = context.WithValue(ctx, types.ContextOriRemoteAddr, oriRemoteAddr)
-	}
-	arc.ContinueFilterChain(ctx, true)
+
+	return ctx
 }
 
 func (al *activeListener) OnNewConnection(ctx context.Context, conn types.Connection) {
+	// the connection is tracked before building the filter chain, because HTTP/1 and HTTP/2
+	// stream connections serve the connection until it is closed on creating
+	ac := newActiveConnection(al, conn)
+
+	al.connsMux.Lock()
+	ac.element = al.conns.PushBack(ac)
+	al.connsMux.Unlock()
+
+	al.stats.DownstreamConnectionActive().Inc(1)
+	al.stats.DownstreamConnectionTotal().Inc(1)
+	atomic.AddInt64(&al.handler.numConnections, 1)
+
+	al.logger.Debugf("new downstream connection %d accepted", conn.ID())
+
+	al.configMux.RLock()
+	networkFiltersFactory := al.networkFiltersFactory
+	disableConnIo := al.disableConnIo
+	al.configMux.RUnlock()
+
 	//Register Proxy's Filter
-	configFactory := al.networkFiltersFactory.CreateFilterFactory(ctx, al.handler.clusterManager)
+	configFactory := networkFiltersFactory.CreateFilterFactory(ctx, al.handler.clusterManager)
 	buildFilterChain(conn.FilterManager(), configFactory)
 
 	// todo: this hack is due to http2 protocol process. golang http2 provides a io loop to read/write stream
-	if !al.disableConnIo {
+	if !disableConnIo {
 		// start conn loops first
 		conn.Start(ctx)
 	}
@@ -295,19 +531,11 @@ func (al *activeListener) OnNewConnection(ctx context.Context, conn types.Connec
 		len(filterManager.ListWriteFilters()) == 0 {
 		// no filter found, close connection
 		conn.Close(types.NoFlush, types.LocalClose)
-	} else {
-		ac := newActiveConnection(al, conn)
-
-		al.connsMux.Lock()
-		e := al.conns.PushBack(ac)
-		al.connsMux.Unlock()
-		ac.element = e
-
-		al.stats.DownstreamConnectionActive().Inc(1)
-		al.stats.DownstreamConnectionTotal().Inc(1)
-		atomic.AddInt64(&al.handler.numConnections, 1)
-
-		al.logger.Debugf("new downstream connection %d accepted", conn.ID())
+	} else if atomic.LoadUint32(&al.handler.draining) == 1 {
+		// accepted before the listener stopped, but the connections are being drained
+		if ac := al.findActiveConnection(conn); ac != nil {
+			ac.drain()
+		}
 	}
 }
 
@@ -323,6 +551,69 @@ func (al *activeListener) removeConnection(ac *activeConnection) {
 	atomic.AddInt64(&al.handler.numConnections, -1)
 
 	al.logger.Debugf("close downstream connection, stats: %s", al.stats.String())
+
+	al.handler.checkDrained()
+}
+
+func (al *activeListener) findActiveConnection(conn types.Connection) *activeConnection {
+	al.connsMux.RLock()
+	defer al.connsMux.RUnlock()
+
+	for e := al.conns.Front(); e != nil; e = e.Next() {
+		if ac := e.Value.(*activeConnection); ac.conn == conn {
+			return ac
+		}
+	}
+
+	return nil
+}
+
+// drainConnections drains all the connections of the listener, which should have stopped accepting
+func (al *activeListener) drainConnections() {
+	al.connsMux.RLock()
+	conns := make([]*activeConnection, 0, al.conns.Len())
+	for e := al.conns.Front(); e != nil; e = e.Next() {
+		conns = append(conns, e.Value.(*activeConnection))
+	}
+	al.connsMux.RUnlock()
+
+	al.logger.Infof("drain listener %s, active connections %d, active requests %d", al.listener.Name(),
+		len(conns), al.stats.DownstreamRequestActive().Count())
+
+	for _, ac := range conns {
+		ac.drain()
+	}
+}
+
+// transferConnections transfers the connections of the listener, which should have stopped accepting
+func (al *activeListener) transferConnections(transfer func(rawc net.Conn, unread []byte) error) {
+	al.connsMux.RLock()
+	var conns []*activeConnection
+	for e := al.conns.Front(); e != nil; e = e.Next() {
+		if ac := e.Value.(*activeConnection); ac.transferable() {
+			// marked before draining, transferring connections are not drained
+			atomic.StoreUint32(&ac.transferring, 1)
+			conns = append(conns, ac)
+		}
+	}
+	al.connsMux.RUnlock()
+
+	al.logger.Infof("transfer listener %s, transferable connections %d", al.listener.Name(), len(conns))
+
+	for _, ac := range conns {
+		go ac.transfer(transfer)
+	}
+}
+
+// resumeConnection resumes the connection transferred from another process, listener filters are skipped
+// as they have been applied by the other process
+func (al *activeListener) resumeConnection(rawc net.Conn, unread []byte) {
+	conn := network.NewTransferredServerConnection(rawc, unread, al.stopChan, al.logger)
+	newCtx := context.WithValue(al.newContext(), types.ContextKeyConnectionID, conn.ID())
+
+	conn.SetBufferLimit(al.listener.PerConnBufferLimitBytes())
+
+	al.OnNewConnection(newCtx, conn)
 }
 
 func (al *activeListener) newConnection(ctx context.Context, rawc net.Conn) {
@@ -367,7 +658,7 @@ func (arc *activeRawConn) SetOrigingalAddr(ip string, port int) {
 func (arc *activeRawConn) HandOffRestoredDestinationConnectionsHandler() {
 	var listener, localListener *activeListener
 
-	for _, lst := range arc.activeListener.handler.listeners {
+	for _, lst := range arc.activeListener.handler.activeListeners() {
 		if lst.listenIP == arc.originalDstIP && lst.listenPort == arc.originalDstPort {
 			listener = lst
 			break
@@ -418,9 +709,10 @@ func (arc *activeRawConn) Conn() net.Conn {
 // ListenerFilterManager note:unsupported now
 // ListenerFilterCallbacks note:unsupported now
 type activeConnection struct {
-	element  *list.Element
-	listener *activeListener
-	conn     types.Connection
+	element      *list.Element
+	listener     *activeListener
+	conn         types.Connection
+	transferring uint32
 }
 
 func newActiveConnection(listener *activeListener, conn types.Connection) *activeConnection {
@@ -449,6 +741,59 @@ func newActiveConnection(listener *activeListener, conn types.Connection) *activ
 	return ac
 }
 
+// drain the connection by the read filters which handle requests on it, connections without
+// such filters, such as tcp proxy connections, are left to be closed by the remote or on drain timeout
+func (ac *activeConnection) drain() {
+	if atomic.LoadUint32(&ac.transferring) == 1 {
+		return
+	}
+
+	for _, filter := range ac.conn.FilterManager().ListReadFilter() {
+		if df, ok := filter.(types.DrainFilter); ok {
+			df.Drain()
+		}
+	}
+}
+
+// transferable reports whether the connection is a plain tcp connection, and all the read filters
+// keep their request states by transfer filters. The states of tls connections can't be handed over.
+func (ac *activeConnection) transferable() bool {
+	if _, ok := ac.conn.RawConn().(*net.TCPConn); !ok {
+		return false
+	}
+
+	filters := ac.conn.FilterManager().ListReadFilter()
+
+	for _, filter := range filters {
+		if _, ok := filter.(types.TransferFilter); !ok {
+			return false
+		}
+	}
+
+	return len(filters) > 0
+}
+
+// idle reports whether there is no active request on the connection
+func (ac *activeConnection) idle() bool {
+	for _, filter := range ac.conn.FilterManager().ListReadFilter() {
+		if !filter.(types.TransferFilter).Idle() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// transfer hands over the connection, or drains it if it could not be transferred
+func (ac *activeConnection) transfer(transfer func(rawc net.Conn, unread []byte) error) {
+	if err := ac.conn.Transfer(ac.idle, transfer); err != nil {
+		ac.listener.logger.Infof("connection %d is not transferred, drain it: %v", ac.conn.ID(), err)
+
+		atomic.StoreUint32(&ac.transferring, 0)
+		ac.drain()
+	}
+}
+
 // ConnectionEventListener
 func (ac *activeConnection) OnEvent(event types.ConnectionEvent) {
 	if event.IsClose() {
@@ -0,0 +1,237 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/network/buffer"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+type mockClusterManagerFilter struct{}
+
+func (cmf *mockClusterManagerFilter) OnCreated(cccb types.ClusterConfigFactoryCb, chcb types.ClusterHostFactoryCb) {
+}
+
+// lineFilterFactory creates filters which respond each line with the name of the process
+type lineFilterFactory struct {
+	name string
+	// drains are recorded instead of closing the connections if set
+	drained chan struct{}
+}
+
+func (f *lineFilterFactory) CreateFilterFactory(context context.Context, clusterManager types.ClusterManager) types.NetworkFilterFactoryCb {
+	return func(manager types.FilterManager) {
+		manager.AddReadFilter(&lineFilter{name: f.name, drained: f.drained})
+	}
+}
+
+type lineFilter struct {
+	name    string
+	drained chan struct{}
+	cb      types.ReadFilterCallbacks
+}
+
+func (f *lineFilter) OnData(data types.IoBuffer) types.FilterStatus {
+	for {
+		idx := bytes.IndexByte(data.Bytes(), '\n')
+		if idx < 0 {
+			return types.StopIteration
+		}
+
+		resp := f.name + ":" + string(data.Bytes()[:idx+1])
+		data.Drain(idx + 1)
+		f.cb.Connection().Write(buffer.NewIoBufferString(resp))
+	}
+}
+
+func (f *lineFilter) OnNewConnection() types.FilterStatus {
+	return types.Continue
+}
+
+func (f *lineFilter) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
+	f.cb = cb
+}
+
+// types.DrainFilter
+func (f *lineFilter) Drain() {
+	if f.drained != nil {
+		f.drained <- struct{}{}
+		return
+	}
+
+	f.cb.Connection().Close(types.FlushWrite, types.LocalClose)
+}
+
+// types.TransferFilter
+func (f *lineFilter) Idle() bool {
+	return true
+}
+
+func newTestHandler(addr net.Addr, name string) *connHandler {
+	handler := NewHandler(&mockClusterManagerFilter{}, nil, log.DefaultLogger).(*connHandler)
+	handler.AddListener(&v2.ListenerConfig{
+		Name:       name,
+		Addr:       addr,
+		BindToPort: true,
+		LogPath:    "stdout",
+	}, &lineFilterFactory{name: name}, nil)
+
+	return handler
+}
+
+func dialLine(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	var conn net.Conn
+	var err error
+	for i := 0; i < 10; i++ {
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	return conn, bufio.NewReader(conn)
+}
+
+func TestUpdateListener(t *testing.T) {
+	log.InitDefaultLogger("stdout", log.INFO)
+
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:34902")
+	handler := newTestHandler(addr, "v1")
+	handler.StartListeners(nil)
+	defer handler.StopListeners(nil, true)
+
+	oldConn, oldReader := dialLine(t, addr.String())
+	defer oldConn.Close()
+	oldConn.Write([]byte("a\n"))
+	if resp, _ := oldReader.ReadString('\n'); resp != "v1:a\n" {
+		t.Fatalf("expected response with v1 config, but got %q", resp)
+	}
+
+	lc := &v2.ListenerConfig{
+		Name:       "v1",
+		Addr:       addr,
+		BindToPort: true,
+		LogPath:    "stdout",
+	}
+	if err := handler.UpdateListener(lc, &lineFilterFactory{name: "v2"}, nil); err != nil {
+		t.Fatalf("update listener failed: %v", err)
+	}
+
+	// the connection accepted before is drained
+	if _, err := oldReader.ReadString('\n'); err == nil {
+		t.Errorf("expected the connection accepted before closed")
+	}
+
+	newConn, newReader := dialLine(t, addr.String())
+	defer newConn.Close()
+	newConn.Write([]byte("a\n"))
+	if resp, _ := newReader.ReadString('\n'); resp != "v2:a\n" {
+		t.Fatalf("expected response with v2 config, but got %q", resp)
+	}
+
+	lc.Addr, _ = net.ResolveTCPAddr("tcp", "127.0.0.1:34903")
+	if err := handler.UpdateListener(lc, &lineFilterFactory{name: "v3"}, nil); err == nil {
+		t.Errorf("expected error on updating the listener address")
+	}
+}
+
+func TestUpdateListenerUnchanged(t *testing.T) {
+	log.InitDefaultLogger("stdout", log.INFO)
+
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:34904")
+	handler := newTestHandler(addr, "unchanged")
+	handler.StartListeners(nil)
+	defer handler.StopListeners(nil, true)
+
+	conn, reader := dialLine(t, addr.String())
+	defer conn.Close()
+	conn.Write([]byte("a\n"))
+	if resp, _ := reader.ReadString('\n'); resp != "unchanged:a\n" {
+		t.Fatalf("unexpected response %q", resp)
+	}
+
+	// the same config is pushed again with new filter factories
+	lc := &v2.ListenerConfig{
+		Name:       "unchanged",
+		Addr:       addr,
+		BindToPort: true,
+		LogPath:    "stdout",
+	}
+	if err := handler.UpdateListener(lc, &lineFilterFactory{name: "unchanged"}, nil); err != nil {
+		t.Fatalf("update listener failed: %v", err)
+	}
+
+	conn.Write([]byte("b\n"))
+	if resp, _ := reader.ReadString('\n'); resp != "unchanged:b\n" {
+		t.Fatalf("expected the connection not drained, but got %q", resp)
+	}
+
+	if n := handler.NumConnections(); n != 1 {
+		t.Errorf("expected 1 connection, but got %d", n)
+	}
+}
+
+func TestRemoveListener(t *testing.T) {
+	log.InitDefaultLogger("stdout", log.INFO)
+
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:34904")
+	handler := newTestHandler(addr, "removed")
+	handler.StartListeners(nil)
+
+	conn, reader := dialLine(t, addr.String())
+	defer conn.Close()
+
+	conn.Write([]byte("a\n"))
+	if resp, _ := reader.ReadString('\n'); resp != "removed:a\n" {
+		t.Fatalf("expected response before removed, but got %q", resp)
+	}
+
+	if err := handler.RemoveListener("removed"); err != nil {
+		t.Fatalf("remove listener failed: %v", err)
+	}
+
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Errorf("expected the connection closed")
+	}
+
+	if l := handler.findActiveListenerByName("removed"); l != nil {
+		t.Errorf("expected listener removed")
+	}
+
+	if c, err := net.Dial("tcp", addr.String()); err == nil {
+		c.Close()
+		t.Errorf("expected listener closed")
+	}
+
+	if err := handler.RemoveListener("removed"); err == nil {
+		t.Errorf("expected error on removing a listener not found")
+	}
+}
@@ -19,6 +19,7 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"runtime"
 	"time"
@@ -114,8 +115,50 @@ func (srv *server) AddListenerAndStart(lc *v2.ListenerConfig, networkFiltersFact
 	return nil
 }
 
-func (srv *server) AddOrUpdateListener(lc v2.ListenerConfig) {
-	// TODO: support add listener or update existing listener
+// AddOrUpdateListener adds and starts the listener, or updates the listener with the same name.
+// New connections use the new config while the connections accepted before are drained,
+// a listener moved to another address is removed and added again, and a listener with the
+// same config is kept as it is.
+func (srv *server) AddOrUpdateListener(lc *v2.ListenerConfig, networkFiltersFactory types.NetworkFilterChainFactory,
+	streamFiltersFactories []types.StreamFilterChainFactory) error {
+
+	item, ok := srv.ListenerInMap.Get(lc.Name)
+	if !ok {
+		return srv.AddListenerAndStart(lc, networkFiltersFactory, streamFiltersFactories)
+	}
+
+	if old, ok := item.(*v2.ListenerConfig); ok && old.Addr.String() != lc.Addr.String() {
+		if err := srv.RemoveListener(lc.Name); err != nil {
+			return err
+		}
+
+		return srv.AddListenerAndStart(lc, networkFiltersFactory, streamFiltersFactories)
+	}
+
+	if err := srv.handler.UpdateListener(lc, networkFiltersFactory, streamFiltersFactories); err != nil {
+		return err
+	}
+
+	srv.ListenerInMap.Set(lc.Name, lc)
+	log.DefaultLogger.Infof("listener %s updated", lc.Name)
+
+	return nil
+}
+
+// RemoveListener stops the listener and drains its connections
+func (srv *server) RemoveListener(name string) error {
+	if !srv.ListenerInMap.Has(name) {
+		return fmt.Errorf("listener %s not found", name)
+	}
+
+	if err := srv.handler.RemoveListener(name); err != nil {
+		return err
+	}
+
+	srv.ListenerInMap.Remove(name)
+	log.DefaultLogger.Infof("listener %s removed", name)
+
+	return nil
 }
 
 func (srv *server) Start() {
@@ -123,6 +166,13 @@ func (srv *server) Start() {
 
 	srv.handler.StartListeners(nil)
 
+	// receive the connections transferred by the old process on hot upgrade
+	if os.Getenv("_MOSN_GRACEFUL_RESTART") == "true" {
+		if err := startTransferServer(srv.handler); err != nil {
+			log.DefaultLogger.Errorf("start transfer server failed: %v", err)
+		}
+	}
+
 	for {
 		select {
 		case <-srv.stopChan:
@@ -131,8 +181,9 @@ func (srv *server) Start() {
 	}
 }
 
+// Restart hands over the listeners to a new process, and exits once the connections are drained
 func (srv *server) Restart() {
-	// TODO
+	reconfigure()
 }
 
 func (srv *server) Close() {
@@ -154,6 +205,17 @@ func StopAccept() {
 	}
 }
 
+// DrainListeners stops all listeners accepting new connections, and drains the connections
+// accepted before without waiting, idle connections are closed at once and the others after
+// their active requests are done
+func DrainListeners() {
+	StopAccept()
+
+	for _, server := range servers {
+		server.handler.DrainConnections()
+	}
+}
+
 func ListListenerFD() []uintptr {
 	var fds []uintptr
 	for _, server := range servers {
@@ -162,21 +224,39 @@ func ListListenerFD() []uintptr {
 	return fds
 }
 
+// ListListenerConfigs returns configs of all listeners
+func ListListenerConfigs() []*v2.ListenerConfig {
+	var configs []*v2.ListenerConfig
+	for _, server := range servers {
+		for _, item := range server.ListenerInMap.Items() {
+			if lc, ok := item.(*v2.ListenerConfig); ok {
+				configs = append(configs, lc)
+			}
+		}
+	}
+	return configs
+}
+
+// WaitConnectionsDone drains the connections of all servers, idle connections are closed at once and
+// the others after their active requests are done. Listeners should have stopped accepting before.
 func WaitConnectionsDone(duration time.Duration) error {
 	timeout := time.NewTimer(duration)
-	wait := make(chan struct{})
-	go func() {
-		//todo close idle connections and wait active connections complete
-		time.Sleep(duration * 2)
-		wait <- struct{}{}
-	}()
-
-	select {
-	case <-timeout.C:
-		return errors.New("wait timeout")
-	case <-wait:
-		return nil
+	defer timeout.Stop()
+
+	var waits []<-chan struct{}
+	for _, server := range servers {
+		waits = append(waits, server.handler.DrainConnections())
+	}
+
+	for _, wait := range waits {
+		select {
+		case <-timeout.C:
+			return errors.New("wait timeout")
+		case <-wait:
+		}
 	}
+
+	return nil
 }
 
 func InitDefaultLogger(config *Config) {
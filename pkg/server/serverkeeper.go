@@ -179,9 +179,15 @@ func reconfigure() {
 	// Stop accepting requests
 	StopAccept()
 
+	// Hand over the long-lived connections to the new process
+	TransferConnections()
+
 	// Wait for all conections to be finished
-	WaitConnectionsDone(gracefulTimeout)
-	log.DefaultLogger.Infof("process %d gracefully shutdown", os.Getpid())
+	if err := WaitConnectionsDone(gracefulTimeout); err != nil {
+		log.DefaultLogger.Errorf("process %d drain connections failed: %v", os.Getpid(), err)
+	} else {
+		log.DefaultLogger.Infof("process %d gracefully shutdown", os.Getpid())
+	}
 
 	// Stop the old server, all the connections have been closed and the new one is running
 	os.Exit(0)
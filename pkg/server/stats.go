@@ -31,6 +31,7 @@ const (
 	DownstreamBytesReadCurrent  = "downstream_bytes_read_current"
 	DownstreamBytesWrite        = "downstream_bytes_write"
 	DownstreamBytesWriteCurrent = "downstream_bytes_write_current"
+	DownstreamRequestActive     = "downstream_request_active"
 )
 
 type ListenerStats struct {
@@ -47,7 +48,7 @@ func initStats(namespace string) *stats.Stats {
 	return stats.NewStats(namespace).AddCounter(DownstreamConnectionTotal).AddCounter(DownstreamConnectionDestroy).
 		AddCounter(DownstreamConnectionActive).AddCounter(DownstreamBytesRead).
 		AddGauge(DownstreamBytesReadCurrent).AddCounter(DownstreamBytesWrite).
-		AddGauge(DownstreamBytesWriteCurrent)
+		AddGauge(DownstreamBytesWriteCurrent).AddCounter(DownstreamRequestActive)
 }
 
 func (ls *ListenerStats) DownstreamConnectionTotal() metrics.Counter {
@@ -78,6 +79,11 @@ func (ls *ListenerStats) DownstreamBytesWriteCurrent() metrics.Gauge {
 	return ls.stats.Gauge(DownstreamBytesWriteCurrent)
 }
 
+// DownstreamRequestActive is counted by the proxies of the listener's connections
+func (ls *ListenerStats) DownstreamRequestActive() metrics.Counter {
+	return ls.stats.Counter(DownstreamRequestActive)
+}
+
 func (ls *ListenerStats) String() string {
 	return ls.stats.String()
 }
@@ -0,0 +1,223 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// Connections are transferred over the unix domain socket during hot upgrade, the old process sends
+// the connection fd with a 4 bytes big endian length of the unread bytes followed by the unread bytes,
+// the new process replies a byte to tell whether the connection is resumed.
+const (
+	transferAckOK   byte = 0
+	transferAckFail byte = 1
+)
+
+var (
+	// TransferDomainSocket is listened by the new process to receive connections
+	TransferDomainSocket = MosnBasePath + string(os.PathSeparator) + "mosn.sock"
+
+	// the new process receives connections within the timeout after started
+	transferTimeout = time.Second * 30
+
+	// the old process retries to connect the new process within the timeout
+	transferDialTimeout = time.Second * 10
+)
+
+// TransferConnections hands over the transferable connections of all servers to the new process,
+// others are drained by WaitConnectionsDone
+func TransferConnections() {
+	for _, server := range servers {
+		server.handler.TransferConnections(transferConnection)
+	}
+}
+
+// transferConnection sends the connection and its unread bytes to the new process
+func transferConnection(rawc net.Conn, unread []byte) error {
+	tcpConn, ok := rawc.(*net.TCPConn)
+	if !ok {
+		return errors.New("only tcp connection could be transferred")
+	}
+
+	// the fd is sent by the raw connection, as the file duplicated by tcpConn.File
+	// switches the shared socket to blocking mode, which breaks the deadlines if the transfer fails
+	sc, err := tcpConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	uc, err := dialTransferServer()
+	if err != nil {
+		return err
+	}
+	defer uc.Close()
+
+	uc.SetDeadline(time.Now().Add(transferDialTimeout))
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(unread)))
+
+	var sendErr error
+	if err := sc.Control(func(fd uintptr) {
+		_, _, sendErr = uc.WriteMsgUnix(header, syscall.UnixRights(int(fd)), nil)
+	}); err != nil {
+		return err
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+
+	if _, err := uc.Write(unread); err != nil {
+		return err
+	}
+
+	ack := make([]byte, 1)
+	if _, err := io.ReadFull(uc, ack); err != nil {
+		return err
+	}
+
+	if ack[0] != transferAckOK {
+		return errors.New("connection rejected by the new process")
+	}
+
+	return nil
+}
+
+func dialTransferServer() (*net.UnixConn, error) {
+	addr := &net.UnixAddr{Name: TransferDomainSocket, Net: "unix"}
+	deadline := time.Now().Add(transferDialTimeout)
+
+	for {
+		uc, err := net.DialUnix("unix", nil, addr)
+		if err == nil || time.Now().After(deadline) {
+			return uc, err
+		}
+
+		// the new process may not be ready
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// startTransferServer receives the connections transferred by the old process, and resumes them on the handler
+func startTransferServer(handler types.ConnectionHandler) error {
+	os.Remove(TransferDomainSocket)
+
+	l, err := net.Listen("unix", TransferDomainSocket)
+	if err != nil {
+		return err
+	}
+
+	time.AfterFunc(transferTimeout, func() {
+		l.Close()
+	})
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				log.DefaultLogger.Infof("transfer server %s closed: %v", TransferDomainSocket, err)
+				return
+			}
+
+			go resumeConnection(handler, c.(*net.UnixConn))
+		}
+	}()
+
+	return nil
+}
+
+func resumeConnection(handler types.ConnectionHandler, uc *net.UnixConn) {
+	defer uc.Close()
+
+	uc.SetDeadline(time.Now().Add(transferDialTimeout))
+
+	rawc, unread, err := receiveConnection(uc)
+	if err != nil {
+		log.DefaultLogger.Errorf("receive transferred connection failed: %v", err)
+		uc.Write([]byte{transferAckFail})
+		return
+	}
+
+	if err := handler.ResumeConnection(rawc, unread); err != nil {
+		log.DefaultLogger.Errorf("resume transferred connection failed: %v", err)
+		rawc.Close()
+		uc.Write([]byte{transferAckFail})
+		return
+	}
+
+	uc.Write([]byte{transferAckOK})
+}
+
+func receiveConnection(uc *net.UnixConn) (net.Conn, []byte, error) {
+	header := make([]byte, 4)
+	oob := make([]byte, syscall.CmsgSpace(4))
+
+	n, oobn, _, _, err := uc.ReadMsgUnix(header, oob)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(msgs) != 1 {
+		return nil, nil, errors.New("no connection fd received")
+	}
+
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(fds) != 1 {
+		return nil, nil, errors.New("one connection fd is expected")
+	}
+
+	file := os.NewFile(uintptr(fds[0]), "")
+	rawc, err := net.FileConn(file)
+	file.Close()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := io.ReadFull(uc, header[n:]); err != nil {
+		rawc.Close()
+		return nil, nil, err
+	}
+
+	unread := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(uc, unread); err != nil {
+		rawc.Close()
+		return nil, nil, err
+	}
+
+	return rawc, unread, nil
+}
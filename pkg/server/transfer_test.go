@@ -0,0 +1,225 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+)
+
+func TestTransferConnections(t *testing.T) {
+	log.InitDefaultLogger("stdout", log.INFO)
+
+	dir, err := ioutil.TempDir("", "mosn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	TransferDomainSocket = filepath.Join(dir, "mosn.sock")
+
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:34901")
+	oldHandler := newTestHandler(addr, "old")
+	oldHandler.StartListeners(nil)
+	defer oldHandler.StopListeners(nil, true)
+
+	newHandler := newTestHandler(addr, "new")
+	if err := startTransferServer(newHandler); err != nil {
+		t.Fatalf("start transfer server failed: %v", err)
+	}
+
+	var client net.Conn
+	for i := 0; i < 10; i++ {
+		if client, err = net.Dial("tcp", addr.String()); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(client)
+
+	client.Write([]byte("a\n"))
+	if resp, _ := reader.ReadString('\n'); resp != "old:a\n" {
+		t.Fatalf("expected response from the old process, but got %q", resp)
+	}
+
+	// unread bytes are handed over with the connection
+	client.Write([]byte("b"))
+	time.Sleep(200 * time.Millisecond)
+
+	oldHandler.StopListeners(nil, false)
+	oldHandler.TransferConnections(transferConnection)
+
+	select {
+	case <-oldHandler.DrainConnections():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection is not transferred")
+	}
+
+	if n := newHandler.NumConnections(); n != 1 {
+		t.Fatalf("expected 1 connection resumed, but got %d", n)
+	}
+
+	client.Write([]byte("c\n"))
+	if resp, _ := reader.ReadString('\n'); resp != "new:bc\n" {
+		t.Fatalf("expected response from the new process, but got %q", resp)
+	}
+}
+
+func TestTransferConnectionsFailed(t *testing.T) {
+	log.InitDefaultLogger("stdout", log.INFO)
+
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:34906")
+	drained := make(chan struct{}, 1)
+	handler := NewHandler(&mockClusterManagerFilter{}, nil, log.DefaultLogger).(*connHandler)
+	handler.AddListener(&v2.ListenerConfig{
+		Name:       "failed",
+		Addr:       addr,
+		BindToPort: true,
+		LogPath:    "stdout",
+	}, &lineFilterFactory{name: "old", drained: drained}, nil)
+	handler.StartListeners(nil)
+	defer handler.StopListeners(nil, true)
+
+	client, reader := dialLine(t, addr.String())
+	defer client.Close()
+
+	client.Write([]byte("a\n"))
+	if resp, _ := reader.ReadString('\n'); resp != "old:a\n" {
+		t.Fatalf("unexpected response %q", resp)
+	}
+
+	client.Write([]byte("b"))
+	time.Sleep(200 * time.Millisecond)
+
+	handler.TransferConnections(func(rawc net.Conn, unread []byte) error {
+		return errors.New("connection rejected by the new process")
+	})
+
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection failed to transfer is not drained")
+	}
+
+	// the connection goes on serving with the unread bytes until it's closed by draining
+	client.Write([]byte("c\n"))
+	if resp, _ := reader.ReadString('\n'); resp != "old:bc\n" {
+		t.Fatalf("expected the connection kept, but got %q", resp)
+	}
+}
+
+// a connection failed to transfer keeps serving by the old process, so its socket should stay nonblocking
+func TestTransferConnectionKeepsNonblocking(t *testing.T) {
+	log.InitDefaultLogger("stdout", log.INFO)
+
+	dir, err := ioutil.TempDir("", "mosn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	TransferDomainSocket = filepath.Join(dir, "mosn.sock")
+
+	// the new process rejects every connection without resuming it,
+	// which would switch the shared socket back to nonblocking mode
+	ul, err := net.ListenUnix("unix", &net.UnixAddr{Name: TransferDomainSocket, Net: "unix"})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ul.Close()
+	go func() {
+		for {
+			uc, err := ul.AcceptUnix()
+			if err != nil {
+				return
+			}
+			oob := make([]byte, syscall.CmsgSpace(4))
+			if _, oobn, _, _, err := uc.ReadMsgUnix(make([]byte, 4), oob); err == nil {
+				msgs, _ := syscall.ParseSocketControlMessage(oob[:oobn])
+				for _, msg := range msgs {
+					fds, _ := syscall.ParseUnixRights(&msg)
+					for _, fd := range fds {
+						syscall.Close(fd)
+					}
+				}
+			}
+			uc.Write([]byte{transferAckFail})
+			uc.Close()
+		}
+	}()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer client.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept failed: %v", err)
+	}
+	defer conn.Close()
+
+	if err := transferConnection(conn, nil); err == nil {
+		t.Fatalf("transfer should fail")
+	}
+
+	sc, _ := conn.(*net.TCPConn).SyscallConn()
+	var flags uintptr
+	sc.Control(func(fd uintptr) {
+		flags, _, _ = syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+	})
+	if flags&syscall.O_NONBLOCK == 0 {
+		t.Errorf("socket should be nonblocking after the transfer failed")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Errorf("read should time out, but got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		// unblock the read
+		client.Close()
+		t.Errorf("read deadline does not fire")
+	}
+}
@@ -49,6 +49,10 @@ type Server interface {
 
 	AddListenerAndStart(lc *v2.ListenerConfig, networkFiltersFactory types.NetworkFilterChainFactory, streamFiltersFactories []types.StreamFilterChainFactory) error
 
+	AddOrUpdateListener(lc *v2.ListenerConfig, networkFiltersFactory types.NetworkFilterChainFactory, streamFiltersFactories []types.StreamFilterChainFactory) error
+
+	RemoveListener(name string) error
+
 	Start()
 
 	Restart()
@@ -0,0 +1,373 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stats
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// DefaultBuckets are the upper bounds of histogram buckets exported to prometheus, in a 1-2-5 series
+var DefaultBuckets = []int64{
+	1, 2, 5, 10, 20, 50, 100, 200, 500,
+	1000, 2000, 5000, 10000, 20000, 50000, 100000, 200000, 500000,
+	1000000, 2000000, 5000000, 10000000,
+}
+
+// histogramBounds are the upper bounds of the buckets values are counted in, 15 buckets
+// per decade keeps the error of interpolated percentiles within about 12%
+var histogramBounds = newHistogramBounds(10000000000)
+
+func newHistogramBounds(maxScale int64) []int64 {
+	mantissas := []int64{100, 125, 150, 175, 200, 250, 300, 350, 400, 450, 500, 600, 700, 800, 900}
+
+	var bounds []int64
+	for scale := int64(1); scale <= maxScale; scale *= 10 {
+		for _, m := range mantissas {
+			bound := m * scale / 100
+			if len(bounds) == 0 || bound > bounds[len(bounds)-1] {
+				bounds = append(bounds, bound)
+			}
+		}
+	}
+
+	return bounds
+}
+
+// BucketHistogram is a lock-free histogram which counts values in fixed buckets instead of
+// sampling them, so histograms can be merged and exported as prometheus histograms
+type BucketHistogram interface {
+	metrics.Histogram
+
+	// Buckets returns the exported upper bounds and the cumulative count of values
+	// less than or equal to each bound, values above all bounds are only counted in Count
+	Buckets() ([]int64, []uint64)
+
+	// Merge adds the values counted in another histogram to this one
+	Merge(other BucketHistogram)
+
+	snapshot() *bucketHistogram
+}
+
+type bucketHistogram struct {
+	count int64
+	sum   int64
+	min   int64
+	max   int64
+
+	// counts[i] is the count of values in (histogramBounds[i-1], histogramBounds[i]],
+	// the last one is for values above all bounds
+	counts []uint64
+	// exported bounds and the count of histogramBounds within each of them
+	bounds      []int64
+	boundsIndex []int
+}
+
+// NewBucketHistogram creates a histogram with the sorted upper bounds of the exported buckets,
+// bounds between two of the counting buckets are rounded down to the lower one
+func NewBucketHistogram(bounds []int64) BucketHistogram {
+	boundsIndex := make([]int, len(bounds))
+	for i, bound := range bounds {
+		boundsIndex[i] = sort.Search(len(histogramBounds), func(j int) bool {
+			return histogramBounds[j] > bound
+		})
+	}
+
+	return &bucketHistogram{
+		min:         math.MaxInt64,
+		max:         math.MinInt64,
+		counts:      make([]uint64, len(histogramBounds)+1),
+		bounds:      bounds,
+		boundsIndex: boundsIndex,
+	}
+}
+
+// GetOrRegisterBucketHistogram returns the histogram registered by name in the registry,
+// a BucketHistogram with DefaultBuckets is registered if not found
+func GetOrRegisterBucketHistogram(name string, r metrics.Registry) metrics.Histogram {
+	if r == nil {
+		r = metrics.DefaultRegistry
+	}
+
+	return r.GetOrRegister(name, func() metrics.Histogram {
+		return NewBucketHistogram(DefaultBuckets)
+	}).(metrics.Histogram)
+}
+
+// UpdateDuration records the duration in microseconds, the unit of all duration histograms
+func UpdateDuration(d time.Duration, histograms ...metrics.Histogram) {
+	us := int64(d / time.Microsecond)
+	for _, h := range histograms {
+		h.Update(us)
+	}
+}
+
+func (h *bucketHistogram) Update(v int64) {
+	i := sort.Search(len(histogramBounds), func(i int) bool {
+		return v <= histogramBounds[i]
+	})
+
+	// min and max are updated first, so they cover all values counted in a snapshot
+	h.updateMinMax(v, v)
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, v)
+	atomic.AddInt64(&h.count, 1)
+}
+
+func (h *bucketHistogram) updateMinMax(min, max int64) {
+	for {
+		old := atomic.LoadInt64(&h.min)
+		if min >= old || atomic.CompareAndSwapInt64(&h.min, old, min) {
+			break
+		}
+	}
+
+	for {
+		old := atomic.LoadInt64(&h.max)
+		if max <= old || atomic.CompareAndSwapInt64(&h.max, old, max) {
+			break
+		}
+	}
+}
+
+// Clear resets the histogram, values updated concurrently may be partly cleared
+func (h *bucketHistogram) Clear() {
+	for i := range h.counts {
+		atomic.StoreUint64(&h.counts[i], 0)
+	}
+
+	atomic.StoreInt64(&h.count, 0)
+	atomic.StoreInt64(&h.sum, 0)
+	atomic.StoreInt64(&h.min, math.MaxInt64)
+	atomic.StoreInt64(&h.max, math.MinInt64)
+}
+
+func (h *bucketHistogram) Merge(other BucketHistogram) {
+	o := other.snapshot()
+	if o.count == 0 {
+		return
+	}
+
+	h.updateMinMax(o.min, o.max)
+	for i, c := range o.counts {
+		atomic.AddUint64(&h.counts[i], c)
+	}
+
+	atomic.AddInt64(&h.sum, o.sum)
+	atomic.AddInt64(&h.count, o.count)
+}
+
+func (h *bucketHistogram) Count() int64 {
+	return atomic.LoadInt64(&h.count)
+}
+
+func (h *bucketHistogram) Sum() int64 {
+	return atomic.LoadInt64(&h.sum)
+}
+
+func (h *bucketHistogram) Min() int64 {
+	if h.Count() == 0 {
+		return 0
+	}
+
+	return atomic.LoadInt64(&h.min)
+}
+
+func (h *bucketHistogram) Max() int64 {
+	if h.Count() == 0 {
+		return 0
+	}
+
+	return atomic.LoadInt64(&h.max)
+}
+
+func (h *bucketHistogram) Mean() float64 {
+	return h.Snapshot().Mean()
+}
+
+func (h *bucketHistogram) StdDev() float64 {
+	return h.Snapshot().StdDev()
+}
+
+func (h *bucketHistogram) Variance() float64 {
+	return h.Snapshot().Variance()
+}
+
+func (h *bucketHistogram) Percentile(p float64) float64 {
+	return h.Snapshot().Percentile(p)
+}
+
+func (h *bucketHistogram) Percentiles(ps []float64) []float64 {
+	return h.Snapshot().Percentiles(ps)
+}
+
+// Sample is not supported, values are counted in buckets only
+func (h *bucketHistogram) Sample() metrics.Sample {
+	return metrics.NilSample{}
+}
+
+func (h *bucketHistogram) Snapshot() metrics.Histogram {
+	return &bucketHistogramSnapshot{h.snapshot()}
+}
+
+// snapshot copies the histogram, the count of the copy is the total of its buckets
+// so that they are consistent even if the histogram is updated concurrently
+func (h *bucketHistogram) snapshot() *bucketHistogram {
+	s := &bucketHistogram{
+		counts:      make([]uint64, len(h.counts)),
+		bounds:      h.bounds,
+		boundsIndex: h.boundsIndex,
+	}
+
+	for i := range h.counts {
+		s.counts[i] = atomic.LoadUint64(&h.counts[i])
+		s.count += int64(s.counts[i])
+	}
+
+	// loaded after the counts, so that they cover the counted values
+	s.sum = atomic.LoadInt64(&h.sum)
+	s.min = atomic.LoadInt64(&h.min)
+	s.max = atomic.LoadInt64(&h.max)
+
+	return s
+}
+
+func (h *bucketHistogram) Buckets() ([]int64, []uint64) {
+	cumulative := make([]uint64, len(h.bounds))
+
+	var count uint64
+	var i int
+	for j, index := range h.boundsIndex {
+		for ; i < index; i++ {
+			count += atomic.LoadUint64(&h.counts[i])
+		}
+		cumulative[j] = count
+	}
+
+	return h.bounds, cumulative
+}
+
+// bucketHistogramSnapshot is a read-only copy of a bucketHistogram
+type bucketHistogramSnapshot struct {
+	*bucketHistogram
+}
+
+func (s *bucketHistogramSnapshot) Update(int64) {
+	panic("Update called on a bucketHistogramSnapshot")
+}
+
+func (s *bucketHistogramSnapshot) Clear() {
+	panic("Clear called on a bucketHistogramSnapshot")
+}
+
+func (s *bucketHistogramSnapshot) Merge(BucketHistogram) {
+	panic("Merge called on a bucketHistogramSnapshot")
+}
+
+func (s *bucketHistogramSnapshot) Snapshot() metrics.Histogram {
+	return s
+}
+
+func (s *bucketHistogramSnapshot) snapshot() *bucketHistogram {
+	return s.bucketHistogram
+}
+
+func (s *bucketHistogramSnapshot) Mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+
+	return float64(s.sum) / float64(s.count)
+}
+
+// Variance is estimated by the midpoints of the buckets
+func (s *bucketHistogramSnapshot) Variance() float64 {
+	if s.count == 0 {
+		return 0
+	}
+
+	mean := s.Mean()
+
+	var sum float64
+	for i, c := range s.counts {
+		if c == 0 {
+			continue
+		}
+
+		lower, upper := s.bucketRange(i)
+		d := (lower+upper)/2 - mean
+		sum += d * d * float64(c)
+	}
+
+	return sum / float64(s.count)
+}
+
+func (s *bucketHistogramSnapshot) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+func (s *bucketHistogramSnapshot) Percentile(p float64) float64 {
+	return s.Percentiles([]float64{p})[0]
+}
+
+// Percentiles are interpolated linearly in the bucket which the rank falls in
+func (s *bucketHistogramSnapshot) Percentiles(ps []float64) []float64 {
+	values := make([]float64, len(ps))
+	if s.count == 0 {
+		return values
+	}
+
+	for i, p := range ps {
+		rank := p * float64(s.count)
+		values[i] = float64(s.max)
+
+		var cumulative uint64
+		for j, c := range s.counts {
+			if c == 0 {
+				continue
+			}
+
+			if float64(cumulative+c) >= rank {
+				lower, upper := s.bucketRange(j)
+				values[i] = lower + (upper-lower)*(rank-float64(cumulative))/float64(c)
+				break
+			}
+			cumulative += c
+		}
+	}
+
+	return values
+}
+
+// bucketRange returns the range of values in the bucket, narrowed by the min and max values
+func (s *bucketHistogramSnapshot) bucketRange(i int) (float64, float64) {
+	lower := s.min
+	if i > 0 && histogramBounds[i-1] > lower {
+		lower = histogramBounds[i-1]
+	}
+
+	upper := s.max
+	if i < len(histogramBounds) && histogramBounds[i] < upper {
+		upper = histogramBounds[i]
+	}
+
+	return float64(lower), float64(upper)
+}
@@ -0,0 +1,166 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stats
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestBucketHistogram(t *testing.T) {
+	h := NewBucketHistogram([]int64{10, 100})
+
+	for _, v := range []int64{1, 10, 11, 100, 1000} {
+		h.Update(v)
+	}
+
+	bounds, counts := h.Buckets()
+	if len(bounds) != 2 || counts[0] != 2 || counts[1] != 4 {
+		t.Errorf("buckets unexpected: %v %v", bounds, counts)
+	}
+
+	if h.Count() != 5 || h.Sum() != 1122 || h.Max() != 1000 {
+		t.Errorf("histogram unexpected, count %d, sum %d, max %d", h.Count(), h.Sum(), h.Max())
+	}
+
+	h.Clear()
+	if _, counts := h.Buckets(); counts[1] != 0 || h.Count() != 0 || h.Sum() != 0 {
+		t.Errorf("histogram should be cleared, counts %v, count %d, sum %d", counts, h.Count(), h.Sum())
+	}
+}
+
+func TestBucketHistogramPercentiles(t *testing.T) {
+	h := NewBucketHistogram(DefaultBuckets)
+
+	for v := int64(1); v <= 10000; v++ {
+		h.Update(v)
+	}
+
+	ps := h.Percentiles([]float64{0, 0.5, 0.9, 0.99, 1})
+	expected := []float64{1, 5000, 9000, 9900, 10000}
+
+	for i, p := range ps {
+		if math.Abs(p-expected[i])/expected[i] > 0.05 {
+			t.Errorf("percentile %d expected about %f, got %f", i, expected[i], p)
+		}
+	}
+
+	if mean := h.Mean(); mean != 5000.5 {
+		t.Errorf("mean expected 5000.5, got %f", mean)
+	}
+
+	if stddev := h.StdDev(); math.Abs(stddev-2886.75)/2886.75 > 0.05 {
+		t.Errorf("stddev expected about 2886.75, got %f", stddev)
+	}
+
+	h = NewBucketHistogram(DefaultBuckets)
+	h.Update(7)
+	if p := h.Percentile(0.99); p != 7 {
+		t.Errorf("percentile of a single value expected 7, got %f", p)
+	}
+
+	if p := NewBucketHistogram(DefaultBuckets).Percentile(0.99); p != 0 {
+		t.Errorf("percentile of an empty histogram expected 0, got %f", p)
+	}
+}
+
+func TestBucketHistogramMerge(t *testing.T) {
+	h1 := NewBucketHistogram(DefaultBuckets)
+	h2 := NewBucketHistogram(DefaultBuckets)
+
+	h1.Update(10)
+	h2.Update(5)
+	h2.Update(1000)
+
+	h1.Merge(h2)
+	h1.Merge(NewBucketHistogram(DefaultBuckets))
+
+	if h1.Count() != 3 || h1.Sum() != 1015 || h1.Min() != 5 || h1.Max() != 1000 {
+		t.Errorf("merged histogram unexpected, count %d, sum %d, min %d, max %d", h1.Count(), h1.Sum(), h1.Min(), h1.Max())
+	}
+
+	if _, counts := h1.Buckets(); counts[2] != 1 || counts[3] != 2 {
+		t.Errorf("merged buckets unexpected: %v", counts)
+	}
+
+	snapshot := h1.Snapshot()
+	h1.Update(1)
+	if snapshot.Count() != 3 || snapshot.Min() != 5 {
+		t.Errorf("snapshot should not be changed, count %d, min %d", snapshot.Count(), snapshot.Min())
+	}
+}
+
+func TestBucketHistogramConcurrent(t *testing.T) {
+	h := NewBucketHistogram(DefaultBuckets)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := int64(0); j < 1000; j++ {
+				h.Update(j)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Percentile(0.99)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, counts := h.Buckets()
+	if counts[len(counts)-1] != 10000 || h.Sum() != 10*999*1000/2 {
+		t.Errorf("histogram unexpected, counts %v, sum %d", counts, h.Sum())
+	}
+}
+
+func TestGetOrRegisterBucketHistogram(t *testing.T) {
+	r := metrics.NewRegistry()
+
+	h := GetOrRegisterBucketHistogram("foo", r)
+	if _, ok := h.(BucketHistogram); !ok {
+		t.Fatalf("expected a bucket histogram, got %T", h)
+	}
+
+	if GetOrRegisterBucketHistogram("foo", r) != h {
+		t.Error("registered histogram should be returned")
+	}
+
+	if s := NewStats("bar").AddHistogram("baz"); s.Histogram("baz") == nil {
+		t.Error("stats histogram should be registered")
+	} else if _, ok := s.Histogram("baz").(BucketHistogram); !ok {
+		t.Errorf("stats histogram should be a bucket histogram, got %T", s.Histogram("baz"))
+	}
+}
+
+func TestUpdateDuration(t *testing.T) {
+	h1 := NewBucketHistogram(DefaultBuckets)
+	h2 := NewBucketHistogram(DefaultBuckets)
+
+	UpdateDuration(3*time.Millisecond, h1, h2)
+
+	if h1.Sum() != 3000 || h2.Sum() != 3000 {
+		t.Errorf("duration should be recorded in microseconds, got %d and %d", h1.Sum(), h2.Sum())
+	}
+}
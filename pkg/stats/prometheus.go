@@ -0,0 +1,234 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stats
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// PrometheusContentType is the content type of the prometheus text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+const prometheusPrefix = "mosn_"
+
+// quantiles of the sample histograms and timers, which are exported as summaries
+var summaryQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// namespaces of the metrics registered as prefix.<name>.metric,
+// the name becomes a label of the metric instead of a part of the metric name
+var labeledNamespaces = []struct {
+	prefix    string
+	subsystem string
+	label     string
+}{
+	{"cluster.", "cluster", "cluster"},
+	{"host.", "host", "host"},
+	{"listener.", "listener", "listener"},
+	{"health_check.", "health_check", "cluster"},
+}
+
+type promSample struct {
+	// rendered labels without braces, such as cluster="foo"
+	labels string
+	metric interface{}
+}
+
+type promFamily struct {
+	name    string
+	typ     string
+	samples []promSample
+}
+
+// WritePrometheus writes all metrics in the registry in prometheus text format, namespaces of
+// clusters, hosts and listeners are converted to labels, and bucket histograms to histograms
+func WritePrometheus(w io.Writer, registry metrics.Registry) error {
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+
+	families := make(map[string]*promFamily)
+
+	registry.Each(func(key string, i interface{}) {
+		name, labels := parseMetricKey(key)
+
+		typ := promType(name, i)
+		if typ == "" {
+			return
+		}
+
+		family, ok := families[name]
+		if !ok {
+			family = &promFamily{name: name, typ: typ}
+			families[name] = family
+		} else if family.typ != typ {
+			// a metric name must have one type, the conflicting one is dropped
+			return
+		}
+
+		family.samples = append(family.samples, promSample{labels: labels, metric: i})
+	})
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		writeFamily(bw, families[name])
+	}
+
+	return bw.Flush()
+}
+
+// parseMetricKey converts the dotted key to a metric name and its labels, such as
+// cluster.foo.upstream_request_total to mosn_cluster_upstream_request_total and cluster="foo"
+func parseMetricKey(key string) (string, string) {
+	for _, ns := range labeledNamespaces {
+		if !strings.HasPrefix(key, ns.prefix) {
+			continue
+		}
+
+		// the label value may contain dots, such as the host address, but the metric never does
+		rest := key[len(ns.prefix):]
+		idx := strings.LastIndex(rest, ".")
+		if idx <= 0 {
+			break
+		}
+
+		value := strings.Trim(rest[:idx], ".")
+		name := prometheusPrefix + ns.subsystem + "_" + sanitizeName(rest[idx+1:])
+
+		return name, ns.label + `="` + escapeLabelValue(value) + `"`
+	}
+
+	return prometheusPrefix + sanitizeName(strings.Trim(key, ".")), ""
+}
+
+func promType(name string, i interface{}) string {
+	switch i.(type) {
+	case BucketHistogram:
+		return "histogram"
+	case metrics.Histogram, metrics.Timer:
+		return "summary"
+	case metrics.Counter:
+		// counters of active connections or requests go up and down
+		if strings.HasSuffix(strings.ToLower(name), "active") {
+			return "gauge"
+		}
+		return "counter"
+	case metrics.Meter:
+		return "counter"
+	case metrics.Gauge, metrics.GaugeFloat64:
+		return "gauge"
+	}
+
+	return ""
+}
+
+func writeFamily(w *bufio.Writer, family *promFamily) {
+	sort.Slice(family.samples, func(i, j int) bool {
+		return family.samples[i].labels < family.samples[j].labels
+	})
+
+	w.WriteString("# TYPE " + family.name + " " + family.typ + "\n")
+
+	for _, s := range family.samples {
+		switch metric := s.metric.(type) {
+		case BucketHistogram:
+			bounds, counts := metric.Buckets()
+			for i, bound := range bounds {
+				writeSample(w, family.name+"_bucket", joinLabels(s.labels, `le="`+strconv.FormatInt(bound, 10)+`"`),
+					strconv.FormatUint(counts[i], 10))
+			}
+
+			// the buckets and the count are not updated atomically together
+			total := uint64(metric.Count())
+			if n := len(counts); n > 0 && counts[n-1] > total {
+				total = counts[n-1]
+			}
+			count := strconv.FormatUint(total, 10)
+			writeSample(w, family.name+"_bucket", joinLabels(s.labels, `le="+Inf"`), count)
+			writeSample(w, family.name+"_sum", s.labels, strconv.FormatInt(metric.Sum(), 10))
+			writeSample(w, family.name+"_count", s.labels, count)
+		case metrics.Histogram:
+			h := metric.Snapshot()
+			writeSummary(w, family.name, s.labels, h.Percentiles(summaryQuantiles), h.Sum(), h.Count())
+		case metrics.Timer:
+			t := metric.Snapshot()
+			writeSummary(w, family.name, s.labels, t.Percentiles(summaryQuantiles), t.Sum(), t.Count())
+		case metrics.Counter:
+			writeSample(w, family.name, s.labels, strconv.FormatInt(metric.Count(), 10))
+		case metrics.Meter:
+			writeSample(w, family.name, s.labels, strconv.FormatInt(metric.Count(), 10))
+		case metrics.Gauge:
+			writeSample(w, family.name, s.labels, strconv.FormatInt(metric.Value(), 10))
+		case metrics.GaugeFloat64:
+			writeSample(w, family.name, s.labels, formatFloat(metric.Value()))
+		}
+	}
+}
+
+func writeSummary(w *bufio.Writer, name string, labels string, values []float64, sum int64, count int64) {
+	for i, q := range summaryQuantiles {
+		writeSample(w, name, joinLabels(labels, `quantile="`+formatFloat(q)+`"`), formatFloat(values[i]))
+	}
+
+	writeSample(w, name+"_sum", labels, strconv.FormatInt(sum, 10))
+	writeSample(w, name+"_count", labels, strconv.FormatInt(count, 10))
+}
+
+func writeSample(w *bufio.Writer, name string, labels string, value string) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + value + "\n")
+}
+
+func joinLabels(labels string, label string) string {
+	if labels == "" {
+		return label
+	}
+
+	return labels + "," + label
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sanitizeName replaces the characters not allowed in prometheus metric names with underscores
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,105 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestParseMetricKey(t *testing.T) {
+	cases := []struct {
+		key    string
+		name   string
+		labels string
+	}{
+		{"cluster.outbound|foo.bar.upstream_request_total", "mosn_cluster_upstream_request_total", `cluster="outbound|foo.bar"`},
+		{"host.127.0.0.1:8080.upstream_connection_total", "mosn_host_upstream_connection_total", `host="127.0.0.1:8080"`},
+		{"listener.2045.downstream_request_total", "mosn_listener_downstream_request_total", `listener="2045"`},
+		{"health_check.foo.attempt", "mosn_health_check_attempt", `cluster="foo"`},
+		{".downstream_connection_total", "mosn_downstream_connection_total", ""},
+		{"some-metric.name", "mosn_some_metric_name", ""},
+	}
+
+	for _, c := range cases {
+		name, labels := parseMetricKey(c.key)
+		if name != c.name || labels != c.labels {
+			t.Errorf("parse %s, expected %s{%s}, got %s{%s}", c.key, c.name, c.labels, name, labels)
+		}
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := metrics.NewRegistry()
+
+	metrics.GetOrRegisterCounter("cluster.foo.upstream_request_total", r).Inc(3)
+	metrics.GetOrRegisterCounter("cluster.bar.upstream_request_total", r).Inc(1)
+	metrics.GetOrRegisterCounter("cluster.foo.upstream_connection_active", r).Inc(2)
+	metrics.GetOrRegisterGauge("health_check.foo.healthy", r).Update(1)
+	metrics.GetOrRegisterHistogram(".downstream_request_time", r, metrics.NewUniformSample(100)).Update(4)
+
+	h := GetOrRegisterBucketHistogram(`listener.2045.request "time"`, r)
+	h.Update(3)
+	h.Update(30)
+
+	buf := new(bytes.Buffer)
+	if err := WritePrometheus(buf, r); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"# TYPE mosn_cluster_upstream_request_total counter\n" +
+			"mosn_cluster_upstream_request_total{cluster=\"bar\"} 1\n" +
+			"mosn_cluster_upstream_request_total{cluster=\"foo\"} 3\n",
+		"# TYPE mosn_cluster_upstream_connection_active gauge\n" +
+			"mosn_cluster_upstream_connection_active{cluster=\"foo\"} 2\n",
+		"# TYPE mosn_health_check_healthy gauge\n" +
+			"mosn_health_check_healthy{cluster=\"foo\"} 1\n",
+		"# TYPE mosn_downstream_request_time summary\n" +
+			"mosn_downstream_request_time{quantile=\"0.5\"} 4\n",
+		"mosn_downstream_request_time_sum 4\n" +
+			"mosn_downstream_request_time_count 1\n",
+		"# TYPE mosn_listener_request__time_ histogram\n" +
+			"mosn_listener_request__time__bucket{listener=\"2045\",le=\"1\"} 0\n",
+		"mosn_listener_request__time__bucket{listener=\"2045\",le=\"5\"} 1\n",
+		"mosn_listener_request__time__bucket{listener=\"2045\",le=\"50\"} 2\n",
+		"mosn_listener_request__time__bucket{listener=\"2045\",le=\"+Inf\"} 2\n" +
+			"mosn_listener_request__time__sum{listener=\"2045\"} 33\n" +
+			"mosn_listener_request__time__count{listener=\"2045\"} 2\n",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("expected output contains:\n%s\ngot:\n%s", e, out)
+		}
+	}
+
+	// families are sorted by name
+	if strings.Index(out, "mosn_cluster_upstream_connection_active") > strings.Index(out, "mosn_cluster_upstream_request_total") {
+		t.Errorf("families should be sorted by name:\n%s", out)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if v := escapeLabelValue("a\\b\"c\nd"); v != `a\\b\"c\nd` {
+		t.Errorf("escape label value unexpected: %s", v)
+	}
+}
@@ -57,7 +57,7 @@ func (s *Stats) AddGauge(name string) *Stats {
 
 func (s *Stats) AddHistogram(name string) *Stats {
 	metricsKey := fmt.Sprintf("%s.%s", s.namespace, name)
-	s.histograms[name] = metrics.GetOrRegisterHistogram(metricsKey, nil, metrics.NewUniformSample(100))
+	s.histograms[name] = GetOrRegisterBucketHistogram(metricsKey, nil)
 
 	return s
 }
@@ -20,9 +20,12 @@ package http
 import (
 	"container/list"
 	"context"
+	"net"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/alipay/sofa-mosn/pkg/stats"
 	str "github.com/alipay/sofa-mosn/pkg/stream"
 	"github.com/alipay/sofa-mosn/pkg/types"
 	"github.com/valyala/fasthttp"
@@ -55,8 +58,18 @@ type codecClient struct {
 func NewHTTP1CodecClient(context context.Context, host types.HostInfo) str.CodecClient {
 	codecClient := &codecClient{
 		client: &fasthttp.HostClient{
-			Addr:          host.AddressString(),
-			DialDualStack: true,
+			Addr: host.AddressString(),
+			// connections are dialed by fasthttp, the dial function records the connect time
+			Dial: func(addr string) (net.Conn, error) {
+				start := time.Now()
+				conn, err := fasthttp.DialDualStack(addr)
+				if err == nil {
+					stats.UpdateDuration(time.Since(start), host.HostStats().UpstreamConnectionConnectTime,
+						host.ClusterInfo().Stats().UpstreamConnectionConnectTime)
+				}
+
+				return conn, err
+			},
 		},
 		context:        context,
 		Host:           host,
@@ -74,6 +74,9 @@ func (p *connPool) NewStream(context context.Context, streamID string, responseD
 }
 
 func (p *connPool) Close() {
+	if client := p.client; client != nil {
+		client.codecClient.Close()
+	}
 	p.client = nil
 }
 
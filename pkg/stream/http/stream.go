@@ -147,7 +147,10 @@ func (csw *clientStreamWrapper) NewStream(streamID string, responseDecoder types
 // types.ServerStreamConnection
 type serverStreamConnection struct {
 	streamConnection
+	connection                types.Connection
 	serverStreamConnCallbacks types.ServerStreamConnectionEventListener
+
+	goAway uint32
 }
 
 func newServerStreamConnection(context context.Context, connection types.Connection,
@@ -157,14 +160,37 @@ func newServerStreamConnection(context context.Context, connection types.Connect
 			context:       context,
 			rawConnection: connection.RawConn(),
 		},
+		connection:                connection,
 		serverStreamConnCallbacks: callbacks,
 	}
 
+	if listener, ok := callbacks.(types.ServerStreamConnectionServeListener); ok {
+		listener.OnServe(ssc)
+	}
+
 	fasthttp.ServeConn(connection.RawConn(), ssc.ServeHTTP)
 
+	// fasthttp returns when the connection is closed by peer, or after responding with 'Connection: close'
+	if atomic.LoadUint32(&ssc.goAway) == 1 {
+		connection.Close(types.NoFlush, types.LocalClose)
+	} else {
+		connection.Close(types.NoFlush, types.RemoteClose)
+	}
+
 	return ssc
 }
 
+// GoAway responds the active request with 'Connection: close', HTTP/1.x has no goaway frame
+func (ssc *serverStreamConnection) GoAway() {
+	if !atomic.CompareAndSwapUint32(&ssc.goAway, 0, 1) {
+		return
+	}
+
+	if ssc.activeStream == nil {
+		ssc.connection.Close(types.NoFlush, types.LocalClose)
+	}
+}
+
 func (ssc *serverStreamConnection) OnGoAway() {
 	ssc.serverStreamConnCallbacks.OnGoAway()
 }
@@ -214,11 +240,11 @@ func (s *stream) AddEventListener(streamCb types.StreamEventListener) {
 	s.streamCbs = append(s.streamCbs, streamCb)
 }
 
-func (s *stream) RemoveEventListener(streamCb types.StreamEventListener) {
+func (s *stream) RemoveEventListener(cb types.StreamEventListener) {
 	cbIdx := -1
 
 	for i, streamCb := range s.streamCbs {
-		if streamCb == streamCb {
+		if streamCb == cb {
 			cbIdx = i
 			break
 		}
@@ -256,19 +282,23 @@ func (s *clientStream) AppendHeaders(headersIn interface{}, endStream bool) erro
 		s.request.SetRequestURI(fmt.Sprintf("http://%s/", s.wrapper.client.Addr))
 	}
 
+	// the headers are kept unchanged, as they may be sent again by retry or request mirroring
 	if method, ok := headers[types.HeaderMethod]; ok {
 		s.request.Header.SetMethod(method)
-		delete(headers, types.HeaderMethod)
-	}
-
-	if host, ok := headers[types.HeaderHost]; ok {
-		s.request.SetHost(host)
-		delete(headers, types.HeaderHost)
 	}
 
 	if path, ok := headers[protocol.MosnHeaderPathKey]; ok {
 		s.request.SetRequestURI(fmt.Sprintf("http://%s%s", s.wrapper.client.Addr, path))
-		delete(headers, protocol.MosnHeaderPathKey)
+	}
+
+	// set after the uri, which resets the host. The host received from downstream is kept,
+	// otherwise the upstream address in the absolute uri is taken as the host by upstream
+	host, ok := headers[types.HeaderHost]
+	if !ok {
+		host, ok = headers[protocol.MosnHeaderHostKey]
+	}
+	if ok {
+		s.request.SetHost(host)
 	}
 
 	encodeReqHeader(s.request, headers)
@@ -338,7 +368,10 @@ func (s *clientStream) doSend() {
 
 func (s *clientStream) handleResponse() {
 	if s.response != nil {
-		s.receiver.OnReceiveHeaders(decodeRespHeader(s.response.Header), false)
+		headers := decodeRespHeader(s.response.Header)
+		headers[types.HeaderStatus] = strconv.Itoa(s.response.StatusCode())
+
+		s.receiver.OnReceiveHeaders(headers, false)
 		buf := buffer.NewIoBufferBytes(s.response.Body())
 		s.receiver.OnReceiveData(buf, true)
 
@@ -398,6 +431,10 @@ func (s *serverStream) AppendTrailers(trailers map[string]string) error {
 }
 
 func (s *serverStream) endStream() {
+	if atomic.LoadUint32(&s.connection.goAway) == 1 {
+		s.ctx.SetConnectionClose()
+	}
+
 	s.doSend()
 	s.responseDoneChan <- true
 
@@ -438,6 +475,11 @@ func (s *serverStream) handleRequest() {
 			header[types.HeaderQueryString] = string(s.ctx.URI().QueryString())
 		}
 
+		//set method header if not found
+		if _, ok := header[types.HeaderMethod]; !ok {
+			header[types.HeaderMethod] = string(s.ctx.Method())
+		}
+
 		s.receiver.OnReceiveHeaders(header, false)
 
 		// data remove detect
@@ -453,18 +495,60 @@ func (s *serverStream) GetStream() types.Stream {
 	return s
 }
 
+const headerSetCookie = "set-cookie"
+
 func encodeReqHeader(req *fasthttp.Request, in map[string]string) {
 	for k, v := range in {
+		if isPseudoReqHeader(k) {
+			continue
+		}
 		req.Header.Set(k, v)
 	}
 }
 
+// isPseudoReqHeader returns whether the header is set in the request line instead of the headers
+func isPseudoReqHeader(k string) bool {
+	return k == types.HeaderMethod || k == types.HeaderHost || k == protocol.MosnHeaderPathKey
+}
+
 func encodeRespHeader(resp *fasthttp.Response, in map[string]string) {
 	for k, v := range in {
+		if strings.EqualFold(k, headerSetCookie) {
+			// each cookie is written as a header of its own
+			for _, cookie := range splitSetCookie(v) {
+				resp.Header.Set(k, cookie)
+			}
+			continue
+		}
 		resp.Header.Set(k, v)
 	}
 }
 
+// splitSetCookie splits comma joined set-cookie values. Commas inside a cookie,
+// such as the one in expires date, are kept, a new cookie starts only when
+// the text after a comma is a name=value pair
+func splitSetCookie(v string) []string {
+	var cookies []string
+
+	start := 0
+	for i := 0; i < len(v); i++ {
+		if v[i] != ',' {
+			continue
+		}
+
+		next := v[i+1:]
+		if end := strings.IndexByte(next, ';'); end >= 0 {
+			next = next[:end]
+		}
+		if strings.IndexByte(next, '=') > 0 {
+			cookies = append(cookies, strings.TrimSpace(v[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(cookies, strings.TrimSpace(v[start:]))
+}
+
 func decodeReqHeader(in fasthttp.RequestHeader) (out map[string]string) {
 	out = make(map[string]string, in.Len())
 
@@ -481,7 +565,14 @@ func decodeRespHeader(in fasthttp.ResponseHeader) (out map[string]string) {
 
 	in.VisitAll(func(key, value []byte) {
 		// convert to lower case for internal process
-		out[strings.ToLower(string(key))] = string(value)
+		k := strings.ToLower(string(key))
+
+		// response may set more than one cookie
+		if cookies, ok := out[k]; ok && k == headerSetCookie {
+			out[k] = cookies + "," + string(value)
+			return
+		}
+		out[k] = string(value)
 	})
 
 	return
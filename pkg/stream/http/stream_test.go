@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/valyala/fasthttp"
+)
+
+func TestSplitSetCookie(t *testing.T) {
+	cookies := splitSetCookie("session=123; Expires=Wed, 21 Oct 2015 07:28:00 GMT; Path=/,route=abc; Path=/")
+	expected := []string{"session=123; Expires=Wed, 21 Oct 2015 07:28:00 GMT; Path=/", "route=abc; Path=/"}
+	if !reflect.DeepEqual(cookies, expected) {
+		t.Errorf("expected %v, got %v", expected, cookies)
+	}
+}
+
+func TestSetCookieHeaders(t *testing.T) {
+	resp := &fasthttp.Response{}
+	encodeRespHeader(resp, map[string]string{headerSetCookie: "session=123; Path=/,route=abc; Path=/"})
+
+	var cookies []string
+	resp.Header.VisitAllCookie(func(key, value []byte) {
+		cookies = append(cookies, string(value))
+	})
+	if len(cookies) != 2 {
+		t.Fatalf("expected two set-cookie headers, got %v", cookies)
+	}
+
+	if cookie := decodeRespHeader(resp.Header)[headerSetCookie]; cookie != "session=123; Path=/,route=abc; Path=/" {
+		t.Errorf("expected both cookies decoded, got %s", cookie)
+	}
+}
+
+func TestClientStreamKeepsHeaders(t *testing.T) {
+	s := &clientStream{
+		wrapper: &clientStreamWrapper{client: &fasthttp.HostClient{Addr: "127.0.0.1:8080"}},
+	}
+
+	headers := map[string]string{
+		types.HeaderMethod:         "POST",
+		types.HeaderHost:           "test.com",
+		protocol.MosnHeaderPathKey: "/foo",
+		"service":                  "test",
+	}
+	expected := make(map[string]string, len(headers))
+	for k, v := range headers {
+		expected[k] = v
+	}
+
+	s.AppendHeaders(headers, false)
+
+	// headers may be sent again by retry or request mirroring
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("headers should not be changed, got %v", headers)
+	}
+
+	if method := string(s.request.Header.Method()); method != "POST" {
+		t.Errorf("expected method POST, got %s", method)
+	}
+	if host := string(s.request.Host()); host != "test.com" {
+		t.Errorf("expected host test.com, got %s", host)
+	}
+	if path := string(s.request.URI().Path()); path != "/foo" {
+		t.Errorf("expected path /foo, got %s", path)
+	}
+	if service := string(s.request.Header.Peek("service")); service != "test" {
+		t.Errorf("expected service header, got %s", service)
+	}
+	if len(s.request.Header.Peek(types.HeaderMethod)) != 0 || len(s.request.Header.Peek(protocol.MosnHeaderPathKey)) != 0 {
+		t.Errorf("pseudo headers should not be sent as headers")
+	}
+}
+
+func TestClientStreamDownstreamHost(t *testing.T) {
+	s := &clientStream{
+		wrapper: &clientStreamWrapper{client: &fasthttp.HostClient{Addr: "127.0.0.1:8080"}},
+	}
+
+	// headers decoded from the downstream request
+	s.AppendHeaders(map[string]string{
+		types.HeaderMethod:         "POST",
+		protocol.MosnHeaderHostKey: "test.com",
+		protocol.MosnHeaderPathKey: "/foo",
+	}, false)
+
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	if err := s.request.Write(w); err != nil {
+		t.Fatalf("write request failed: %v", err)
+	}
+	w.Flush()
+
+	request := b.String()
+	if !strings.HasPrefix(request, "POST /foo HTTP/1.1\r\n") {
+		t.Errorf("expected the path in request line, got %q", request)
+	}
+	if !strings.Contains(request, "\r\nHost: test.com\r\n") {
+		t.Errorf("expected the downstream host, got %q", request)
+	}
+}
@@ -20,8 +20,10 @@ package http2
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/stats"
 	str "github.com/alipay/sofa-mosn/pkg/stream"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
@@ -187,10 +189,14 @@ func newActiveClient(context context.Context, pool *connPool) *activeClient {
 	}
 	data := pool.host.CreateConnection(context)
 
+	start := time.Now()
 	if err := data.Connection.Connect(false); err != nil {
 		return nil
 	}
 
+	stats.UpdateDuration(time.Since(start), pool.host.HostStats().UpstreamConnectionConnectTime,
+		pool.host.ClusterInfo().Stats().UpstreamConnectionConnectTime)
+
 	codecClient := pool.createCodecClient(context, data)
 	codecClient.AddConnectionCallbacks(ac)
 	codecClient.SetCodecClientCallbacks(ac)
@@ -63,7 +63,6 @@ func (f *streamConnFactory) CreateBiDirectStream(context context.Context, connec
 }
 
 var transport http2.Transport
-var server http2.Server
 
 // types.StreamConnection
 // types.StreamConnectionEventListener
@@ -110,6 +109,7 @@ func newClientStreamConnection(context context.Context, connection types.ClientC
 			http2Conn:     h2Conn,
 			activeStreams: list.New(),
 			connCallbacks: connCallbacks,
+			logger:        log.ByContext(context),
 		},
 		streamConnCallbacks: streamConnCallbacks,
 	}
@@ -139,6 +139,11 @@ func (csc *clientStreamConnection) NewStream(streamID string, responseDecoder ty
 type serverStreamConnection struct {
 	streamConnection
 	serverStreamConnCallbacks types.ServerStreamConnectionEventListener
+
+	// http2 server sends GOAWAY on base config shutting down, so each connection has its own server
+	// to goaway individually
+	server     *http2.Server
+	baseConfig *http.Server
 }
 
 func newServerStreamConnection(context context.Context, connection types.Connection,
@@ -149,9 +154,17 @@ func newServerStreamConnection(context context.Context, connection types.Connect
 			context:       context,
 			rawConnection: connection.RawConn(),
 			activeStreams: list.New(),
+			logger:        log.ByContext(context),
 		},
 		serverStreamConnCallbacks: callbacks,
+		server:                    new(http2.Server),
+		baseConfig:                new(http.Server),
+	}
+	if err := http2.ConfigureServer(ssc.baseConfig, ssc.server); err != nil {
+		logger := log.ByContext(context)
+		logger.Errorf("http2 configure server error: %v", err)
 	}
+
 	if tlsConn, ok := ssc.rawConnection.(*tls.Conn); ok {
 
 		if err := tlsConn.Handshake(); err != nil {
@@ -161,13 +174,26 @@ func newServerStreamConnection(context context.Context, connection types.Connect
 		}
 	}
 
-	server.ServeConn(connection.RawConn(), &http2.ServeConnOpts{
-		Handler: ssc,
+	if listener, ok := callbacks.(types.ServerStreamConnectionServeListener); ok {
+		listener.OnServe(ssc)
+	}
+
+	ssc.server.ServeConn(connection.RawConn(), &http2.ServeConnOpts{
+		Handler:    ssc,
+		BaseConfig: ssc.baseConfig,
 	})
 
+	// http2 server returns when the connection is closed by peer, or after all the streams are done on goaway
+	connection.Close(types.NoFlush, types.RemoteClose)
+
 	return ssc
 }
 
+// GoAway sends GOAWAY to the peer, the connection is closed after the active streams are done
+func (ssc *serverStreamConnection) GoAway() {
+	ssc.baseConfig.Shutdown(context.Background())
+}
+
 func (ssc *serverStreamConnection) OnGoAway() {
 	ssc.serverStreamConnCallbacks.OnGoAway()
 }
@@ -218,11 +244,11 @@ func (s *stream) AddEventListener(streamCb types.StreamEventListener) {
 	s.streamCbs = append(s.streamCbs, streamCb)
 }
 
-func (s *stream) RemoveEventListener(streamCb types.StreamEventListener) {
+func (s *stream) RemoveEventListener(cb types.StreamEventListener) {
 	cbIdx := -1
 
 	for i, streamCb := range s.streamCbs {
-		if streamCb == streamCb {
+		if streamCb == cb {
 			cbIdx = i
 			break
 		}
@@ -256,28 +282,28 @@ func (s *clientStream) AppendHeaders(headers interface{}, endStream bool) error
 			s.connection.rawConnection.RemoteAddr().String()))
 	}
 
-	if method, ok := headersMap[types.HeaderMethod]; ok {
-		s.request.Method = method
-		delete(headersMap, types.HeaderMethod)
-	}
-
-	if host, ok := headersMap[types.HeaderHost]; ok {
-		s.request.Host = host
-		delete(headersMap, types.HeaderHost)
-	}
-
-	if path, ok := headersMap[protocol.MosnHeaderPathKey]; ok {
-		s.request.URL, _ = url.Parse(fmt.Sprintf("http://%s%s",
-			s.connection.rawConnection.RemoteAddr().String(), path))
-		delete(headersMap, protocol.MosnHeaderPathKey)
+	// the headers are kept unchanged, as they may be sent again by retry or request mirroring
+	reqHeaders := make(map[string]string, len(headersMap))
+	for k, v := range headersMap {
+		switch k {
+		case types.HeaderMethod:
+			s.request.Method = v
+		case types.HeaderHost:
+			s.request.Host = v
+		case protocol.MosnHeaderPathKey:
+			s.request.URL, _ = url.Parse(fmt.Sprintf("http://%s%s",
+				s.connection.rawConnection.RemoteAddr().String(), v))
+		default:
+			reqHeaders[k] = v
+		}
 	}
 
-	if _, ok := headersMap["Host"]; ok {
-		headersMap["Host"] = s.connection.rawConnection.RemoteAddr().String()
+	if _, ok := reqHeaders["Host"]; ok {
+		reqHeaders["Host"] = s.connection.rawConnection.RemoteAddr().String()
 		s.request.Host = s.connection.rawConnection.RemoteAddr().String()
 	}
 
-	s.request.Header = encodeHeader(headersMap)
+	s.request.Header = encodeHeader(reqHeaders)
 
 	log.StartLogger.Tracef("http2 client stream encode headers,headers = %v", s.request.Header)
 
@@ -388,7 +414,10 @@ func (s *clientStream) CleanStream() {
 
 func (s *clientStream) handleResponse() {
 	if s.response != nil {
-		s.decoder.OnReceiveHeaders(decodeHeader(s.response.Header), false)
+		headers := decodeHeader(s.response.Header)
+		headers[types.HeaderStatus] = strconv.Itoa(s.response.StatusCode)
+
+		s.decoder.OnReceiveHeaders(headers, false)
 		buf := &buffer.IoBuffer{}
 		buf.ReadFrom(s.response.Body)
 		s.decoder.OnReceiveData(buf, false)
@@ -499,7 +528,23 @@ func (s *serverStream) doSend() {
 
 func (s *serverStream) handleRequest() {
 	if s.request != nil {
-		s.decoder.OnReceiveHeaders(decodeHeader(s.request.Header), false)
+		header := decodeHeader(s.request.Header)
+
+		//set method header if not found
+		if _, ok := header[types.HeaderMethod]; !ok {
+			header[types.HeaderMethod] = s.request.Method
+		}
+
+		//set host and path header if not found, which are not carried by the headers in http2
+		if _, ok := header[types.HeaderHost]; !ok {
+			header[types.HeaderHost] = s.request.Host
+		}
+
+		if _, ok := header[protocol.MosnHeaderPathKey]; !ok && s.request.URL != nil {
+			header[protocol.MosnHeaderPathKey] = s.request.URL.Path
+		}
+
+		s.decoder.OnReceiveHeaders(header, false)
 
 		//remove detect
 		if s.element != nil {
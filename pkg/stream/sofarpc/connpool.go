@@ -20,8 +20,10 @@ package sofarpc
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/stats"
 	str "github.com/alipay/sofa-mosn/pkg/stream"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
@@ -63,8 +65,11 @@ func (p *connPool) NewStream(context context.Context, streamID string,
 	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
 		cb.OnFailure(streamID, types.Overflow, nil)
 	} else {
-		// todo: update host stats
 		p.activeClient.totalStream++
+		p.host.HostStats().UpstreamRequestTotal.Inc(1)
+		p.host.HostStats().UpstreamRequestActive.Inc(1)
+		p.host.ClusterInfo().Stats().UpstreamRequestTotal.Inc(1)
+		p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
 		p.host.ClusterInfo().ResourceManager().Requests().Increase()
 		streamEncoder := p.activeClient.codecClient.NewStream(streamID, responseDecoder)
 		cb.OnReady(streamID, streamEncoder, p.host)
@@ -90,7 +95,8 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 }
 
 func (p *connPool) onStreamDestroy(client *activeClient) {
-	// todo: update host stats
+	p.host.HostStats().UpstreamRequestActive.Dec(1)
+	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
 }
 
@@ -126,10 +132,14 @@ func newActiveClient(context context.Context, pool *connPool) *activeClient {
 	ac.codecClient = codecClient
 	ac.host = data
 
+	start := time.Now()
 	if err := ac.host.Connection.Connect(true); err != nil {
 		return nil
 	}
 
+	stats.UpdateDuration(time.Since(start), pool.host.HostStats().UpstreamConnectionConnectTime,
+		pool.host.ClusterInfo().Stats().UpstreamConnectionConnectTime)
+
 	return ac
 }
 
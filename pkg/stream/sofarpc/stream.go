@@ -20,6 +20,7 @@ package sofarpc
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/alipay/sofa-mosn/pkg/log"
 	"github.com/alipay/sofa-mosn/pkg/protocol"
@@ -69,6 +70,7 @@ type streamConnection struct {
 	activeStreams   streamMap
 	clientCallbacks types.StreamConnectionEventListener
 	serverCallbacks types.ServerStreamConnectionEventListener
+	goAway          uint32
 
 	logger log.Logger
 }
@@ -96,8 +98,15 @@ func (conn *streamConnection) Protocol() types.Protocol {
 	return conn.protocol
 }
 
+// GoAway closes the connection after the active streams are done, as SOFARPC has no goaway frame
 func (conn *streamConnection) GoAway() {
-	// todo
+	if !atomic.CompareAndSwapUint32(&conn.goAway, 0, 1) {
+		return
+	}
+
+	if conn.activeStreams.Len() == 0 {
+		conn.connection.Close(types.FlushWrite, types.LocalClose)
+	}
 }
 
 func (conn *streamConnection) NewStream(streamID string, responseDecoder types.StreamReceiver) types.StreamSender {
@@ -320,6 +329,10 @@ func (s *stream) endStream() {
 		// for a server stream, remove stream on response wrote
 		s.connection.activeStreams.Remove(s.streamID)
 		//	log.StartLogger.Warnf("Remove Request ID = %+v",s.streamID)
+
+		if atomic.LoadUint32(&s.connection.goAway) == 1 && s.connection.activeStreams.Len() == 0 {
+			s.connection.connection.Close(types.FlushWrite, types.LocalClose)
+		}
 	}
 }
 
@@ -369,6 +382,13 @@ func (m *streamMap) Remove(streamID string) {
 	delete(m.smap, streamID)
 }
 
+func (m *streamMap) Len() int {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return len(m.smap)
+}
+
 func (m *streamMap) Set(streamID string, s stream) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
@@ -57,6 +57,9 @@ func (s *stream) encodeSterilize(headers interface{}) interface{} {
 				case types.DeserialExceptionCode:
 					//Hessian Exception
 					respHeaders, err = sofarpc.BuildSofaRespMsg(s.context, headerMaps, sofarpc.RESPONSE_STATUS_SERVER_DESERIAL_EXCEPTION)
+				case types.RateLimitedCode:
+					//Request Rate Limited
+					respHeaders, err = sofarpc.BuildSofaRespMsg(s.context, headerMaps, sofarpc.RESPONSE_STATUS_SERVER_THREADPOOL_BUSY)
 				case types.TimeoutExceptionCode:
 					//Response Timeout
 					respHeaders, err = sofarpc.BuildSofaRespMsg(s.context, headerMaps, sofarpc.RESPONSE_STATUS_TIMEOUT)
@@ -20,9 +20,11 @@ package xprotocol
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/log"
 	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/stats"
 	str "github.com/alipay/sofa-mosn/pkg/stream"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
@@ -33,11 +35,14 @@ type connPool struct {
 	drainingClient *activeClient
 	mux            sync.Mutex
 	host           types.Host
+	subProtocol    types.SubProtocol
 }
 
-func NewConnPool(host types.Host) types.ConnectionPool {
+// NewConnPool creates a connection pool of the sub protocol to the host
+func NewConnPool(host types.Host, subProtocol types.SubProtocol) types.ConnectionPool {
 	return &connPool{
-		host: host,
+		host:        host,
+		subProtocol: subProtocol,
 	}
 }
 
@@ -148,8 +153,10 @@ func (p *connPool) onGoAway(client *activeClient) {
 	}
 }
 
-func (p *connPool) createCodecClient(context context.Context, connData types.CreateConnectionData) str.CodecClient {
-	return str.NewCodecClient(context, protocol.Xprotocol, connData.Connection, connData.HostInfo)
+func (p *connPool) createCodecClient(ctx context.Context, connData types.CreateConnectionData) str.CodecClient {
+	// connections in the pool always speak the pool's sub protocol
+	ctx = context.WithValue(ctx, types.ContextSubProtocol, p.subProtocol)
+	return str.NewCodecClient(ctx, protocol.Xprotocol, connData.Connection, connData.HostInfo)
 }
 
 func (p *connPool) movePrimaryToDraining() {
@@ -183,7 +190,11 @@ func newActiveClient(context context.Context, pool *connPool) *activeClient {
 
 	log.StartLogger.Tracef("xprotocol new active client , try to create connection")
 	data := pool.host.CreateConnection(context)
-	data.Connection.Connect(true)
+	start := time.Now()
+	if err := data.Connection.Connect(true); err == nil {
+		stats.UpdateDuration(time.Since(start), pool.host.HostStats().UpstreamConnectionConnectTime,
+			pool.host.ClusterInfo().Stats().UpstreamConnectionConnectTime)
+	}
 	log.StartLogger.Tracef("xprotocol new active client , connect success %v", data)
 
 	log.StartLogger.Tracef("xprotocol new active client , try to create codec client")
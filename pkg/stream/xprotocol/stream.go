@@ -25,7 +25,9 @@ import (
 	"sync/atomic"
 
 	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/network/buffer"
 	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/xprotocol/subprotocol"
 	str "github.com/alipay/sofa-mosn/pkg/stream"
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
@@ -43,22 +45,43 @@ func init() {
 	str.Register(protocol.Xprotocol, &streamConnFactory{})
 }
 
-type streamConnFactory struct{}
+// NewStreamConnFactory returns a stream factory speaking the sub protocol,
+// it is used to register a sub protocol as a protocol of its own
+func NewStreamConnFactory(subProtocol types.SubProtocol) str.ProtocolStreamFactory {
+	return &streamConnFactory{
+		subProtocol: subProtocol,
+	}
+}
+
+type streamConnFactory struct {
+	subProtocol types.SubProtocol
+}
 
 func (f *streamConnFactory) CreateClientStream(context context.Context, connection types.ClientConnection,
 	clientCallbacks types.StreamConnectionEventListener, connCallbacks types.ConnectionEventListener) types.ClientStreamConnection {
-	return newStreamConnection(context, connection, clientCallbacks, nil)
+	return newStreamConnection(f.withSubProtocol(context), connection, clientCallbacks, nil)
 }
 
 func (f *streamConnFactory) CreateServerStream(context context.Context, connection types.Connection,
 	serverCallbacks types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
-	return newStreamConnection(context, connection, nil, serverCallbacks)
+	return newStreamConnection(f.withSubProtocol(context), connection, nil, serverCallbacks)
 }
 
 func (f *streamConnFactory) CreateBiDirectStream(context context.Context, connection types.ClientConnection,
 	clientCallbacks types.StreamConnectionEventListener,
 	serverCallbacks types.ServerStreamConnectionEventListener) types.ClientStreamConnection {
-	return newStreamConnection(context, connection, clientCallbacks, serverCallbacks)
+	return newStreamConnection(f.withSubProtocol(context), connection, clientCallbacks, serverCallbacks)
+}
+
+func (f *streamConnFactory) withSubProtocol(ctx context.Context) context.Context {
+	if f.subProtocol == "" {
+		return ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, types.ContextSubProtocol, f.subProtocol)
 }
 
 // types.DecodeFilter
@@ -73,38 +96,125 @@ type streamConnection struct {
 	clientCallbacks types.StreamConnectionEventListener
 	serverCallbacks types.ServerStreamConnectionEventListener
 
+	// codec of the sub protocol, nil means every read is dispatched as a whole request
+	subProtocol  types.SubProtocol
+	codec        types.Multiplexing
+	nextStreamID uint64
+
 	logger log.Logger
 }
 
 func newStreamConnection(context context.Context, connection types.Connection, clientCallbacks types.StreamConnectionEventListener,
 	serverCallbacks types.ServerStreamConnectionEventListener) types.ClientStreamConnection {
 
-	return &streamConnection{
+	conn := &streamConnection{
 		context:         context,
+		protocol:        protocol.Xprotocol,
 		connection:      connection,
 		activeStream:    newStreamMap(context),
 		clientCallbacks: clientCallbacks,
 		serverCallbacks: serverCallbacks,
 		logger:          log.ByContext(context),
 	}
+
+	if subProtocol, ok := context.Value(types.ContextSubProtocol).(types.SubProtocol); ok && subProtocol != "" {
+		conn.subProtocol = subProtocol
+		conn.codec = subprotocol.CreateSubProtocolCodec(context, subProtocol)
+
+		if conn.codec == nil {
+			conn.logger.Errorf("xprotocol sub protocol %s is not registered, dispatch without framing", subProtocol)
+		}
+	}
+
+	return conn
 }
 
 // types.StreamConnection
 func (conn *streamConnection) Dispatch(buffer types.IoBuffer) {
 	log.StartLogger.Tracef("stream connection dispatch data = %v", buffer.String())
-	streamID := ""
-	if conn.serverCallbacks != nil {
-		reqID := atomic.AddUint32(&streamIDXprotocolCount, 1)
-		streamID = strconv.FormatUint(uint64(reqID), 10)
+
+	frames, err := conn.splitFrame(buffer)
+	for _, frame := range frames {
+		conn.dispatchFrame(frame)
+	}
+
+	if err != nil {
+		// the rest of the buffer can never be decoded, close the connection
+		// instead of buffering data forever
+		log.DefaultLogger.Errorf("xprotocol split frame failed, close the connection: %v", err)
+		buffer.Drain(buffer.Len())
+		conn.connection.Close(types.NoFlush, types.LocalClose)
+	}
+}
+
+// splitFrame cuts complete frames out of the buffer and drains them,
+// incomplete data is left in the buffer until more data is read
+func (conn *streamConnection) splitFrame(buffer types.IoBuffer) ([][]byte, error) {
+	data := buffer.Bytes()
+	if len(data) == 0 {
+		return nil, nil
 	}
+
+	var frames [][]byte
+	var err error
+	if conn.codec == nil {
+		frames = [][]byte{data}
+	} else {
+		frames, err = conn.codec.SplitFrame(data)
+	}
+
+	consumed := 0
+	result := make([][]byte, 0, len(frames))
+	for _, frame := range frames {
+		// frames refer to the connection buffer, copy them before draining
+		copied := make([]byte, len(frame))
+		copy(copied, frame)
+		result = append(result, copied)
+		consumed += len(frame)
+	}
+	buffer.Drain(consumed)
+
+	return result, err
+}
+
+func (conn *streamConnection) dispatchFrame(frame []byte) {
+	streamID := ""
 	headers := make(map[string]string)
 	// support dynamic route
 	headers[strings.ToLower(protocol.MosnHeaderHostKey)] = conn.connection.RemoteAddr().String()
 	headers[strings.ToLower(protocol.MosnHeaderPathKey)] = "/"
+
+	if conn.codec != nil {
+		if heartbeat, ok := conn.codec.(types.Heartbeat); ok && heartbeat.IsHeartbeatRequest(frame) {
+			if resp := heartbeat.HeartbeatResponse(frame); resp != nil {
+				conn.connection.Write(buffer.NewIoBufferBytes(resp))
+			}
+			return
+		}
+
+		streamID = conn.codec.GetStreamID(frame)
+
+		if routeHeaders, ok := conn.codec.(types.RouteHeaders); ok {
+			for k, v := range routeHeaders.GetRouteHeaders(frame) {
+				headers[k] = v
+			}
+		} else if tracing, ok := conn.codec.(types.Tracing); ok {
+			if service := tracing.GetServiceName(frame); service != "" {
+				headers[subprotocol.HeaderServiceName] = service
+			}
+			if method := tracing.GetMethodName(frame); method != "" {
+				headers[subprotocol.HeaderMethodName] = method
+			}
+		}
+	} else if conn.serverCallbacks != nil {
+		reqID := atomic.AddUint32(&streamIDXprotocolCount, 1)
+		streamID = strconv.FormatUint(uint64(reqID), 10)
+	}
+
 	log.StartLogger.Tracef("before Dispatch on decode header")
 	conn.OnReceiveHeaders(streamID, headers)
 	log.StartLogger.Tracef("after Dispatch on decode header")
-	conn.OnReceiveData(streamID, buffer)
+	conn.OnReceiveData(streamID, buffer.NewIoBufferBytes(frame))
 	log.StartLogger.Tracef("after Dispatch on decode data")
 }
 
@@ -126,6 +236,11 @@ func (conn *streamConnection) OnUnderlyingConnectionBelowWriteBufferLowWatermark
 
 func (conn *streamConnection) NewStream(streamID string, responseDecoder types.StreamReceiver) types.StreamSender {
 	log.StartLogger.Tracef("xprotocol stream new stream")
+	if conn.codec != nil {
+		// requests are correlated with responses by a request id unique on this connection
+		streamID = strconv.FormatUint(atomic.AddUint64(&conn.nextStreamID, 1), 10)
+	}
+
 	stream := stream{
 		context:    context.WithValue(conn.context, types.ContextKeyStreamID, streamID),
 		streamID:   streamID,
@@ -276,9 +391,17 @@ func (s *stream) AppendTrailers(trailers map[string]string) error {
 //TODO: x-protocol stream has encodeHeaders?
 func (s *stream) endStream() {
 	log.StartLogger.Tracef("xprotocol stream end stream invoked , request id = %s, direction = %d", s.streamID, s.direction)
+	oneWay := s.isOneWayRequest()
 	if stream, ok := s.connection.activeStream.Get(s.streamID); ok {
-		log.StartLogger.Tracef("xprotocol stream end stream write encodedata")
-		stream.connection.connection.Write(s.encodedData)
+		// a one way request gets an empty response from upstream, which is not written
+		if s.encodedData != nil {
+			log.StartLogger.Tracef("xprotocol stream end stream write encodedata")
+			if codec := s.connection.codec; codec != nil {
+				// client streams carry the upstream request id, server streams restore the downstream one
+				s.encodedData = buffer.NewIoBufferBytes(codec.SetStreamID(s.encodedData.Bytes(), s.streamID))
+			}
+			stream.connection.connection.Write(s.encodedData)
+		}
 	} else {
 		s.connection.logger.Errorf("No stream %s to end", s.streamID)
 	}
@@ -287,7 +410,23 @@ func (s *stream) endStream() {
 		// for a server stream, remove stream on response wrote
 		s.connection.activeStream.Remove(s.streamID)
 		log.StartLogger.Warnf("Remove Request ID = %+v", s.streamID)
+	} else if oneWay {
+		// no response is expected for a one way request, end it with an empty response once it is sent
+		s.connection.activeStream.Remove(s.streamID)
+		if s.decoder != nil {
+			s.decoder.OnReceiveHeaders(make(map[string]string), true)
+		}
+	}
+}
+
+// isOneWayRequest returns whether the stream sends a request expecting no response
+func (s *stream) isOneWayRequest() bool {
+	if s.direction != ClientStream || s.encodedData == nil {
+		return false
 	}
+
+	oneWay, ok := s.connection.codec.(types.OneWay)
+	return ok && oneWay.IsOneWayRequest(s.encodedData.Bytes())
 }
 
 func (s *stream) GetStream() types.Stream {
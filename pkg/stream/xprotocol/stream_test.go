@@ -0,0 +1,296 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xprotocol
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/network/buffer"
+	"github.com/alipay/sofa-mosn/pkg/protocol/xprotocol/subprotocol"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+const testSubProtocol types.SubProtocol = "x-test"
+
+const testMaxPayloadLen = 1024
+
+// frame: 4 bytes payload length | 4 bytes request id | payload(service name)
+type testCodec struct{}
+
+func (c *testCodec) CreateSubProtocolCodec(context context.Context) types.Multiplexing {
+	return c
+}
+
+func (c *testCodec) SplitFrame(data []byte) ([][]byte, error) {
+	var frames [][]byte
+	for len(data) >= 8 {
+		payloadLen := binary.BigEndian.Uint32(data[0:4])
+		if payloadLen > testMaxPayloadLen {
+			return frames, errors.New("payload too large")
+		}
+		frameLen := 8 + int(payloadLen)
+		if len(data) < frameLen {
+			break
+		}
+		frames = append(frames, data[:frameLen])
+		data = data[frameLen:]
+	}
+	return frames, nil
+}
+
+func (c *testCodec) GetStreamID(data []byte) string {
+	return strconv.FormatUint(uint64(binary.BigEndian.Uint32(data[4:8])), 10)
+}
+
+func (c *testCodec) SetStreamID(data []byte, streamID string) []byte {
+	id, _ := strconv.ParseUint(streamID, 10, 32)
+	binary.BigEndian.PutUint32(data[4:8], uint32(id))
+	return data
+}
+
+func (c *testCodec) GetServiceName(data []byte) string {
+	return string(data[8:])
+}
+
+func (c *testCodec) GetMethodName(data []byte) string {
+	return ""
+}
+
+// requests of testOneWayService expect no response
+const testOneWayService = "com.test.OneWay"
+
+func (c *testCodec) IsOneWayRequest(data []byte) bool {
+	return c.GetServiceName(data) == testOneWayService
+}
+
+func init() {
+	subprotocol.Register(testSubProtocol, &testCodec{})
+}
+
+func newTestFrame(id uint32, service string) []byte {
+	frame := make([]byte, 8+len(service))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(service)))
+	binary.BigEndian.PutUint32(frame[4:8], id)
+	copy(frame[8:], service)
+	return frame
+}
+
+type mockConnection struct {
+	types.Connection
+	written []types.IoBuffer
+	closed  bool
+}
+
+func (c *mockConnection) Close(ccType types.ConnectionCloseType, eventType types.ConnectionEvent) error {
+	c.closed = true
+	return nil
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12200}
+}
+
+func (c *mockConnection) Write(buf ...types.IoBuffer) error {
+	c.written = append(c.written, buf...)
+	return nil
+}
+
+type mockReceiver struct {
+	headers []map[string]string
+	data    []types.IoBuffer
+	ended   bool
+}
+
+func (r *mockReceiver) OnReceiveHeaders(headers map[string]string, endOfStream bool) {
+	r.headers = append(r.headers, headers)
+	r.ended = endOfStream
+}
+
+func (r *mockReceiver) OnReceiveData(data types.IoBuffer, endOfStream bool) {
+	r.data = append(r.data, data)
+	r.ended = endOfStream
+}
+
+func (r *mockReceiver) OnReceiveTrailers(trailers map[string]string) {}
+
+func (r *mockReceiver) OnDecodeError(err error, headers map[string]string) {}
+
+type mockServerCallbacks struct {
+	receiver  *mockReceiver
+	streamIDs []string
+	senders   []types.StreamSender
+}
+
+func (cb *mockServerCallbacks) OnGoAway() {}
+
+func (cb *mockServerCallbacks) NewStream(streamID string, responseSender types.StreamSender) types.StreamReceiver {
+	cb.streamIDs = append(cb.streamIDs, streamID)
+	cb.senders = append(cb.senders, responseSender)
+	return cb.receiver
+}
+
+func testContext() context.Context {
+	return context.WithValue(context.Background(), types.ContextSubProtocol, testSubProtocol)
+}
+
+func Test_streamConnection_DispatchFrames(t *testing.T) {
+	conn := &mockConnection{}
+	callbacks := &mockServerCallbacks{receiver: &mockReceiver{}}
+	sc := newStreamConnection(testContext(), conn, nil, callbacks)
+
+	third := newTestFrame(11, "com.test.C")
+	data := append(newTestFrame(7, "com.test.A"), newTestFrame(9, "com.test.B")...)
+	data = append(data, third[:5]...)
+	buf := buffer.NewIoBufferBytes(data)
+
+	sc.Dispatch(buf)
+	if len(callbacks.streamIDs) != 2 || callbacks.streamIDs[0] != "7" || callbacks.streamIDs[1] != "9" {
+		t.Fatalf("expected streams 7 and 9, got %v", callbacks.streamIDs)
+	}
+	if service := callbacks.receiver.headers[1][subprotocol.HeaderServiceName]; service != "com.test.B" {
+		t.Errorf("expected service header com.test.B, got %s", service)
+	}
+	if buf.Len() != 5 {
+		t.Errorf("expected the incomplete frame to stay in buffer, %d bytes left", buf.Len())
+	}
+
+	buf.Write(third[5:])
+	sc.Dispatch(buf)
+	if len(callbacks.streamIDs) != 3 || callbacks.streamIDs[2] != "11" {
+		t.Fatalf("expected stream 11 after the frame completed, got %v", callbacks.streamIDs)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected buffer drained, %d bytes left", buf.Len())
+	}
+}
+
+func Test_streamConnection_CloseOnSplitError(t *testing.T) {
+	conn := &mockConnection{}
+	callbacks := &mockServerCallbacks{receiver: &mockReceiver{}}
+	sc := newStreamConnection(testContext(), conn, nil, callbacks)
+
+	invalid := make([]byte, 8)
+	binary.BigEndian.PutUint32(invalid[0:4], testMaxPayloadLen+1)
+	buf := buffer.NewIoBufferBytes(append(newTestFrame(7, "com.test.A"), invalid...))
+
+	sc.Dispatch(buf)
+	if len(callbacks.streamIDs) != 1 || callbacks.streamIDs[0] != "7" {
+		t.Errorf("expected the frame before the invalid data dispatched, got %v", callbacks.streamIDs)
+	}
+	if !conn.closed {
+		t.Error("expected connection closed on invalid frame")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected buffer drained, %d bytes left", buf.Len())
+	}
+}
+
+func Test_streamConnection_ServerRestoresStreamID(t *testing.T) {
+	conn := &mockConnection{}
+	callbacks := &mockServerCallbacks{receiver: &mockReceiver{}}
+	sc := newStreamConnection(testContext(), conn, nil, callbacks)
+
+	sc.Dispatch(buffer.NewIoBufferBytes(newTestFrame(7, "com.test.A")))
+	if len(callbacks.senders) != 1 {
+		t.Fatalf("expected one server stream, got %d", len(callbacks.senders))
+	}
+
+	// response from upstream carries the upstream request id
+	callbacks.senders[0].AppendData(buffer.NewIoBufferBytes(newTestFrame(100, "com.test.A")), true)
+	if len(conn.written) != 1 {
+		t.Fatalf("expected one response written, got %d", len(conn.written))
+	}
+	if id := (&testCodec{}).GetStreamID(conn.written[0].Bytes()); id != "7" {
+		t.Errorf("expected response request id 7, got %s", id)
+	}
+	if sc.(*streamConnection).activeStream.Has("7") {
+		t.Error("expected server stream removed after response")
+	}
+}
+
+func Test_streamConnection_ClientCorrelatesResponse(t *testing.T) {
+	conn := &mockConnection{}
+	cc := newStreamConnection(testContext(), conn, nil, nil)
+
+	receivers := []*mockReceiver{{}, {}}
+	senders := []types.StreamSender{cc.NewStream("", receivers[0]), cc.NewStream("", receivers[1])}
+	for i, sender := range senders {
+		// both downstream requests use the same request id
+		sender.AppendData(buffer.NewIoBufferBytes(newTestFrame(7, "com.test."+strconv.Itoa(i))), true)
+	}
+	if len(conn.written) != 2 {
+		t.Fatalf("expected two requests written, got %d", len(conn.written))
+	}
+
+	codec := &testCodec{}
+	first := codec.GetStreamID(conn.written[0].Bytes())
+	second := codec.GetStreamID(conn.written[1].Bytes())
+	if first == second {
+		t.Fatalf("expected distinct upstream request ids, got %s and %s", first, second)
+	}
+
+	// responses arrive out of order
+	id, _ := strconv.ParseUint(second, 10, 32)
+	cc.Dispatch(buffer.NewIoBufferBytes(newTestFrame(uint32(id), "com.test.1")))
+	if len(receivers[1].data) != 1 || len(receivers[0].data) != 0 {
+		t.Fatalf("expected response delivered to the second stream only")
+	}
+	if cc.(*streamConnection).activeStream.Has(second) {
+		t.Error("expected client stream removed after response")
+	}
+}
+
+func Test_streamConnection_OneWayRequest(t *testing.T) {
+	// the client stream ends as soon as the one way request is sent
+	conn := &mockConnection{}
+	cc := newStreamConnection(testContext(), conn, nil, nil)
+
+	receiver := &mockReceiver{}
+	cc.NewStream("", receiver).AppendData(buffer.NewIoBufferBytes(newTestFrame(7, testOneWayService)), true)
+	if len(conn.written) != 1 {
+		t.Fatalf("expected the request written, got %d", len(conn.written))
+	}
+	if !receiver.ended || len(receiver.headers) != 1 {
+		t.Errorf("expected the one way request ended without response")
+	}
+	if id := (&testCodec{}).GetStreamID(conn.written[0].Bytes()); cc.(*streamConnection).activeStream.Has(id) {
+		t.Error("expected one way client stream not waiting for response")
+	}
+
+	// the server stream writes nothing for the empty response
+	conn = &mockConnection{}
+	callbacks := &mockServerCallbacks{receiver: &mockReceiver{}}
+	sc := newStreamConnection(testContext(), conn, nil, callbacks)
+
+	sc.Dispatch(buffer.NewIoBufferBytes(newTestFrame(8, testOneWayService)))
+	if len(callbacks.senders) != 1 {
+		t.Fatalf("expected one server stream, got %d", len(callbacks.senders))
+	}
+	callbacks.senders[0].AppendHeaders(map[string]string{}, true)
+	if len(conn.written) != 0 {
+		t.Errorf("expected no response written for one way request, got %d", len(conn.written))
+	}
+	if sc.(*streamConnection).activeStream.Has("8") {
+		t.Error("expected server stream removed")
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/config"
+	"github.com/alipay/sofa-mosn/pkg/mosn"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+)
+
+// CountServer counts the received requests
+type CountServer struct {
+	received int32
+}
+
+func (s *CountServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.received, 1)
+	fmt.Fprintf(w, "\nServerName:count\n")
+}
+
+func CreateCorsConfig(addr string, hosts []string) *config.MOSNConfig {
+	cmconfig := CreateBasicClusterConfig([]cluster{
+		cluster{name: "mainCluster", hosts: hosts},
+	})
+	header := v2.HeaderMatcher{Name: "service", Value: ".*"}
+	routerV2 := v2.Router{
+		Match: v2.RouterMatch{Headers: []v2.HeaderMatcher{header}},
+		Route: v2.RouteAction{ClusterName: "mainCluster"},
+	}
+	cors := &v2.CorsPolicy{
+		AllowOrigins: []v2.OriginMatcher{{Exact: "http://allowed.com"}},
+		AllowMethods: "GET, POST",
+	}
+	p := &v2.Proxy{
+		DownstreamProtocol: string(protocol.HTTP1),
+		UpstreamProtocol:   string(protocol.HTTP1),
+		VirtualHosts: []*v2.VirtualHost{
+			&v2.VirtualHost{Name: "testHost", Domains: []string{"*"}, Routers: []v2.Router{routerV2}, Cors: cors},
+		},
+	}
+	b, _ := json.Marshal(p)
+	filterChains := make(map[string]interface{})
+	json.Unmarshal(b, &filterChains)
+	proxyconfig := []config.FilterChain{
+		config.FilterChain{Filters: []config.FilterConfig{
+			config.FilterConfig{Type: "proxy", Config: filterChains},
+		}},
+	}
+	meshConfig := CreateMeshConfig(addr, proxyconfig, cmconfig)
+	meshConfig.Servers[0].Listeners[0].StreamFilters = []config.FilterConfig{
+		config.FilterConfig{Type: "cors"},
+	}
+	return meshConfig
+}
+
+func TestCors(t *testing.T) {
+	countServer := &CountServer{}
+	server := httptest.NewServer(countServer)
+	defer server.Close()
+
+	meshAddr := "127.0.0.1:2045"
+	meshConfig := CreateCorsConfig(meshAddr, []string{GetServerAddr(server)})
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+	defer mesh.Close()
+	time.Sleep(5 * time.Second) //wait mesh and server start
+
+	// preflight request is answered by mosn
+	req, _ := http.NewRequest("OPTIONS", fmt.Sprintf("http://%s/", meshAddr), nil)
+	req.Header.Add("service", "test")
+	req.Header.Add("Origin", "http://allowed.com")
+	req.Header.Add("Access-Control-Request-Method", "POST")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("preflight request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK ||
+		resp.Header.Get("Access-Control-Allow-Origin") != "http://allowed.com" ||
+		resp.Header.Get("Access-Control-Allow-Methods") != "GET, POST" {
+		t.Errorf("unexpected preflight response, status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+	if received := atomic.LoadInt32(&countServer.received); received != 0 {
+		t.Errorf("preflight request should not be proxied, upstream received %d", received)
+	}
+
+	// cors headers are set in the response of normal requests
+	req, _ = http.NewRequest("GET", fmt.Sprintf("http://%s/", meshAddr), nil)
+	req.Header.Add("service", "test")
+	req.Header.Add("Origin", "http://allowed.com")
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "http://allowed.com" {
+		t.Errorf("unexpected response, status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+	if received := atomic.LoadInt32(&countServer.received); received != 1 {
+		t.Errorf("request should be proxied, upstream received %d", received)
+	}
+}
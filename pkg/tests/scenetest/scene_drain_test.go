@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/mosn"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/server"
+)
+
+const (
+	drainMeshAddr    = "127.0.0.1:2046"
+	drainUpstreamEnv = "MOSN_TEST_DRAIN_UPSTREAM"
+	drainTimeout     = 30 * time.Second
+)
+
+// TestDrainHelperProcess runs the mesh in a child process forked by TestDrainConnections,
+// it drains the connections on SIGUSR2 and exits
+func TestDrainHelperProcess(t *testing.T) {
+	upstream := os.Getenv(drainUpstreamEnv)
+	if upstream == "" {
+		return
+	}
+	meshConfig := CreateSimpleMeshConfig(drainMeshAddr, []string{upstream}, protocol.HTTP1, protocol.HTTP1)
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGUSR2)
+	<-sigchan
+
+	server.StopAccept()
+	if err := server.WaitConnectionsDone(drainTimeout); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// when the mesh is draining
+// the idle connection should be closed at once, the active request should get its response
+// and the mesh should exit as soon as the request is done
+func TestDrainConnections(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+		w.Write([]byte("drained"))
+	}))
+	defer upstream.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestDrainHelperProcess")
+	cmd.Env = append(os.Environ(), drainUpstreamEnv+"="+GetServerAddr(upstream))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start mesh process failed: %v\n", err)
+	}
+	defer cmd.Process.Kill()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	time.Sleep(5 * time.Second) //wait mesh start
+
+	idle, err := net.Dial("tcp", drainMeshAddr)
+	if err != nil {
+		t.Fatalf("connect to mesh failed: %v\n", err)
+	}
+	defer idle.Close()
+
+	type result struct {
+		resp *http.Response
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		req, _ := http.NewRequest("GET", "http://"+drainMeshAddr+"/", nil)
+		req.Header.Add("service", "drain")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		results <- result{resp: resp, body: string(body), err: err}
+	}()
+	time.Sleep(500 * time.Millisecond) //wait request in flight
+
+	start := time.Now()
+	if err := cmd.Process.Signal(syscall.SIGUSR2); err != nil {
+		t.Fatalf("signal mesh process failed: %v\n", err)
+	}
+
+	//idle connection
+	idle.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := idle.Read(make([]byte, 1)); err == nil {
+		t.Errorf("idle connection is expected to be closed\n")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Errorf("idle connection is not closed on draining\n")
+	}
+
+	//active request
+	r := <-results
+	if r.err != nil {
+		t.Fatalf("request failed: %v\n", r.err)
+	}
+	if r.body != "drained" {
+		t.Errorf("expected response body drained, but got %s\n", r.body)
+	}
+	if !r.resp.Close {
+		t.Errorf("expected response with Connection: close\n")
+	}
+
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Errorf("mesh process exit with error: %v\n", err)
+		}
+		if cost := time.Since(start); cost > 5*time.Second {
+			t.Errorf("mesh process exits %s after draining, expected once the request is done\n", cost)
+		}
+	case <-time.After(drainTimeout):
+		t.Errorf("mesh process does not exit after draining\n")
+	}
+}
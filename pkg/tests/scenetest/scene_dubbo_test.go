@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/mosn"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/dubbo"
+)
+
+func TestDubbo(t *testing.T) {
+	dubboAddr := "127.0.0.1:20880"
+	meshAddr := "127.0.0.1:2046"
+	server := NewUpstreamServer(t, dubboAddr, ServeDubbo)
+	server.GoServe()
+	defer server.Close()
+	meshConfig := CreateSimpleMeshConfig(meshAddr, []string{dubboAddr}, protocol.Dubbo, protocol.Dubbo)
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+	defer mesh.Close()
+	time.Sleep(5 * time.Second) //wait mesh and server start
+	//two clients use the same request ids, mesh multiplexes them on the upstream connection
+	var clients []*DubboClient
+	for i := 0; i < 2; i++ {
+		client, err := NewDubboClient(t, meshAddr)
+		if err != nil {
+			t.Fatalf("connect to mesh error: %v\n", err)
+		}
+		defer client.Close()
+		clients = append(clients, client)
+	}
+	requests := make(map[*DubboClient]map[uint64][]byte)
+	for i, client := range clients {
+		requests[client] = make(map[uint64][]byte)
+		for id := uint64(1); id <= 10; id++ {
+			req := buildDubboRequest(id, "com.test.DubboService", fmt.Sprintf("method%d_%d", i, id))
+			requests[client][id] = req
+			client.Send(req)
+		}
+	}
+	//heartbeat is answered by mesh, the stub server ignores it
+	clients[0].Send(dubbo.NewHeartbeat(100))
+	<-time.After(3 * time.Second)
+	for client, reqs := range requests {
+		for id, req := range reqs {
+			resp := client.Response(id)
+			if resp == nil {
+				t.Errorf("request %d no response\n", id)
+				continue
+			}
+			if dubbo.ResponseStatus(resp) != dubbo.ResponseStatusOK || !bytes.HasSuffix(resp, req[dubbo.HeaderLen:]) {
+				t.Errorf("request %d get unexpected response\n", id)
+			}
+		}
+	}
+	if hb := clients[0].Response(100); hb == nil || !dubbo.IsHeartbeat(hb) || dubbo.ResponseStatus(hb) != dubbo.ResponseStatusOK {
+		t.Errorf("heartbeat no response\n")
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/config"
+	"github.com/alipay/sofa-mosn/pkg/mosn"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+)
+
+func CreateRateLimitConfig(addr string, hosts []string, maxTokens int) *config.MOSNConfig {
+	cmconfig := CreateBasicClusterConfig([]cluster{
+		cluster{name: "mainCluster", hosts: hosts},
+	})
+	header := v2.HeaderMatcher{Name: "service", Value: ".*"}
+	routerV2 := v2.Router{
+		Match: v2.RouterMatch{Headers: []v2.HeaderMatcher{header}},
+		Route: v2.RouteAction{
+			ClusterName: "mainCluster",
+			RateLimits: []v2.RateLimit{
+				{Actions: []v2.RateLimitAction{{DestinationCluster: &v2.DestinationClusterAction{}}}},
+			},
+		},
+	}
+	p := &v2.Proxy{
+		DownstreamProtocol: string(protocol.HTTP1),
+		UpstreamProtocol:   string(protocol.HTTP1),
+		VirtualHosts: []*v2.VirtualHost{
+			&v2.VirtualHost{Name: "testHost", Domains: []string{"*"}, Routers: []v2.Router{routerV2}},
+		},
+	}
+	b, _ := json.Marshal(p)
+	filterChains := make(map[string]interface{})
+	json.Unmarshal(b, &filterChains)
+	proxyconfig := []config.FilterChain{
+		config.FilterChain{Filters: []config.FilterConfig{
+			config.FilterConfig{Type: "proxy", Config: filterChains},
+		}},
+	}
+	meshConfig := CreateMeshConfig(addr, proxyconfig, cmconfig)
+	meshConfig.Servers[0].Listeners[0].StreamFilters = []config.FilterConfig{
+		config.FilterConfig{
+			Type: "local_rate_limit",
+			Config: map[string]interface{}{
+				"descriptors": []interface{}{
+					map[string]interface{}{
+						"entries": []interface{}{
+							map[string]interface{}{"key": "destination_cluster", "value": "mainCluster"},
+						},
+						"max_tokens":    maxTokens,
+						"fill_interval": "1h",
+					},
+				},
+			},
+		},
+	}
+	return meshConfig
+}
+
+func TestLocalRateLimit(t *testing.T) {
+	server := httptest.NewServer(&HTTPServer{
+		t:    t,
+		name: "main",
+	})
+	defer server.Close()
+
+	meshAddr := "127.0.0.1:2045"
+	meshConfig := CreateRateLimitConfig(meshAddr, []string{GetServerAddr(server)}, 2)
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+	defer mesh.Close()
+	time.Sleep(5 * time.Second) //wait mesh and server start
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/", meshAddr), nil)
+		if err != nil {
+			t.Fatalf("create request failed: %v", err)
+		}
+		req.Header.Add("service", "test")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != want {
+			t.Errorf("request %d should get status %d, got %d", i, want, resp.StatusCode)
+		}
+	}
+}
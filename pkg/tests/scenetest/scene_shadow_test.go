@@ -0,0 +1,322 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/config"
+	"github.com/alipay/sofa-mosn/pkg/mosn"
+	"github.com/alipay/sofa-mosn/pkg/network/buffer"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/serialize"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc/codec"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/orcaman/concurrent-map"
+	"golang.org/x/net/http2"
+)
+
+// mirroredRequest is the request received by the shadow server
+type mirroredRequest struct {
+	method  string
+	host    string
+	path    string
+	service string
+	body    string
+}
+
+// ShadowServer counts the mirrored requests, and responds slowly or closes the connection
+// without response, which should not affect the responses of the mirrored requests
+type ShadowServer struct {
+	received int32
+	request  atomic.Value
+	fail     bool
+}
+
+func (s *ShadowServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.received, 1)
+	body, _ := ioutil.ReadAll(r.Body)
+	s.request.Store(mirroredRequest{
+		method:  r.Method,
+		host:    r.Host,
+		path:    r.URL.Path,
+		service: r.Header.Get("service"),
+		body:    string(body),
+	})
+
+	if s.fail {
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	}
+
+	time.Sleep(2 * time.Second)
+	fmt.Fprintf(w, "\nServerName:shadow\n")
+}
+
+// ServeHTTP2 serves the mirrored requests in http2
+func (s *ShadowServer) ServeHTTP2(t *testing.T, conn net.Conn) {
+	server := &http2.Server{IdleTimeout: 1 * time.Minute}
+	server.ServeConn(conn, &http2.ServeConnOpts{Handler: s})
+}
+
+// ServeBoltV1 records the mirrored sofarpc requests and never responds
+func (s *ShadowServer) ServeBoltV1(t *testing.T, conn net.Conn) {
+	iobuf := buffer.NewIoBuffer(102400)
+	buf := make([]byte, 10*1024)
+	for {
+		bytesRead, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		iobuf.Write(buf[:bytesRead])
+		for iobuf.Len() > 1 {
+			_, cmd := codec.BoltV1.GetDecoder().Decode(nil, iobuf)
+			if cmd == nil {
+				break
+			}
+			if req, ok := cmd.(*sofarpc.BoltRequestCommand); ok {
+				headers := make(map[string]string)
+				serialize.Instance.DeSerialize(req.HeaderMap, &headers)
+				atomic.AddInt32(&s.received, 1)
+				s.request.Store(mirroredRequest{service: headers["service"]})
+			}
+		}
+	}
+}
+
+// checkMirrored checks the number of the mirrored requests received if count is not 0, and the last one
+func (s *ShadowServer) checkMirrored(t *testing.T, count int32, expected mirroredRequest) {
+	// mirrored requests are sent asynchronously
+	time.Sleep(time.Second)
+	received := atomic.LoadInt32(&s.received)
+	if received == 0 || (count != 0 && received != count) {
+		t.Errorf("shadow server should receive %d mirrored requests, got %d", count, received)
+	}
+	if request, _ := s.request.Load().(mirroredRequest); request != expected {
+		t.Errorf("mirrored request should be %+v, got %+v", expected, request)
+	}
+}
+
+func CreateShadowRouteConfig(addr string, hosts, shadowHosts []string, proto types.Protocol) *config.MOSNConfig {
+	cmconfig := CreateBasicClusterConfig([]cluster{
+		cluster{name: "mainCluster", hosts: hosts},
+		cluster{name: "shadowCluster", hosts: shadowHosts},
+	})
+	header := v2.HeaderMatcher{Name: "service", Value: ".*"}
+	routerV2 := v2.Router{
+		Match: v2.RouterMatch{Headers: []v2.HeaderMatcher{header}},
+		Route: v2.RouteAction{
+			ClusterName:  "mainCluster",
+			ShadowPolicy: &v2.ShadowPolicy{Cluster: "shadowCluster"},
+		},
+	}
+	p := &v2.Proxy{
+		DownstreamProtocol: string(proto),
+		UpstreamProtocol:   string(proto),
+		VirtualHosts: []*v2.VirtualHost{
+			&v2.VirtualHost{Name: "testHost", Domains: []string{"*"}, Routers: []v2.Router{routerV2}},
+		},
+	}
+	b, _ := json.Marshal(p)
+	filterChains := make(map[string]interface{})
+	json.Unmarshal(b, &filterChains)
+	proxyconfig := []config.FilterChain{
+		config.FilterChain{Filters: []config.FilterConfig{
+			config.FilterConfig{Type: "proxy", Config: filterChains},
+		}},
+	}
+	return CreateMeshConfig(addr, proxyconfig, cmconfig)
+}
+
+func newShadowRequest(t *testing.T, meshAddr string) *http.Request {
+	req, err := http.NewRequest("POST", fmt.Sprintf("http://%s/foo", meshAddr), strings.NewReader("mirrored body"))
+	if err != nil {
+		t.Fatalf("create request failed: %v", err)
+	}
+	req.Host = "shadow.test"
+	req.Header.Add("service", "test")
+	return req
+}
+
+var expectedMirroredRequest = mirroredRequest{
+	method:  "POST",
+	host:    "shadow.test",
+	path:    "/foo",
+	service: "test",
+	body:    "mirrored body",
+}
+
+func TestShadow(t *testing.T) {
+	mainServer := httptest.NewServer(&HTTPServer{
+		t:    t,
+		name: "main",
+	})
+	defer mainServer.Close()
+	shadowServer := &ShadowServer{}
+	server := httptest.NewServer(shadowServer)
+	defer server.Close()
+
+	meshAddr := "127.0.0.1:2045"
+	meshConfig := CreateShadowRouteConfig(meshAddr, []string{GetServerAddr(mainServer)}, []string{GetServerAddr(server)}, protocol.HTTP1)
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+	defer mesh.Close()
+	time.Sleep(5 * time.Second) //wait mesh and server start
+
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if name := ParseHTTPResponse(t, newShadowRequest(t, meshAddr)); name != "main" {
+			t.Errorf("response should be from the main cluster, got %s", name)
+		}
+		if cost := time.Since(start); cost > time.Second {
+			t.Errorf("mirrored request should not delay the response, cost %v", cost)
+		}
+	}
+
+	shadowServer.checkMirrored(t, 5, expectedMirroredRequest)
+}
+
+func TestShadowFailure(t *testing.T) {
+	mainServer := httptest.NewServer(&HTTPServer{
+		t:    t,
+		name: "main",
+	})
+	defer mainServer.Close()
+	shadowServer := &ShadowServer{fail: true}
+	server := httptest.NewServer(shadowServer)
+	defer server.Close()
+
+	// one of the shadow hosts is not listening, mirrored requests to it fail to connect
+	meshAddr := "127.0.0.1:2047"
+	meshConfig := CreateShadowRouteConfig(meshAddr, []string{GetServerAddr(mainServer)},
+		[]string{GetServerAddr(server), "127.0.0.1:2048"}, protocol.HTTP1)
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+	defer mesh.Close()
+	time.Sleep(5 * time.Second) //wait mesh and server start
+
+	for i := 0; i < 4; i++ {
+		if name := ParseHTTPResponse(t, newShadowRequest(t, meshAddr)); name != "main" {
+			t.Errorf("response should be from the main cluster, got %s", name)
+		}
+	}
+
+	// some of the mirrored requests reach the shadow server
+	shadowServer.checkMirrored(t, 0, expectedMirroredRequest)
+}
+
+func TestShadowHTTP2(t *testing.T) {
+	mainAddr := "127.0.0.1:8080"
+	mainServer := NewUpstreamHTTP2(t, mainAddr)
+	mainServer.GoServe()
+	defer mainServer.Close()
+	shadowAddr := "127.0.0.1:8081"
+	shadowServer := &ShadowServer{}
+	server := NewUpstreamServer(t, shadowAddr, shadowServer.ServeHTTP2)
+	server.GoServe()
+	defer server.Close()
+
+	meshAddr := "127.0.0.1:2049"
+	meshConfig := CreateShadowRouteConfig(meshAddr, []string{mainAddr}, []string{shadowAddr}, protocol.HTTP2)
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+	defer mesh.Close()
+	time.Sleep(5 * time.Second) //wait mesh and server start
+
+	tr := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(netw, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(netw, addr)
+		},
+	}
+	httpClient := http.Client{Transport: tr}
+
+	for i := 0; i < 5; i++ {
+		req := newShadowRequest(t, meshAddr)
+		req.Header.Add("Requestid", fmt.Sprintf("%d", i))
+
+		start := time.Now()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			t.Errorf("request %d failed: %v", i, err)
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), fmt.Sprintf("\nRequestId:%d\n", i)) {
+			t.Errorf("response should be from the main cluster, got %q", body)
+		}
+		if cost := time.Since(start); cost > time.Second {
+			t.Errorf("mirrored request should not delay the response, cost %v", cost)
+		}
+	}
+
+	shadowServer.checkMirrored(t, 5, expectedMirroredRequest)
+}
+
+func TestShadowSofaRPC(t *testing.T) {
+	mainAddr := "127.0.0.1:8080"
+	mainServer := NewUpstreamServer(t, mainAddr, ServeBoltV1)
+	mainServer.GoServe()
+	defer mainServer.Close()
+	shadowAddr := "127.0.0.1:8081"
+	shadowServer := &ShadowServer{}
+	server := NewUpstreamServer(t, shadowAddr, shadowServer.ServeBoltV1)
+	server.GoServe()
+	defer server.Close()
+
+	meshAddr := "127.0.0.1:2051"
+	meshConfig := CreateShadowRouteConfig(meshAddr, []string{mainAddr}, []string{shadowAddr}, protocol.SofaRPC)
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+	defer mesh.Close()
+	time.Sleep(5 * time.Second) //wait mesh and server start
+
+	client := &BoltV1Client{
+		t:        t,
+		ClientID: "testClient",
+		Waits:    cmap.New(),
+	}
+	client.Connect(meshAddr)
+	defer client.conn.Close(types.NoFlush, types.LocalClose)
+	for i := 0; i < 5; i++ {
+		client.SendRequest()
+	}
+
+	// the shadow server never responds, which should not affect the responses from the main cluster
+	<-time.After(time.Second)
+	if !client.Waits.IsEmpty() {
+		t.Errorf("exists request no response\n")
+	}
+
+	shadowServer.checkMirrored(t, 5, mirroredRequest{service: "testSofa"})
+}
@@ -0,0 +1,215 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/config"
+	"github.com/alipay/sofa-mosn/pkg/mosn"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"golang.org/x/net/http2"
+)
+
+// TraceServer fails the first request, and records the trace context of the received requests
+type TraceServer struct {
+	mux     sync.Mutex
+	spanIDs []string
+	traceID string
+}
+
+func (s *TraceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	s.spanIDs = append(s.spanIDs, r.Header.Get("X-B3-SpanId"))
+	s.traceID = r.Header.Get("X-B3-TraceId")
+	first := len(s.spanIDs) == 1
+	s.mux.Unlock()
+
+	if first {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "\nServerName:trace\n")
+}
+
+func (s *TraceServer) ServeConn(t *testing.T, conn net.Conn) {
+	server := &http2.Server{IdleTimeout: 1 * time.Minute}
+	server.ServeConn(conn, &http2.ServeConnOpts{Handler: s})
+}
+
+type collectedSpan struct {
+	TraceID  string            `json:"traceId"`
+	ID       string            `json:"id"`
+	ParentID string            `json:"parentId"`
+	Name     string            `json:"name"`
+	Kind     string            `json:"kind"`
+	Tags     map[string]string `json:"tags"`
+}
+
+// ZipkinCollector is a zipkin compatible collector stand-in
+type ZipkinCollector struct {
+	mux   sync.Mutex
+	spans []collectedSpan
+}
+
+func (c *ZipkinCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var spans []collectedSpan
+	json.NewDecoder(r.Body).Decode(&spans)
+
+	c.mux.Lock()
+	c.spans = append(c.spans, spans...)
+	c.mux.Unlock()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func CreateTraceConfig(addr string, hosts []string, collector string) *config.MOSNConfig {
+	cmconfig := CreateBasicClusterConfig([]cluster{
+		cluster{name: "mainCluster", hosts: hosts},
+	})
+	header := v2.HeaderMatcher{Name: "service", Value: ".*"}
+	routerV2 := v2.Router{
+		Match: v2.RouterMatch{Headers: []v2.HeaderMatcher{header}},
+		Route: v2.RouteAction{
+			ClusterName: "mainCluster",
+			RetryPolicy: &v2.RetryPolicy{RetryOn: true, NumRetries: 1},
+		},
+		Decorator: "traced-operation",
+	}
+	p := &v2.Proxy{
+		DownstreamProtocol: string(protocol.HTTP1),
+		UpstreamProtocol:   string(protocol.HTTP2),
+		VirtualHosts: []*v2.VirtualHost{
+			&v2.VirtualHost{Name: "testHost", Domains: []string{"*"}, Routers: []v2.Router{routerV2}},
+		},
+	}
+	b, _ := json.Marshal(p)
+	filterChains := make(map[string]interface{})
+	json.Unmarshal(b, &filterChains)
+	proxyconfig := []config.FilterChain{
+		config.FilterChain{Filters: []config.FilterConfig{
+			config.FilterConfig{Type: "proxy", Config: filterChains},
+		}},
+	}
+	meshConfig := CreateMeshConfig(addr, proxyconfig, cmconfig)
+	meshConfig.Tracing = config.TracingConfig{
+		Enable: true,
+		Driver: "zipkin",
+		Config: map[string]interface{}{
+			"collector_endpoint": collector,
+			"flush_interval":     "100ms",
+		},
+	}
+	return meshConfig
+}
+
+func TestTrace(t *testing.T) {
+	traceServer := &TraceServer{}
+	appAddr := "127.0.0.1:8080"
+	server := NewUpstreamServer(t, appAddr, traceServer.ServeConn)
+	server.GoServe()
+	defer server.Close()
+
+	collector := &ZipkinCollector{}
+	collectorServer := httptest.NewServer(collector)
+	defer collectorServer.Close()
+
+	meshAddr := "127.0.0.1:2045"
+	meshConfig := CreateTraceConfig(meshAddr, []string{appAddr}, collectorServer.URL+"/api/v2/spans")
+	mesh := mosn.NewMosn(meshConfig)
+	go mesh.Start()
+	time.Sleep(5 * time.Second) //wait mesh and server start
+
+	traceID := "463ac35c9f6413ad48485a3953bb6124"
+	callerSpanID := "a2fb4a1d1a96d312"
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://%s/", meshAddr), nil)
+	req.Header.Add("service", "test")
+	req.Header.Add("X-B3-TraceId", traceID)
+	req.Header.Add("X-B3-SpanId", callerSpanID)
+	req.Header.Add("X-B3-Sampled", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		mesh.Close()
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// closing flushes the spans
+	time.Sleep(time.Second)
+	mesh.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 after retry, but got %d", resp.StatusCode)
+	}
+
+	traceServer.mux.Lock()
+	upstreamSpanIDs := traceServer.spanIDs
+	upstreamTraceID := traceServer.traceID
+	traceServer.mux.Unlock()
+
+	if len(upstreamSpanIDs) != 2 || upstreamTraceID != traceID {
+		t.Fatalf("upstream should receive 2 attempts in the trace, but got %v in trace %s", upstreamSpanIDs, upstreamTraceID)
+	}
+
+	collector.mux.Lock()
+	spans := collector.spans
+	collector.mux.Unlock()
+
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans reported, but got %+v", spans)
+	}
+
+	var serverSpan *collectedSpan
+	clientSpans := make(map[string]collectedSpan)
+	for i := range spans {
+		if spans[i].TraceID != traceID {
+			t.Errorf("span not in the trace: %+v", spans[i])
+		}
+		if spans[i].Kind == "SERVER" {
+			serverSpan = &spans[i]
+		} else {
+			clientSpans[spans[i].ID] = spans[i]
+		}
+	}
+
+	if serverSpan == nil || serverSpan.ParentID != callerSpanID || serverSpan.Name != "traced-operation" ||
+		serverSpan.Tags["response_code"] != "200" {
+		t.Fatalf("unexpected server span: %+v", serverSpan)
+	}
+
+	// each attempt is a child span of the request, and the upstream joins the trace with it
+	for i, spanID := range upstreamSpanIDs {
+		clientSpan, ok := clientSpans[spanID]
+		if !ok || clientSpan.ParentID != serverSpan.ID || clientSpan.Tags["upstream_cluster"] != "mainCluster" {
+			t.Errorf("unexpected client span of attempt %d: %+v", i, clientSpan)
+		}
+	}
+
+	if failed := clientSpans[upstreamSpanIDs[0]]; failed.Tags["error"] != "true" || failed.Tags["response_code"] != "500" {
+		t.Errorf("the failed attempt should be tagged with error: %+v", failed)
+	}
+}
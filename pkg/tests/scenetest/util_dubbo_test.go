@@ -0,0 +1,147 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/protocol/dubbo"
+	"github.com/alipay/sofa-mosn/pkg/protocol/xprotocol/subprotocol"
+)
+
+// Dubbo Protocols
+func buildDubboFrame(flag byte, status byte, requestID uint64, body []byte) []byte {
+	frame := make([]byte, dubbo.HeaderLen+len(body))
+	frame[0] = dubbo.MagicHigh
+	frame[1] = dubbo.MagicLow
+	frame[2] = flag
+	frame[3] = status
+	binary.BigEndian.PutUint64(frame[4:], requestID)
+	binary.BigEndian.PutUint32(frame[12:], uint32(len(body)))
+	copy(frame[dubbo.HeaderLen:], body)
+	return frame
+}
+
+// hessian2 short strings only
+func buildHessianString(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func buildDubboRequest(requestID uint64, service, method string) []byte {
+	var body []byte
+	for _, s := range []string{"2.0.2", service, "1.0.0", method, ""} {
+		body = append(body, buildHessianString(s)...)
+	}
+	return buildDubboFrame(dubbo.FlagRequest|dubbo.FlagTwoWay|dubbo.SerializationHessian2, 0, requestID, body)
+}
+
+func buildDubboResponse(req []byte) []byte {
+	// RESPONSE_VALUE(int 1) followed by the request body as the value
+	body := append([]byte{0x91}, req[dubbo.HeaderLen:]...)
+	return buildDubboFrame(dubbo.SerializationHessian2, dubbo.ResponseStatusOK, dubbo.RequestID(req), body)
+}
+
+// Dubbo Serve
+// answers every request, heartbeats are ignored so that they can only be answered by mesh
+func ServeDubbo(t *testing.T, conn net.Conn) {
+	codec := subprotocol.CreateSubProtocolCodec(nil, dubbo.SubProtocol)
+	var data []byte
+	buf := make([]byte, 10*1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		bytesRead, err := conn.Read(buf)
+		if err != nil {
+			if err, ok := err.(net.Error); ok && err.Timeout() {
+				continue
+			}
+			return
+		}
+		data = append(data, buf[:bytesRead]...)
+		frames, _ := codec.SplitFrame(data)
+		for _, frame := range frames {
+			data = data[len(frame):]
+			if dubbo.IsHeartbeat(frame) {
+				continue
+			}
+			conn.Write(buildDubboResponse(frame))
+		}
+	}
+}
+
+// Dubbo Client
+// sends raw dubbo frames and records the responses by request id
+type DubboClient struct {
+	t         *testing.T
+	conn      net.Conn
+	mu        sync.Mutex
+	responses map[uint64][]byte
+}
+
+func NewDubboClient(t *testing.T, addr string) (*DubboClient, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &DubboClient{
+		t:         t,
+		conn:      conn,
+		responses: make(map[uint64][]byte),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *DubboClient) readLoop() {
+	codec := subprotocol.CreateSubProtocolCodec(nil, dubbo.SubProtocol)
+	var data []byte
+	buf := make([]byte, 10*1024)
+	for {
+		bytesRead, err := c.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		data = append(data, buf[:bytesRead]...)
+		frames, _ := codec.SplitFrame(data)
+		for _, frame := range frames {
+			data = data[len(frame):]
+			c.mu.Lock()
+			c.responses[dubbo.RequestID(frame)] = frame
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (c *DubboClient) Send(frame []byte) {
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Errorf("dubbo client write error: %v\n", err)
+	}
+}
+
+func (c *DubboClient) Response(requestID uint64) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.responses[requestID]
+}
+
+func (c *DubboClient) Close() {
+	c.conn.Close()
+}
@@ -0,0 +1,199 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package trace
+
+import (
+	"strings"
+
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc/models"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// B3 and W3C trace context headers, in lower case as the http streams decode headers
+const (
+	headerB3TraceID      = "x-b3-traceid"
+	headerB3SpanID       = "x-b3-spanid"
+	headerB3ParentSpanID = "x-b3-parentspanid"
+	headerB3Sampled      = "x-b3-sampled"
+	headerB3Flags        = "x-b3-flags"
+	headerTraceParent    = "traceparent"
+
+	traceParentVersion = "00"
+	// the rpc id of the root span in SOFATracer
+	rootRpcID = "0"
+)
+
+// spanContext is the trace context propagated across processes
+type spanContext struct {
+	traceID  string
+	spanID   string
+	parentID string
+
+	sampled bool
+	// sampledSet is true if the sampling decision is made by the caller
+	sampledSet bool
+
+	// SOFATracer context, the trace id is kept as is to be propagated to SOFARPC upstreams
+	sofaTraceID string
+	rpcID       string
+}
+
+// extractContext extracts the caller's trace context from the request headers,
+// B3 headers are preferred to W3C traceparent, and then SOFATracer rpc_trace_context
+func extractContext(headers map[string]string) (spanContext, bool) {
+	ctx, ok := extractB3(headers)
+
+	if !ok {
+		ctx, ok = extractTraceParent(headers)
+	}
+
+	if !ok {
+		return extractSofaTracer(headers)
+	}
+
+	ctx.sofaTraceID = ctx.traceID
+	ctx.rpcID = rootRpcID
+
+	if rpcID, ok := headers[models.RPC_ID_KEY]; ok && rpcID != "" {
+		ctx.rpcID = rpcID
+	}
+
+	return ctx, true
+}
+
+func extractB3(headers map[string]string) (spanContext, bool) {
+	traceID, ok := normalizeTraceID(headers[headerB3TraceID])
+	if !ok {
+		return spanContext{}, false
+	}
+
+	ctx := spanContext{traceID: traceID}
+
+	if spanID := headers[headerB3SpanID]; isHexID(spanID, 16) {
+		ctx.parentID = spanID
+	}
+
+	if headers[headerB3Flags] == "1" {
+		ctx.sampled, ctx.sampledSet = true, true
+	} else {
+		switch headers[headerB3Sampled] {
+		case "1", "true":
+			ctx.sampled, ctx.sampledSet = true, true
+		case "0", "false":
+			ctx.sampled, ctx.sampledSet = false, true
+		}
+	}
+
+	return ctx, true
+}
+
+// traceparent: version-traceid-parentid-flags, such as 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01
+func extractTraceParent(headers map[string]string) (spanContext, bool) {
+	parts := strings.Split(headers[headerTraceParent], "-")
+
+	if len(parts) < 4 || parts[0] != traceParentVersion ||
+		!isHexID(parts[1], 32) || !isHexID(parts[2], 16) || !isHex(parts[3], 2) {
+		return spanContext{}, false
+	}
+
+	return spanContext{
+		traceID:    parts[1],
+		parentID:   parts[2],
+		sampled:    parts[3][1]&1 == 1,
+		sampledSet: true,
+	}, true
+}
+
+func extractSofaTracer(headers map[string]string) (spanContext, bool) {
+	sofaTraceID := headers[models.TRACER_ID_KEY]
+	if sofaTraceID == "" {
+		return spanContext{}, false
+	}
+
+	// SOFATracer's trace id is hex encoded in general, otherwise a new id is used in zipkin format
+	traceID, ok := normalizeTraceID(sofaTraceID)
+	if !ok {
+		traceID = newTraceID()
+	}
+
+	rpcID := headers[models.RPC_ID_KEY]
+	if rpcID == "" {
+		rpcID = rootRpcID
+	}
+
+	return spanContext{
+		traceID:     traceID,
+		sofaTraceID: sofaTraceID,
+		rpcID:       rpcID,
+	}, true
+}
+
+// injectContext writes the trace context in SOFATracer's format for SOFARPC,
+// and in both B3 and W3C formats for other protocols
+func injectContext(proto types.Protocol, ctx spanContext, headers map[string]string) {
+	if proto == protocol.SofaRPC {
+		headers[models.TRACER_ID_KEY] = ctx.sofaTraceID
+		headers[models.RPC_ID_KEY] = ctx.rpcID
+		return
+	}
+
+	sampled, flags := "0", "00"
+	if ctx.sampled {
+		sampled, flags = "1", "01"
+	}
+
+	headers[headerB3TraceID] = ctx.traceID
+	headers[headerB3SpanID] = ctx.spanID
+	headers[headerB3Sampled] = sampled
+
+	if ctx.parentID != "" {
+		headers[headerB3ParentSpanID] = ctx.parentID
+	} else {
+		delete(headers, headerB3ParentSpanID)
+	}
+
+	headers[headerTraceParent] = strings.Join([]string{traceParentVersion, ctx.traceID, ctx.spanID, flags}, "-")
+}
+
+// normalizeTraceID pads the hex trace id to 32 characters
+func normalizeTraceID(id string) (string, bool) {
+	if len(id) == 0 || len(id) > 32 || !isHex(id, len(id)) || strings.Trim(id, "0") == "" {
+		return "", false
+	}
+
+	return strings.Repeat("0", 32-len(id)) + strings.ToLower(id), true
+}
+
+// isHexID checks the id is hex encoded in the given length, and not all zeros
+func isHexID(id string, length int) bool {
+	return isHex(id, length) && strings.Trim(id, "0") != ""
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+
+	return true
+}
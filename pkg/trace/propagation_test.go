@@ -0,0 +1,193 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package trace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/sofarpc/models"
+)
+
+func TestExtractContext(t *testing.T) {
+	testCases := []struct {
+		name     string
+		headers  map[string]string
+		ok       bool
+		expected spanContext
+	}{
+		{
+			name:    "no context",
+			headers: map[string]string{"service": "test"},
+		},
+		{
+			name: "b3",
+			headers: map[string]string{
+				headerB3TraceID: "463ac35c9f6413ad",
+				headerB3SpanID:  "a2fb4a1d1a96d312",
+				headerB3Sampled: "0",
+			},
+			ok: true,
+			expected: spanContext{
+				traceID:     "0000000000000000463ac35c9f6413ad",
+				parentID:    "a2fb4a1d1a96d312",
+				sampledSet:  true,
+				sofaTraceID: "0000000000000000463ac35c9f6413ad",
+				rpcID:       rootRpcID,
+			},
+		},
+		{
+			name: "b3 without sampling decision",
+			headers: map[string]string{
+				headerB3TraceID: "463ac35c9f6413ad48485a3953bb6124",
+			},
+			ok: true,
+			expected: spanContext{
+				traceID:     "463ac35c9f6413ad48485a3953bb6124",
+				sofaTraceID: "463ac35c9f6413ad48485a3953bb6124",
+				rpcID:       rootRpcID,
+			},
+		},
+		{
+			name: "traceparent",
+			headers: map[string]string{
+				headerTraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			},
+			ok: true,
+			expected: spanContext{
+				traceID:     "0af7651916cd43dd8448eb211c80319c",
+				parentID:    "b7ad6b7169203331",
+				sampled:     true,
+				sampledSet:  true,
+				sofaTraceID: "0af7651916cd43dd8448eb211c80319c",
+				rpcID:       rootRpcID,
+			},
+		},
+		{
+			name: "invalid traceparent",
+			headers: map[string]string{
+				headerTraceParent: "00-00000000000000000000000000000000-b7ad6b7169203331-01",
+			},
+		},
+		{
+			name: "sofatracer",
+			headers: map[string]string{
+				models.TRACER_ID_KEY: "0a0fe8801518533188424100118090",
+				models.RPC_ID_KEY:    "0.1",
+			},
+			ok: true,
+			expected: spanContext{
+				traceID:     "000a0fe8801518533188424100118090",
+				sofaTraceID: "0a0fe8801518533188424100118090",
+				rpcID:       "0.1",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		ctx, ok := extractContext(tc.headers)
+		if ok != tc.ok {
+			t.Errorf("%s: expected extracted %v, but got %v", tc.name, tc.ok, ok)
+			continue
+		}
+		if ctx != tc.expected {
+			t.Errorf("%s: expected context %+v, but got %+v", tc.name, tc.expected, ctx)
+		}
+	}
+}
+
+func TestExtractNonHexSofaTraceID(t *testing.T) {
+	ctx, ok := extractContext(map[string]string{models.TRACER_ID_KEY: "not-a-hex-id"})
+	if !ok {
+		t.Fatal("sofatracer context should be extracted")
+	}
+	if !isHexID(ctx.traceID, 32) || ctx.sofaTraceID != "not-a-hex-id" || ctx.rpcID != rootRpcID {
+		t.Errorf("unexpected context %+v", ctx)
+	}
+}
+
+func TestInjectContext(t *testing.T) {
+	tracer := &tracer{sampleRate: 1}
+	parent := tracer.Start(map[string]string{
+		models.TRACER_ID_KEY: "0a0fe8801518533188424100118090",
+		models.RPC_ID_KEY:    "0.1",
+	}, "parent", time.Now())
+
+	first := parent.SpawnChild("first", time.Now())
+	second := parent.SpawnChild("second", time.Now())
+
+	// http upstream joins the trace by b3 and traceparent headers
+	headers := map[string]string{}
+	second.InjectContext(protocol.HTTP1, headers)
+
+	if headers[headerB3TraceID] != parent.TraceID() ||
+		headers[headerB3SpanID] != second.SpanID() ||
+		headers[headerB3ParentSpanID] != parent.SpanID() ||
+		headers[headerB3Sampled] != "1" {
+		t.Errorf("unexpected b3 headers: %v", headers)
+	}
+
+	expected := "00-" + parent.TraceID() + "-" + second.SpanID() + "-01"
+	if headers[headerTraceParent] != expected {
+		t.Errorf("expected traceparent %s, but got %s", expected, headers[headerTraceParent])
+	}
+
+	ctx, ok := extractContext(headers)
+	if !ok || ctx.traceID != parent.TraceID() || ctx.parentID != second.SpanID() || !ctx.sampled {
+		t.Errorf("injected context is not extracted, got %+v", ctx)
+	}
+
+	// sofarpc upstream joins the trace by rpc_trace_context, rpc ids are numbered by the children
+	headers = map[string]string{}
+	first.InjectContext(protocol.SofaRPC, headers)
+
+	if headers[models.TRACER_ID_KEY] != "0a0fe8801518533188424100118090" || headers[models.RPC_ID_KEY] != "0.1.1" {
+		t.Errorf("unexpected sofatracer headers: %v", headers)
+	}
+
+	headers = map[string]string{}
+	second.InjectContext(protocol.SofaRPC, headers)
+
+	if headers[models.RPC_ID_KEY] != "0.1.2" {
+		t.Errorf("expected rpc id 0.1.2, but got %s", headers[models.RPC_ID_KEY])
+	}
+}
+
+func TestSampling(t *testing.T) {
+	tracer := &tracer{sampleRate: 0}
+
+	// new traces are not sampled
+	s := tracer.Start(map[string]string{}, "test", time.Now()).(*span)
+	if s.context.sampled {
+		t.Error("span should not be sampled with sample rate 0")
+	}
+
+	// the caller's sampling decision is kept
+	s = tracer.Start(map[string]string{
+		headerB3TraceID: "463ac35c9f6413ad",
+		headerB3Sampled: "1",
+	}, "test", time.Now()).(*span)
+	if !s.context.sampled {
+		t.Error("span should be sampled as the caller decides")
+	}
+
+	child := s.SpawnChild("child", time.Now()).(*span)
+	if !child.context.sampled || child.kind != spanKindClient {
+		t.Errorf("unexpected child span: %+v", child.context)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package trace
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// tags set on the spans created by the proxy
+const (
+	TagProtocol          = "protocol"
+	TagDownstreamAddress = "downstream_address"
+	TagUpstreamCluster   = "upstream_cluster"
+	TagUpstreamHost      = "upstream_host"
+	TagResponseCode      = "response_code"
+	TagResponseFlags     = "response_flags"
+	TagResetReason       = "reset_reason"
+	TagError             = "error"
+)
+
+// span kinds, a span is a server span if it's started by the driver,
+// and a client span if it's spawned as a child
+const (
+	spanKindServer = "SERVER"
+	spanKindClient = "CLIENT"
+)
+
+// reporter exports the finished spans
+type reporter interface {
+	report(s *span)
+}
+
+// tracer is a types.Driver creating spans which are exported by the reporter
+type tracer struct {
+	serviceName string
+	sampleRate  float64
+	reporter    reporter
+}
+
+func (t *tracer) Start(requestHeaders map[string]string, operationName string, startTime time.Time) types.Span {
+	ctx, ok := extractContext(requestHeaders)
+
+	if !ok {
+		traceID := newTraceID()
+		ctx = spanContext{
+			traceID:     traceID,
+			sampled:     t.sample(),
+			sofaTraceID: traceID,
+			rpcID:       rootRpcID,
+		}
+	} else if !ctx.sampledSet {
+		ctx.sampled = t.sample()
+	}
+
+	ctx.spanID = newSpanID()
+
+	return newSpan(t, ctx, operationName, spanKindServer, startTime)
+}
+
+func (t *tracer) sample() bool {
+	return t.sampleRate >= 1 || rand.Float64() < t.sampleRate
+}
+
+// span implements types.Span
+type span struct {
+	tracer    *tracer
+	context   spanContext
+	kind      string
+	startTime time.Time
+	duration  time.Duration
+
+	mux       sync.Mutex
+	operation string
+	tags      map[string]string
+
+	// count of the spawned children, used to generate the children's rpc id
+	children uint32
+	finished uint32
+}
+
+func newSpan(t *tracer, ctx spanContext, operationName string, kind string, startTime time.Time) *span {
+	return &span{
+		tracer:    t,
+		context:   ctx,
+		kind:      kind,
+		startTime: startTime,
+		operation: operationName,
+		tags:      make(map[string]string),
+	}
+}
+
+func (s *span) TraceID() string {
+	return s.context.traceID
+}
+
+func (s *span) SpanID() string {
+	return s.context.spanID
+}
+
+func (s *span) ParentSpanID() string {
+	return s.context.parentID
+}
+
+func (s *span) SetOperation(operation string) {
+	s.mux.Lock()
+	s.operation = operation
+	s.mux.Unlock()
+}
+
+func (s *span) SetTag(key string, value string) {
+	s.mux.Lock()
+	s.tags[key] = value
+	s.mux.Unlock()
+}
+
+func (s *span) FinishSpan() {
+	if !atomic.CompareAndSwapUint32(&s.finished, 0, 1) {
+		return
+	}
+
+	s.duration = time.Since(s.startTime)
+
+	if s.context.sampled && s.tracer.reporter != nil {
+		s.tracer.reporter.report(s)
+	}
+}
+
+func (s *span) InjectContext(protocol types.Protocol, requestHeaders map[string]string) {
+	injectContext(protocol, s.context, requestHeaders)
+}
+
+func (s *span) SpawnChild(operationName string, startTime time.Time) types.Span {
+	seq := atomic.AddUint32(&s.children, 1)
+
+	ctx := spanContext{
+		traceID:     s.context.traceID,
+		spanID:      newSpanID(),
+		parentID:    s.context.spanID,
+		sampled:     s.context.sampled,
+		sampledSet:  true,
+		sofaTraceID: s.context.sofaTraceID,
+		rpcID:       s.context.rpcID + "." + strconv.FormatUint(uint64(seq), 10),
+	}
+
+	return newSpan(s.tracer, ctx, operationName, spanKindClient, startTime)
+}
+
+// snapshot returns the operation name and a copy of the tags
+func (s *span) snapshot() (string, map[string]string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+
+	return s.operation, tags
+}
+
+// ids are lower-hex encoded, 32 characters for trace ids and 16 characters for span ids
+func newTraceID() string {
+	return fmt.Sprintf("%016x%016x", rand.Uint64(), newID())
+}
+
+func newSpanID() string {
+	return fmt.Sprintf("%016x", newID())
+}
+
+func newID() uint64 {
+	for {
+		if id := rand.Uint64(); id != 0 {
+			return id
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// DriverCreator creates a tracing driver with the driver's config
+type DriverCreator func(config map[string]interface{}) (types.Driver, error)
+
+var (
+	creators = make(map[string]DriverCreator)
+
+	// the enabled driver, holds a driverHolder
+	driver atomic.Value
+	// serializes enabling and disabling the driver
+	mux sync.Mutex
+)
+
+type driverHolder struct {
+	driver types.Driver
+}
+
+// RegisterDriver registers a tracing driver creator by name
+func RegisterDriver(name string, creator DriverCreator) {
+	creators[name] = creator
+}
+
+// Init creates the named driver and enables tracing with it, the previous driver is disabled
+func Init(name string, config map[string]interface{}) error {
+	creator, ok := creators[name]
+	if !ok {
+		return fmt.Errorf("unknown tracing driver: %s", name)
+	}
+
+	d, err := creator(config)
+	if err != nil {
+		return err
+	}
+
+	if d == nil {
+		return errors.New("tracing driver " + name + " created nil driver")
+	}
+
+	setDriver(d)
+	log.DefaultLogger.Infof("tracing enabled with driver %s", name)
+
+	return nil
+}
+
+// Disable disables tracing, the spans buffered by the driver are flushed
+func Disable() {
+	setDriver(nil)
+}
+
+// Driver returns the enabled driver, or nil if tracing is disabled
+func Driver() types.Driver {
+	if holder, ok := driver.Load().(driverHolder); ok {
+		return holder.driver
+	}
+
+	return nil
+}
+
+// IsEnabled returns true if tracing is enabled
+func IsEnabled() bool {
+	return Driver() != nil
+}
+
+func setDriver(d types.Driver) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	old := Driver()
+	driver.Store(driverHolder{driver: d})
+
+	if closer, ok := old.(io.Closer); ok {
+		closer.Close()
+	}
+}
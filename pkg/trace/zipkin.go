@@ -0,0 +1,260 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// ZipkinDriverName is the name of the driver exporting spans to a zipkin compatible collector
+const ZipkinDriverName = "zipkin"
+
+const (
+	defaultServiceName   = "mosn"
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+	defaultPostTimeout   = 5 * time.Second
+	// spans are dropped if the report queue is full
+	reportQueueSize = 4096
+)
+
+func init() {
+	RegisterDriver(ZipkinDriverName, CreateZipkinDriver)
+}
+
+type zipkinConfig struct {
+	CollectorEndpoint string   `json:"collector_endpoint"`
+	ServiceName       string   `json:"service_name,omitempty"`
+	SampleRate        *float64 `json:"sample_rate,omitempty"`
+	BatchSize         int      `json:"batch_size,omitempty"`
+	FlushInterval     string   `json:"flush_interval,omitempty"`
+}
+
+// zipkinDriver is a tracer reporting spans to the collector in zipkin v2 json format
+type zipkinDriver struct {
+	*tracer
+	reporter *zipkinReporter
+}
+
+// Close flushes the buffered spans and stops reporting
+func (d *zipkinDriver) Close() error {
+	d.reporter.close()
+	return nil
+}
+
+// CreateZipkinDriver creates a zipkin driver, the config contains:
+// collector_endpoint: the url spans are posted to, such as http://127.0.0.1:9411/api/v2/spans
+// service_name: the local service name, defaults to mosn
+// sample_rate: rate of the sampled traces started by mosn, defaults to 1
+// batch_size and flush_interval: spans are posted in batches, defaults to 100 and 1s
+func CreateZipkinDriver(config map[string]interface{}) (types.Driver, error) {
+	cfg := &zipkinConfig{}
+
+	if data, err := json.Marshal(config); err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing zipkin config failed: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("parsing zipkin config failed: %v", err)
+	}
+
+	if cfg.CollectorEndpoint == "" {
+		return nil, errors.New("zipkin collector_endpoint is required")
+	}
+
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = defaultServiceName
+	}
+
+	sampleRate := 1.0
+	if cfg.SampleRate != nil {
+		sampleRate = *cfg.SampleRate
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	flushInterval := defaultFlushInterval
+	if cfg.FlushInterval != "" {
+		d, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid zipkin flush_interval: %s", cfg.FlushInterval)
+		}
+		flushInterval = d
+	}
+
+	reporter := newZipkinReporter(cfg.CollectorEndpoint, cfg.ServiceName, cfg.BatchSize, flushInterval)
+
+	return &zipkinDriver{
+		tracer: &tracer{
+			serviceName: cfg.ServiceName,
+			sampleRate:  sampleRate,
+			reporter:    reporter,
+		},
+		reporter: reporter,
+	}, nil
+}
+
+// zipkin v2 span model
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name,omitempty"`
+	Kind          string            `json:"kind,omitempty"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	LocalEndpoint *zipkinEndpoint   `json:"localEndpoint,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type zipkinReporter struct {
+	endpoint      string
+	localEndpoint *zipkinEndpoint
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+
+	spans     chan *zipkinSpan
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newZipkinReporter(endpoint string, serviceName string, batchSize int, flushInterval time.Duration) *zipkinReporter {
+	r := &zipkinReporter{
+		endpoint:      endpoint,
+		localEndpoint: &zipkinEndpoint{ServiceName: serviceName},
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		client:        &http.Client{Timeout: defaultPostTimeout},
+		spans:         make(chan *zipkinSpan, reportQueueSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go r.run()
+
+	return r
+}
+
+func (r *zipkinReporter) report(s *span) {
+	operation, tags := s.snapshot()
+
+	zs := &zipkinSpan{
+		TraceID:       s.context.traceID,
+		ID:            s.context.spanID,
+		ParentID:      s.context.parentID,
+		Name:          operation,
+		Kind:          s.kind,
+		Timestamp:     s.startTime.UnixNano() / int64(time.Microsecond),
+		Duration:      int64(s.duration / time.Microsecond),
+		LocalEndpoint: r.localEndpoint,
+		Tags:          tags,
+	}
+
+	select {
+	case r.spans <- zs:
+	default:
+		log.DefaultLogger.Warnf("zipkin report queue is full, span dropped, trace id = %s", zs.TraceID)
+	}
+}
+
+func (r *zipkinReporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*zipkinSpan, 0, r.batchSize)
+
+	for {
+		select {
+		case zs := <-r.spans:
+			batch = append(batch, zs)
+			if len(batch) >= r.batchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.stop:
+			// drain the queued spans
+			for {
+				select {
+				case zs := <-r.spans:
+					batch = append(batch, zs)
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush posts the batch to the collector, and returns the emptied batch
+func (r *zipkinReporter) flush(batch []*zipkinSpan) []*zipkinSpan {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := r.post(batch); err != nil {
+		log.DefaultLogger.Errorf("report %d spans to zipkin collector %s failed: %v", len(batch), r.endpoint, err)
+	}
+
+	return batch[:0]
+}
+
+func (r *zipkinReporter) post(batch []*zipkinSpan) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Post(r.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (r *zipkinReporter) close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+	})
+}
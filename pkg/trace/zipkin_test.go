@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package trace
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+)
+
+// mockCollector is a zipkin compatible collector stand-in
+type mockCollector struct {
+	mux   sync.Mutex
+	spans []zipkinSpan
+}
+
+func (c *mockCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var spans []zipkinSpan
+	if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mux.Lock()
+	c.spans = append(c.spans, spans...)
+	c.mux.Unlock()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (c *mockCollector) received() []zipkinSpan {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return append([]zipkinSpan{}, c.spans...)
+}
+
+func TestZipkinDriver(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	collector := &mockCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	if err := Init(ZipkinDriverName, map[string]interface{}{
+		"collector_endpoint": server.URL + "/api/v2/spans",
+		"service_name":       "test",
+		"flush_interval":     "1h",
+	}); err != nil {
+		t.Fatalf("init zipkin driver failed: %v", err)
+	}
+
+	driver := Driver()
+	if driver == nil || !IsEnabled() {
+		t.Fatal("tracing should be enabled")
+	}
+
+	parent := driver.Start(map[string]string{}, "ingress", time.Now())
+	parent.SetOperation("decorated")
+	parent.SetTag(TagResponseCode, "200")
+
+	child := parent.SpawnChild("cluster", time.Now())
+	child.SetTag(TagUpstreamCluster, "cluster")
+	child.FinishSpan()
+	parent.FinishSpan()
+	// spans are reported once
+	parent.FinishSpan()
+
+	// disabling flushes the buffered spans
+	Disable()
+	if IsEnabled() {
+		t.Fatal("tracing should be disabled")
+	}
+
+	spans := collector.received()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, but got %d", len(spans))
+	}
+
+	c, p := spans[0], spans[1]
+	if p.TraceID != parent.TraceID() || p.ID != parent.SpanID() || p.ParentID != "" ||
+		p.Name != "decorated" || p.Kind != spanKindServer || p.Tags[TagResponseCode] != "200" {
+		t.Errorf("unexpected parent span: %+v", p)
+	}
+	if c.TraceID != parent.TraceID() || c.ParentID != parent.SpanID() ||
+		c.Name != "cluster" || c.Kind != spanKindClient || c.Tags[TagUpstreamCluster] != "cluster" {
+		t.Errorf("unexpected child span: %+v", c)
+	}
+	if p.LocalEndpoint == nil || p.LocalEndpoint.ServiceName != "test" || p.Timestamp == 0 {
+		t.Errorf("unexpected parent span: %+v", p)
+	}
+}
+
+func TestZipkinDriverNotSampled(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	collector := &mockCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	driver, err := CreateZipkinDriver(map[string]interface{}{
+		"collector_endpoint": server.URL,
+		"sample_rate":        0,
+	})
+	if err != nil {
+		t.Fatalf("create zipkin driver failed: %v", err)
+	}
+
+	driver.Start(map[string]string{}, "ingress", time.Now()).FinishSpan()
+	driver.(*zipkinDriver).Close()
+
+	if spans := collector.received(); len(spans) != 0 {
+		t.Errorf("spans not sampled should not be reported, but got %d", len(spans))
+	}
+}
+
+func TestInitInvalidDriver(t *testing.T) {
+	if err := Init("unknown", nil); err == nil {
+		t.Error("unknown driver should fail")
+	}
+
+	if err := Init(ZipkinDriverName, map[string]interface{}{}); err == nil {
+		t.Error("zipkin driver without collector should fail")
+	}
+
+	if err := Init(ZipkinDriverName, map[string]interface{}{
+		"collector_endpoint": "http://127.0.0.1:9411/api/v2/spans",
+		"flush_interval":     "invalid",
+	}); err == nil {
+		t.Error("zipkin driver with invalid flush interval should fail")
+	}
+
+	if IsEnabled() {
+		t.Error("tracing should not be enabled by invalid drivers")
+	}
+}
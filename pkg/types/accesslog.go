@@ -69,6 +69,10 @@ const (
 	LogDownstreamRemoteAddress string = "DownstreamRemoteAddress"
 	// identification of host selected
 	LogUpstreamHostSelectedGetter string = "UpstreamHostSelected"
+	// identification of percentiles of the upstream request time in the selected host's cluster
+	LogUpstreamRequestTimeP50 string = "UpstreamRequestTimeP50"
+	LogUpstreamRequestTimeP95 string = "UpstreamRequestTimeP95"
+	LogUpstreamRequestTimeP99 string = "UpstreamRequestTimeP99"
 )
 
 const (
@@ -31,6 +31,7 @@ const (
 	ContextKeyLogger                     ContextKey = "Logger"
 	ContextKeyAccessLogs                 ContextKey = "AccessLogs"
 	ContextOriRemoteAddr                 ContextKey = "OriRemoteAddr"
+	ContextSubProtocol                   ContextKey = "SubProtocol"
 )
 
 const (
@@ -42,6 +42,7 @@ const (
 	DeserialExceptionCode int = 3
 	SuccessCode           int = 200
 	RouterUnavailableCode int = 404
+	RateLimitedCode       int = 429
 	NoHealthUpstreamCode  int = 500
 	UpstreamOverFlowCode  int = 503
 	TimeoutExceptionCode  int = 504
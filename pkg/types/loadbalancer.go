@@ -24,8 +24,12 @@ import (
 type LoadBalancerType string
 
 const (
-	RoundRobin LoadBalancerType = "RoundRobin"
-	Random     LoadBalancerType = "Random"
+	RoundRobin         LoadBalancerType = "RoundRobin"
+	Random             LoadBalancerType = "Random"
+	WeightedRoundRobin LoadBalancerType = "WeightedRoundRobin"
+	LeastRequest       LoadBalancerType = "LeastRequest"
+	RingHash           LoadBalancerType = "RingHash"
+	Maglev             LoadBalancerType = "Maglev"
 )
 
 type LoadBalancer interface {
@@ -42,6 +46,10 @@ type LoadBalancerContext interface {
 	DownstreamConnection() net.Conn
 
 	DownstreamHeaders() map[string]string
+
+	// ShouldSelectAnotherHost returns true if the chosen host is not preferred, e.g. it has failed the request before.
+	// The load balancer tries a few more times before using the host anyway.
+	ShouldSelectAnotherHost(host Host) bool
 }
 
 // SubSetLoadBalancer
@@ -75,7 +75,8 @@ import (
 //
 
 const (
-	ListenerStatsPrefix = "listener.%d."
+	ListenerStatsPrefix    = "listener.%d"
+	HealthCheckStatsPrefix = "health_check.%s."
 )
 
 // listener interface
@@ -107,6 +108,10 @@ type Listener interface {
 
 	// Close listener, not closing connections
 	Close(lctx context.Context) error
+
+	// Update the listener with the config on the same address, such as TLS,
+	// the accepted connections are not affected
+	Update(lc *v2.ListenerConfig)
 }
 
 // TLS ContextManager
@@ -360,6 +365,12 @@ type Connection interface {
 	// Caution: raw conn only used in io-loop disable mode
 	// todo: a better way to provide raw conn
 	RawConn() net.Conn
+
+	// Transfer hands over the connection to another process. Reading is stopped and the read loop waits for
+	// ready to report true, then calls transfer with the raw connection and the bytes read but not dispatched.
+	// The connection is closed without shutting down the socket after transfer succeeds, as it is shared with
+	// the other process. If transfer fails, reading is resumed and the error is returned.
+	Transfer(ready func() bool, transfer func(rawc net.Conn, unread []byte) error) error
 }
 
 type ConnectionStats struct {
@@ -412,6 +423,14 @@ type ConnectionHandler interface {
 	AddListener(lc *v2.ListenerConfig, networkFiltersFactory NetworkFilterChainFactory,
 		streamFiltersFactories []StreamFilterChainFactory) ListenerEventListener
 
+	// Update the listener with the same name, new connections use the new config
+	// and the connections accepted before are drained
+	UpdateListener(lc *v2.ListenerConfig, networkFiltersFactory NetworkFilterChainFactory,
+		streamFiltersFactories []StreamFilterChainFactory) error
+
+	// Remove the listener by name, it stops listening and its connections are drained
+	RemoveListener(name string) error
+
 	// Start a listener by tag
 	StartListener(lctx context.Context, listenerTag uint64)
 
@@ -433,6 +452,17 @@ type ConnectionHandler interface {
 
 	// List all listeners' fd
 	ListListenersFD(lctx context.Context) []uintptr
+
+	// Drain connections of all listeners gracefully,
+	// the returned channel is closed when all the connections are closed
+	DrainConnections() <-chan struct{}
+
+	// Transfer connections of all listeners to another process by transfer,
+	// the connections which could not be transferred are drained
+	TransferConnections(transfer func(rawc net.Conn, unread []byte) error)
+
+	// Resume a connection transferred from another process on the listener it was accepted by
+	ResumeConnection(rawc net.Conn, unread []byte) error
 }
 
 // Connection binary read filter
@@ -448,6 +478,21 @@ type ReadFilter interface {
 	InitializeReadFilterCallbacks(cb ReadFilterCallbacks)
 }
 
+// Read filter which handles requests on the connection, such as the proxy,
+// so that the connection can be drained gracefully
+type DrainFilter interface {
+	// Stop taking new requests on the connection, the connection is closed at once if it is idle,
+	// otherwise a protocol-appropriate goaway is sent and the connection is closed after the active requests are done
+	Drain()
+}
+
+// Read filter which keeps request states of the connection, the connection could be transferred
+// to another process only if all its read filters are transfer filters
+type TransferFilter interface {
+	// Whether there is no active request on the connection
+	Idle() bool
+}
+
 // Connection binary write filter
 // only called by conn accept loop
 type WriteFilter interface {
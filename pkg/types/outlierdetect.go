@@ -17,13 +17,67 @@
 
 package types
 
+import "time"
+
+// OutlierResult is the result of an upstream request, reported to the host's DetectorHostMonitor
+type OutlierResult string
+
+const (
+	// request got a non-5xx response
+	OutlierResultSuccess OutlierResult = "Success"
+	// request got a 5xx response except gateway failures
+	OutlierResultServerError OutlierResult = "ServerError"
+	// request got a 502/503/504 response
+	OutlierResultGatewayFailure OutlierResult = "GatewayFailure"
+	// connect to upstream failed
+	OutlierResultConnectFailed OutlierResult = "ConnectFailed"
+	// upstream stream reset before response
+	OutlierResultReset OutlierResult = "Reset"
+	// upstream request timeout
+	OutlierResultTimeout OutlierResult = "Timeout"
+)
+
+// OutlierType is the reason a host got ejected
+type OutlierType string
+
+const (
+	OutlierConsecutive5xx            OutlierType = "Consecutive5xx"
+	OutlierConsecutiveGatewayFailure OutlierType = "ConsecutiveGatewayFailure"
+	OutlierSuccessRate               OutlierType = "SuccessRate"
+)
+
+// Detector is a passive outlier detector for an upstream cluster
 type Detector interface {
+	// Add a callback, which will be called when a host is ejected or unejected
 	AddChangedStateCb(cb func(host Host))
 
+	// Success rate average of the last interval, -1 means not calculated
 	SuccessRateAverage() float64
 
+	// Success rate ejection threshold of the last interval, -1 means not calculated
 	SuccessRateEjectionThreshold() float64
+
+	// Stop stops the detector's interval timer
+	Stop()
 }
 
+// DetectorHostMonitor monitors a host's request results for the outlier detector
 type DetectorHostMonitor interface {
+	// Number of times the host has been ejected
+	NumEjections() uint32
+
+	// Report a request result
+	PutResult(result OutlierResult)
+
+	// Report a response time
+	PutResponseTime(duration time.Duration)
+
+	// Last time the host was ejected
+	LastEjectionTime() time.Time
+
+	// Last time the host was unejected
+	LastUnejectionTime() time.Time
+
+	// Success rate of the last interval, -1 means not calculated
+	SuccessRate() float64
 }
@@ -48,6 +48,8 @@ const (
 	FaultInjected ResponseFlag = 0x400
 	// rate limited
 	RateLimited ResponseFlag = 0x800
+	// rate limit service failed
+	RateLimitServiceError ResponseFlag = 0x1000
 )
 
 type RequestInfo interface {
@@ -19,9 +19,12 @@ package types
 
 import (
 	"container/list"
+	"context"
 	"crypto/md5"
 	"regexp"
 	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
 )
 
 type Priority int
@@ -94,6 +97,10 @@ type Policy interface {
 	CorsPolicy() CorsPolicy
 
 	LoadBalancerPolicy() LoadBalancerPolicy
+
+	// RateLimitPolicy returns the rate limits applied to the route,
+	// including the virtual host's if required
+	RateLimitPolicy() RateLimitPolicy
 }
 
 type TargetCluster interface {
@@ -103,7 +110,8 @@ type TargetCluster interface {
 }
 
 type CorsPolicy interface {
-	AllowOrigins() []string
+	// AllowOrigin returns whether cross origin requests from the origin are allowed
+	AllowOrigin(origin string) bool
 
 	AllowMethods() string
 
@@ -122,16 +130,19 @@ type LoadBalancerPolicy interface {
 	HashPolicy() HashPolicy
 }
 
-type AddCookieCallback func(key string, ttl int)
+// AddCookieCallback is called to set a generated cookie in the response
+type AddCookieCallback func(name string, value string, path string, ttl time.Duration)
 
 type HashPolicy interface {
-	GenerateHash(downstreamAddress string, headers map[string]string, addCookieCb AddCookieCallback)
+	// GenerateHash returns the hash key for consistent hash load balancing,
+	// an empty value is returned if no hash key can be generated
+	GenerateHash(downstreamAddress string, headers map[string]string, addCookieCb AddCookieCallback) HashedValue
 }
 
 type RateLimitPolicy interface {
 	Enabled() bool
 
-	GetApplicableRateLimit(stage string) []RateLimitPolicyEntry
+	GetApplicableRateLimit(stage uint64) []RateLimitPolicyEntry
 }
 
 type RateLimitPolicyEntry interface {
@@ -139,8 +150,11 @@ type RateLimitPolicyEntry interface {
 
 	DisableKey() string
 
-	PopulateDescriptors(route RouteRule, descriptors []Descriptor, localSrvCluster string, headers map[string]string, remoteAddr string)
+	// PopulateDescriptors appends the descriptor generated by the entry's actions to descriptors,
+	// nothing is appended if any of the actions can not generate a descriptor entry
+	PopulateDescriptors(route RouteRule, descriptors []Descriptor, localSrvCluster string, headers map[string]string, remoteAddr string) []Descriptor
 }
+
 type LimitStatus string
 
 const (
@@ -149,13 +163,19 @@ const (
 	OverLimit LimitStatus = "OverLimit"
 )
 
+// RateLimitClient asks the rate limit service whether requests with the descriptors should be limited,
+// Error is returned with the error if the service fails
+type RateLimitClient interface {
+	ShouldRateLimit(ctx context.Context, domain string, descriptors []Descriptor) (LimitStatus, error)
+}
+
 type DescriptorEntry struct {
 	Key   string
 	Value string
 }
 
 type Descriptor struct {
-	entries []DescriptorEntry
+	Entries []DescriptorEntry
 }
 
 type RetryCheckStatus int
@@ -172,6 +192,15 @@ type RetryPolicy interface {
 	TryTimeout() time.Duration
 
 	NumRetries() uint32
+
+	// RetryOnCondition returns whether to retry on the condition
+	RetryOnCondition(condition v2.RetryCondition) bool
+
+	RetriableStatusCodes() []uint32
+
+	BackOffBaseInterval() time.Duration
+
+	BackOffMaxInterval() time.Duration
 }
 
 type DoRetryCallback func()
@@ -186,6 +215,9 @@ type ShadowPolicy interface {
 	ClusterName() string
 
 	RuntimeKey() string
+
+	// Percent returns the percentage of requests to be mirrored, in [0, 100]
+	Percent() uint32
 }
 
 type VirtualServer interface {
@@ -232,9 +264,11 @@ type RedirectRule interface {
 }
 
 type TraceDecorator interface {
-	operate(span Span)
+	// Apply applies the route's tracing settings to the span
+	Apply(span Span)
 
-	getOperation() string
+	// Operation returns the operation name set by the route
+	Operation() string
 }
 
 type MetadataMatchCriterion interface {
@@ -253,11 +287,6 @@ type MetadataMatchCriteria interface {
 	MergeMatchCriteria(metadataMatches map[string]interface{}) MetadataMatchCriteria
 }
 
-type Decorator interface {
-	apply(span Span)
-	getOperation() string
-}
-
 //type HashedValue [16]byte // value as md5's result
 
 // todo change hashed value to [16]string
@@ -337,8 +366,6 @@ type PathMatchCriterion interface {
 	Matcher() string
 }
 
-type Loader struct{}
-
 type RouteMetaData map[string]HashedValue
 
 // generate hashed valued with md5
@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+// RuntimeLayer is a source of runtime values, layers loaded later override the former ones
+type RuntimeLayer struct {
+	Name   string
+	Values map[string]string
+}
+
+// RuntimeSnapshot is an immutable view of the runtime values, it's never changed once created
+type RuntimeSnapshot interface {
+	// Get returns the value of the key, false if the key is not found
+	Get(key string) (string, bool)
+
+	// GetInteger returns the value of the key as an integer, the default value is returned
+	// if the key is not found or the value is not an integer
+	GetInteger(key string, defaultValue uint64) uint64
+
+	// FeatureEnabled returns whether a feature is enabled for the request by the random value,
+	// the value of the key is the percentage of requests enabled, in [0, 100]
+	FeatureEnabled(key string, defaultPercent uint64, randomValue uint64) bool
+
+	// Layers returns the layers the snapshot is merged from
+	Layers() []RuntimeLayer
+}
+
+// Loader loads the runtime layers, and swaps the snapshot atomically when any layer changes,
+// so requests in flight go on with the snapshot they got
+type Loader interface {
+	// Snapshot returns the current snapshot
+	Snapshot() RuntimeSnapshot
+
+	// Reload loads the runtime files again, the current snapshot is kept if loading fails
+	Reload() error
+
+	// MergeValues merges the values into the admin layer, a key with empty value is removed
+	MergeValues(values map[string]string)
+}
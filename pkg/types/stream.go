@@ -194,6 +194,13 @@ type ServerStreamConnectionEventListener interface {
 	NewStream(streamID string, responseEncoder StreamSender) StreamReceiver
 }
 
+// Server stream connection event listener which gets the stream connection before it serves,
+// HTTP/1 and HTTP/2 stream connections serve the connection until it is closed on creating
+type ServerStreamConnectionServeListener interface {
+	// Called before the stream connection starts serving
+	OnServe(conn ServerStreamConnection)
+}
+
 type StreamFilterBase interface {
 	OnDestroy()
 }
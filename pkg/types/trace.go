@@ -19,18 +19,33 @@ package types
 
 import "time"
 
+// Span records a unit of work, such as a downstream request or an upstream attempt
 type Span interface {
+	// TraceID returns the id of the trace the span belongs to
+	TraceID() string
+
+	// SpanID returns the id of the span
+	SpanID() string
+
+	// ParentSpanID returns the id of the parent span, empty for the root span
+	ParentSpanID() string
+
 	SetOperation(operation string)
 
 	SetTag(key string, value string)
 
+	// FinishSpan ends the span and reports it if it's sampled
 	FinishSpan()
 
-	InjectContext()
+	// InjectContext writes the span's context into the request headers of the given protocol,
+	// so that the upstream joins the trace
+	InjectContext(protocol Protocol, requestHeaders map[string]string)
 
-	SpawnChild() Span
+	// SpawnChild creates a child span, such as a span for an upstream attempt
+	SpawnChild(operationName string, startTime time.Time) Span
 }
 
+// Driver creates spans, the trace context propagated in the request headers is extracted if found
 type Driver interface {
-	start(requestHeaders map[string]string, operationName string, startTime time.Time) Span
+	Start(requestHeaders map[string]string, operationName string, startTime time.Time) Span
 }
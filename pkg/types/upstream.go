@@ -47,7 +47,7 @@ type ClusterManager interface {
 
 	HTTPConnPoolForCluster(balancerContext LoadBalancerContext, cluster string, protocol Protocol) ConnectionPool
 
-	XprotocolConnPoolForCluster(balancerContext LoadBalancerContext, cluster string, protocol Protocol) ConnectionPool
+	XprotocolConnPoolForCluster(balancerContext LoadBalancerContext, cluster string, subProtocol SubProtocol) ConnectionPool
 
 	TCPConnForCluster(balancerContext LoadBalancerContext, cluster string) CreateConnectionData
 
@@ -94,6 +94,9 @@ type Cluster interface {
 	HealthChecker() HealthChecker
 
 	OutlierDetector() Detector
+
+	// Stop stops the cluster's health checker and outlier detector, called when the cluster is removed or updated
+	Stop()
 }
 
 type InitializePhase string
@@ -197,7 +200,10 @@ type HostInfo interface {
 
 	HostStats() HostStats
 
-	// TODO: add deploy locality
+	// priority of the host set the host belongs to
+	Priority() uint32
+
+	Locality() v2.Locality
 }
 
 type HostStats struct {
@@ -221,6 +227,9 @@ type HostStats struct {
 	UpstreamRequestTimeout                         metrics.Counter
 	UpstreamRequestFailureEject                    metrics.Counter
 	UpstreamRequestPendingOverflow                 metrics.Counter
+	UpstreamConnectionConnectTime                  metrics.Histogram
+	UpstreamRequestTime                            metrics.Histogram
+	UpstreamRequestTimeToFirstByte                 metrics.Histogram
 }
 
 type ClusterInfo interface {
@@ -305,6 +314,8 @@ type ClusterStats struct {
 	UpstreamRequestLocalReset                      metrics.Counter
 	UpstreamRequestRemoteReset                     metrics.Counter
 	UpstreamRequestRetry                           metrics.Counter
+	UpstreamRequestRetryActive                     metrics.Counter
+	UpstreamRequestRetrySuccess                    metrics.Counter
 	UpstreamRequestRetryOverflow                   metrics.Counter
 	UpstreamRequestTimeout                         metrics.Counter
 	UpstreamRequestFailureEject                    metrics.Counter
@@ -313,6 +324,15 @@ type ClusterStats struct {
 	LBSubSetsActive                                metrics.Counter
 	LBSubsetsCreated                               metrics.Counter
 	LBSubsetsRemoved                               metrics.Counter
+	OutlierDetectionEjectionsTotal                 metrics.Counter
+	OutlierDetectionEjectionsActive                metrics.Counter
+	OutlierDetectionEjectionsOverflow              metrics.Counter
+	OutlierDetectionEjectionsConsecutive5xx        metrics.Counter
+	OutlierDetectionEjectionsGatewayFailure        metrics.Counter
+	OutlierDetectionEjectionsSuccessRate           metrics.Counter
+	UpstreamConnectionConnectTime                  metrics.Histogram
+	UpstreamRequestTime                            metrics.Histogram
+	UpstreamRequestTimeToFirstByte                 metrics.Histogram
 }
 
 type CreateConnectionData struct {
@@ -0,0 +1,69 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+// SubProtocol is the name of a protocol carried by xprotocol, such as dubbo
+type SubProtocol string
+
+// Multiplexing is the codec a sub protocol plugs into the xprotocol stream layer.
+// The stream layer relies on it to cut frames out of the byte stream and to
+// correlate requests and responses by request id.
+type Multiplexing interface {
+	// SplitFrame splits data into complete frames, in order.
+	// Bytes after the last complete frame are kept and passed in again with more data.
+	// An error means data is not a valid frame of the sub protocol, the frames
+	// returned before it are still valid but the connection can not be read any more.
+	SplitFrame(data []byte) ([][]byte, error)
+
+	// GetStreamID returns the request id carried in the frame
+	GetStreamID(data []byte) string
+
+	// SetStreamID rewrites the request id carried in the frame and returns the new frame
+	SetStreamID(data []byte, streamID string) []byte
+}
+
+// Tracing is an optional extension of Multiplexing for sub protocols that
+// expose rpc service and method names, which are used as route headers
+type Tracing interface {
+	Multiplexing
+
+	GetServiceName(data []byte) string
+
+	GetMethodName(data []byte) string
+}
+
+// RouteHeaders is an optional extension of Multiplexing for sub protocols
+// that carry more route information than service and method names
+type RouteHeaders interface {
+	GetRouteHeaders(data []byte) map[string]string
+}
+
+// Heartbeat is an optional extension of Multiplexing for sub protocols with heartbeat.
+// Heartbeat requests are answered by the stream layer and never proxied.
+type Heartbeat interface {
+	IsHeartbeatRequest(data []byte) bool
+
+	// HeartbeatResponse returns the response of the heartbeat request, or nil if no response is expected
+	HeartbeatResponse(data []byte) []byte
+}
+
+// OneWay is an optional extension of Multiplexing for sub protocols with requests expecting no response.
+// One way requests are ended by the stream layer as soon as they are sent.
+type OneWay interface {
+	IsOneWayRequest(data []byte) bool
+}
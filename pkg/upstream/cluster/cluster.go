@@ -20,10 +20,12 @@ package cluster
 import (
 	"fmt"
 	"net"
+	"strings"
 	"sync"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/stats"
 	"github.com/alipay/sofa-mosn/pkg/tls"
 	"github.com/alipay/sofa-mosn/pkg/types"
 	"github.com/rcrowley/go-metrics"
@@ -38,6 +40,7 @@ type cluster struct {
 	mux                            sync.RWMutex
 	initHelper                     concreteClusterInitHelper
 	healthChecker                  types.HealthChecker
+	outlierDetector                *outlierDetector
 }
 
 type concreteClusterInitHelper interface {
@@ -49,8 +52,11 @@ func NewCluster(clusterConfig v2.Cluster, sourceAddr net.Addr, addedViaAPI bool)
 
 	switch clusterConfig.ClusterType {
 
-	case v2.SIMPLE_CLUSTER, v2.DYNAMIC_CLUSTER:
+	case v2.SIMPLE_CLUSTER, v2.DYNAMIC_CLUSTER, v2.STATIC_CLUSTER:
 		newCluster = newSimpleInMemCluster(clusterConfig, sourceAddr, addedViaAPI)
+
+	case v2.STRICT_DNS_CLUSTER, v2.LOGICAL_DNS_CLUSTER:
+		newCluster = newDNSCluster(clusterConfig, sourceAddr, addedViaAPI, defaultDNSResolver)
 	}
 
 	// init health check for cluster's host
@@ -85,6 +91,18 @@ func newCluster(clusterConfig v2.Cluster, sourceAddr net.Addr, addedViaAPI bool,
 
 	case v2.LB_ROUNDROBIN:
 		cluster.info.lbType = types.RoundRobin
+
+	case v2.LB_WEIGHTEDROUNDROBIN:
+		cluster.info.lbType = types.WeightedRoundRobin
+
+	case v2.LB_LEASTREQUEST:
+		cluster.info.lbType = types.LeastRequest
+
+	case v2.LB_RINGHASH:
+		cluster.info.lbType = types.RingHash
+
+	case v2.LB_MAGLEV:
+		cluster.info.lbType = types.Maglev
 	}
 
 	// TODO: init more props: maxrequestsperconn, connecttimeout, connectionbuflimit
@@ -110,6 +128,16 @@ func newCluster(clusterConfig v2.Cluster, sourceAddr net.Addr, addedViaAPI bool,
 
 	cluster.info.lbInstance = lb
 
+	// init passive outlier detection for cluster's host
+	if outlierDetectionEnabled(clusterConfig.OutlierDetection) {
+		ps := cluster.prioritySet
+		cluster.outlierDetector = newOutlierDetector(clusterConfig.OutlierDetection, ps, cluster.info.stats)
+		cluster.outlierDetector.AddChangedStateCb(func(host types.Host) {
+			refreshHealthHosts(ps.HostSetsByPriority(), host)
+		})
+		cluster.outlierDetector.start()
+	}
+
 	cluster.info.tlsMng = tls.NewTLSClientContextManager(&clusterConfig.TLS, cluster.info)
 
 	return cluster
@@ -153,6 +181,10 @@ func newClusterStats(config v2.Cluster) types.ClusterStats {
 		UpstreamRequestActive:                          metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_request_active"), nil),
 		UpstreamRequestLocalReset:                      metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_request_local_reset"), nil),
 		UpstreamRequestRemoteReset:                     metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_request_remote_reset"), nil),
+		UpstreamRequestRetry:                           metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_retry"), nil),
+		UpstreamRequestRetryActive:                     metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_retry_active"), nil),
+		UpstreamRequestRetrySuccess:                    metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_retry_success"), nil),
+		UpstreamRequestRetryOverflow:                   metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_retry_overflow"), nil),
 		UpstreamRequestTimeout:                         metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_request_timeout"), nil),
 		UpstreamRequestFailureEject:                    metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_failure_eject"), nil),
 		UpstreamRequestPendingOverflow:                 metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_pending_overflow"), nil),
@@ -160,6 +192,32 @@ func newClusterStats(config v2.Cluster) types.ClusterStats {
 		LBSubSetsActive:                                metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_LBSubSetsActive"), nil),
 		LBSubsetsCreated:                               metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_LBSubsetsCreated"), nil),
 		LBSubsetsRemoved:                               metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_LBSubsetsRemoved"), nil),
+		OutlierDetectionEjectionsTotal:                 metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "outlier_detection_ejections_total"), nil),
+		OutlierDetectionEjectionsActive:                metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "outlier_detection_ejections_active"), nil),
+		OutlierDetectionEjectionsOverflow:              metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "outlier_detection_ejections_overflow"), nil),
+		OutlierDetectionEjectionsConsecutive5xx:        metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "outlier_detection_ejections_consecutive_5xx"), nil),
+		OutlierDetectionEjectionsGatewayFailure:        metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "outlier_detection_ejections_consecutive_gateway_failure"), nil),
+		OutlierDetectionEjectionsSuccessRate:           metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "outlier_detection_ejections_success_rate"), nil),
+		UpstreamConnectionConnectTime:                  stats.GetOrRegisterBucketHistogram(fmt.Sprintf("%s.%s", nameSpace, "upstream_connection_connect_time"), nil),
+		UpstreamRequestTime:                            stats.GetOrRegisterBucketHistogram(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_time"), nil),
+		UpstreamRequestTimeToFirstByte:                 stats.GetOrRegisterBucketHistogram(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_time_to_first_byte"), nil),
+	}
+}
+
+// unregisterClusterStats unregisters the stats created by newClusterStats
+func unregisterClusterStats(name string) {
+	prefix := fmt.Sprintf("cluster.%s.", name)
+
+	var names []string
+	metrics.DefaultRegistry.Each(func(n string, _ interface{}) {
+		// stats of cluster named with the prefix, such as cluster.foo.bar, are not matched
+		if strings.HasPrefix(n, prefix) && !strings.Contains(n[len(prefix):], ".") {
+			names = append(names, n)
+		}
+	})
+
+	for _, n := range names {
+		metrics.DefaultRegistry.Unregister(n)
 	}
 }
 
@@ -191,18 +249,35 @@ func (c *cluster) HealthChecker() types.HealthChecker {
 }
 
 func (c *cluster) OutlierDetector() types.Detector {
-	// TODO
-	return nil
+	if c.outlierDetector == nil {
+		return nil
+	}
+
+	return c.outlierDetector
+}
+
+func (c *cluster) Stop() {
+	if c.healthChecker != nil {
+		c.healthChecker.Stop()
+	}
+
+	if c.outlierDetector != nil {
+		c.outlierDetector.Stop()
+	}
 }
 
 // update health-hostSet for only one hostSet, reduce update times
 func (c *cluster) refreshHealthHosts(host types.Host) {
+	refreshHealthHosts(c.prioritySet.HostSetsByPriority(), host)
+}
+
+func refreshHealthHosts(hostSets []types.HostSet, host types.Host) {
 	if host.Health() {
 		log.DefaultLogger.Debugf("Add health host %s to cluster's healthHostSet by refreshHealthHosts", host.AddressString())
-		addHealthyHost(c.prioritySet.hostSets, host)
+		addHealthyHost(hostSets, host)
 	} else {
 		log.DefaultLogger.Debugf("Del host %s from cluster's healthHostSet by refreshHealthHosts", host.AddressString())
-		delHealthHost(c.prioritySet.hostSets, host)
+		delHealthHost(hostSets, host)
 	}
 }
 
@@ -368,90 +443,139 @@ func getHealthHost(hosts []types.Host) []types.Host {
 	return healthyHost
 }
 
+// getHealthHostsPerLocality returns the healthy hosts of each locality, indexed the same as the localities
 func getHealthHostsPerLocality(hhpl [][]types.Host) [][]types.Host {
 	var healthyHostPerLocality = make([][]types.Host, len(hhpl))
-	hi := 0
 
 	for i := range hhpl {
-		hj := 0
-		for j := range hhpl[i] {
-			if hhpl[i][j].Health() {
-				healthyHostPerLocality[hi] = append(healthyHostPerLocality[hi], hhpl[i][j])
-				hj++
-			}
+		healthyHostPerLocality[i] = getHealthHost(hhpl[i])
+	}
+	return healthyHostPerLocality
+}
+
+// groupHostsByPriority returns the hosts of each priority, indexed by priority
+func groupHostsByPriority(hosts []types.Host) [][]types.Host {
+	var hostsByPriority [][]types.Host
+
+	for _, h := range hosts {
+		for uint32(len(hostsByPriority)) <= h.Priority() {
+			hostsByPriority = append(hostsByPriority, nil)
 		}
-		if hj > 0 {
-			hi++
+		hostsByPriority[h.Priority()] = append(hostsByPriority[h.Priority()], h)
+	}
+	return hostsByPriority
+}
+
+// groupHostsByLocality returns the hosts of each locality, localities are in the order they first appear
+func groupHostsByLocality(hosts []types.Host) [][]types.Host {
+	var hostsPerLocality [][]types.Host
+	localityIndex := make(map[v2.Locality]int)
+
+	for _, h := range hosts {
+		i, ok := localityIndex[h.Locality()]
+		if !ok {
+			i = len(hostsPerLocality)
+			localityIndex[h.Locality()] = i
+			hostsPerLocality = append(hostsPerLocality, nil)
 		}
+		hostsPerLocality[i] = append(hostsPerLocality[i], h)
 	}
-	return healthyHostPerLocality
+	return hostsPerLocality
+}
+
+// hostsOfPriority returns the hosts in the priority
+func hostsOfPriority(hosts []types.Host, priority uint32) []types.Host {
+	var result []types.Host
+
+	for _, h := range hosts {
+		if h.Priority() == priority {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func containsHost(hosts []types.Host, host types.Host) bool {
+	for _, h := range hosts {
+		if h.AddressString() == host.AddressString() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeHost returns a new slice without the host, the input slice is not modified,
+// as it may be shared with hostSet's hosts
+func removeHost(hosts []types.Host, host types.Host) []types.Host {
+	result := make([]types.Host, 0, len(hosts))
+
+	for _, h := range hosts {
+		if h.AddressString() != host.AddressString() {
+			result = append(result, h)
+		}
+	}
+
+	return result
 }
 
 func addHealthyHost(hostSets []types.HostSet, host types.Host) {
 	// Note: currently, one host only belong to a hostSet
 
 	for i, hostSet := range hostSets {
-		found := false
+		if !containsHost(hostSet.Hosts(), host) {
+			continue
+		}
+
+		log.DefaultLogger.Debugf("add healthy host = %s, in priority = %d", host.AddressString(), i)
 
-		for _, h := range hostSet.Hosts() {
-			if h.AddressString() == host.AddressString() {
-				log.DefaultLogger.Debugf("add healthy host = %s, in priority = %d", host.AddressString(), i)
-				found = true
+		if containsHost(hostSet.HealthyHosts(), host) {
+			break
+		}
+
+		healthyHosts := hostSet.HealthyHosts()
+		newHealthHost := make([]types.Host, 0, len(healthyHosts)+1)
+		newHealthHost = append(newHealthHost, healthyHosts...)
+		newHealthHost = append(newHealthHost, host)
+
+		var newHealthyHostPerLocality [][]types.Host
+
+		for _, hosts := range hostSet.HostsPerLocality() {
+			if containsHost(hosts, host) {
+				newHealthyHostPerLocality = getHealthHostsPerLocality(hostSet.HostsPerLocality())
 				break
 			}
 		}
 
-		if found {
-			newHealthHost := hostSet.HealthyHosts()
-			newHealthHost = append(newHealthHost, host)
-			newHealthyHostPerLocality := hostSet.HealthHostsPerLocality()
-			newHealthyHostPerLocality[len(newHealthyHostPerLocality)-1] = append(newHealthyHostPerLocality[len(newHealthyHostPerLocality)-1], host)
-
-			hostSet.UpdateHosts(hostSet.Hosts(), newHealthHost, hostSet.HostsPerLocality(),
-				newHealthyHostPerLocality, nil, nil)
-			break
+		if newHealthyHostPerLocality == nil {
+			newHealthyHostPerLocality = hostSet.HealthHostsPerLocality()
 		}
+
+		hostSet.UpdateHosts(hostSet.Hosts(), newHealthHost, hostSet.HostsPerLocality(),
+			newHealthyHostPerLocality, nil, nil)
+		break
 	}
 }
 
 func delHealthHost(hostSets []types.HostSet, host types.Host) {
 	for i, hostSet := range hostSets {
 		// Note: currently, one host only belong to a hostSet
-		found := false
-
-		for _, h := range hostSet.Hosts() {
-			if h.AddressString() == host.AddressString() {
-				log.DefaultLogger.Debugf("del healthy host = %s, in priority = %d", host.AddressString(), i)
-				found = true
-				break
-			}
+		if !containsHost(hostSet.Hosts(), host) {
+			continue
 		}
 
-		if found {
-			newHealthHost := hostSet.HealthyHosts()
-			newHealthyHostPerLocality := hostSet.HealthHostsPerLocality()
+		log.DefaultLogger.Debugf("del healthy host = %s, in priority = %d", host.AddressString(), i)
 
-			for i, hh := range newHealthHost {
-				if host.Hostname() == hh.Hostname() {
-					//remove
-					newHealthHost = append(newHealthHost[:i], newHealthHost[i+1:]...)
-					break
-				}
-			}
+		newHealthHost := removeHost(hostSet.HealthyHosts(), host)
+		healthyHostPerLocality := hostSet.HealthHostsPerLocality()
+		newHealthyHostPerLocality := make([][]types.Host, 0, len(healthyHostPerLocality))
 
-			for i := range newHealthyHostPerLocality {
-				for j := range newHealthyHostPerLocality[i] {
-
-					if host.Hostname() == newHealthyHostPerLocality[i][j].Hostname() {
-						newHealthyHostPerLocality[i] = append(newHealthyHostPerLocality[i][:j], newHealthyHostPerLocality[i][j+1:]...)
-						break
-					}
-				}
-			}
-
-			hostSet.UpdateHosts(hostSet.Hosts(), newHealthHost, hostSet.HostsPerLocality(),
-				newHealthyHostPerLocality, nil, nil)
-			break
+		for _, hosts := range healthyHostPerLocality {
+			newHealthyHostPerLocality = append(newHealthyHostPerLocality, removeHost(hosts, host))
 		}
+
+		hostSet.UpdateHosts(hostSet.Hosts(), newHealthHost, hostSet.HostsPerLocality(),
+			newHealthyHostPerLocality, nil, nil)
+		break
 	}
 }
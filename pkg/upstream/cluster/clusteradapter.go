@@ -19,6 +19,7 @@ package cluster
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/log"
@@ -81,6 +82,16 @@ func (ca *Adapter) TriggerClusterAdded(cluster v2.Cluster) {
 	}
 }
 
+// Called by xds client to add or update cluster with its config, hosts discovered by EDS are kept
+// if the config has none
+func (ca *Adapter) TriggerClusterAddOrUpdate(cluster v2.Cluster) error {
+	if !ca.clusterMng.AddOrUpdatePrimaryCluster(cluster) {
+		return fmt.Errorf("cluster %s is not added via API and can't be updated", cluster.Name)
+	}
+
+	return nil
+}
+
 // Called when mesh receive unsubscribe info
 func (ca *Adapter) TriggerClusterDel(clusterName string) {
 	log.DefaultLogger.Debugf("Delete Cluster %s", clusterName)
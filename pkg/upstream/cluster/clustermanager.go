@@ -22,10 +22,14 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"reflect"
+	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/log"
 	proto "github.com/alipay/sofa-mosn/pkg/protocol"
+	// dubbo streams use xprotocol connection pools
+	_ "github.com/alipay/sofa-mosn/pkg/stream/dubbo"
 	"github.com/alipay/sofa-mosn/pkg/stream/http"
 	"github.com/alipay/sofa-mosn/pkg/stream/http2"
 	"github.com/alipay/sofa-mosn/pkg/stream/sofarpc"
@@ -38,15 +42,19 @@ import (
 type clusterManager struct {
 	sourceAddr             net.Addr
 	primaryClusters        cmap.ConcurrentMap // string: *primaryCluster
-	sofaRPCConnPool        cmap.ConcurrentMap // string: types.ConnectionPool
-	http2ConnPool          cmap.ConcurrentMap // string: types.ConnectionPool
-	xProtocolConnPool      cmap.ConcurrentMap // string: types.ConnectionPool
-	http1ConnPool          cmap.ConcurrentMap // string: types.ConnectionPool
+	sofaRPCConnPool        cmap.ConcurrentMap // cluster name: cmap.ConcurrentMap, address: types.ConnectionPool
+	http2ConnPool          cmap.ConcurrentMap // cluster name: cmap.ConcurrentMap, address: types.ConnectionPool
+	xProtocolConnPool      cmap.ConcurrentMap // cluster name: cmap.ConcurrentMap, address: types.ConnectionPool
+	http1ConnPool          cmap.ConcurrentMap // cluster name: cmap.ConcurrentMap, address: types.ConnectionPool
 	clusterAdapter         Adapter
 	autoDiscovery          bool
 	registryUseHealthCheck bool
 }
 
+// connection pools of the removed or updated cluster are closed after the timeout,
+// so that the requests in flight could be finished on them
+var connPoolDrainTimeout = 30 * time.Second
+
 type clusterSnapshot struct {
 	prioritySet  types.PrioritySet
 	clusterInfo  types.ClusterInfo
@@ -73,8 +81,9 @@ func NewClusterManager(sourceAddr net.Addr, clusters []v2.Cluster,
 
 	//Add cluster to cm
 	//Register upstream update type
+	//clusters in config file can't be updated or removed via API
 	for _, cluster := range clusters {
-		cm.AddOrUpdatePrimaryCluster(cluster)
+		cm.loadCluster(cluster, false)
 	}
 
 	// Add hosts to cluster
@@ -101,23 +110,40 @@ func (cs *clusterSnapshot) LoadBalancer() types.LoadBalancer {
 type primaryCluster struct {
 	cluster     types.Cluster
 	addedViaAPI bool
+	config      v2.Cluster
 }
 
 func (cm *clusterManager) AddOrUpdatePrimaryCluster(cluster v2.Cluster) bool {
 	clusterName := cluster.Name
 
 	if v, exist := cm.primaryClusters.Get(clusterName); exist {
-		if !v.(*primaryCluster).addedViaAPI {
+		pc := v.(*primaryCluster)
+
+		if !pc.addedViaAPI {
 			return false
 		}
+
+		// only hosts are updated if the cluster config is not changed
+		if sameClusterConfig(pc.config, cluster) {
+			if len(cluster.Hosts) > 0 {
+				updateClusterHosts(pc.cluster, cluster.Hosts)
+			}
+
+			return true
+		}
 	}
 
-	// todo for static cluster, shouldn't use this way
 	cm.loadCluster(cluster, true)
 
 	return true
 }
 
+func sameClusterConfig(old, new v2.Cluster) bool {
+	old.Hosts, new.Hosts = nil, nil
+
+	return reflect.DeepEqual(old, new)
+}
+
 func (cm *clusterManager) ClusterExist(clusterName string) bool {
 	if _, exist := cm.primaryClusters.Get(clusterName); exist {
 		return true
@@ -135,14 +161,85 @@ func (cm *clusterManager) loadCluster(clusterConfig v2.Cluster, addedViaAPI bool
 		})
 	})
 
+	old, exist := cm.primaryClusters.Get(clusterConfig.Name)
+
+	// hosts discovered separately are kept when the cluster is updated
+	hostConfigs := clusterConfig.Hosts
+	if len(hostConfigs) == 0 && exist {
+		hostConfigs = clusterHostConfigs(old.(*primaryCluster).cluster)
+	}
+
+	if len(hostConfigs) > 0 {
+		updateClusterHosts(cluster, hostConfigs)
+	}
+
 	cm.primaryClusters.Set(clusterConfig.Name, &primaryCluster{
 		cluster:     cluster,
 		addedViaAPI: addedViaAPI,
+		config:      clusterConfig,
 	})
 
+	// the old cluster is rebuilt, requests in flight go on with the old snapshot
+	if exist {
+		old.(*primaryCluster).cluster.Stop()
+		cm.drainConnPools(clusterConfig.Name)
+		log.DefaultLogger.Debugf("Update Primary Cluster, Cluster Name = %s", clusterConfig.Name)
+	}
+
 	return cluster
 }
 
+// clusterHostConfigs returns the configs of the cluster's hosts, to rebuild them on the updated cluster
+func clusterHostConfigs(c types.Cluster) []v2.Host {
+	// hosts of dns cluster are rebuilt from the hostnames
+	if dc, ok := c.(*dnsCluster); ok {
+		return dc.configs()
+	}
+
+	var hostConfigs []v2.Host
+
+	for _, hostSet := range c.PrioritySet().HostSetsByPriority() {
+		for _, h := range hostSet.Hosts() {
+			if ch, ok := h.(*host); ok {
+				config := ch.config
+				config.Weight = ch.Weight()
+				hostConfigs = append(hostConfigs, config)
+			}
+		}
+	}
+
+	return hostConfigs
+}
+
+// connPools returns the connection pools of the cluster in the pools map
+func connPools(pools cmap.ConcurrentMap, cluster string) cmap.ConcurrentMap {
+	v := pools.Upsert(cluster, nil, func(exist bool, valueInMap interface{}, newValue interface{}) interface{} {
+		if exist {
+			return valueInMap
+		}
+
+		return cmap.New()
+	})
+
+	return v.(cmap.ConcurrentMap)
+}
+
+// drainConnPools removes the connection pools of the cluster, and closes them after the requests in flight are done
+func (cm *clusterManager) drainConnPools(cluster string) {
+	for _, pools := range []cmap.ConcurrentMap{cm.sofaRPCConnPool, cm.http2ConnPool, cm.xProtocolConnPool, cm.http1ConnPool} {
+		v, ok := pools.Pop(cluster)
+		if !ok {
+			continue
+		}
+
+		for _, p := range v.(cmap.ConcurrentMap).Items() {
+			connPool := p.(types.ConnectionPool)
+			connPool.DrainConnections()
+			time.AfterFunc(connPoolDrainTimeout, connPool.Close)
+		}
+	}
+}
+
 func (cm *clusterManager) getOrCreateClusterSnapshot(clusterName string) *clusterSnapshot {
 	if v, ok := cm.primaryClusters.Get(clusterName); ok {
 		pcc := v.(*primaryCluster).cluster
@@ -177,23 +274,30 @@ func (cm *clusterManager) Get(context context.Context, cluster string) types.Clu
 
 func (cm *clusterManager) UpdateClusterHosts(clusterName string, priority uint32, hostConfigs []v2.Host) error {
 	if v, ok := cm.primaryClusters.Get(clusterName); ok {
-		pcc := v.(*primaryCluster).cluster
-
-		// todo: hack
-		if concretedCluster, ok := pcc.(*simpleInMemCluster); ok {
-			var hosts []types.Host
+		return updateClusterHosts(v.(*primaryCluster).cluster, hostConfigs)
+	}
+	return fmt.Errorf("cluster %s not found", clusterName)
 
-			for _, hc := range hostConfigs {
-				hosts = append(hosts, NewHost(hc, pcc.Info()))
-			}
-			concretedCluster.UpdateHosts(hosts)
-			return nil
-		}
-		return fmt.Errorf("cluster's hostset %s can't be update", clusterName)
+}
 
+func updateClusterHosts(pcc types.Cluster, hostConfigs []v2.Host) error {
+	// hosts of dns cluster are updated after resolved
+	if dc, ok := pcc.(*dnsCluster); ok {
+		dc.updateHostConfigs(hostConfigs)
+		return nil
 	}
-	return fmt.Errorf("cluster %s not found", clusterName)
 
+	// todo: hack
+	if concretedCluster, ok := pcc.(*simpleInMemCluster); ok {
+		var hosts []types.Host
+
+		for _, hc := range hostConfigs {
+			hosts = append(hosts, NewHost(hc, pcc.Info()))
+		}
+		concretedCluster.UpdateHosts(hosts)
+		return nil
+	}
+	return fmt.Errorf("cluster's hostset %s can't be update", pcc.Info().Name())
 }
 
 func (cm *clusterManager) RemoveClusterHosts(clusterName string, host types.Host) error {
@@ -239,7 +343,7 @@ func (cm *clusterManager) HTTPConnPoolForCluster(lbCtx types.LoadBalancerContext
 		return nil
 	}
 
-	host := clusterSnapshot.loadbalancer.ChooseHost(lbCtx)
+	host := chooseHost(clusterSnapshot.loadbalancer, lbCtx)
 
 	if host != nil {
 		addr := host.AddressString()
@@ -247,23 +351,25 @@ func (cm *clusterManager) HTTPConnPoolForCluster(lbCtx types.LoadBalancerContext
 
 		switch protocol {
 		case proto.HTTP2:
+			pools := connPools(cm.http2ConnPool, cluster)
 
-			if connPool, ok := cm.http2ConnPool.Get(addr); ok {
+			if connPool, ok := pools.Get(addr); ok {
 				return connPool.(types.ConnectionPool)
 			}
 			// todo: move this to a centralized factory, remove dependency to http2 stream
 			connPool := http2.NewConnPool(host)
-			cm.http2ConnPool.Set(addr, connPool)
+			pools.Set(addr, connPool)
 
 			return connPool
 		case proto.HTTP1:
+			pools := connPools(cm.http1ConnPool, cluster)
 
-			if connPool, ok := cm.http1ConnPool.Get(addr); ok {
+			if connPool, ok := pools.Get(addr); ok {
 				return connPool.(types.ConnectionPool)
 			}
 			// todo: move this to a centralized factory, remove dependency to http1 stream
 			connPool := http.NewConnPool(host)
-			cm.http1ConnPool.Set(addr, connPool)
+			pools.Set(addr, connPool)
 
 			return connPool
 		}
@@ -274,24 +380,27 @@ func (cm *clusterManager) HTTPConnPoolForCluster(lbCtx types.LoadBalancerContext
 }
 
 func (cm *clusterManager) XprotocolConnPoolForCluster(lbCtx types.LoadBalancerContext, cluster string,
-	protocol types.Protocol) types.ConnectionPool {
+	subProtocol types.SubProtocol) types.ConnectionPool {
 	clusterSnapshot := cm.getOrCreateClusterSnapshot(cluster)
 
 	if clusterSnapshot == nil {
 		return nil
 	}
 
-	host := clusterSnapshot.loadbalancer.ChooseHost(nil)
+	host := chooseHost(clusterSnapshot.loadbalancer, lbCtx)
 
 	if host != nil {
 		addr := host.AddressString()
-		log.StartLogger.Tracef("Xprotocol connection pool upstream addr : %v", addr)
+		log.StartLogger.Tracef("Xprotocol connection pool upstream addr : %v, sub protocol : %v", addr, subProtocol)
 
-		if connPool, ok := cm.xProtocolConnPool.Get(addr); ok {
+		// connections can not be shared between sub protocols
+		key := string(subProtocol) + "@" + addr
+		pools := connPools(cm.xProtocolConnPool, cluster)
+		if connPool, ok := pools.Get(key); ok {
 			return connPool.(types.ConnectionPool)
 		}
-		connPool := xprotocol.NewConnPool(host)
-		cm.xProtocolConnPool.Set(addr, connPool)
+		connPool := xprotocol.NewConnPool(host, subProtocol)
+		pools.Set(key, connPool)
 
 		return connPool
 	}
@@ -306,7 +415,7 @@ func (cm *clusterManager) TCPConnForCluster(lbCtx types.LoadBalancerContext, clu
 		return types.CreateConnectionData{}
 	}
 
-	host := clusterSnapshot.loadbalancer.ChooseHost(lbCtx)
+	host := chooseHost(clusterSnapshot.loadbalancer, lbCtx)
 
 	if host != nil {
 		return host.CreateConnection(nil)
@@ -323,18 +432,19 @@ func (cm *clusterManager) SofaRPCConnPoolForCluster(lbCtx types.LoadBalancerCont
 		return nil
 	}
 
-	host := clusterSnapshot.loadbalancer.ChooseHost(lbCtx)
+	host := chooseHost(clusterSnapshot.loadbalancer, lbCtx)
 
 	if host != nil {
 		addr := host.AddressString()
 		log.DefaultLogger.Debugf(" clusterSnapshot.loadbalancer.ChooseHost result is %s, cluster name = %s", addr, cluster)
 
-		if connPool, ok := cm.sofaRPCConnPool.Get(addr); ok {
+		pools := connPools(cm.sofaRPCConnPool, cluster)
+		if connPool, ok := pools.Get(addr); ok {
 			return connPool.(types.ConnectionPool)
 		}
 		// todo: move this to a centralized factory, remove dependency to sofarpc stream
 		connPool := sofarpc.NewConnPool(host)
-		cm.sofaRPCConnPool.Set(addr, connPool)
+		pools.Set(addr, connPool)
 
 		return connPool
 
@@ -346,13 +456,18 @@ func (cm *clusterManager) SofaRPCConnPoolForCluster(lbCtx types.LoadBalancerCont
 
 func (cm *clusterManager) RemovePrimaryCluster(clusterName string) bool {
 	if v, exist := cm.primaryClusters.Get(clusterName); exist {
-		if !v.(*primaryCluster).addedViaAPI {
-			return false
+		pc := v.(*primaryCluster)
+
+		if !pc.addedViaAPI {
 			log.DefaultLogger.Warnf("Remove Primary Cluster Failed, Cluster Name = %s not addedViaAPI", clusterName)
-		} else {
-			cm.primaryClusters.Remove(clusterName)
-			log.DefaultLogger.Debugf("Remove Primary Cluster, Cluster Name = %s", clusterName)
+			return false
 		}
+
+		cm.primaryClusters.Remove(clusterName)
+		pc.cluster.Stop()
+		cm.drainConnPools(clusterName)
+		unregisterClusterStats(clusterName)
+		log.DefaultLogger.Debugf("Remove Primary Cluster, Cluster Name = %s", clusterName)
 	}
 
 	return true
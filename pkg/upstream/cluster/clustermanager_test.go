@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/rcrowley/go-metrics"
+)
+
+func newTestClusterConfig(name string, lbType v2.LbType, hosts ...string) v2.Cluster {
+	config := v2.Cluster{
+		Name:        name,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      lbType,
+		OutlierDetection: v2.OutlierDetection{
+			Consecutive5xx: 3,
+			Interval:       time.Hour,
+		},
+	}
+
+	for _, host := range hosts {
+		config.Hosts = append(config.Hosts, v2.Host{Address: host})
+	}
+
+	return config
+}
+
+func primaryClusterOf(cm *clusterManager, name string) *simpleInMemCluster {
+	v, _ := cm.primaryClusters.Get(name)
+	return v.(*primaryCluster).cluster.(*simpleInMemCluster)
+}
+
+func clusterHostAddrs(cs *clusterSnapshot) map[string]bool {
+	addrs := make(map[string]bool)
+	for _, host := range cs.PrioritySet().HostSetsByPriority()[0].Hosts() {
+		addrs[host.AddressString()] = true
+	}
+
+	return addrs
+}
+
+func outlierDetectorStopped(d *outlierDetector) bool {
+	select {
+	case <-d.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestClusterManager_UpdatePrimaryCluster(t *testing.T) {
+	cm := NewClusterManager(nil, nil, nil, false, false).(*clusterManager)
+
+	cm.AddOrUpdatePrimaryCluster(newTestClusterConfig("update", v2.LB_ROUNDROBIN, "127.0.0.1:10001", "127.0.0.1:10002"))
+	old := cm.getOrCreateClusterSnapshot("update")
+	oldCluster := primaryClusterOf(cm, "update")
+
+	if cm.HTTPConnPoolForCluster(nil, "update", protocol.HTTP1) == nil {
+		t.Fatalf("expected connection pool for cluster")
+	}
+
+	// hosts are updated in place if the config is not changed
+	cm.AddOrUpdatePrimaryCluster(newTestClusterConfig("update", v2.LB_ROUNDROBIN, "127.0.0.1:10003"))
+	cs := cm.getOrCreateClusterSnapshot("update")
+
+	if cs.PrioritySet() != old.PrioritySet() {
+		t.Errorf("expected cluster not rebuilt when only hosts changed")
+	}
+	if addrs := clusterHostAddrs(cs); len(addrs) != 1 || !addrs["127.0.0.1:10003"] {
+		t.Errorf("expected hosts updated, but got %v", addrs)
+	}
+	if _, ok := cm.http1ConnPool.Get("update"); !ok {
+		t.Errorf("expected connection pools kept when only hosts changed")
+	}
+
+	// cluster is rebuilt, hosts are kept if the config has none
+	cm.AddOrUpdatePrimaryCluster(newTestClusterConfig("update", v2.LB_RANDOM))
+	cs = cm.getOrCreateClusterSnapshot("update")
+
+	if cs.PrioritySet() == old.PrioritySet() {
+		t.Fatalf("expected cluster rebuilt when config changed")
+	}
+	if cs.ClusterInfo().LbType() != types.Random {
+		t.Errorf("expected updated lb type, but got %v", cs.ClusterInfo().LbType())
+	}
+	if addrs := clusterHostAddrs(cs); len(addrs) != 1 || !addrs["127.0.0.1:10003"] {
+		t.Errorf("expected hosts kept on updated cluster, but got %v", addrs)
+	}
+	if cs.PrioritySet().HostSetsByPriority()[0].Hosts()[0].ClusterInfo() != cs.ClusterInfo() {
+		t.Errorf("expected hosts rebuilt with updated cluster info")
+	}
+
+	// requests in flight go on with the old snapshot
+	if addrs := clusterHostAddrs(old); len(addrs) != 1 || !addrs["127.0.0.1:10003"] {
+		t.Errorf("expected old snapshot not changed, but got %v", addrs)
+	}
+	if !outlierDetectorStopped(oldCluster.outlierDetector) {
+		t.Errorf("expected outlier detector of old cluster stopped")
+	}
+	if _, ok := cm.http1ConnPool.Get("update"); ok {
+		t.Errorf("expected connection pools of old cluster drained")
+	}
+}
+
+func TestClusterManager_RemovePrimaryCluster(t *testing.T) {
+	static := newTestClusterConfig("static", v2.LB_ROUNDROBIN, "127.0.0.1:10001")
+	cm := NewClusterManager(nil, []v2.Cluster{static}, nil, false, false).(*clusterManager)
+
+	if cm.RemovePrimaryCluster("static") || !cm.ClusterExist("static") {
+		t.Errorf("expected cluster in config file not removed")
+	}
+	if cm.AddOrUpdatePrimaryCluster(newTestClusterConfig("static", v2.LB_RANDOM)) {
+		t.Errorf("expected cluster in config file not updated")
+	}
+
+	cm.AddOrUpdatePrimaryCluster(newTestClusterConfig("removed", v2.LB_ROUNDROBIN, "127.0.0.1:10001"))
+	removed := primaryClusterOf(cm, "removed")
+	cm.HTTPConnPoolForCluster(nil, "removed", protocol.HTTP1)
+
+	if !cm.RemovePrimaryCluster("removed") {
+		t.Fatalf("remove cluster failed")
+	}
+
+	if cm.ClusterExist("removed") {
+		t.Errorf("expected cluster removed")
+	}
+	if _, ok := cm.http1ConnPool.Get("removed"); ok {
+		t.Errorf("expected connection pools of removed cluster drained")
+	}
+	if !outlierDetectorStopped(removed.outlierDetector) {
+		t.Errorf("expected outlier detector of removed cluster stopped")
+	}
+	if metrics.DefaultRegistry.Get("cluster.removed.upstream_request_request_total") != nil {
+		t.Errorf("expected stats of removed cluster unregistered")
+	}
+	if metrics.DefaultRegistry.Get("cluster.static.upstream_request_request_total") == nil {
+		t.Errorf("expected stats of other clusters kept")
+	}
+}
+
+func TestClusterManager_UpdateClusterHostsByPriority(t *testing.T) {
+	cm := NewClusterManager(nil, nil, nil, false, false).(*clusterManager)
+	cm.AddOrUpdatePrimaryCluster(newTestClusterConfig("priority", v2.LB_ROUNDROBIN))
+
+	cm.UpdateClusterHosts("priority", 0, []v2.Host{
+		{Address: "127.0.0.1:10001", Locality: v2.Locality{Zone: "zone_a"}},
+		{Address: "127.0.0.1:10002", Locality: v2.Locality{Zone: "zone_b"}},
+		{Address: "127.0.0.1:10003", Locality: v2.Locality{Zone: "zone_a"}},
+		{Address: "127.0.0.1:10004", Priority: 1},
+	})
+
+	hostSets := cm.getOrCreateClusterSnapshot("priority").PrioritySet().HostSetsByPriority()
+	if len(hostSets) != 2 {
+		t.Fatalf("expected 2 priorities, but got %d", len(hostSets))
+	}
+
+	hostsPerLocality := hostSets[0].HostsPerLocality()
+	if len(hostSets[0].Hosts()) != 3 || len(hostsPerLocality) != 2 ||
+		len(hostsPerLocality[0]) != 2 || hostsPerLocality[0][1].AddressString() != "127.0.0.1:10003" {
+		t.Errorf("expected hosts of priority 0 grouped by locality, but got %v", hostsPerLocality)
+	}
+	if len(hostSets[0].HealthHostsPerLocality()) != 2 {
+		t.Errorf("expected healthy hosts grouped by locality, but got %v", hostSets[0].HealthHostsPerLocality())
+	}
+	if hosts := hostSets[1].Hosts(); len(hosts) != 1 || hosts[0].AddressString() != "127.0.0.1:10004" {
+		t.Errorf("expected host in priority 1, but got %v", hosts)
+	}
+
+	// host moved to priority 0, priority 1 is cleared
+	cm.UpdateClusterHosts("priority", 0, []v2.Host{
+		{Address: "127.0.0.1:10004"},
+	})
+
+	if hosts := hostSets[0].Hosts(); len(hosts) != 1 || hosts[0].Priority() != 0 {
+		t.Errorf("expected host moved to priority 0, but got %v", hosts)
+	}
+	if hosts := hostSets[1].Hosts(); len(hosts) != 0 {
+		t.Errorf("expected priority 1 cleared, but got %v", hosts)
+	}
+}
@@ -47,8 +47,15 @@ func (dc *dynamicClusterBase) updateDynamicHostList(newHosts []types.Host, curre
 			curNh := currentHosts[i]
 
 			if nh.AddressString() == curNh.AddressString() {
-				curNh.SetWeight(nh.Weight())
-				finalHosts = append(finalHosts, curNh)
+				if curNh.Priority() == nh.Priority() && curNh.Locality() == nh.Locality() {
+					curNh.SetWeight(nh.Weight())
+					finalHosts = append(finalHosts, curNh)
+				} else {
+					// host moved to another priority or locality is replaced
+					finalHosts = append(finalHosts, nh)
+					hostsAdded = append(hostsAdded, nh)
+					hostsRemoved = append(hostsRemoved, curNh)
+				}
 				currentHosts = append(currentHosts[:i], currentHosts[i+1:]...)
 				found = true
 			} else {
@@ -63,7 +70,7 @@ func (dc *dynamicClusterBase) updateDynamicHostList(newHosts []types.Host, curre
 	}
 
 	if len(currentHosts) > 0 {
-		hostsRemoved = currentHosts
+		hostsRemoved = append(hostsRemoved, currentHosts...)
 	}
 
 	if len(hostsAdded) > 0 || len(hostsRemoved) > 0 {
@@ -92,6 +99,27 @@ func newSimpleInMemCluster(clusterConfig v2.Cluster, sourceAddr net.Addr, addedV
 	}
 }
 
+// updatePrioritySet rebuilds the host sets from the hosts, hosts are grouped by priority,
+// and by locality in each priority
+func (sc *simpleInMemCluster) updatePrioritySet(hostsAdded []types.Host, hostsRemoved []types.Host) {
+	hostsByPriority := groupHostsByPriority(sc.hosts)
+
+	// the priorities without hosts any more are cleared
+	for len(hostsByPriority) < len(sc.prioritySet.HostSetsByPriority()) {
+		hostsByPriority = append(hostsByPriority, nil)
+	}
+
+	for i, hosts := range hostsByPriority {
+		priority := uint32(i)
+		hostsPerLocality := groupHostsByLocality(hosts)
+
+		// hosts kept from the current list may be failing health check or ejected by outlier detection
+		sc.prioritySet.GetOrCreateHostSet(priority).UpdateHosts(hosts, getHealthHost(hosts),
+			hostsPerLocality, getHealthHostsPerLocality(hostsPerLocality),
+			hostsOfPriority(hostsAdded, priority), hostsOfPriority(hostsRemoved, priority))
+	}
+}
+
 func (sc *simpleInMemCluster) UpdateHosts(newHosts []types.Host) {
 	sc.mux.Lock()
 	defer sc.mux.Unlock()
@@ -113,10 +141,7 @@ func (sc *simpleInMemCluster) UpdateHosts(newHosts []types.Host) {
 
 	if changed {
 		sc.hosts = finalHosts
-		// todo: need to consider how to update healthyHost
-		// Note: currently, we only use priority 0
-		sc.prioritySet.GetOrCreateHostSet(0).UpdateHosts(sc.hosts,
-			sc.hosts, nil, nil, hostsAdded, hostsRemoved)
+		sc.updatePrioritySet(hostsAdded, hostsRemoved)
 
 		if sc.healthChecker != nil {
 			sc.healthChecker.OnClusterMemberUpdate(hostsAdded, hostsRemoved)
@@ -0,0 +1,194 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// default interval to resolve the hostnames of DNS clusters
+const defaultDNSRefreshRate = 5 * time.Second
+
+// DNSResolver resolves a hostname to ip addresses, the ttl is 0 if it is unknown
+type DNSResolver interface {
+	Resolve(hostname string) (ips []string, ttl time.Duration, err error)
+}
+
+var defaultDNSResolver DNSResolver = &netResolver{
+	resolvConf: "/etc/resolv.conf",
+	timeout:    2 * time.Second,
+}
+
+// dnsCluster resolves the hostnames of the hosts in config periodically, and updates the cluster with
+// a host for each resolved address. A strict DNS cluster uses all the addresses of a hostname,
+// a logical DNS cluster uses the first one only.
+// Addresses of a hostname are kept if it fails to resolve.
+type dnsCluster struct {
+	simpleInMemCluster
+
+	logical     bool
+	refreshRate time.Duration
+	resolver    DNSResolver
+
+	resolveMux  sync.Mutex
+	hostConfigs []v2.Host
+	addrs       map[string][]string // key: host address in config
+
+	started     bool
+	refreshChan chan bool
+	stopOnce    sync.Once
+	stopChan    chan bool
+}
+
+func newDNSCluster(clusterConfig v2.Cluster, sourceAddr net.Addr, addedViaAPI bool, resolver DNSResolver) *dnsCluster {
+	refreshRate := clusterConfig.DNSRefreshRate
+	if refreshRate <= 0 {
+		refreshRate = defaultDNSRefreshRate
+	}
+
+	return &dnsCluster{
+		simpleInMemCluster: simpleInMemCluster{
+			dynamicClusterBase: dynamicClusterBase{
+				cluster: newCluster(clusterConfig, sourceAddr, addedViaAPI, nil),
+			},
+		},
+		logical:     clusterConfig.ClusterType == v2.LOGICAL_DNS_CLUSTER,
+		refreshRate: refreshRate,
+		resolver:    resolver,
+		addrs:       make(map[string][]string),
+		refreshChan: make(chan bool, 1),
+		stopChan:    make(chan bool),
+	}
+}
+
+// updateHostConfigs replaces the hosts to resolve, and resolves them at once.
+// The first time the hosts are resolved before it returns, so that a new cluster has hosts once added
+func (dc *dnsCluster) updateHostConfigs(hostConfigs []v2.Host) {
+	dc.resolveMux.Lock()
+	dc.hostConfigs = hostConfigs
+	started := dc.started
+	dc.started = true
+	dc.resolveMux.Unlock()
+
+	if !started {
+		interval := dc.resolve()
+		go dc.run(interval)
+		return
+	}
+
+	select {
+	case dc.refreshChan <- true:
+	default:
+	}
+}
+
+// run resolves the hosts after the interval, and keeps resolving until stopped,
+// resolve is only called by one goroutine at a time
+func (dc *dnsCluster) run(interval time.Duration) {
+	for {
+		select {
+		case <-dc.stopChan:
+			return
+		case <-dc.refreshChan:
+		case <-time.After(interval):
+		}
+
+		interval = dc.resolve()
+	}
+}
+
+// resolve updates the cluster with the resolved addresses of the hosts, and returns the interval
+// to resolve again, which is the refresh rate or the ttl of the records if it is shorter.
+// The hostnames are looked up without holding the lock, so that configs are not blocked by dns
+func (dc *dnsCluster) resolve() time.Duration {
+	dc.resolveMux.Lock()
+	hostConfigs := dc.hostConfigs
+	lastAddrs := dc.addrs
+	dc.resolveMux.Unlock()
+
+	interval := dc.refreshRate
+	addrs := make(map[string][]string, len(hostConfigs))
+	var hosts []types.Host
+
+	for _, hc := range hostConfigs {
+		hostname, port, err := net.SplitHostPort(hc.Address)
+		if err != nil {
+			log.DefaultLogger.Errorf("invalid host address %s in cluster %s: %v", hc.Address, dc.info.name, err)
+			continue
+		}
+
+		ips, ttl, err := dc.lookup(hostname)
+		if err != nil {
+			log.DefaultLogger.Warnf("resolve %s in cluster %s failed, keep the last addresses: %v", hostname, dc.info.name, err)
+			ips = lastAddrs[hc.Address]
+		} else if ttl > 0 && ttl < interval {
+			interval = ttl
+		}
+
+		if dc.logical && len(ips) > 1 {
+			ips = ips[:1]
+		}
+		addrs[hc.Address] = ips
+
+		for _, ip := range ips {
+			config := hc
+			config.Address = net.JoinHostPort(ip, port)
+			if config.Hostname == "" {
+				config.Hostname = hostname
+			}
+			hosts = append(hosts, NewHost(config, dc.info))
+		}
+	}
+
+	dc.resolveMux.Lock()
+	dc.addrs = addrs
+	dc.UpdateHosts(hosts)
+	dc.resolveMux.Unlock()
+
+	return interval
+}
+
+func (dc *dnsCluster) lookup(hostname string) ([]string, time.Duration, error) {
+	if net.ParseIP(hostname) != nil {
+		return []string{hostname}, 0, nil
+	}
+
+	return dc.resolver.Resolve(hostname)
+}
+
+// configs of the hosts to resolve, used to rebuild the cluster
+func (dc *dnsCluster) configs() []v2.Host {
+	dc.resolveMux.Lock()
+	defer dc.resolveMux.Unlock()
+
+	return dc.hostConfigs
+}
+
+func (dc *dnsCluster) Stop() {
+	dc.stopOnce.Do(func() {
+		close(dc.stopChan)
+	})
+
+	dc.simpleInMemCluster.Stop()
+}
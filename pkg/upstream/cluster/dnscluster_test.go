@@ -0,0 +1,306 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+type mockResolver struct {
+	records map[string][]string
+	ttl     time.Duration
+	err     error
+}
+
+func (r *mockResolver) Resolve(hostname string) ([]string, time.Duration, error) {
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+
+	return r.records[hostname], r.ttl, nil
+}
+
+func newTestDNSCluster(clusterType v2.ClusterType, resolver DNSResolver) *dnsCluster {
+	dc := newDNSCluster(v2.Cluster{
+		Name:           "dns_cluster",
+		ClusterType:    clusterType,
+		LbType:         v2.LB_ROUNDROBIN,
+		DNSRefreshRate: 10 * time.Second,
+	}, nil, true, resolver)
+
+	dc.hostConfigs = []v2.Host{
+		{Address: "foo.test:8080", Weight: 10},
+		{Address: "127.0.0.9:8080"},
+	}
+
+	return dc
+}
+
+func dnsClusterHosts(dc *dnsCluster) []string {
+	var addrs []string
+	for _, host := range dc.PrioritySet().HostSetsByPriority()[0].Hosts() {
+		addrs = append(addrs, host.AddressString())
+	}
+	sort.Strings(addrs)
+
+	return addrs
+}
+
+func hostOf(dc *dnsCluster, addr string) types.Host {
+	for _, host := range dc.PrioritySet().HostSetsByPriority()[0].Hosts() {
+		if host.AddressString() == addr {
+			return host
+		}
+	}
+
+	return nil
+}
+
+func sameAddrs(addrs []string, want ...string) bool {
+	if len(addrs) != len(want) {
+		return false
+	}
+	for i := range addrs {
+		if addrs[i] != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestDNSCluster_StrictDNS(t *testing.T) {
+	resolver := &mockResolver{
+		records: map[string][]string{"foo.test": {"127.0.0.1", "127.0.0.2"}},
+	}
+	dc := newTestDNSCluster(v2.STRICT_DNS_CLUSTER, resolver)
+
+	if interval := dc.resolve(); interval != 10*time.Second {
+		t.Errorf("expected resolve after refresh rate, but got %v", interval)
+	}
+	if addrs := dnsClusterHosts(dc); !sameAddrs(addrs, "127.0.0.1:8080", "127.0.0.2:8080", "127.0.0.9:8080") {
+		t.Fatalf("expected all resolved addresses, but got %v", addrs)
+	}
+
+	kept := hostOf(dc, "127.0.0.2:8080")
+	if kept.Hostname() != "foo.test" || kept.Weight() != 10 {
+		t.Errorf("expected host inherits hostname and weight, but got %s %d", kept.Hostname(), kept.Weight())
+	}
+
+	// addresses changed, hosts of unchanged addresses are kept
+	resolver.records["foo.test"] = []string{"127.0.0.2", "127.0.0.3"}
+	resolver.ttl = 2 * time.Second
+	if interval := dc.resolve(); interval != 2*time.Second {
+		t.Errorf("expected resolve after ttl, but got %v", interval)
+	}
+	if addrs := dnsClusterHosts(dc); !sameAddrs(addrs, "127.0.0.2:8080", "127.0.0.3:8080", "127.0.0.9:8080") {
+		t.Fatalf("expected hosts updated with resolved addresses, but got %v", addrs)
+	}
+	if hostOf(dc, "127.0.0.2:8080") != kept {
+		t.Errorf("expected host of unchanged address kept")
+	}
+
+	// addresses are kept if failed to resolve
+	resolver.err = errors.New("dns timeout")
+	dc.resolve()
+	if addrs := dnsClusterHosts(dc); !sameAddrs(addrs, "127.0.0.2:8080", "127.0.0.3:8080", "127.0.0.9:8080") {
+		t.Errorf("expected last addresses kept, but got %v", addrs)
+	}
+}
+
+func TestDNSCluster_LogicalDNS(t *testing.T) {
+	resolver := &mockResolver{
+		records: map[string][]string{"foo.test": {"127.0.0.1", "127.0.0.2"}},
+	}
+	dc := newTestDNSCluster(v2.LOGICAL_DNS_CLUSTER, resolver)
+
+	dc.resolve()
+	if addrs := dnsClusterHosts(dc); !sameAddrs(addrs, "127.0.0.1:8080", "127.0.0.9:8080") {
+		t.Errorf("expected the first resolved address only, but got %v", addrs)
+	}
+}
+
+func TestDNSCluster_Refresh(t *testing.T) {
+	resolver := &mockResolver{
+		records: map[string][]string{"foo.test": {"127.0.0.1"}},
+	}
+	dc := NewCluster(v2.Cluster{
+		Name:        "dns_cluster",
+		ClusterType: v2.STRICT_DNS_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+	}, nil, true).(*dnsCluster)
+	dc.resolver = resolver
+	defer dc.Stop()
+
+	// hosts are resolved before the first update returns
+	updateClusterHosts(dc, []v2.Host{{Address: "foo.test:8080"}})
+	if addrs := dnsClusterHosts(dc); !sameAddrs(addrs, "127.0.0.1:8080") {
+		t.Errorf("expected hosts resolved, but got %v", addrs)
+	}
+
+	// later updates are resolved in background
+	resolver.records["foo.test"] = []string{"127.0.0.2"}
+	updateClusterHosts(dc, []v2.Host{{Address: "foo.test:8081"}})
+	for i := 0; i < 50 && !sameAddrs(dnsClusterHosts(dc), "127.0.0.2:8081"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addrs := dnsClusterHosts(dc); !sameAddrs(addrs, "127.0.0.2:8081") {
+		t.Errorf("expected hosts resolved again, but got %v", addrs)
+	}
+
+	if configs := clusterHostConfigs(dc); len(configs) != 1 || configs[0].Address != "foo.test:8081" {
+		t.Errorf("expected hostnames kept to rebuild the cluster, but got %v", configs)
+	}
+}
+
+// blockResolver blocks until released
+type blockResolver struct {
+	resolving chan bool
+	release   chan bool
+}
+
+func (r *blockResolver) Resolve(hostname string) ([]string, time.Duration, error) {
+	r.resolving <- true
+	<-r.release
+
+	return []string{"127.0.0.1"}, 0, nil
+}
+
+func TestDNSCluster_ResolveWithoutLock(t *testing.T) {
+	resolver := &blockResolver{
+		resolving: make(chan bool, 1),
+		release:   make(chan bool),
+	}
+	dc := newTestDNSCluster(v2.STRICT_DNS_CLUSTER, resolver)
+
+	done := make(chan bool)
+	go func() {
+		dc.resolve()
+		close(done)
+	}()
+	<-resolver.resolving
+
+	configs := make(chan []v2.Host)
+	go func() {
+		configs <- dc.configs()
+	}()
+	select {
+	case <-configs:
+	case <-time.After(time.Second):
+		t.Fatal("configs should not be blocked by dns lookup")
+	}
+
+	close(resolver.release)
+	<-done
+	if addrs := dnsClusterHosts(dc); !sameAddrs(addrs, "127.0.0.1:8080", "127.0.0.9:8080") {
+		t.Errorf("expected hosts resolved, but got %v", addrs)
+	}
+}
+
+// serveDNS answers the A queries with a CNAME record of cnameTTL and an A record of aTTL,
+// and the other queries without answers
+func serveDNS(conn net.PacketConn, cnameTTL, aTTL uint32) {
+	buf := make([]byte, dnsMaxMsgLen)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := buf[:n]
+		qtype := binary.BigEndian.Uint16(query[n-4:])
+
+		resp := append([]byte{}, query...)
+		binary.BigEndian.PutUint16(resp[2:], 0x8180)
+		if qtype == dnsTypeA {
+			binary.BigEndian.PutUint16(resp[6:], 2)
+			// the names point to the question
+			resp = appendRecord(resp, dnsTypeCNAME, cnameTTL, []byte{0xc0, dnsHeaderLen})
+			resp = appendRecord(resp, dnsTypeA, aTTL, []byte{127, 0, 0, 1})
+		}
+
+		conn.WriteTo(resp, addr)
+	}
+}
+
+func appendRecord(msg []byte, rrType uint16, ttl uint32, rdata []byte) []byte {
+	record := make([]byte, 12)
+	record[0], record[1] = 0xc0, dnsHeaderLen
+	binary.BigEndian.PutUint16(record[2:], rrType)
+	binary.BigEndian.PutUint16(record[4:], dnsClassIN)
+	binary.BigEndian.PutUint32(record[6:], ttl)
+	binary.BigEndian.PutUint16(record[10:], uint16(len(rdata)))
+	return append(append(msg, record...), rdata...)
+}
+
+func TestQueryTTL(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer conn.Close()
+	go serveDNS(conn, 300, 30)
+
+	// the minimum ttl of the records leading to the addresses
+	if ttl, err := queryTTL(conn.LocalAddr().String(), "www.example.com", dnsTypeA, time.Second); err != nil || ttl != 30*time.Second {
+		t.Errorf("expected ttl 30s, but got %v, %v", ttl, err)
+	}
+
+	// no record of the type
+	if ttl, err := queryTTL(conn.LocalAddr().String(), "www.example.com", dnsTypeAAAA, time.Second); err != nil || ttl != 0 {
+		t.Errorf("expected ttl 0 without records, but got %v, %v", ttl, err)
+	}
+
+	if _, err := queryTTL(conn.LocalAddr().String(), "www..com", dnsTypeA, time.Second); err == nil {
+		t.Errorf("invalid hostname should fail")
+	}
+}
+
+func TestNetResolver_Nameservers(t *testing.T) {
+	f, err := ioutil.TempFile("", "resolv.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("search example.com\nnameserver 10.0.0.1\nnameserver ::1\n")
+	f.Close()
+
+	r := &netResolver{resolvConf: f.Name()}
+	servers := r.nameservers()
+	if len(servers) != 2 || servers[0] != "10.0.0.1:53" || servers[1] != "[::1]:53" {
+		t.Errorf("unexpected nameservers %v", servers)
+	}
+
+	// addresses are resolved by the go resolver without ttl if no nameserver answers
+	r = &netResolver{resolvConf: "/nonexistent/resolv.conf", timeout: time.Second}
+	if ips, ttl, err := r.Resolve("127.0.0.1"); err != nil || len(ips) != 1 || ttl != 0 {
+		t.Errorf("unexpected resolve result %v, %v, %v", ips, ttl, err)
+	}
+	if ips, ttl, err := r.Resolve("localhost"); err != nil || len(ips) == 0 || ttl != 0 {
+		t.Errorf("unexpected resolve result %v, %v, %v", ips, ttl, err)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// dns message constants used by the ttl query
+const (
+	dnsTypeA     uint16 = 1
+	dnsTypeCNAME uint16 = 5
+	dnsTypeAAAA  uint16 = 28
+	dnsClassIN   uint16 = 1
+
+	dnsHeaderLen  = 12
+	dnsMaxMsgLen  = 1232
+	dnsRcodeMask  = 0x000f
+	dnsFlagRD     = 0x0100
+	dnsPointerTag = 0xc0
+)
+
+var errInvalidDNSMessage = errors.New("invalid dns message")
+
+// netResolver resolves the addresses by the go resolver, so that /etc/hosts and the search domains
+// work as usual. The go resolver does not expose the ttl of the records, so the ttl is queried from
+// the nameservers in resolvConf, it's 0 if the query fails, e.g. for the names in /etc/hosts
+type netResolver struct {
+	resolvConf string
+	timeout    time.Duration
+}
+
+func (r *netResolver) Resolve(hostname string) ([]string, time.Duration, error) {
+	ips, err := net.DefaultResolver.LookupHost(context.Background(), hostname)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if net.ParseIP(hostname) != nil {
+		return ips, 0, nil
+	}
+
+	return ips, r.lookupTTL(hostname), nil
+}
+
+// lookupTTL returns the ttl of the A records of the hostname, or the AAAA records if there is no A record
+func (r *netResolver) lookupTTL(hostname string) time.Duration {
+	for _, server := range r.nameservers() {
+		for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+			ttl, err := queryTTL(server, hostname, qtype, r.timeout)
+			if err != nil {
+				break
+			}
+			if ttl > 0 {
+				return ttl
+			}
+		}
+	}
+
+	return 0
+}
+
+// nameservers returns the addresses of the nameservers in resolvConf
+func (r *netResolver) nameservers() []string {
+	f, err := os.Open(r.resolvConf)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+
+	return servers
+}
+
+// queryTTL queries the records of the hostname from the server, and returns the minimum ttl of the answers,
+// which includes the CNAME records leading to the addresses. The ttl is 0 if there is no record of qtype
+func queryTTL(server, hostname string, qtype uint16, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	id := uint16(rand.Uint32())
+	query, err := buildDNSQuery(id, hostname, qtype)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, dnsMaxMsgLen)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return 0, err
+		}
+
+		// ignore the responses of other queries
+		if n >= dnsHeaderLen && binary.BigEndian.Uint16(resp) == id {
+			return parseDNSTTL(resp[:n], qtype)
+		}
+	}
+}
+
+func buildDNSQuery(id uint16, hostname string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, dnsHeaderLen, dnsHeaderLen+len(hostname)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], dnsFlagRD)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+
+	for _, label := range strings.Split(strings.TrimSuffix(hostname, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, errInvalidDNSMessage
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+
+	msg = append(msg, byte(qtype>>8), byte(qtype), byte(dnsClassIN>>8), byte(dnsClassIN))
+	return msg, nil
+}
+
+func parseDNSTTL(msg []byte, qtype uint16) (time.Duration, error) {
+	if rcode := binary.BigEndian.Uint16(msg[2:]) & dnsRcodeMask; rcode != 0 {
+		return 0, fmt.Errorf("dns query failed with rcode %d", rcode)
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	offset := dnsHeaderLen
+	for i := 0; i < qdcount; i++ {
+		var err error
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return 0, err
+		}
+		offset += 4
+	}
+
+	var minTTL uint32
+	counted, found := false, false
+	for i := 0; i < ancount; i++ {
+		var err error
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return 0, err
+		}
+		if offset+10 > len(msg) {
+			return 0, errInvalidDNSMessage
+		}
+
+		rrType := binary.BigEndian.Uint16(msg[offset:])
+		ttl := binary.BigEndian.Uint32(msg[offset+4:])
+		offset += 10 + int(binary.BigEndian.Uint16(msg[offset+8:]))
+
+		if rrType != qtype && rrType != dnsTypeCNAME {
+			continue
+		}
+		if rrType == qtype {
+			found = true
+		}
+		if !counted || ttl < minTTL {
+			minTTL, counted = ttl, true
+		}
+	}
+
+	if !found {
+		return 0, nil
+	}
+
+	return time.Duration(minTTL) * time.Second, nil
+}
+
+// skipDNSName returns the offset after the name starting at offset
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for offset < len(msg) {
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&dnsPointerTag == dnsPointerTag:
+			return offset + 2, nil
+		default:
+			offset += length + 1
+		}
+	}
+
+	return 0, errInvalidDNSMessage
+}
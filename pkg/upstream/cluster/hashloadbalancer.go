@@ -0,0 +1,296 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+const (
+	// virtual nodes of each host on the hash ring per weight, a fixed number keeps
+	// the positions of existing hosts unchanged when hosts added or removed
+	ringHashVirtualNodes = 160
+	// maglev lookup table size, must be a prime
+	maglevTableSize = 65537
+)
+
+// hashTable is the lookup table of consistent hash load balancer
+// built from a set of hosts
+type hashTable interface {
+	chooseHost(hash uint64) types.Host
+}
+
+// hashLoadBalancer chooses host by the hash key computed from LoadBalancerContext,
+// the priority and zones are also chosen by the hash key, and the lookup table of each priority
+// and zones is rebuilt when its healthy hosts changed. Host is chosen randomly if no hash key found
+type hashLoadBalancer struct {
+	loadbalaner
+	newTable func(hosts []types.Host) hashTable
+
+	mux    sync.RWMutex
+	tables map[hostsTableKey]*hostsTable
+}
+
+// hostsTableKey is the key of the hosts chosen by priority and zones
+type hostsTableKey struct {
+	priority uint32
+	route    zoneRoute
+}
+
+// hostsTable is the lookup table built from the hosts
+type hostsTable struct {
+	hosts []types.Host
+	table hashTable
+}
+
+func newRingHashLoadBalancer(prioritySet types.PrioritySet) types.LoadBalancer {
+	return &hashLoadBalancer{
+		loadbalaner: loadbalaner{
+			prioritySet: prioritySet,
+		},
+		newTable: newRingHashTable,
+		tables:   make(map[hostsTableKey]*hostsTable),
+	}
+}
+
+func newMaglevLoadBalancer(prioritySet types.PrioritySet) types.LoadBalancer {
+	return &hashLoadBalancer{
+		loadbalaner: loadbalaner{
+			prioritySet: prioritySet,
+		},
+		newTable: newMaglevTable,
+		tables:   make(map[hostsTableKey]*hostsTable),
+	}
+}
+
+func (l *hashLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	var hashKey types.HashedValue
+	if context != nil {
+		hashKey = context.ComputeHashKey()
+	}
+
+	if hashKey == "" {
+		hosts := l.healthyHosts()
+		if len(hosts) == 0 {
+			return nil
+		}
+
+		return hosts[rand.Intn(len(hosts))]
+	}
+
+	hash := hashString(string(hashKey))
+
+	hostSet := l.chooseHostSet(hash)
+	if hostSet == nil {
+		return nil
+	}
+
+	// the point of priority is the lower digits of the hash, zones are chosen by the higher ones
+	hosts, route := hostSet.zoneHosts(hash / priorityLoadPrecision)
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	return l.hashTable(hostsTableKey{priority: hostSet.hostSet.Priority(), route: route}, hosts).chooseHost(hash)
+}
+
+func (l *hashLoadBalancer) hashTable(key hostsTableKey, hosts []types.Host) hashTable {
+	l.mux.RLock()
+	if t, ok := l.tables[key]; ok && sameHosts(t.hosts, hosts) {
+		l.mux.RUnlock()
+
+		return t.table
+	}
+	l.mux.RUnlock()
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	t, ok := l.tables[key]
+	if !ok || !sameHosts(t.hosts, hosts) {
+		t = &hostsTable{
+			hosts: hosts,
+			table: l.newTable(hosts),
+		}
+		l.tables[key] = t
+	}
+
+	return t.table
+}
+
+func sameHosts(hosts1, hosts2 []types.Host) bool {
+	if len(hosts1) != len(hosts2) {
+		return false
+	}
+
+	for i := range hosts1 {
+		if hosts1[i] != hosts2[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+
+	return mixHash(h.Sum64())
+}
+
+// mixHash is the finalizer of murmur3, makes the bits of fnv hash well distributed
+func mixHash(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
+
+// Ring hash, the same as ketama. Each host is placed on the ring
+// by its address multiple times according to its weight
+type ringHashTable struct {
+	ring []ringHashEntry
+}
+
+type ringHashEntry struct {
+	hash uint64
+	host types.Host
+}
+
+func newRingHashTable(hosts []types.Host) hashTable {
+	var totalWeight int64
+	for _, host := range hosts {
+		totalWeight += hostWeight(host)
+	}
+
+	ring := make([]ringHashEntry, 0, totalWeight*ringHashVirtualNodes)
+
+	for _, host := range hosts {
+		address := host.AddressString()
+		num := hostWeight(host) * ringHashVirtualNodes
+
+		for i := int64(0); i < num; i++ {
+			ring = append(ring, ringHashEntry{
+				hash: hashString(address + "_" + strconv.FormatInt(i, 10)),
+				host: host,
+			})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	return &ringHashTable{
+		ring: ring,
+	}
+}
+
+func (t *ringHashTable) chooseHost(hash uint64) types.Host {
+	idx := sort.Search(len(t.ring), func(i int) bool {
+		return t.ring[i].hash >= hash
+	})
+
+	if idx == len(t.ring) {
+		idx = 0
+	}
+
+	return t.ring[idx].host
+}
+
+// Maglev, see https://research.google.com/pubs/pub44824.html
+// Hosts fill the lookup table in turn by their preference list,
+// hosts with higher weight get more turns
+type maglevTable struct {
+	table []types.Host
+}
+
+func newMaglevTable(hosts []types.Host) hashTable {
+	type buildEntry struct {
+		host         types.Host
+		offset       uint64
+		skip         uint64
+		weight       float64
+		targetWeight float64
+		next         uint64
+	}
+
+	var maxWeight int64
+	for _, host := range hosts {
+		if weight := hostWeight(host); weight > maxWeight {
+			maxWeight = weight
+		}
+	}
+
+	entries := make([]*buildEntry, 0, len(hosts))
+	for _, host := range hosts {
+		address := host.AddressString()
+		entries = append(entries, &buildEntry{
+			host:   host,
+			offset: hashString(address) % maglevTableSize,
+			skip:   hashString(address+"#skip")%(maglevTableSize-1) + 1,
+			weight: float64(hostWeight(host)) / float64(maxWeight),
+		})
+	}
+
+	table := make([]types.Host, maglevTableSize)
+	filled := 0
+
+	for iteration := 1; filled < maglevTableSize; iteration++ {
+		for _, entry := range entries {
+			if filled >= maglevTableSize {
+				break
+			}
+
+			// host with max weight fills the table on every iteration,
+			// host with 1/n of max weight fills every n iterations
+			if float64(iteration)*entry.weight < entry.targetWeight {
+				continue
+			}
+			entry.targetWeight++
+
+			c := (entry.offset + entry.skip*entry.next) % maglevTableSize
+			for table[c] != nil {
+				entry.next++
+				c = (entry.offset + entry.skip*entry.next) % maglevTableSize
+			}
+
+			table[c] = entry.host
+			entry.next++
+			filled++
+		}
+	}
+
+	return &maglevTable{
+		table: table,
+	}
+}
+
+func (t *maglevTable) chooseHost(hash uint64) types.Host {
+	return t.table[hash%maglevTableSize]
+}
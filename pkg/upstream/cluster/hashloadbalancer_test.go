@@ -0,0 +1,143 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+func newHashTestHosts(num int) []types.Host {
+	var hosts []types.Host
+	for i := 0; i < num; i++ {
+		hosts = append(hosts, NewHost(v2.Host{Address: fmt.Sprintf("10.0.0.%d:12200", i+1)}, nil))
+	}
+
+	return hosts
+}
+
+func newHashTestLoadBalancer(lbType types.LoadBalancerType, hosts []types.Host) (types.LoadBalancer, *hostSet) {
+	hs := &hostSet{
+		hosts:        hosts,
+		healthyHosts: hosts,
+	}
+
+	return NewLoadBalancer(lbType, &prioritySet{hostSets: []types.HostSet{hs}}), hs
+}
+
+func chooseHostByKey(lb types.LoadBalancer, key int) types.Host {
+	return lb.ChooseHost(&ContextImplMock{hashKey: types.HashedValue(fmt.Sprintf("key-%d", key))})
+}
+
+func Test_hashLoadBalancer_Consistent(t *testing.T) {
+	for _, lbType := range []types.LoadBalancerType{types.RingHash, types.Maglev} {
+		lb, _ := newHashTestLoadBalancer(lbType, newHashTestHosts(5))
+
+		for key := 0; key < 100; key++ {
+			host := chooseHostByKey(lb, key)
+			for i := 0; i < 3; i++ {
+				if got := chooseHostByKey(lb, key); got != host {
+					t.Errorf("%s should choose the same host for key %d, got %v, want %v", lbType, key, got, host)
+				}
+			}
+		}
+	}
+}
+
+func Test_hashLoadBalancer_Distribution(t *testing.T) {
+	hosts := newHashTestHosts(5)
+	hosts[0].SetWeight(4)
+
+	for _, lbType := range []types.LoadBalancerType{types.RingHash, types.Maglev} {
+		lb, _ := newHashTestLoadBalancer(lbType, hosts)
+
+		counts := make(map[types.Host]int)
+		for key := 0; key < 10000; key++ {
+			counts[chooseHostByKey(lb, key)]++
+		}
+
+		// host0 has half of total weight
+		if counts[hosts[0]] < 4000 || counts[hosts[0]] > 6000 {
+			t.Errorf("%s host with weight 4 got %d of 10000 keys", lbType, counts[hosts[0]])
+		}
+
+		for _, host := range hosts[1:] {
+			if counts[host] < 800 || counts[host] > 1700 {
+				t.Errorf("%s host with weight 1 got %d of 10000 keys", lbType, counts[host])
+			}
+		}
+	}
+}
+
+func Test_hashLoadBalancer_HostRemoved(t *testing.T) {
+	hosts := newHashTestHosts(5)
+
+	// ring hash keeps all keys of remaining hosts unchanged, maglev changes a few
+	minStable := map[types.LoadBalancerType]float64{
+		types.RingHash: 1,
+		types.Maglev:   0.9,
+	}
+
+	for lbType, min := range minStable {
+		lb, hs := newHashTestLoadBalancer(lbType, hosts)
+
+		before := make(map[int]types.Host)
+		for key := 0; key < 10000; key++ {
+			before[key] = chooseHostByKey(lb, key)
+		}
+
+		hs.healthyHosts = hosts[1:]
+
+		var moved, total int
+		for key := 0; key < 10000; key++ {
+			got := chooseHostByKey(lb, key)
+			if got == hosts[0] {
+				t.Fatalf("%s should not choose removed host", lbType)
+			}
+
+			if before[key] != hosts[0] {
+				total++
+				if got != before[key] {
+					moved++
+				}
+			}
+		}
+
+		if stable := float64(total-moved) / float64(total); stable < min {
+			t.Errorf("%s only %f of keys are stable after host removed", lbType, stable)
+		}
+	}
+}
+
+func Test_hashLoadBalancer_NoHashKey(t *testing.T) {
+	for _, lbType := range []types.LoadBalancerType{types.RingHash, types.Maglev} {
+		lb, hs := newHashTestLoadBalancer(lbType, newHashTestHosts(3))
+
+		if got := lb.ChooseHost(nil); got == nil {
+			t.Errorf("%s should choose a host randomly without hash key", lbType)
+		}
+
+		hs.healthyHosts = nil
+		if got := chooseHostByKey(lb, 1); got != nil {
+			t.Errorf("%s should choose no host, but got %v", lbType, got)
+		}
+	}
+}
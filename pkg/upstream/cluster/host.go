@@ -22,10 +22,12 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
 	"github.com/alipay/sofa-mosn/pkg/log"
 	"github.com/alipay/sofa-mosn/pkg/network"
+	"github.com/alipay/sofa-mosn/pkg/stats"
 	"github.com/alipay/sofa-mosn/pkg/types"
 	"github.com/rcrowley/go-metrics"
 )
@@ -104,7 +106,10 @@ type host struct {
 }
 
 func NewHost(config v2.Host, clusterInfo types.ClusterInfo) types.Host {
-	addr, _ := net.ResolveTCPAddr("tcp", config.Address)
+	addr, err := net.ResolveTCPAddr("tcp", config.Address)
+	if err != nil {
+		log.DefaultLogger.Errorf("resolve host address %s failed: %v", config.Address, err)
+	}
 
 	return &host{
 		hostInfo: newHostInfo(addr, config, clusterInfo),
@@ -136,6 +141,9 @@ func newHostStats(config v2.Host) types.HostStats {
 		UpstreamRequestTimeout:                         metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_request_timeout"), nil),
 		UpstreamRequestFailureEject:                    metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_failure_eject"), nil),
 		UpstreamRequestPendingOverflow:                 metrics.GetOrRegisterCounter(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_pending_overflow"), nil),
+		UpstreamConnectionConnectTime:                  stats.GetOrRegisterBucketHistogram(fmt.Sprintf("%s.%s", nameSpace, "upstream_connection_connect_time"), nil),
+		UpstreamRequestTime:                            stats.GetOrRegisterBucketHistogram(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_time"), nil),
+		UpstreamRequestTimeToFirstByte:                 stats.GetOrRegisterBucketHistogram(fmt.Sprintf("%s.%s", nameSpace, "upstream_request_time_to_first_byte"), nil),
 	}
 }
 
@@ -162,29 +170,41 @@ func (h *host) Gauges() types.HostStats {
 // health:0, unhealth:1
 // set h.healthFlags = 0
 // ^1 = 0
+// flags are updated by both active health checker and outlier detector, so use CAS here
 func (h *host) ClearHealthFlag(flag types.HealthFlag) {
-	h.healthFlags &= ^uint64(flag)
+	for {
+		old := atomic.LoadUint64(&h.healthFlags)
+		if atomic.CompareAndSwapUint64(&h.healthFlags, old, old&^uint64(flag)) {
+			return
+		}
+	}
 }
 
 // return 1, if h.healthFlags = 1
 func (h *host) ContainHealthFlag(flag types.HealthFlag) bool {
-	return h.healthFlags&uint64(flag) > 0
+	return atomic.LoadUint64(&h.healthFlags)&uint64(flag) > 0
 }
 
 // set h.healthFlags = 1
 func (h *host) SetHealthFlag(flag types.HealthFlag) {
-	h.healthFlags |= uint64(flag)
+	for {
+		old := atomic.LoadUint64(&h.healthFlags)
+		if atomic.CompareAndSwapUint64(&h.healthFlags, old, old|uint64(flag)) {
+			return
+		}
+	}
 }
 
 // return 1 when h.healthFlags == 0
 func (h *host) Health() bool {
-	return h.healthFlags == 0
+	return atomic.LoadUint64(&h.healthFlags) == 0
 }
 
 func (h *host) SetHealthChecker(healthCheck types.HealthCheckHostMonitor) {
 }
 
 func (h *host) SetOutlierDetector(outlierDetector types.DetectorHostMonitor) {
+	h.outlierDetector = outlierDetector
 }
 
 func (h *host) Weight() uint32 {
@@ -212,8 +232,11 @@ type hostInfo struct {
 	clusterInfo   types.ClusterInfo
 	stats         types.HostStats
 	metaData      types.RouteMetaData
+	config        v2.Host
+
+	outlierDetector types.DetectorHostMonitor
 
-	// TODO: locality, outlier, healthchecker
+	// TODO: healthchecker
 }
 
 func newHostInfo(addr net.Addr, config v2.Host, clusterInfo types.ClusterInfo) hostInfo {
@@ -224,6 +247,7 @@ func newHostInfo(addr net.Addr, config v2.Host, clusterInfo types.ClusterInfo) h
 		clusterInfo:   clusterInfo,
 		stats:         newHostStats(config),
 		metaData:      GenerateHostMetadata(config.MetaData),
+		config:        config,
 	}
 }
 
@@ -244,7 +268,7 @@ func (hi *hostInfo) ClusterInfo() types.ClusterInfo {
 }
 
 func (hi *hostInfo) OutlierDetector() types.DetectorHostMonitor {
-	return nil
+	return hi.outlierDetector
 }
 
 func (hi *hostInfo) HealthChecker() types.HealthCheckHostMonitor {
@@ -263,6 +287,14 @@ func (hi *hostInfo) HostStats() types.HostStats {
 	return hi.stats
 }
 
+func (hi *hostInfo) Priority() uint32 {
+	return hi.config.Priority
+}
+
+func (hi *hostInfo) Locality() v2.Locality {
+	return hi.config.Locality
+}
+
 func GenerateHostMetadata(metadata v2.Metadata) types.RouteMetaData {
 	rm := make(map[string]types.HashedValue, 1)
 
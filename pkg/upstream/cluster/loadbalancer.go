@@ -18,7 +18,10 @@
 package cluster
 
 import (
+	"math"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 
 	"github.com/alipay/sofa-mosn/pkg/types"
 )
@@ -28,6 +31,14 @@ func NewLoadBalancer(lbType types.LoadBalancerType, prioritySet types.PrioritySe
 	switch lbType {
 	case types.RoundRobin:
 		return newRoundRobinLoadBalancer(prioritySet)
+	case types.WeightedRoundRobin:
+		return newWeightedRoundRobinLoadBalancer(prioritySet)
+	case types.LeastRequest:
+		return newLeastRequestLoadBalancer(prioritySet)
+	case types.RingHash:
+		return newRingHashLoadBalancer(prioritySet)
+	case types.Maglev:
+		return newMaglevLoadBalancer(prioritySet)
 	default:
 		return newRandomLoadbalancer(prioritySet)
 	}
@@ -35,8 +46,271 @@ func NewLoadBalancer(lbType types.LoadBalancerType, prioritySet types.PrioritySe
 	return nil
 }
 
+// chooseHost chooses a host by the load balancer, hosts rejected by the context,
+// such as the ones failed in previous tries, are skipped if possible
+func chooseHost(lb types.LoadBalancer, context types.LoadBalancerContext) types.Host {
+	host := lb.ChooseHost(context)
+
+	if context == nil {
+		return host
+	}
+
+	for i := 0; i < hostSelectionRetries && host != nil && context.ShouldSelectAnotherHost(host); i++ {
+		host = lb.ChooseHost(context)
+	}
+
+	return host
+}
+
+const (
+	// host's weight is treated as 1 if not set
+	defaultHostWeight = 1
+	// max number of extra host selections when the chosen host is rejected
+	hostSelectionRetries = 3
+	// a group of hosts is treated fully healthy if its healthy ratio multiplied by the factor
+	// is not less than 1, so the traffic fails over only if more than 1/1.4 of the hosts are unhealthy
+	overprovisioningFactor = 1.4
+	// precision of the point used to choose the priority
+	priorityLoadPrecision = 10000
+	// zone aware routing only applies to host sets with at least this many healthy hosts,
+	// the hosts in local zone of a small host set are easily overloaded
+	minZoneAwareHosts = 6
+)
+
+// zoneRoute is the group of zones the hosts are chosen from by zone aware routing
+type zoneRoute int
+
+const (
+	allZones zoneRoute = iota
+	localZoneOnly
+	remoteZones
+)
+
+// localZone is the zone of the application, the hosts in local zone are preferred if it is set
+var localZone atomic.Value
+
+// SetLocalZone sets the zone of the application for zone aware routing
+func SetLocalZone(zone string) {
+	localZone.Store(zone)
+}
+
+func getLocalZone() string {
+	zone, _ := localZone.Load().(string)
+	return zone
+}
+
 type loadbalaner struct {
 	prioritySet types.PrioritySet
+	// *prioritySetState computed from the host sets, rebuilt when they are updated
+	state atomic.Value
+}
+
+// prioritySetState is the priority loads and zone splits of the host sets. It is computed
+// once the host sets are updated, instead of on each choice
+type prioritySetState struct {
+	zone     string
+	hostSets []*hostSetState
+	// total load of all priorities, less than 1 if all priorities together are not healthy enough
+	totalLoad float64
+}
+
+// hostSetState is the load and zone split of a host set
+type hostSetState struct {
+	hostSet types.HostSet
+	// hosts and healthy hosts the state is computed from, the host set replaces them on update
+	hosts        []types.Host
+	healthyHosts []types.Host
+	load         float64
+
+	// zone aware routing applies only if localLoad is greater than 0
+	localHosts  []types.Host
+	remoteHosts []types.Host
+	localLoad   float64
+}
+
+// healthyHosts returns the healthy hosts to choose from, the priority and zones are chosen randomly
+func (l *loadbalaner) healthyHosts() []types.Host {
+	hosts, _ := l.chooseHosts()
+	return hosts
+}
+
+// chooseHosts is healthyHosts returning the key of the chosen priority and zones as well
+func (l *loadbalaner) chooseHosts() ([]types.Host, hostsTableKey) {
+	hostSet := l.chooseHostSet(rand.Uint64())
+	if hostSet == nil {
+		return nil, hostsTableKey{}
+	}
+
+	hosts, route := hostSet.zoneHosts(rand.Uint64())
+	return hosts, hostsTableKey{priority: hostSet.hostSet.Priority(), route: route}
+}
+
+// chooseHostSet chooses the host set by priority load. Each priority takes the load as much as its health,
+// which is the healthy ratio multiplied by the overprovisioning factor and capped at 100%,
+// the load left fails over to the lower priorities. The load is normalized if all priorities together
+// are not healthy enough, and the point is used to choose in the normalized load
+func (l *loadbalaner) chooseHostSet(point uint64) *hostSetState {
+	state := l.prioritySetState()
+	if len(state.hostSets) == 0 {
+		return nil
+	}
+
+	// no healthy hosts in all priorities
+	if state.totalLoad <= 0 {
+		return state.hostSets[0]
+	}
+
+	p := float64(point%priorityLoadPrecision) / priorityLoadPrecision * state.totalLoad
+	chosen := state.hostSets[0]
+	for _, hostSet := range state.hostSets {
+		if hostSet.load <= 0 {
+			continue
+		}
+
+		chosen = hostSet
+		if p < hostSet.load {
+			break
+		}
+		p -= hostSet.load
+	}
+
+	return chosen
+}
+
+// prioritySetState returns the state of the host sets, which is rebuilt if any host set is updated
+func (l *loadbalaner) prioritySetState() *prioritySetState {
+	hostSets := l.prioritySet.HostSetsByPriority()
+	zone := getLocalZone()
+
+	if state, ok := l.state.Load().(*prioritySetState); ok && state.upToDate(hostSets, zone) {
+		return state
+	}
+
+	state := newPrioritySetState(hostSets, zone)
+	l.state.Store(state)
+
+	return state
+}
+
+func newPrioritySetState(hostSets []types.HostSet, zone string) *prioritySetState {
+	state := &prioritySetState{
+		zone:     zone,
+		hostSets: make([]*hostSetState, len(hostSets)),
+	}
+
+	left := 1.0
+	for i, hostSet := range hostSets {
+		s := newHostSetState(hostSet, zone)
+		s.load = math.Min(health(len(s.healthyHosts), len(s.hosts)), left)
+		left -= s.load
+
+		state.hostSets[i] = s
+	}
+	state.totalLoad = 1 - left
+
+	return state
+}
+
+func (s *prioritySetState) upToDate(hostSets []types.HostSet, zone string) bool {
+	if s.zone != zone || len(s.hostSets) != len(hostSets) {
+		return false
+	}
+
+	for i, hostSet := range hostSets {
+		state := s.hostSets[i]
+		if state.hostSet != hostSet || !sameSlice(state.hosts, hostSet.Hosts()) ||
+			!sameSlice(state.healthyHosts, hostSet.HealthyHosts()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameSlice tells whether the two slices share the same elements, without comparing them one by one
+func sameSlice(hosts1, hosts2 []types.Host) bool {
+	if len(hosts1) != len(hosts2) {
+		return false
+	}
+
+	return len(hosts1) == 0 || &hosts1[0] == &hosts2[0]
+}
+
+// health returns the healthy ratio multiplied by the overprovisioning factor, capped at 1
+func health(healthy int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return math.Min(1, overprovisioningFactor*float64(healthy)/float64(total))
+}
+
+// newHostSetState splits the healthy hosts into the local zone and the remote ones. The downstream
+// is assumed to spread evenly over the zones of the host set, so the local zone is expected to take
+// 1/zones of the traffic. The local zone takes all the traffic if its share of the healthy hosts is
+// not less than that, otherwise it takes the traffic as much as its share, and the residual goes to the other zones
+func newHostSetState(hostSet types.HostSet, zone string) *hostSetState {
+	s := &hostSetState{
+		hostSet:      hostSet,
+		hosts:        hostSet.Hosts(),
+		healthyHosts: hostSet.HealthyHosts(),
+	}
+
+	if zone == "" || len(s.healthyHosts) < minZoneAwareHosts {
+		return s
+	}
+
+	hostsPerLocality := hostSet.HostsPerLocality()
+	healthyHostsPerLocality := hostSet.HealthHostsPerLocality()
+	if len(hostsPerLocality) != len(healthyHostsPerLocality) {
+		return s
+	}
+
+	zones := make(map[string]bool)
+	var localHosts, remoteHosts []types.Host
+	for i, hosts := range hostsPerLocality {
+		if len(hosts) == 0 {
+			continue
+		}
+
+		zones[hosts[0].Locality().Zone] = true
+		if hosts[0].Locality().Zone == zone {
+			localHosts = append(localHosts, healthyHostsPerLocality[i]...)
+		} else {
+			remoteHosts = append(remoteHosts, healthyHostsPerLocality[i]...)
+		}
+	}
+
+	if len(localHosts) == 0 || len(remoteHosts) == 0 {
+		return s
+	}
+
+	s.localHosts = localHosts
+	s.remoteHosts = remoteHosts
+	s.localLoad = float64(len(localHosts)) / float64(len(s.healthyHosts)) * float64(len(zones))
+
+	return s
+}
+
+// zoneHosts returns the healthy hosts of the zones chosen by the point
+func (s *hostSetState) zoneHosts(point uint64) ([]types.Host, zoneRoute) {
+	if s.localLoad <= 0 {
+		return s.healthyHosts, allZones
+	}
+
+	if s.localLoad >= 1 || float64(point%priorityLoadPrecision)/priorityLoadPrecision < s.localLoad {
+		return s.localHosts, localZoneOnly
+	}
+
+	return s.remoteHosts, remoteZones
+}
+
+func hostWeight(host types.Host) int64 {
+	if weight := host.Weight(); weight > 0 {
+		return int64(weight)
+	}
+
+	return defaultHostWeight
 }
 
 // Random LoadBalancer
@@ -53,11 +327,7 @@ func newRandomLoadbalancer(prioritySet types.PrioritySet) types.LoadBalancer {
 }
 
 func (l *randomLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
-	hostSets := l.prioritySet.HostSetsByPriority()
-	idx := rand.Intn(len(hostSets))
-	hostset := hostSets[idx]
-
-	hosts := hostset.HealthyHosts()
+	hosts := l.healthyHosts()
 	//logger := log.ByContext(context)
 
 	if len(hosts) == 0 {
@@ -71,8 +341,6 @@ func (l *randomLoadBalancer) ChooseHost(context types.LoadBalancerContext) types
 // TODO: more loadbalancers@boqin
 type roundRobinLoadBalancer struct {
 	loadbalaner
-	// rrIndex for hostSet select
-	rrIndexPriority uint32
 	// rrIndex for host select
 	rrIndex uint32
 }
@@ -86,19 +354,7 @@ func newRoundRobinLoadBalancer(prioritySet types.PrioritySet) types.LoadBalancer
 }
 
 func (l *roundRobinLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
-	var selectedHostSet []types.Host
-
-	hostSets := l.prioritySet.HostSetsByPriority()
-	hostSetsNum := uint32(len(hostSets))
-	curHostSet := hostSets[l.rrIndexPriority%hostSetsNum].HealthyHosts()
-
-	if l.rrIndex >= uint32(len(curHostSet)) {
-		l.rrIndexPriority = (l.rrIndexPriority + 1) % hostSetsNum
-		l.rrIndex = 0
-		selectedHostSet = hostSets[l.rrIndexPriority].HealthyHosts()
-	} else {
-		selectedHostSet = curHostSet
-	}
+	selectedHostSet := l.healthyHosts()
 
 	if len(selectedHostSet) == 0 {
 		//logger := log.ByContext(context)
@@ -111,3 +367,106 @@ func (l *roundRobinLoadBalancer) ChooseHost(context types.LoadBalancerContext) t
 
 	return selectedHost
 }
+
+// Smooth weighted round robin LoadBalancer, same as nginx
+// For hosts {a:5, b:1, c:1}, the sequence is {a, a, b, a, c, a, a}
+type weightedRoundRobinLoadBalancer struct {
+	loadbalaner
+	mux sync.Mutex
+	// current weights of hosts in each priority and zones, updated on each choice
+	currentWeights map[hostsTableKey]map[types.Host]int64
+}
+
+func newWeightedRoundRobinLoadBalancer(prioritySet types.PrioritySet) types.LoadBalancer {
+	return &weightedRoundRobinLoadBalancer{
+		loadbalaner: loadbalaner{
+			prioritySet: prioritySet,
+		},
+		currentWeights: make(map[hostsTableKey]map[types.Host]int64),
+	}
+}
+
+func (l *weightedRoundRobinLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	hosts, key := l.chooseHosts()
+
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	// the weights are kept per priority and zones, so that choosing another group
+	// of hosts does not reset the sequence of the others
+	weights, ok := l.currentWeights[key]
+	if !ok {
+		weights = make(map[types.Host]int64, len(hosts))
+		l.currentWeights[key] = weights
+	}
+
+	var selectedHost types.Host
+	var selectedWeight, totalWeight int64
+
+	for _, host := range hosts {
+		weight := hostWeight(host)
+		totalWeight += weight
+
+		current := weights[host] + weight
+		weights[host] = current
+
+		if selectedHost == nil || current > selectedWeight {
+			selectedHost = host
+			selectedWeight = current
+		}
+	}
+
+	weights[selectedHost] -= totalWeight
+
+	// drop hosts which are removed or unhealthy
+	if len(weights) > len(hosts) {
+		currentWeights := make(map[types.Host]int64, len(hosts))
+		for _, host := range hosts {
+			currentWeights[host] = weights[host]
+		}
+		l.currentWeights[key] = currentWeights
+	}
+
+	return selectedHost
+}
+
+// Least request LoadBalancer, use power of two choices:
+// choose two random hosts and pick the one with fewer active requests
+type leastRequestLoadBalancer struct {
+	loadbalaner
+}
+
+func newLeastRequestLoadBalancer(prioritySet types.PrioritySet) types.LoadBalancer {
+	return &leastRequestLoadBalancer{
+		loadbalaner: loadbalaner{
+			prioritySet: prioritySet,
+		},
+	}
+}
+
+func (l *leastRequestLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	hosts := l.healthyHosts()
+
+	switch len(hosts) {
+	case 0:
+		return nil
+	case 1:
+		return hosts[0]
+	}
+
+	first := rand.Intn(len(hosts))
+	second := rand.Intn(len(hosts) - 1)
+	if second >= first {
+		second++
+	}
+
+	if hosts[second].HostStats().UpstreamRequestActive.Count() < hosts[first].HostStats().UpstreamRequestActive.Count() {
+		return hosts[second]
+	}
+
+	return hosts[first]
+}
@@ -65,7 +65,8 @@ func Test_roundRobinLoadBalancer_ChooseHost(t *testing.T) {
 		loadbalaner: loadbalaner,
 	}
 
-	want := []types.Host{host1, host2, host3, host4, host5}
+	// all priorities are healthy, lower priorities take no traffic
+	want := []types.Host{host1, host2, host1, host2, host1}
 
 	for i := 0; i < len(want); i++ {
 		got := l.ChooseHost(nil)
@@ -74,3 +75,321 @@ func Test_roundRobinLoadBalancer_ChooseHost(t *testing.T) {
 		}
 	}
 }
+
+func Test_chooseHost_SkipRejectedHost(t *testing.T) {
+	host1 := NewHost(v2.Host{Address: "127.0.0.1", Hostname: "a"}, nil)
+	host2 := NewHost(v2.Host{Address: "127.0.0.2", Hostname: "b"}, nil)
+
+	hosts := []types.Host{host1, host2}
+
+	l := &roundRobinLoadBalancer{
+		loadbalaner: loadbalaner{
+			prioritySet: &prioritySet{
+				hostSets: []types.HostSet{&hostSet{
+					hosts:        hosts,
+					healthyHosts: hosts,
+				}},
+			},
+		},
+	}
+
+	ctx := &ContextImplMock{rejectedHost: host1}
+
+	for i := 0; i < 4; i++ {
+		if got := chooseHost(l, ctx); got != host2 {
+			t.Errorf("Test Error in case %d , got %+v, but want %+v,", i, got, host2)
+		}
+	}
+}
+
+func Test_weightedRoundRobinLoadBalancer_ChooseHost(t *testing.T) {
+	host1 := NewHost(v2.Host{Address: "127.0.0.1", Hostname: "a", Weight: 5}, nil)
+	host2 := NewHost(v2.Host{Address: "127.0.0.2", Hostname: "b", Weight: 1}, nil)
+	host3 := NewHost(v2.Host{Address: "127.0.0.3", Hostname: "c", Weight: 0}, nil)
+
+	hosts := []types.Host{host1, host2, host3}
+
+	prioritySet := prioritySet{
+		hostSets: []types.HostSet{&hostSet{
+			hosts:        hosts,
+			healthyHosts: hosts,
+		}},
+	}
+
+	l := newWeightedRoundRobinLoadBalancer(&prioritySet)
+
+	want := []types.Host{host1, host1, host2, host1, host3, host1, host1}
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < len(want); i++ {
+			got := l.ChooseHost(nil)
+			if got != want[i] {
+				t.Errorf("Test Error in round %d case %d , got %+v, but want %+v,", round, i, got, want[i])
+			}
+		}
+	}
+}
+
+func Test_weightedRoundRobinLoadBalancer_HostsChanged(t *testing.T) {
+	host1 := NewHost(v2.Host{Address: "127.0.0.1", Hostname: "a", Weight: 2}, nil)
+	host2 := NewHost(v2.Host{Address: "127.0.0.2", Hostname: "b", Weight: 1}, nil)
+
+	hs := &hostSet{
+		hosts:        []types.Host{host1, host2},
+		healthyHosts: []types.Host{host1, host2},
+	}
+
+	l := newWeightedRoundRobinLoadBalancer(&prioritySet{
+		hostSets: []types.HostSet{hs},
+	}).(*weightedRoundRobinLoadBalancer)
+
+	l.ChooseHost(nil)
+	hs.healthyHosts = []types.Host{host2}
+
+	for i := 0; i < 3; i++ {
+		if got := l.ChooseHost(nil); got != host2 {
+			t.Errorf("Test Error in case %d , got %+v, but want %+v,", i, got, host2)
+		}
+	}
+
+	if weights := l.currentWeights[hostsTableKey{}]; len(weights) != 1 {
+		t.Errorf("unhealthy host's weight should be dropped, got %d weights", len(weights))
+	}
+}
+
+func Test_weightedRoundRobinLoadBalancer_LocalZone(t *testing.T) {
+	SetLocalZone("zone_a")
+	defer SetLocalZone("")
+
+	host1 := NewHost(v2.Host{Address: "127.0.0.1", Hostname: "a", Weight: 2, Locality: v2.Locality{Zone: "zone_a"}}, nil)
+	host2 := NewHost(v2.Host{Address: "127.0.0.2", Hostname: "b", Weight: 1, Locality: v2.Locality{Zone: "zone_a"}}, nil)
+	local := []types.Host{host1, host2}
+	remote := newHostsInZone("zone_b", "127.0.1.1", "127.0.1.2", "127.0.1.3", "127.0.1.4", "127.0.1.5", "127.0.1.6")
+	all := append(append([]types.Host{}, local...), remote...)
+
+	// local zone takes half of the traffic
+	l := newWeightedRoundRobinLoadBalancer(&prioritySet{
+		hostSets: []types.HostSet{&hostSet{
+			hosts:                   all,
+			healthyHosts:            all,
+			hostsPerLocality:        [][]types.Host{local, remote},
+			healthyHostsPerLocality: [][]types.Host{local, remote},
+		}},
+	})
+
+	// the sequence in local zone is kept while the requests switch between the zones
+	want := []types.Host{host1, host2, host1}
+	var got []types.Host
+	for i := 0; i < 300; i++ {
+		if host := l.ChooseHost(nil); host == host1 || host == host2 {
+			got = append(got, host)
+		}
+	}
+
+	if len(got) == len(want) {
+		t.Fatalf("expected requests to both zones, but got %d of 300 to local zone", len(got))
+	}
+	for i, host := range got {
+		if host != want[i%len(want)] {
+			t.Fatalf("Test Error in case %d , got %+v, but want %+v,", i, host, want[i%len(want)])
+		}
+	}
+}
+
+func Test_leastRequestLoadBalancer_ChooseHost(t *testing.T) {
+	host1 := NewHost(v2.Host{Address: "127.0.0.11", Hostname: "a"}, nil)
+	host2 := NewHost(v2.Host{Address: "127.0.0.12", Hostname: "b"}, nil)
+
+	hosts := []types.Host{host1, host2}
+
+	l := newLeastRequestLoadBalancer(&prioritySet{
+		hostSets: []types.HostSet{&hostSet{
+			hosts:        hosts,
+			healthyHosts: hosts,
+		}},
+	})
+
+	host1.HostStats().UpstreamRequestActive.Inc(10)
+	defer host1.HostStats().UpstreamRequestActive.Dec(10)
+
+	// with two hosts, both are always chosen as candidates
+	for i := 0; i < 10; i++ {
+		if got := l.ChooseHost(nil); got != host2 {
+			t.Errorf("Test Error in case %d , got %+v, but want %+v,", i, got, host2)
+		}
+	}
+}
+
+func Test_leastRequestLoadBalancer_NoHealthyHost(t *testing.T) {
+	l := newLeastRequestLoadBalancer(&prioritySet{
+		hostSets: []types.HostSet{&hostSet{}},
+	})
+
+	if got := l.ChooseHost(nil); got != nil {
+		t.Errorf("should choose no host, but got %+v", got)
+	}
+}
+
+func newHostsInZone(zone string, addrs ...string) []types.Host {
+	var hosts []types.Host
+	for _, addr := range addrs {
+		hosts = append(hosts, NewHost(v2.Host{Address: addr, Locality: v2.Locality{Zone: zone}}, nil))
+	}
+
+	return hosts
+}
+
+func Test_loadbalaner_PriorityFailover(t *testing.T) {
+	p0 := newHostsInZone("", "127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4", "127.0.0.5",
+		"127.0.0.6", "127.0.0.7", "127.0.0.8", "127.0.0.9", "127.0.0.10")
+	p1 := newHostsInZone("", "127.0.1.1", "127.0.1.2")
+
+	hs0 := &hostSet{priority: 0, hosts: p0}
+	hs1 := &hostSet{priority: 1, hosts: p1, healthyHosts: p1}
+	l := &loadbalaner{
+		prioritySet: &prioritySet{
+			hostSets: []types.HostSet{hs0, hs1},
+		},
+	}
+
+	// 80% healthy is enough with overprovisioning factor
+	hs0.healthyHosts = p0[:8]
+	for _, point := range []uint64{0, 5000, 9999} {
+		if got := l.chooseHostSet(point).hostSet; got != hs0 {
+			t.Errorf("expected priority 0 at point %d, but got priority %d", point, got.Priority())
+		}
+	}
+
+	// 50% healthy takes 70% load, the rest fails over to priority 1
+	hs0.healthyHosts = p0[:5]
+	for point, want := range map[uint64]types.HostSet{0: hs0, 6000: hs0, 8000: hs1, 9999: hs1} {
+		if got := l.chooseHostSet(point).hostSet; got != want {
+			t.Errorf("expected priority %d at point %d, but got priority %d", want.Priority(), point, got.Priority())
+		}
+	}
+
+	// load is normalized if all priorities are not healthy enough
+	hs0.healthyHosts = p0[:2]
+	hs1.healthyHosts = nil
+	if got := l.chooseHostSet(9999).hostSet; got != hs0 {
+		t.Errorf("expected priority 0 takes all load, but got priority %d", got.Priority())
+	}
+
+	// no healthy hosts at all
+	hs0.healthyHosts = nil
+	if got := l.healthyHosts(); len(got) != 0 {
+		t.Errorf("expected no healthy hosts, but got %v", got)
+	}
+}
+
+func Test_loadbalaner_StateRebuiltOnUpdate(t *testing.T) {
+	hosts := newHostsInZone("", "127.0.0.1", "127.0.0.2")
+	hs := &hostSet{hosts: hosts, healthyHosts: hosts}
+	l := &loadbalaner{
+		prioritySet: &prioritySet{
+			hostSets: []types.HostSet{hs},
+		},
+	}
+
+	state := l.prioritySetState()
+	if got := l.prioritySetState(); got != state {
+		t.Errorf("expected the state is reused until the host set is updated")
+	}
+
+	hs.UpdateHosts(hosts, hosts[:1], nil, nil, nil, nil)
+	if got := l.prioritySetState(); got == state || !sameHosts(got.hostSets[0].healthyHosts, hosts[:1]) {
+		t.Errorf("expected the state is rebuilt after the host set is updated")
+	}
+}
+
+func Test_loadbalaner_LocalZone(t *testing.T) {
+	SetLocalZone("zone_a")
+	defer SetLocalZone("")
+
+	local := newHostsInZone("zone_a", "127.0.0.1", "127.0.0.2", "127.0.0.3")
+	remote := newHostsInZone("zone_b", "127.0.1.1", "127.0.1.2", "127.0.1.3")
+	all := append(append([]types.Host{}, local...), remote...)
+
+	hs := &hostSet{
+		hosts:                   all,
+		healthyHosts:            all,
+		hostsPerLocality:        [][]types.Host{remote, local},
+		healthyHostsPerLocality: [][]types.Host{remote, local},
+	}
+	l := &loadbalaner{
+		prioritySet: &prioritySet{
+			hostSets: []types.HostSet{hs},
+		},
+	}
+
+	// local zone has its share of hosts, and takes all the traffic
+	if got := l.healthyHosts(); !sameHosts(got, local) {
+		t.Errorf("expected hosts in local zone, but got %v", got)
+	}
+
+	// the local zone with less healthy hosts takes the traffic as much as its share,
+	// 2 of 6 healthy hosts in 2 zones take 2/3 of the traffic
+	healthyLocal := local[:2]
+	remote = append(remote, newHostsInZone("zone_b", "127.0.1.4")...)
+	hs.hosts = append(append([]types.Host{}, local...), remote...)
+	hs.healthyHosts = append(append([]types.Host{}, healthyLocal...), remote...)
+	hs.hostsPerLocality = [][]types.Host{remote, local}
+	hs.healthyHostsPerLocality = [][]types.Host{remote, healthyLocal}
+	for point, want := range map[uint64][]types.Host{0: healthyLocal, 6666: healthyLocal, 6667: remote, 9999: remote} {
+		if got, _ := l.chooseHostSet(0).zoneHosts(point); !sameHosts(got, want) {
+			t.Errorf("expected %v at point %d, but got %v", want, point, got)
+		}
+	}
+
+	// no zone aware routing without local zone
+	SetLocalZone("")
+	if got := l.healthyHosts(); !sameHosts(got, hs.healthyHosts) {
+		t.Errorf("expected all healthy hosts, but got %v", got)
+	}
+}
+
+func Test_loadbalaner_SmallLocalZone(t *testing.T) {
+	SetLocalZone("zone_a")
+	defer SetLocalZone("")
+
+	local := newHostsInZone("zone_a", "127.0.0.1")
+	remote := newHostsInZone("zone_b", "127.0.1.1", "127.0.1.2", "127.0.1.3", "127.0.1.4", "127.0.1.5")
+	others := newHostsInZone("zone_c", "127.0.2.1", "127.0.2.2", "127.0.2.3")
+	all := append(append(append([]types.Host{}, local...), remote...), others...)
+
+	hs := &hostSet{
+		hosts:                   all,
+		healthyHosts:            all,
+		hostsPerLocality:        [][]types.Host{local, remote, others},
+		healthyHostsPerLocality: [][]types.Host{local, remote, others},
+	}
+	l := &loadbalaner{
+		prioritySet: &prioritySet{
+			hostSets: []types.HostSet{hs},
+		},
+	}
+
+	// 1 of 9 hosts in 3 zones takes 1/3 of the traffic, the residual goes to the other zones
+	for point, want := range map[uint64]zoneRoute{0: localZoneOnly, 3332: localZoneOnly, 3334: remoteZones, 9999: remoteZones} {
+		if _, got := l.chooseHostSet(0).zoneHosts(point); got != want {
+			t.Errorf("expected zone route %d at point %d, but got %d", want, point, got)
+		}
+	}
+
+	localRequests := 0
+	for i := uint64(0); i < priorityLoadPrecision; i++ {
+		if hosts, _ := l.chooseHostSet(0).zoneHosts(i); sameHosts(hosts, local) {
+			localRequests++
+		}
+	}
+	if localRequests != 3334 {
+		t.Errorf("expected 3334 of 10000 requests to local zone, but got %d", localRequests)
+	}
+
+	// zone aware routing is disabled with too few healthy hosts
+	hs.healthyHosts = append(append([]types.Host{}, local...), remote[:4]...)
+	hs.healthyHostsPerLocality = [][]types.Host{local, remote[:4], nil}
+	if got, route := l.chooseHostSet(0).zoneHosts(0); route != allZones || !sameHosts(got, hs.healthyHosts) {
+		t.Errorf("expected all healthy hosts, but got %v", got)
+	}
+}
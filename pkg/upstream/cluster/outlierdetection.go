@@ -0,0 +1,519 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// default values used when the outlier detection config is not specified
+const (
+	DefaultOutlierInterval                  = 10 * time.Second
+	DefaultOutlierBaseEjectionTime          = 30 * time.Second
+	DefaultOutlierMaxEjectionPercent        = uint32(10)
+	DefaultOutlierConsecutive5xx            = uint32(5)
+	DefaultOutlierConsecutiveGatewayFailure = uint32(5)
+	DefaultOutlierEnforcingConsecutive5xx   = uint32(100)
+	DefaultOutlierEnforcingSuccessRate      = uint32(100)
+	DefaultOutlierSuccessRateMinimumHosts   = uint32(5)
+	DefaultOutlierSuccessRateRequestVolume  = uint32(100)
+	DefaultOutlierSuccessRateStdevFactor    = uint32(1900)
+
+	// ejection time grows as BaseEjectionTime * 2^(numEjections-1), the exponent is capped by maxEjectionTimeShift
+	maxEjectionTimeShift = 10
+)
+
+// outlier detection is enabled when any of the config is specified
+func outlierDetectionEnabled(config v2.OutlierDetection) bool {
+	return config != v2.OutlierDetection{}
+}
+
+func outlierDetectionWithDefault(config v2.OutlierDetection) v2.OutlierDetection {
+	if config.Interval == 0 {
+		config.Interval = DefaultOutlierInterval
+	}
+
+	if config.BaseEjectionTime == 0 {
+		config.BaseEjectionTime = DefaultOutlierBaseEjectionTime
+	}
+
+	if config.MaxEjectionPercent == 0 {
+		config.MaxEjectionPercent = DefaultOutlierMaxEjectionPercent
+	}
+
+	if config.Consecutive5xx == 0 {
+		config.Consecutive5xx = DefaultOutlierConsecutive5xx
+	}
+
+	if config.ConsecutiveGatewayFailure == 0 {
+		config.ConsecutiveGatewayFailure = DefaultOutlierConsecutiveGatewayFailure
+	}
+
+	if config.EnforcingConsecutive5xx == 0 {
+		config.EnforcingConsecutive5xx = DefaultOutlierEnforcingConsecutive5xx
+	}
+
+	if config.EnforcingSuccessRate == 0 {
+		config.EnforcingSuccessRate = DefaultOutlierEnforcingSuccessRate
+	}
+
+	if config.SuccessRateMinimumHosts == 0 {
+		config.SuccessRateMinimumHosts = DefaultOutlierSuccessRateMinimumHosts
+	}
+
+	if config.SuccessRateRequestVolume == 0 {
+		config.SuccessRateRequestVolume = DefaultOutlierSuccessRateRequestVolume
+	}
+
+	if config.SuccessRateStdevFactor == 0 {
+		config.SuccessRateStdevFactor = DefaultOutlierSuccessRateStdevFactor
+	}
+
+	return config
+}
+
+// types.Detector
+type outlierDetector struct {
+	config      v2.OutlierDetection
+	prioritySet types.PrioritySet
+	stats       types.ClusterStats
+
+	hostMonitors    map[types.Host]*outlierHostMonitor
+	changedStateCbs []func(host types.Host)
+	ejectedHosts    uint32
+
+	successRateAverage           float64
+	successRateEjectionThreshold float64
+
+	mux      sync.RWMutex
+	stopChan chan bool
+	stopOnce sync.Once
+}
+
+func newOutlierDetector(config v2.OutlierDetection, prioritySet types.PrioritySet, stats types.ClusterStats) *outlierDetector {
+	d := &outlierDetector{
+		config:                       outlierDetectionWithDefault(config),
+		prioritySet:                  prioritySet,
+		stats:                        stats,
+		hostMonitors:                 make(map[types.Host]*outlierHostMonitor),
+		successRateAverage:           -1,
+		successRateEjectionThreshold: -1,
+		stopChan:                     make(chan bool),
+	}
+
+	for _, hostSet := range prioritySet.HostSetsByPriority() {
+		d.addHosts(hostSet.Hosts())
+	}
+
+	prioritySet.AddMemberUpdateCb(func(priority uint32, hostsAdded []types.Host, hostsRemoved []types.Host) {
+		d.addHosts(hostsAdded)
+		d.removeHosts(hostsRemoved)
+	})
+
+	return d
+}
+
+func (d *outlierDetector) start() {
+	go func() {
+		ticker := time.NewTicker(d.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				d.onInterval()
+			case <-d.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (d *outlierDetector) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopChan)
+	})
+}
+
+func (d *outlierDetector) AddChangedStateCb(cb func(host types.Host)) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.changedStateCbs = append(d.changedStateCbs, cb)
+}
+
+func (d *outlierDetector) SuccessRateAverage() float64 {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
+	return d.successRateAverage
+}
+
+func (d *outlierDetector) SuccessRateEjectionThreshold() float64 {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
+	return d.successRateEjectionThreshold
+}
+
+func (d *outlierDetector) addHosts(hosts []types.Host) {
+	if len(hosts) == 0 {
+		return
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	for _, host := range hosts {
+		if _, ok := d.hostMonitors[host]; ok {
+			continue
+		}
+
+		monitor := newOutlierHostMonitor(d, host)
+		d.hostMonitors[host] = monitor
+		host.SetOutlierDetector(monitor)
+	}
+}
+
+func (d *outlierDetector) removeHosts(hosts []types.Host) {
+	if len(hosts) == 0 {
+		return
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	for _, host := range hosts {
+		if _, ok := d.hostMonitors[host]; !ok {
+			continue
+		}
+
+		if host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+			d.ejectedHosts--
+			d.stats.OutlierDetectionEjectionsActive.Dec(1)
+		}
+
+		delete(d.hostMonitors, host)
+	}
+}
+
+func (d *outlierDetector) hostsCount() int {
+	count := 0
+
+	for _, hostSet := range d.prioritySet.HostSetsByPriority() {
+		count += len(hostSet.Hosts())
+	}
+
+	return count
+}
+
+func (d *outlierDetector) ejectionDuration(numEjections uint32) time.Duration {
+	shift := numEjections - 1
+
+	if numEjections == 0 {
+		shift = 0
+	} else if shift > maxEjectionTimeShift {
+		shift = maxEjectionTimeShift
+	}
+
+	return d.config.BaseEjectionTime << shift
+}
+
+// called by host monitor when a consecutive failure threshold is reached
+func (d *outlierDetector) onConsecutiveFailure(monitor *outlierHostMonitor, outlierType types.OutlierType) {
+	var enforcing uint32
+
+	switch outlierType {
+	case types.OutlierConsecutive5xx:
+		enforcing = d.config.EnforcingConsecutive5xx
+	case types.OutlierConsecutiveGatewayFailure:
+		enforcing = d.config.EnforcingConsecutiveGatewayFailure
+	}
+
+	d.mux.Lock()
+	ejected := d.ejectHost(monitor, outlierType, enforcing)
+	d.mux.Unlock()
+
+	if ejected {
+		d.runCallbacks(monitor.host)
+	}
+}
+
+// ejectHost must be called with d.mux held
+func (d *outlierDetector) ejectHost(monitor *outlierHostMonitor, outlierType types.OutlierType, enforcing uint32) bool {
+	host := monitor.host
+
+	if host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		return false
+	}
+
+	if _, ok := d.hostMonitors[host]; !ok {
+		return false
+	}
+
+	if float64(d.ejectedHosts)*100 >= float64(d.config.MaxEjectionPercent)*float64(d.hostsCount()) {
+		d.stats.OutlierDetectionEjectionsOverflow.Inc(1)
+		return false
+	}
+
+	if enforcing < 100 && uint32(rand.Intn(100)) >= enforcing {
+		return false
+	}
+
+	host.SetHealthFlag(types.FAILED_OUTLIER_CHECK)
+	d.ejectedHosts++
+	monitor.eject(time.Now())
+
+	d.stats.OutlierDetectionEjectionsTotal.Inc(1)
+	d.stats.OutlierDetectionEjectionsActive.Inc(1)
+
+	switch outlierType {
+	case types.OutlierConsecutive5xx:
+		d.stats.OutlierDetectionEjectionsConsecutive5xx.Inc(1)
+	case types.OutlierConsecutiveGatewayFailure:
+		d.stats.OutlierDetectionEjectionsGatewayFailure.Inc(1)
+	case types.OutlierSuccessRate:
+		d.stats.OutlierDetectionEjectionsSuccessRate.Inc(1)
+	}
+
+	log.DefaultLogger.Infof("outlier detection: host %s ejected, type = %s, ejections = %d",
+		host.AddressString(), outlierType, monitor.NumEjections())
+
+	return true
+}
+
+func (d *outlierDetector) runCallbacks(host types.Host) {
+	d.mux.RLock()
+	cbs := d.changedStateCbs
+	d.mux.RUnlock()
+
+	for _, cb := range cbs {
+		cb(host)
+	}
+}
+
+// onInterval unejects the hosts whose ejection time is over, and runs success rate ejection
+func (d *outlierDetector) onInterval() {
+	now := time.Now()
+
+	var changed []types.Host
+	var validMonitors []*outlierHostMonitor
+	var successRates []float64
+
+	d.mux.Lock()
+
+	for host, monitor := range d.hostMonitors {
+		if host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+			if now.Sub(monitor.LastEjectionTime()) >= d.ejectionDuration(monitor.NumEjections()) {
+				host.ClearHealthFlag(types.FAILED_OUTLIER_CHECK)
+				d.ejectedHosts--
+				monitor.uneject(now)
+				d.stats.OutlierDetectionEjectionsActive.Dec(1)
+				changed = append(changed, host)
+
+				log.DefaultLogger.Infof("outlier detection: host %s unejected", host.AddressString())
+			}
+		} else {
+			monitor.decreaseEjections()
+		}
+
+		if rate, ok := monitor.updateSuccessRate(d.config.SuccessRateRequestVolume); ok {
+			validMonitors = append(validMonitors, monitor)
+			successRates = append(successRates, rate)
+		}
+	}
+
+	d.successRateAverage = -1
+	d.successRateEjectionThreshold = -1
+
+	if len(validMonitors) > 0 && uint32(len(validMonitors)) >= d.config.SuccessRateMinimumHosts {
+		mean, stdev := meanAndStdev(successRates)
+		threshold := mean - stdev*float64(d.config.SuccessRateStdevFactor)/1000
+
+		d.successRateAverage = mean
+		d.successRateEjectionThreshold = threshold
+
+		for i, monitor := range validMonitors {
+			if successRates[i] < threshold &&
+				d.ejectHost(monitor, types.OutlierSuccessRate, d.config.EnforcingSuccessRate) {
+				changed = append(changed, monitor.host)
+			}
+		}
+	}
+
+	d.mux.Unlock()
+
+	for _, host := range changed {
+		d.runCallbacks(host)
+	}
+}
+
+func meanAndStdev(values []float64) (float64, float64) {
+	var sum, variance float64
+
+	for _, v := range values {
+		sum += v
+	}
+
+	mean := sum / float64(len(values))
+
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+
+	variance /= float64(len(values))
+
+	return mean, math.Sqrt(variance)
+}
+
+// types.DetectorHostMonitor
+type outlierHostMonitor struct {
+	detector *outlierDetector
+	host     types.Host
+
+	consecutive5xx            uint32
+	consecutiveGatewayFailure uint32
+
+	// request counters of the current interval, used by success rate ejection
+	requestTotal   uint64
+	requestSuccess uint64
+
+	mux                sync.RWMutex
+	numEjections       uint32
+	lastEjectionTime   time.Time
+	lastUnejectionTime time.Time
+	successRate        float64
+}
+
+func newOutlierHostMonitor(detector *outlierDetector, host types.Host) *outlierHostMonitor {
+	return &outlierHostMonitor{
+		detector:    detector,
+		host:        host,
+		successRate: -1,
+	}
+}
+
+func (m *outlierHostMonitor) PutResult(result types.OutlierResult) {
+	atomic.AddUint64(&m.requestTotal, 1)
+
+	switch result {
+	case types.OutlierResultSuccess:
+		atomic.AddUint64(&m.requestSuccess, 1)
+		atomic.StoreUint32(&m.consecutive5xx, 0)
+		atomic.StoreUint32(&m.consecutiveGatewayFailure, 0)
+
+		return
+	case types.OutlierResultServerError:
+		atomic.StoreUint32(&m.consecutiveGatewayFailure, 0)
+	default:
+		// gateway failure, connect failure, reset and timeout are all treated as gateway failures
+		if atomic.AddUint32(&m.consecutiveGatewayFailure, 1) == m.detector.config.ConsecutiveGatewayFailure {
+			m.detector.onConsecutiveFailure(m, types.OutlierConsecutiveGatewayFailure)
+		}
+	}
+
+	// gateway failures are also counted as 5xx
+	if atomic.AddUint32(&m.consecutive5xx, 1) == m.detector.config.Consecutive5xx {
+		m.detector.onConsecutiveFailure(m, types.OutlierConsecutive5xx)
+	}
+}
+
+func (m *outlierHostMonitor) PutResponseTime(duration time.Duration) {}
+
+func (m *outlierHostMonitor) NumEjections() uint32 {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.numEjections
+}
+
+func (m *outlierHostMonitor) LastEjectionTime() time.Time {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.lastEjectionTime
+}
+
+func (m *outlierHostMonitor) LastUnejectionTime() time.Time {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.lastUnejectionTime
+}
+
+func (m *outlierHostMonitor) SuccessRate() float64 {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.successRate
+}
+
+func (m *outlierHostMonitor) eject(now time.Time) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.numEjections++
+	m.lastEjectionTime = now
+}
+
+func (m *outlierHostMonitor) uneject(now time.Time) {
+	atomic.StoreUint32(&m.consecutive5xx, 0)
+	atomic.StoreUint32(&m.consecutiveGatewayFailure, 0)
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.lastUnejectionTime = now
+}
+
+// a host which stays healthy in an interval gets its ejection time backing off
+func (m *outlierHostMonitor) decreaseEjections() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if m.numEjections > 0 {
+		m.numEjections--
+	}
+}
+
+// updateSuccessRate calculates the success rate of the last interval and resets the counters,
+// returns false if the request volume is not enough
+func (m *outlierHostMonitor) updateSuccessRate(requestVolume uint32) (float64, bool) {
+	total := atomic.SwapUint64(&m.requestTotal, 0)
+	success := atomic.SwapUint64(&m.requestSuccess, 0)
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if total == 0 || total < uint64(requestVolume) {
+		m.successRate = -1
+		return 0, false
+	}
+
+	m.successRate = float64(success) * 100 / float64(total)
+
+	return m.successRate, true
+}
@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+func newOutlierTestCluster(t *testing.T, name string, hostNum int, config v2.OutlierDetection) (*simpleInMemCluster, []types.Host) {
+	c := NewCluster(v2.Cluster{
+		Name:             name,
+		ClusterType:      v2.SIMPLE_CLUSTER,
+		LbType:           v2.LB_ROUNDROBIN,
+		OutlierDetection: config,
+	}, nil, true).(*simpleInMemCluster)
+
+	var hosts []types.Host
+	for i := 0; i < hostNum; i++ {
+		hosts = append(hosts, NewHost(v2.Host{Address: fmt.Sprintf("127.0.0.1:%d", 10000+i)}, c.Info()))
+	}
+	c.UpdateHosts(hosts)
+
+	if c.OutlierDetector() == nil {
+		t.Fatalf("outlier detector should be created")
+	}
+
+	return c, hosts
+}
+
+func Test_outlierDetector_Consecutive5xx(t *testing.T) {
+	c, hosts := newOutlierTestCluster(t, "outlier_5xx", 4, v2.OutlierDetection{
+		Consecutive5xx:     3,
+		Interval:           time.Hour,
+		BaseEjectionTime:   10 * time.Millisecond,
+		MaxEjectionPercent: 50,
+	})
+	defer c.OutlierDetector().Stop()
+
+	host := hosts[0]
+	host.OutlierDetector().PutResult(types.OutlierResultServerError)
+	host.OutlierDetector().PutResult(types.OutlierResultServerError)
+	host.OutlierDetector().PutResult(types.OutlierResultSuccess)
+	host.OutlierDetector().PutResult(types.OutlierResultServerError)
+	host.OutlierDetector().PutResult(types.OutlierResultServerError)
+
+	if host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		t.Fatalf("host should not be ejected, failures are not consecutive")
+	}
+
+	host.OutlierDetector().PutResult(types.OutlierResultTimeout)
+
+	if !host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		t.Fatalf("host should be ejected after 3 consecutive 5xx")
+	}
+
+	if containsHost(c.PrioritySet().HostSetsByPriority()[0].HealthyHosts(), host) {
+		t.Errorf("ejected host should be removed from healthy hosts")
+	}
+
+	if len(c.PrioritySet().HostSetsByPriority()[0].Hosts()) != 4 {
+		t.Errorf("ejected host should not be removed from hosts")
+	}
+
+	for i := 0; i < 8; i++ {
+		if got := c.Info().LBInstance().ChooseHost(nil); got == host {
+			t.Errorf("load balancer should not choose ejected host")
+		}
+	}
+
+	if c.Info().Stats().OutlierDetectionEjectionsConsecutive5xx.Count() != 1 ||
+		c.Info().Stats().OutlierDetectionEjectionsActive.Count() != 1 {
+		t.Errorf("outlier detection stats error")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	c.outlierDetector.onInterval()
+
+	if host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		t.Fatalf("host should be unejected after base ejection time")
+	}
+
+	if !containsHost(c.PrioritySet().HostSetsByPriority()[0].HealthyHosts(), host) {
+		t.Errorf("unejected host should be added back to healthy hosts")
+	}
+
+	if c.Info().Stats().OutlierDetectionEjectionsActive.Count() != 0 {
+		t.Errorf("outlier detection active ejections stats error")
+	}
+}
+
+func Test_outlierDetector_MaxEjectionPercent(t *testing.T) {
+	c, hosts := newOutlierTestCluster(t, "outlier_max_percent", 4, v2.OutlierDetection{
+		ConsecutiveGatewayFailure:          1,
+		EnforcingConsecutiveGatewayFailure: 100,
+		Interval:                           time.Hour,
+		MaxEjectionPercent:                 25,
+	})
+	defer c.OutlierDetector().Stop()
+
+	hosts[0].OutlierDetector().PutResult(types.OutlierResultConnectFailed)
+	hosts[1].OutlierDetector().PutResult(types.OutlierResultGatewayFailure)
+
+	if !hosts[0].ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		t.Errorf("host should be ejected on gateway failure")
+	}
+
+	if hosts[1].ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		t.Errorf("host should not be ejected over max ejection percent")
+	}
+
+	if c.Info().Stats().OutlierDetectionEjectionsOverflow.Count() != 1 {
+		t.Errorf("outlier detection overflow stats error")
+	}
+}
+
+func Test_outlierDetector_SuccessRate(t *testing.T) {
+	c, hosts := newOutlierTestCluster(t, "outlier_success_rate", 5, v2.OutlierDetection{
+		Interval:                 time.Hour,
+		MaxEjectionPercent:       100,
+		SuccessRateMinimumHosts:  5,
+		SuccessRateRequestVolume: 10,
+		SuccessRateStdevFactor:   1000,
+	})
+	defer c.OutlierDetector().Stop()
+
+	for i, host := range hosts {
+		for j := 0; j < 10; j++ {
+			if i == 0 && j%2 == 0 {
+				host.OutlierDetector().PutResult(types.OutlierResultServerError)
+			} else {
+				host.OutlierDetector().PutResult(types.OutlierResultSuccess)
+			}
+		}
+	}
+
+	c.outlierDetector.onInterval()
+
+	if c.OutlierDetector().SuccessRateAverage() != 90 {
+		t.Errorf("success rate average should be 90, got %f", c.OutlierDetector().SuccessRateAverage())
+	}
+
+	if c.OutlierDetector().SuccessRateEjectionThreshold() != 70 {
+		t.Errorf("success rate threshold should be 70, got %f", c.OutlierDetector().SuccessRateEjectionThreshold())
+	}
+
+	if hosts[0].OutlierDetector().SuccessRate() != 50 {
+		t.Errorf("host success rate should be 50, got %f", hosts[0].OutlierDetector().SuccessRate())
+	}
+
+	for i, host := range hosts {
+		if ejected := host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK); ejected != (i == 0) {
+			t.Errorf("host %d ejected state error, got %v", i, ejected)
+		}
+	}
+
+	if c.Info().Stats().OutlierDetectionEjectionsSuccessRate.Count() != 1 {
+		t.Errorf("outlier detection success rate stats error")
+	}
+}
+
+func Test_outlierDetector_EjectionDuration(t *testing.T) {
+	d := &outlierDetector{
+		config: outlierDetectionWithDefault(v2.OutlierDetection{BaseEjectionTime: time.Second}),
+	}
+
+	cases := map[uint32]time.Duration{
+		0:  time.Second,
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		11: 1024 * time.Second,
+		20: 1024 * time.Second,
+	}
+
+	for num, want := range cases {
+		if got := d.ejectionDuration(num); got != want {
+			t.Errorf("ejection duration of %d ejections should be %s, got %s", num, want, got)
+		}
+	}
+}
@@ -37,7 +37,7 @@ type resourcemanager struct {
 	connections     *resource
 	pendingRequests *resource
 	requests        *resource
-	retries         *resource
+	retries         types.Resource
 }
 
 func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManager {
@@ -45,6 +45,7 @@ func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManage
 	maxPendingRequests := DefaultMaxPendingRequests
 	maxRequests := DefaultMaxRequests
 	maxRetries := DefaultMaxRetries
+	var retryBudget *v2.RetryBudget
 
 	// note: we dont support group cb by priority
 	if circuitBreakers.Thresholds != nil && len(circuitBreakers.Thresholds) > 0 {
@@ -52,22 +53,31 @@ func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManage
 		maxPendingRequests = uint64(circuitBreakers.Thresholds[0].MaxPendingRequests)
 		maxRequests = uint64(circuitBreakers.Thresholds[0].MaxRequests)
 		maxRetries = uint64(circuitBreakers.Thresholds[0].MaxRetries)
+		retryBudget = circuitBreakers.Thresholds[0].RetryBudget
 	}
 
-	return &resourcemanager{
+	requests := &resource{
+		max: maxRequests,
+	}
+
+	rm := &resourcemanager{
 		connections: &resource{
 			max: maxConnections,
 		},
 		pendingRequests: &resource{
 			max: maxPendingRequests,
 		},
-		requests: &resource{
-			max: maxRequests,
-		},
+		requests: requests,
 		retries: &resource{
 			max: maxRetries,
 		},
 	}
+
+	if retryBudget != nil {
+		rm.retries = newRetryBudgetResource(retryBudget, requests)
+	}
+
+	return rm
 }
 
 func (rm *resourcemanager) Connections() types.Resource {
@@ -113,3 +123,45 @@ func (r *resource) Decrease() {
 func (r *resource) Max() uint64 {
 	return r.max
 }
+
+// retryBudgetResource limits concurrent retries to a percentage of active requests,
+// at least MinRetryConcurrency retries are allowed
+type retryBudgetResource struct {
+	resource
+	budgetPercent       float64
+	minRetryConcurrency uint64
+	requests            *resource
+}
+
+func newRetryBudgetResource(budget *v2.RetryBudget, requests *resource) *retryBudgetResource {
+	return &retryBudgetResource{
+		budgetPercent:       budget.BudgetPercent,
+		minRetryConcurrency: uint64(budget.MinRetryConcurrency),
+		requests:            requests,
+	}
+}
+
+func (r *retryBudgetResource) CanCreate() bool {
+	curValue := atomic.LoadInt64(&r.current)
+
+	if curValue < 0 {
+		return true
+	}
+
+	return uint64(curValue) < r.Max()
+}
+
+// Max returns the retries allowed by the budget of current active requests
+func (r *retryBudgetResource) Max() uint64 {
+	active := atomic.LoadInt64(&r.requests.current)
+	if active < 0 {
+		active = 0
+	}
+
+	max := uint64(float64(active) * r.budgetPercent / 100)
+	if max < r.minRetryConcurrency {
+		max = r.minRetryConcurrency
+	}
+
+	return max
+}
@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+)
+
+func TestResourceManager_MaxRetries(t *testing.T) {
+	rm := NewResourceManager(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{{MaxRetries: 2}},
+	})
+
+	retries := rm.Retries()
+	for i := 0; i < 2; i++ {
+		if !retries.CanCreate() {
+			t.Fatalf("retry %d should be allowed", i)
+		}
+		retries.Increase()
+	}
+
+	if retries.CanCreate() {
+		t.Errorf("retries should be limited by max retries")
+	}
+}
+
+func TestResourceManager_RetryBudget(t *testing.T) {
+	rm := NewResourceManager(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{{
+			MaxRequests: 1000,
+			MaxRetries:  1,
+			RetryBudget: &v2.RetryBudget{
+				BudgetPercent:       20,
+				MinRetryConcurrency: 3,
+			},
+		}},
+	})
+
+	requests, retries := rm.Requests(), rm.Retries()
+
+	// min retry concurrency is allowed without active requests
+	if retries.Max() != 3 {
+		t.Errorf("want min retry concurrency 3, got %d", retries.Max())
+	}
+
+	for i := 0; i < 100; i++ {
+		requests.Increase()
+	}
+
+	if retries.Max() != 20 {
+		t.Errorf("want 20 retries of 100 active requests, got %d", retries.Max())
+	}
+
+	for i := 0; i < 20; i++ {
+		if !retries.CanCreate() {
+			t.Fatalf("retry %d should be allowed by the budget", i)
+		}
+		retries.Increase()
+	}
+
+	if retries.CanCreate() {
+		t.Errorf("retries should be limited by the budget")
+	}
+
+	// budget shrinks with active requests
+	for i := 0; i < 50; i++ {
+		requests.Decrease()
+	}
+	retries.Decrease()
+
+	if retries.CanCreate() {
+		t.Errorf("retries should be limited by the shrunk budget")
+	}
+}
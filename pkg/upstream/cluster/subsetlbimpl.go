@@ -75,6 +75,12 @@ func (sslb *subSetLoadBalancer) Update(priority uint32, hostAdded []types.Host,
 	// step1. create or update fallback subset
 	sslb.UpdateFallbackSubset(priority, hostAdded, hostsRemoved)
 
+	// no member changed, only hosts' health state changed, refresh healthy hosts in all subsets
+	if len(hostAdded) == 0 && len(hostsRemoved) == 0 {
+		sslb.refreshSubsets(sslb.subSets, priority)
+		return
+	}
+
 	// step2. create or update global subset
 	sslb.ProcessSubsets(hostAdded, hostsRemoved,
 		func(entry types.LBSubsetEntry) {
@@ -101,6 +107,19 @@ func (sslb *subSetLoadBalancer) Update(priority uint32, hostAdded []types.Host,
 		})
 }
 
+// refresh healthy hosts in subsets recursively
+func (sslb *subSetLoadBalancer) refreshSubsets(subsets types.LbSubsetMap, priority uint32) {
+	for _, vsMap := range subsets {
+		for _, entry := range vsMap {
+			if entry.Initialized() {
+				entry.PrioritySubset().Update(priority, nil, nil)
+			}
+
+			sslb.refreshSubsets(entry.Children(), priority)
+		}
+	}
+}
+
 // SubSet LB Entry
 func (sslb *subSetLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
 
@@ -481,12 +500,7 @@ func NewPrioritySubsetImpl(subsetLB *subSetLoadBalancer, predicate types.HostPre
 		psi.Update(i, subsetLB.originalPrioritySet.HostSetsByPriority()[i].Hosts(), []types.Host{})
 	}
 
-	switch subsetLB.lbType {
-	case types.Random:
-		psi.loadbalancer = newRandomLoadbalancer(psi.prioritySubset)
-	case types.RoundRobin:
-		psi.loadbalancer = newRoundRobinLoadBalancer(psi.prioritySubset)
-	}
+	psi.loadbalancer = NewLoadBalancer(subsetLB.lbType, psi.prioritySubset)
 
 	return psi
 }
@@ -472,6 +472,46 @@ func Test_subSetLoadBalancer_ChooseHost(t *testing.T) {
 	}
 }
 
+func Test_subSetLoadBalancer_ChooseHostWithInnerLbType(t *testing.T) {
+	hostSet := InitExampleHosts()
+
+	// version=1.1 (e3, e4, e6)
+	context := &ContextImplMock{
+		mmc: &router.MetadataMatchCriteriaImpl{
+			MatchCriteriaArray: []types.MetadataMatchCriterion{
+				&router.MetadataMatchCriterionImpl{
+					"version",
+					types.GenerateHashedValue("1.1"),
+				},
+			},
+		},
+	}
+
+	want := map[string]bool{
+		hostSet[2].AddressString(): true,
+		hostSet[3].AddressString(): true,
+		hostSet[5].AddressString(): true,
+	}
+
+	for _, lbType := range []types.LoadBalancerType{types.WeightedRoundRobin, types.LeastRequest} {
+		sslb := NewSubsetLoadBalancer(lbType, &prioritySetExample,
+			newClusterStats(v2.Cluster{Name: "testcluster"}), NewLBSubsetInfo(InitExampleLbSubsetConfig()))
+
+		chosen := make(map[string]bool)
+		for i := 0; i < 30; i++ {
+			got := sslb.ChooseHost(context)
+			if got == nil || !want[got.AddressString()] {
+				t.Fatalf("%s subSetLoadBalancer.ChooseHost() = %v, not in subset", lbType, got)
+			}
+			chosen[got.AddressString()] = true
+		}
+
+		if lbType == types.WeightedRoundRobin && len(chosen) != len(want) {
+			t.Errorf("%s subSetLoadBalancer.ChooseHost() should choose all hosts in subset, got %v", lbType, chosen)
+		}
+	}
+}
+
 // passed
 func TestGenerateSubsetKeys(t *testing.T) {
 	type args struct {
@@ -685,12 +725,13 @@ func InitExampleHosts() []types.Host {
 }
 
 type ContextImplMock struct {
-	mmc *router.MetadataMatchCriteriaImpl
+	mmc          *router.MetadataMatchCriteriaImpl
+	hashKey      types.HashedValue
+	rejectedHost types.Host
 }
 
 func (ci *ContextImplMock) ComputeHashKey() types.HashedValue {
-	//return [16]byte{}
-	return ""
+	return ci.hashKey
 }
 
 func (ci *ContextImplMock) MetadataMatchCriteria() types.MetadataMatchCriteria {
@@ -704,3 +745,7 @@ func (ci *ContextImplMock) DownstreamConnection() net.Conn {
 func (ci *ContextImplMock) DownstreamHeaders() map[string]string {
 	return nil
 }
+
+func (ci *ContextImplMock) ShouldSelectAnotherHost(host types.Host) bool {
+	return host == ci.rejectedHost
+}
@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/network/buffer"
+	"github.com/alipay/sofa-mosn/pkg/protocol"
+	"github.com/alipay/sofa-mosn/pkg/protocol/dubbo"
+	"github.com/alipay/sofa-mosn/pkg/stream"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// dubboHealthChecker checks hosts by dubbo heartbeat
+type dubboHealthChecker struct {
+	*healthChecker
+}
+
+func newDubboHealthChecker(config v2.HealthCheck) *dubboHealthChecker {
+	hc := newHealthChecker(config)
+
+	dhc := &dubboHealthChecker{
+		healthChecker: hc,
+	}
+
+	dhc.sessionFactory = dhc
+
+	return dhc
+}
+
+func (c *dubboHealthChecker) newSession(host types.Host) types.HealthCheckSession {
+	dhcs := &dubboHealthCheckSession{
+		healthChecker:      c,
+		healthCheckSession: *newHealthCheckSession(c.healthChecker, host),
+		responseStatus:     -1,
+	}
+	// add timer to trigger hb sending and timeout handling
+	dhcs.intervalTimer = newTimer(dhcs.onInterval)
+	dhcs.timeoutTimer = newTimer(dhcs.onTimeout)
+
+	return dhcs
+}
+
+func (c *dubboHealthChecker) createCodecClient(data types.CreateConnectionData) stream.CodecClient {
+	return stream.NewCodecClient(context.Background(), protocol.Dubbo, data.Connection, data.HostInfo)
+}
+
+// types.StreamReceiver
+type dubboHealthCheckSession struct {
+	healthCheckSession
+
+	client         stream.CodecClient
+	requestSender  types.StreamSender
+	responseStatus int16
+	healthChecker  *dubboHealthChecker
+	expectReset    bool
+}
+
+func (s *dubboHealthCheckSession) OnReceiveHeaders(headers map[string]string, endStream bool) {
+	if endStream {
+		s.onResponseComplete()
+	}
+}
+
+func (s *dubboHealthCheckSession) OnReceiveData(data types.IoBuffer, endStream bool) {
+	if frame := data.Bytes(); dubbo.IsHeartbeat(frame) {
+		s.responseStatus = int16(dubbo.ResponseStatus(frame))
+	}
+
+	if endStream {
+		s.onResponseComplete()
+	}
+}
+
+func (s *dubboHealthCheckSession) OnReceiveTrailers(trailers map[string]string) {
+	s.onResponseComplete()
+}
+
+func (s *dubboHealthCheckSession) OnDecodeError(err error, headers map[string]string) {
+}
+
+// overload healthCheckSession
+func (s *dubboHealthCheckSession) Start() {
+	// start interval timer
+	s.onInterval()
+}
+
+// overload healthCheckSession, the connection to host is closed on stop
+func (s *dubboHealthCheckSession) Stop() {
+	s.healthCheckSession.Stop()
+
+	if s.client != nil {
+		s.expectReset = true
+		s.client.Close()
+	}
+}
+
+func (s *dubboHealthCheckSession) onInterval() {
+	if s.client == nil {
+		connData := s.host.CreateConnection(nil)
+
+		if err := connData.Connection.Connect(true); err != nil {
+			s.handleFailure(types.FailureActive)
+			log.DefaultLogger.Debugf("For health check, Connect Error!")
+			return
+		}
+
+		s.client = s.healthChecker.createCodecClient(connData)
+
+		s.expectReset = false
+	}
+
+	id := rand.Uint32()
+	reqID := strconv.Itoa(int(id))
+
+	s.requestSender = s.client.NewStream(reqID, s)
+	s.requestSender.GetStream().AddEventListener(s)
+
+	// the request id is reassigned by the stream layer
+	s.requestSender.AppendData(buffer.NewIoBufferBytes(dubbo.NewHeartbeat(uint64(id))), true)
+	log.DefaultLogger.Debugf("DubboHealthCheck Sending Heart Beat to %s", s.host.AddressString())
+	s.requestSender = nil
+	// start timeout interval
+	s.healthCheckSession.onInterval()
+}
+
+func (s *dubboHealthCheckSession) onTimeout() {
+	s.expectReset = true
+	s.client.Close()
+	s.client = nil
+
+	log.DefaultLogger.Errorf("Health Check Timeout for Remote Host = %s", s.host.AddressString())
+	// deal with timeout event
+	s.healthCheckSession.onTimeout()
+}
+
+func (s *dubboHealthCheckSession) onResponseComplete() {
+	if s.isHealthCheckSucceeded() {
+		s.handleSuccess()
+	} else {
+		s.handleFailure(types.FailureActive)
+	}
+	s.responseStatus = -1
+}
+
+func (s *dubboHealthCheckSession) isHealthCheckSucceeded() bool {
+	return s.responseStatus == int16(dubbo.ResponseStatusOK)
+}
+
+func (s *dubboHealthCheckSession) OnResetStream(reason types.StreamResetReason) {
+	if s.expectReset {
+		return
+	}
+
+	s.handleFailure(types.FailureNetwork)
+}
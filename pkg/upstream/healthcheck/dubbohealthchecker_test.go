@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/protocol/dubbo"
+	"github.com/alipay/sofa-mosn/pkg/protocol/xprotocol/subprotocol"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/alipay/sofa-mosn/pkg/upstream/cluster"
+)
+
+// serveDubboHeartbeat answers heartbeats with the status
+func serveDubboHeartbeat(t *testing.T, status byte) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				codec := subprotocol.CreateSubProtocolCodec(nil, dubbo.SubProtocol)
+				var data []byte
+				buf := make([]byte, 1024)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					data = append(data, buf[:n]...)
+					frames, _ := codec.SplitFrame(data)
+					for _, frame := range frames {
+						data = data[len(frame):]
+						resp := make([]byte, len(frame))
+						copy(resp, frame)
+						resp[2] = dubbo.FlagEvent | dubbo.SerializationHessian2
+						resp[3] = status
+						conn.Write(resp)
+					}
+				}
+			}()
+		}
+	}()
+
+	return l
+}
+
+func newDubboHealthCheckEnv(t *testing.T, name string, status byte) (*dubboHealthChecker, types.Host, func()) {
+	log.InitDefaultLogger("", log.INFO)
+
+	l := serveDubboHeartbeat(t, status)
+
+	hc := newDubboHealthChecker(v2.HealthCheck{
+		Protocol:           "Dubbo",
+		ServiceName:        name,
+		Timeout:            time.Second,
+		Interval:           100 * time.Millisecond,
+		HealthyThreshold:   1,
+		UnhealthyThreshold: 1,
+	})
+
+	c := cluster.NewCluster(v2.Cluster{
+		Name:        name,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	}, nil, true)
+	hc.SetCluster(c)
+
+	host := cluster.NewHost(v2.Host{
+		Address: l.Addr().String(),
+	}, c.Info())
+
+	s := hc.newSession(host)
+	s.Start()
+
+	return hc, host, func() {
+		s.Stop()
+		l.Close()
+	}
+}
+
+func Test_dubboHealthChecker_Success(t *testing.T) {
+	hc, host, stop := newDubboHealthCheckEnv(t, "dubbo_hc_success.", dubbo.ResponseStatusOK)
+	defer stop()
+
+	time.Sleep(time.Second)
+
+	if hc.stats.success.Count() == 0 {
+		t.Fatal("expected successful heartbeats")
+	}
+	if hc.stats.failure.Count() != 0 {
+		t.Errorf("expected no failure, got %d", hc.stats.failure.Count())
+	}
+	if host.ContainHealthFlag(types.FAILED_ACTIVE_HC) {
+		t.Error("expected host healthy")
+	}
+}
+
+func Test_dubboHealthChecker_Failure(t *testing.T) {
+	hc, host, stop := newDubboHealthCheckEnv(t, "dubbo_hc_failure.", dubbo.ResponseStatusServerError)
+	defer stop()
+
+	time.Sleep(time.Second)
+
+	if hc.stats.failure.Count() == 0 {
+		t.Fatal("expected failed heartbeats")
+	}
+	if !host.ContainHealthFlag(types.FAILED_ACTIVE_HC) {
+		t.Error("expected host unhealthy")
+	}
+}
@@ -37,6 +37,8 @@ func (nhc *healthCheckerFactory) New(config v2.HealthCheck) types.HealthChecker
 		return newSofaRPCHealthChecker(config)
 	case string(protocol.HTTP2):
 		return newHTTPHealthCheck(config)
+	case string(protocol.Dubbo):
+		return newDubboHealthChecker(config)
 	default:
 		// todo: http1
 		return nil
@@ -18,7 +18,10 @@
 package healthcheck
 
 import (
+	"fmt"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/api/v2"
@@ -37,6 +40,8 @@ type healthChecker struct {
 	healthCheckCbs      []types.HealthCheckCb
 	cluster             types.Cluster
 	healthCheckSessions map[types.Host]types.HealthCheckSession
+	sessionsMux         sync.Mutex
+	stopped             bool
 
 	timeout        time.Duration
 	interval       time.Duration
@@ -59,7 +64,7 @@ func newHealthChecker(config v2.HealthCheck) *healthChecker {
 		intervalJitter:      config.IntervalJitter,
 		healthyThreshold:    config.HealthyThreshold,
 		unhealthyThreshold:  config.UnhealthyThreshold,
-		stats:               newHealthCheckStats(config.ServiceName),
+		stats:               newHealthCheckStats(fmt.Sprintf(types.HealthCheckStatsPrefix, config.ServiceName)),
 	}
 
 	if config.ServiceName != "" {
@@ -80,7 +85,15 @@ func (c *healthChecker) Start() {
 }
 
 func (c *healthChecker) Stop() {
-	// todo
+	c.sessionsMux.Lock()
+	defer c.sessionsMux.Unlock()
+
+	c.stopped = true
+
+	for host, session := range c.healthCheckSessions {
+		session.Stop()
+		delete(c.healthCheckSessions, host)
+	}
 }
 
 func (c *healthChecker) AddHostCheckCompleteCb(cb types.HealthCheckCb) {
@@ -110,13 +123,30 @@ func (c *healthChecker) addHosts(hosts []types.Host) {
 				return
 			}
 
+			c.sessionsMux.Lock()
+			if c.stopped {
+				c.sessionsMux.Unlock()
+				return
+			}
 			c.healthCheckSessions[h] = ns
-			c.healthCheckSessions[h].Start()
+			c.sessionsMux.Unlock()
+
+			ns.Start()
 		}()
 	}
 }
 
-func (c *healthChecker) delHosts(hosts []types.Host) {}
+func (c *healthChecker) delHosts(hosts []types.Host) {
+	c.sessionsMux.Lock()
+	defer c.sessionsMux.Unlock()
+
+	for _, host := range hosts {
+		if session, ok := c.healthCheckSessions[host]; ok {
+			session.Stop()
+			delete(c.healthCheckSessions, host)
+		}
+	}
+}
 
 func (c *healthChecker) OnClusterMemberUpdate(hostsAdded []types.Host, hostDel []types.Host) {
 	c.addHosts(hostsAdded)
@@ -194,6 +224,7 @@ type healthCheckSession struct {
 	numHealthy   uint32
 	numUnHealthy uint32
 	host         types.Host
+	stopped      uint32
 }
 
 func newHealthCheckSession(hc *healthChecker, host types.Host) *healthCheckSession {
@@ -213,9 +244,23 @@ func (s *healthCheckSession) Start() {
 	s.onInterval()
 }
 
-//// stop intervalTimer to stop sending health message
+// stop the timers to stop sending health message
 func (s *healthCheckSession) Stop() {
+	if !atomic.CompareAndSwapUint32(&s.stopped, 0, 1) {
+		return
+	}
+
 	s.intervalTimer.stop()
+	s.timeoutTimer.stop()
+}
+
+// start a new interval timer unless the session is stopped
+func (s *healthCheckSession) startIntervalTimer() {
+	if atomic.LoadUint32(&s.stopped) == 1 {
+		return
+	}
+
+	s.intervalTimer.start(s.healthChecker.getInterval())
 }
 
 func (s *healthCheckSession) handleSuccess() {
@@ -238,7 +283,7 @@ func (s *healthCheckSession) handleSuccess() {
 	// stop timeout timer
 	s.timeoutTimer.stop()
 	// start a new interval timer
-	s.intervalTimer.start(s.healthChecker.getInterval())
+	s.startIntervalTimer()
 }
 
 func (s *healthCheckSession) SetUnhealthy(fType types.FailureType) {
@@ -274,7 +319,7 @@ func (s *healthCheckSession) handleFailure(fType types.FailureType) {
 	s.timeoutTimer.stop()
 
 	// start a new interval timer
-	s.intervalTimer.start(s.healthChecker.getInterval())
+	s.startIntervalTimer()
 }
 
 func (s *healthCheckSession) onInterval() {
@@ -285,7 +330,7 @@ func (s *healthCheckSession) onInterval() {
 func (s *healthCheckSession) onTimeout() {
 	s.SetUnhealthy(types.FailureNetwork)
 	// sending another health check
-	s.intervalTimer.start(s.healthChecker.getInterval())
+	s.startIntervalTimer()
 }
 
 type healthCheckStats struct {
@@ -306,6 +351,6 @@ func newHealthCheckStats(namespace string) *healthCheckStats {
 		passiveFailure: metrics.GetOrRegisterCounter(namespace+"passiveFailure", nil),
 		networkFailure: metrics.GetOrRegisterCounter(namespace+"networkFailure", nil),
 		verifyCluster:  metrics.GetOrRegisterCounter(namespace+"verifyCluster", nil),
-		healthy:        metrics.GetOrRegisterGauge(namespace+"healty", nil),
+		healthy:        metrics.GetOrRegisterGauge(namespace+"healthy", nil),
 	}
 }
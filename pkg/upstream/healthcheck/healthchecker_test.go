@@ -35,7 +35,7 @@ const (
 )
 
 type mockHealthChecker struct {
-	healthChecker
+	*healthChecker
 
 	mode int // 1:success, 2:failed, 3: failed -> success, 4: success -> failed, 5: timeout
 }
@@ -44,7 +44,7 @@ func newMockHealthCheck(config v2.HealthCheck, mode int) types.HealthChecker {
 	hc := newHealthChecker(config)
 
 	mhc := &mockHealthChecker{
-		healthChecker: *hc,
+		healthChecker: hc,
 		mode:          mode,
 	}
 
@@ -56,7 +56,7 @@ func newMockHealthCheck(config v2.HealthCheck, mode int) types.HealthChecker {
 func (c *mockHealthChecker) newSession(host types.Host) types.HealthCheckSession {
 	shcs := &mockHealthCheckSession{
 		healthChecker:      c,
-		healthCheckSession: *newHealthCheckSession(&c.healthChecker, host),
+		healthCheckSession: *newHealthCheckSession(c.healthChecker, host),
 	}
 
 	// add timer to trigger hb sending and timeout handling
@@ -29,7 +29,7 @@ import (
 )
 
 type http2HealthChecker struct {
-	healthChecker
+	*healthChecker
 	checkPath   string
 	serviceName string
 }
@@ -38,11 +38,11 @@ func newHTTPHealthCheck(config v2.HealthCheck) types.HealthChecker {
 	hc := newHealthChecker(config)
 
 	hhc := &http2HealthChecker{
-		healthChecker: *hc,
+		healthChecker: hc,
 		checkPath:     config.CheckPath,
 	}
 
-	hhc.sessionFactory = hc
+	hhc.sessionFactory = hhc
 
 	return hhc
 }
@@ -50,7 +50,7 @@ func newHTTPHealthCheck(config v2.HealthCheck) types.HealthChecker {
 func (c *http2HealthChecker) newSession(host types.Host) types.HealthCheckSession {
 	hhcs := &http2HealthCheckSession{
 		healthChecker:      c,
-		healthCheckSession: *newHealthCheckSession(&c.healthChecker, host),
+		healthCheckSession: *newHealthCheckSession(c.healthChecker, host),
 	}
 
 	hhcs.intervalTimer = newTimer(hhcs.onInterval)
@@ -101,6 +101,16 @@ func (s *http2HealthCheckSession) Start() {
 	s.onInterval()
 }
 
+// overload healthCheckSession, the connection to host is closed on stop
+func (s *http2HealthCheckSession) Stop() {
+	s.healthCheckSession.Stop()
+
+	if s.client != nil {
+		s.expectReset = true
+		s.client.Close()
+	}
+}
+
 func (s *http2HealthCheckSession) onInterval() {
 	if s.client == nil {
 		connData := s.host.CreateConnection(nil)
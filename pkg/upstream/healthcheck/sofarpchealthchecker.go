@@ -32,7 +32,7 @@ import (
 )
 
 type sofarpcHealthChecker struct {
-	healthChecker
+	*healthChecker
 	//TODO set 'protocolCode' after service subscribe finished
 	protocolCode sofarpc.ProtocolType
 }
@@ -41,7 +41,7 @@ func newSofaRPCHealthChecker(config v2.HealthCheck) *sofarpcHealthChecker {
 	hc := newHealthChecker(config)
 
 	shc := &sofarpcHealthChecker{
-		healthChecker: *hc,
+		healthChecker: hc,
 	}
 
 	// use bolt v1 as default sofa health check protocol
@@ -56,7 +56,7 @@ func newSofaRPCHealthChecker(config v2.HealthCheck) *sofarpcHealthChecker {
 
 func newSofaRPCHealthCheckerWithBaseHealthChecker(hc *healthChecker, pro sofarpc.ProtocolType) *sofarpcHealthChecker {
 	shc := &sofarpcHealthChecker{
-		healthChecker: *hc,
+		healthChecker: hc,
 		protocolCode:  pro,
 	}
 
@@ -69,7 +69,7 @@ func (c *sofarpcHealthChecker) newSofaRPCHealthCheckSession(codecClinet stream.C
 	shcs := &sofarpcHealthCheckSession{
 		client:             codecClinet,
 		healthChecker:      c,
-		healthCheckSession: *newHealthCheckSession(&c.healthChecker, host),
+		healthCheckSession: *newHealthCheckSession(c.healthChecker, host),
 	}
 
 	// add timer to trigger hb sending and timeout handling
@@ -82,7 +82,7 @@ func (c *sofarpcHealthChecker) newSofaRPCHealthCheckSession(codecClinet stream.C
 func (c *sofarpcHealthChecker) newSession(host types.Host) types.HealthCheckSession {
 	shcs := &sofarpcHealthCheckSession{
 		healthChecker:      c,
-		healthCheckSession: *newHealthCheckSession(&c.healthChecker, host),
+		healthCheckSession: *newHealthCheckSession(c.healthChecker, host),
 	}
 	// add timer to trigger hb sending and timeout handling
 	shcs.intervalTimer = newTimer(shcs.onInterval)
@@ -143,6 +143,16 @@ func (s *sofarpcHealthCheckSession) Start() {
 	s.onInterval()
 }
 
+// overload healthCheckSession, the connection to host is closed on stop
+func (s *sofarpcHealthCheckSession) Stop() {
+	s.healthCheckSession.Stop()
+
+	if s.client != nil {
+		s.expectReset = true
+		s.client.Close()
+	}
+}
+
 func (s *sofarpcHealthCheckSession) onInterval() {
 	if s.client == nil {
 		connData := s.host.CreateConnection(nil)
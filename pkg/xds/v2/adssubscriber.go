@@ -18,112 +18,286 @@
 package v2
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
 	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/config"
 	"github.com/alipay/sofa-mosn/pkg/log"
 	envoy_api_v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core1 "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	ads "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
+	google_rpc "github.com/gogo/googleapis/google/rpc"
+	"google.golang.org/grpc/codes"
 )
 
+var (
+	// the delay to reconnect the management server is doubled after each failure, up to the max delay
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = time.Second * 30
+)
+
+// resources are subscribed in this order on a new stream, clusters are warmed before listeners
+var subscribeOrder = []string{ClusterType, EndpointType, ListenerType, RouteType}
+
 func (adsClient *ADSClient) Start() {
-	adsClient.StreamClient = adsClient.AdsConfig.GetStreamClient()
 	adsClient.MosnConfig = &config.MOSNConfig{}
-	go adsClient.SendThread()
-	go adsClient.ReceiveThread()
+	adsClient.subscriptions = map[string]*subscription{
+		ListenerType: {},
+		RouteType:    {},
+		ClusterType:  {},
+		EndpointType: {},
+	}
+	adsClient.doneChan = make(chan int)
+	go adsClient.run()
 }
 
-func (adsClient *ADSClient) SendThread() {
-	refreshDelay := adsClient.AdsConfig.RefreshDelay
-	t1 := time.NewTimer(*refreshDelay)
+// run keeps the ADS stream alive, the stream is reconnected with backoff when it drops
+func (adsClient *ADSClient) run() {
+	defer close(adsClient.doneChan)
+
+	delay := reconnectBaseDelay
 	for {
+		if streamClient := adsClient.connect(); streamClient != nil {
+			if adsClient.serve(streamClient) {
+				// the stream worked before it dropped, reconnect without backoff
+				delay = reconnectBaseDelay
+			}
+			adsClient.disconnect()
+		}
+
+		log.DefaultLogger.Warnf("ads stream disconnected, reconnect after %v", delay)
 		select {
-		case <-adsClient.SendControlChan:
-			log.DefaultLogger.Tracef("send thread receive graceful shut down signal")
-			adsClient.AdsConfig.CloseADSStreamClient()
-			adsClient.StopChan <- 1
+		case <-adsClient.StopChan:
 			return
-		case <-t1.C:
-			log.DefaultLogger.Tracef("send thread request lds")
-			err := adsClient.V2Client.ReqListeners(adsClient.StreamClient)
-			if err != nil {
-				log.DefaultLogger.Warnf("send thread request lds fail!auto retry next period")
-			}
-			log.DefaultLogger.Tracef("send thread request cds")
-			err = adsClient.V2Client.ReqClusters(adsClient.StreamClient)
-			if err != nil {
-				log.DefaultLogger.Warnf("send thread request cds fail!auto retry next period")
-			}
-			t1.Reset(*refreshDelay)
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
 		}
 	}
 }
 
-func (adsClient *ADSClient) ReceiveThread() {
+func (adsClient *ADSClient) connect() ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient {
+	adsClient.streamMux.Lock()
+	defer adsClient.streamMux.Unlock()
+
+	if adsClient.stopped {
+		return nil
+	}
+
+	adsClient.StreamClient = adsClient.AdsConfig.GetStreamClient()
+	return adsClient.StreamClient
+}
+
+func (adsClient *ADSClient) disconnect() {
+	adsClient.streamMux.Lock()
+	defer adsClient.streamMux.Unlock()
+
+	adsClient.AdsConfig.CloseADSStreamClient()
+	adsClient.StreamClient = nil
+}
+
+// serve subscribes the resources on the stream and handles the responses until the stream drops,
+// it returns whether any response is received
+func (adsClient *ADSClient) serve(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient) bool {
+	// the accepted versions are sent on the new stream, so the management server
+	// could skip the resources not changed
+	for _, typeURL := range subscribeOrder {
+		sub := adsClient.subscriptions[typeURL]
+		sub.nonce = ""
+
+		// endpoints and routes are subscribed by name after clusters and listeners are discovered
+		if (typeURL == EndpointType || typeURL == RouteType) && len(sub.resourceNames) == 0 {
+			continue
+		}
+
+		if err := adsClient.sendRequest(streamClient, typeURL, nil); err != nil {
+			log.DefaultLogger.Warnf("ads stream subscribe %s failed: %v", typeURL, err)
+			return false
+		}
+	}
+
+	received := false
 	for {
-		select {
-		case <-adsClient.RecvControlChan:
-			log.DefaultLogger.Tracef("receive thread receive graceful shut down signal")
-			adsClient.StopChan <- 2
-			return
-		default:
-			resp, err := adsClient.StreamClient.Recv()
-			if err != nil {
-				log.DefaultLogger.Warnf("get resp timeout: %v", err)
-				continue
+		resp, err := streamClient.Recv()
+		if err != nil {
+			log.DefaultLogger.Warnf("ads stream receive failed: %v", err)
+			return received
+		}
+		received = true
+
+		if err := adsClient.handleResponse(streamClient, resp); err != nil {
+			log.DefaultLogger.Warnf("ads stream send failed: %v", err)
+			return received
+		}
+	}
+}
+
+// handleResponse applies the resources in the response, ACKs the response with its version if
+// the resources are accepted, otherwise NACKs it with the last accepted version and the error detail.
+// The resources depending on the accepted ones are subscribed after the ACK
+func (adsClient *ADSClient) handleResponse(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient,
+	resp *envoy_api_v2.DiscoveryResponse) error {
+	sub, ok := adsClient.subscriptions[resp.TypeUrl]
+	if !ok {
+		log.DefaultLogger.Warnf("ads stream receive unknown resource type %s", resp.TypeUrl)
+		return nil
+	}
+	sub.nonce = resp.Nonce
+
+	var errorDetail *google_rpc.Status
+	dependentType, dependentNames, err := adsClient.applyResponse(resp)
+	if err != nil {
+		log.DefaultLogger.Errorf("reject %s version %s: %v", resp.TypeUrl, resp.VersionInfo, err)
+		errorDetail = &google_rpc.Status{
+			Code:    int32(codes.InvalidArgument),
+			Message: err.Error(),
+		}
+	} else {
+		log.DefaultLogger.Infof("accept %s version %s", resp.TypeUrl, resp.VersionInfo)
+		sub.version = resp.VersionInfo
+	}
+
+	if err := adsClient.sendRequest(streamClient, resp.TypeUrl, errorDetail); err != nil {
+		return err
+	}
+
+	if dependentType == "" {
+		return nil
+	}
+	return adsClient.subscribe(streamClient, dependentType, dependentNames)
+}
+
+// applyResponse applies the resources in the response, and returns the type and names of the resources
+// depending on them, endpoints of eds clusters and routes of listeners, which are subscribed by name
+func (adsClient *ADSClient) applyResponse(resp *envoy_api_v2.DiscoveryResponse) (string, []string, error) {
+	switch resp.TypeUrl {
+	case ListenerType:
+		listeners, err := adsClient.V2Client.HandleListersResp(resp)
+		if err != nil {
+			return "", nil, err
+		}
+		log.DefaultLogger.Infof("get %d listeners from LDS", len(listeners))
+		if err := adsClient.MosnConfig.OnUpdateListeners(listeners); err != nil {
+			return "", nil, fmt.Errorf("fail to update listeners: %v", err)
+		}
+		return RouteType, config.GetRouteConfigNames(listeners), nil
+
+	case RouteType:
+		resources, err := adsClient.V2Client.HandleRoutesResp(resp)
+		if err != nil {
+			return "", nil, err
+		}
+		var routes []*envoy_api_v2.RouteConfiguration
+		for _, route := range resources {
+			if adsClient.subscribed(RouteType, route.Name) {
+				routes = append(routes, route)
 			}
-			typeURL := resp.TypeUrl
-			if typeURL == "type.googleapis.com/envoy.api.v2.Listener" {
-				log.DefaultLogger.Tracef("get lds resp,handle it")
-				listeners := adsClient.V2Client.HandleListersResp(resp)
-				log.DefaultLogger.Infof("get %d listeners from LDS", len(listeners))
-				err := adsClient.MosnConfig.OnUpdateListeners(listeners)
-				if err != nil {
-					log.DefaultLogger.Fatalf("fail to update listeners")
-					return
-				}
-				log.DefaultLogger.Infof("update listeners success")
-			} else if typeURL == "type.googleapis.com/envoy.api.v2.Cluster" {
-				log.DefaultLogger.Tracef("get cds resp,handle it")
-				clusters := adsClient.V2Client.HandleClustersResp(resp)
-				log.DefaultLogger.Infof("get %d clusters from CDS", len(clusters))
-				err := adsClient.MosnConfig.OnUpdateClusters(clusters)
-				if err != nil {
-					log.DefaultLogger.Fatalf("fall to update clusters")
-					return
-				}
-				log.DefaultLogger.Infof("update clusters success")
-				clusterNames := make([]string, 0)
-				for _, cluster := range clusters {
-					if cluster.Type == envoy_api_v2.Cluster_EDS {
-						clusterNames = append(clusterNames, cluster.Name)
-					}
-				}
-				adsClient.V2Client.ReqEndpoints(adsClient.StreamClient, clusterNames)
-			} else if typeURL == "type.googleapis.com/envoy.api.v2.ClusterLoadAssignment" {
-				log.DefaultLogger.Tracef("get eds resp,handle it ")
-				endpoints := adsClient.V2Client.HandleEndpointesResp(resp)
-				log.DefaultLogger.Tracef("get %d endpoints for cluster", len(endpoints))
-				err = adsClient.MosnConfig.OnUpdateEndpoints(endpoints)
-				if err != nil {
-					log.DefaultLogger.Fatalf("fail to update endpoints for cluster")
-					return
-				}
-				log.DefaultLogger.Tracef("update endpoints for cluster %s success")
+		}
+		log.DefaultLogger.Infof("get %d routes from RDS", len(routes))
+		if err := adsClient.MosnConfig.OnUpdateRoutes(routes); err != nil {
+			return "", nil, fmt.Errorf("fail to update routes: %v", err)
+		}
+
+	case ClusterType:
+		clusters, err := adsClient.V2Client.HandleClustersResp(resp)
+		if err != nil {
+			return "", nil, err
+		}
+		log.DefaultLogger.Infof("get %d clusters from CDS", len(clusters))
+		if err := adsClient.MosnConfig.OnUpdateClusters(clusters); err != nil {
+			return "", nil, fmt.Errorf("fail to update clusters: %v", err)
+		}
+
+		clusterNames := make([]string, 0, len(clusters))
+		for _, cluster := range clusters {
+			if cluster.Type == envoy_api_v2.Cluster_EDS {
+				clusterNames = append(clusterNames, cluster.Name)
+			}
+		}
+		return EndpointType, clusterNames, nil
+
+	case EndpointType:
+		resources, err := adsClient.V2Client.HandleEndpointesResp(resp)
+		if err != nil {
+			return "", nil, err
+		}
+		var endpoints []*envoy_api_v2.ClusterLoadAssignment
+		for _, endpoint := range resources {
+			if adsClient.subscribed(EndpointType, endpoint.ClusterName) {
+				endpoints = append(endpoints, endpoint)
 			}
 		}
+		log.DefaultLogger.Infof("get %d endpoints from EDS", len(endpoints))
+		if err := adsClient.MosnConfig.OnUpdateEndpoints(endpoints); err != nil {
+			return "", nil, fmt.Errorf("fail to update endpoints: %v", err)
+		}
 	}
+
+	return "", nil, nil
 }
 
-func (adsClient *ADSClient) Stop() {
-	adsClient.SendControlChan <- 1
-	adsClient.RecvControlChan <- 1
-	for i := 0; i < 2; i++ {
-		select {
-		case <-adsClient.StopChan:
-			log.DefaultLogger.Tracef("stop signal")
-		}
+// subscribed returns whether the resource is subscribed by name, resources of the clusters
+// or listeners removed may still be sent before the management server handles the unsubscription
+func (adsClient *ADSClient) subscribed(typeURL string, name string) bool {
+	names := adsClient.subscriptions[typeURL].resourceNames
+	i := sort.SearchStrings(names, name)
+
+	return i < len(names) && names[i] == name
+}
+
+// subscribe updates the names of the resources subscribed, the request is sent only if the names are changed.
+// Once the names become empty, the request with no names is still sent to unsubscribe the stale ones,
+// though it means all resources to the management server, the resources not subscribed are skipped
+func (adsClient *ADSClient) subscribe(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient,
+	typeURL string, resourceNames []string) error {
+	sub := adsClient.subscriptions[typeURL]
+
+	if len(resourceNames) == 0 {
+		resourceNames = nil
 	}
-	close(adsClient.SendControlChan)
-	close(adsClient.RecvControlChan)
+	sort.Strings(resourceNames)
+	if reflect.DeepEqual(sub.resourceNames, resourceNames) {
+		return nil
+	}
+	sub.resourceNames = resourceNames
+
+	log.DefaultLogger.Infof("subscribe %s: %v", typeURL, resourceNames)
+	return adsClient.sendRequest(streamClient, typeURL, nil)
+}
+
+func (adsClient *ADSClient) sendRequest(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient,
+	typeURL string, errorDetail *google_rpc.Status) error {
+	if streamClient == nil {
+		return errors.New("stream client is nil")
+	}
+
+	sub := adsClient.subscriptions[typeURL]
+	return streamClient.Send(&envoy_api_v2.DiscoveryRequest{
+		VersionInfo:   sub.version,
+		ResourceNames: sub.resourceNames,
+		TypeUrl:       typeURL,
+		ResponseNonce: sub.nonce,
+		ErrorDetail:   errorDetail,
+		Node: &envoy_api_v2_core1.Node{
+			Id:      adsClient.V2Client.ServiceNode,
+			Cluster: adsClient.V2Client.ServiceCluster,
+		},
+	})
+}
+
+func (adsClient *ADSClient) Stop() {
+	adsClient.streamMux.Lock()
+	adsClient.stopped = true
+	// the blocked Recv returns once the stream is closed
+	adsClient.AdsConfig.CloseADSStreamClient()
+	adsClient.streamMux.Unlock()
+
 	close(adsClient.StopChan)
+	<-adsClient.doneChan
+	log.DefaultLogger.Tracef("ads client stopped")
 }
@@ -0,0 +1,477 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v2
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/api/v2"
+	"github.com/alipay/sofa-mosn/pkg/config"
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/upstream/cluster"
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	xdscore "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	xdsendpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
+	xdslistener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
+	ads "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc"
+)
+
+const testNode = "test_node"
+
+// fakeADSServer serves the resources of each type with its version, and records the requests received.
+// It responds to the first request of each type on a stream and the requests changing the resource names,
+// NACKs are not responded, and the resources are pushed to the streams subscribing them once updated
+type fakeADSServer struct {
+	mu        sync.Mutex
+	versions  map[string]string
+	resources map[string][]types.Any
+	notifies  map[chan struct{}]bool
+	requests  chan *xdsapi.DiscoveryRequest
+}
+
+func newFakeADSServer() *fakeADSServer {
+	return &fakeADSServer{
+		versions:  make(map[string]string),
+		resources: make(map[string][]types.Any),
+		notifies:  make(map[chan struct{}]bool),
+		requests:  make(chan *xdsapi.DiscoveryRequest, 100),
+	}
+}
+
+// setResources updates the resources of the type url, and pushes them to the streams
+func (s *fakeADSServer) setResources(typeURL string, version string, resources []types.Any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[typeURL] = version
+	s.resources[typeURL] = resources
+	for notify := range s.notifies {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *fakeADSServer) getResources(typeURL string) (string, []types.Any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.versions[typeURL], s.resources[typeURL]
+}
+
+func (s *fakeADSServer) StreamAggregatedResources(stream ads.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	notify := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.notifies[notify] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.notifies, notify)
+		s.mu.Unlock()
+	}()
+
+	requests := make(chan *xdsapi.DiscoveryRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			s.requests <- req
+			select {
+			case requests <- req:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	// the version and resource names sent and subscribed of each type
+	sent := make(map[string]string)
+	names := make(map[string][]string)
+	nonce := 0
+	respond := func(typeURL string) error {
+		version, resources := s.getResources(typeURL)
+		sent[typeURL] = version
+		nonce++
+		return stream.Send(&xdsapi.DiscoveryResponse{
+			VersionInfo: version,
+			Resources:   resources,
+			TypeUrl:     typeURL,
+			Nonce:       strconv.Itoa(nonce),
+		})
+	}
+
+	for {
+		select {
+		case req := <-requests:
+			_, subscribed := sent[req.TypeUrl]
+			changed := !reflect.DeepEqual(names[req.TypeUrl], req.ResourceNames)
+			names[req.TypeUrl] = req.ResourceNames
+			if subscribed && !changed {
+				continue
+			}
+			if err := respond(req.TypeUrl); err != nil {
+				return err
+			}
+		case <-notify:
+			for typeURL, version := range sent {
+				if current, _ := s.getResources(typeURL); current == version {
+					continue
+				}
+				if err := respond(typeURL); err != nil {
+					return err
+				}
+			}
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+func startFakeADSServer(t *testing.T, addr string, server *fakeADSServer) (*grpc.Server, string) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	ads.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
+	go grpcServer.Serve(l)
+
+	return grpcServer, l.Addr().String()
+}
+
+// newResources marshals the resources of the type url
+func newResources(t *testing.T, typeURL string, resources ...interface {
+	Marshal() ([]byte, error)
+}) []types.Any {
+	anys := make([]types.Any, 0, len(resources))
+	for _, resource := range resources {
+		data, err := resource.Marshal()
+		if err != nil {
+			t.Fatalf("marshal %s failed: %v", typeURL, err)
+		}
+		anys = append(anys, types.Any{TypeUrl: typeURL, Value: data})
+	}
+	return anys
+}
+
+// waitRequest waits for the request of the type url matching the condition, the requests not matching are skipped
+func waitRequest(t *testing.T, requests chan *xdsapi.DiscoveryRequest, typeURL string, match func(req *xdsapi.DiscoveryRequest) bool) *xdsapi.DiscoveryRequest {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case req := <-requests:
+			if req.TypeUrl == typeURL && match(req) {
+				return req
+			}
+		case <-timeout:
+			t.Fatalf("wait for %s request timeout", typeURL)
+			return nil
+		}
+	}
+}
+
+func newEDSCluster(name string) *xdsapi.Cluster {
+	return &xdsapi.Cluster{
+		Name:           name,
+		Type:           xdsapi.Cluster_EDS,
+		ConnectTimeout: time.Second,
+		EdsClusterConfig: &xdsapi.Cluster_EdsClusterConfig{
+			EdsConfig: &xdscore.ConfigSource{
+				ConfigSourceSpecifier: &xdscore.ConfigSource_Ads{
+					Ads: &xdscore.AggregatedConfigSource{},
+				},
+			},
+		},
+	}
+}
+
+func newLoadAssignment(clusterName string, port uint32) *xdsapi.ClusterLoadAssignment {
+	return &xdsapi.ClusterLoadAssignment{
+		ClusterName: clusterName,
+		Endpoints: []xdsendpoint.LocalityLbEndpoints{
+			{
+				LbEndpoints: []xdsendpoint.LbEndpoint{
+					{
+						Endpoint: &xdsendpoint.Endpoint{
+							Address: &xdscore.Address{
+								Address: &xdscore.Address_SocketAddress{
+									SocketAddress: &xdscore.SocketAddress{
+										Address: "127.0.0.1",
+										PortSpecifier: &xdscore.SocketAddress_PortValue{
+											PortValue: port,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestADSClient(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	reconnectBaseDelay = 100 * time.Millisecond
+
+	cm := cluster.NewClusterManager(nil, []v2.Cluster{
+		{
+			Name:        "static_cluster",
+			ClusterType: v2.SIMPLE_CLUSTER,
+			LbType:      v2.LB_RANDOM,
+			Hosts:       []v2.Host{{Address: "127.0.0.1:10000"}},
+		},
+	}, nil, false, false)
+
+	adsServer := newFakeADSServer()
+	adsServer.setResources(ClusterType, "1", newResources(t, ClusterType, newEDSCluster("eds_cluster")))
+	adsServer.setResources(EndpointType, "1", newResources(t, EndpointType, newLoadAssignment("eds_cluster", 10001)))
+	requests := adsServer.requests
+	grpcServer, addr := startFakeADSServer(t, "127.0.0.1:0", adsServer)
+
+	adsClient := &ADSClient{
+		AdsConfig: &ADSConfig{
+			Services: []*ServiceConfig{
+				{
+					ClusterConfig: &ClusterConfig{
+						LbPolicy: xdsapi.Cluster_RANDOM,
+						Address:  []string{addr},
+					},
+				},
+			},
+		},
+		V2Client: &ClientV2{
+			ServiceCluster: "test_cluster",
+			ServiceNode:    testNode,
+		},
+		StopChan: make(chan int),
+	}
+	adsClient.Start()
+	defer adsClient.Stop()
+
+	// clusters are ACKed with the version and nonce of the response
+	waitRequest(t, requests, ClusterType, func(req *xdsapi.DiscoveryRequest) bool {
+		return req.VersionInfo == "1" && req.ResponseNonce != "" && req.ErrorDetail == nil
+	})
+
+	// endpoints of the discovered eds cluster are subscribed, and ACKed
+	waitRequest(t, requests, EndpointType, func(req *xdsapi.DiscoveryRequest) bool {
+		return len(req.ResourceNames) == 1 && req.ResourceNames[0] == "eds_cluster"
+	})
+	waitRequest(t, requests, EndpointType, func(req *xdsapi.DiscoveryRequest) bool {
+		return req.VersionInfo == "1" && req.ErrorDetail == nil
+	})
+
+	if !cm.ClusterExist("eds_cluster") {
+		t.Fatalf("expected eds cluster added")
+	}
+	hosts := cm.Get(nil, "eds_cluster").PrioritySet().HostSetsByPriority()[0].Hosts()
+	if len(hosts) != 1 || hosts[0].AddressString() != "127.0.0.1:10001" {
+		t.Errorf("expected endpoints updated, but got %v", hosts)
+	}
+
+	// clusters conflicting with the cluster in config file are NACKed with the last accepted version
+	adsServer.setResources(ClusterType, "2",
+		newResources(t, ClusterType, newEDSCluster("eds_cluster"), newEDSCluster("static_cluster")))
+
+	waitRequest(t, requests, ClusterType, func(req *xdsapi.DiscoveryRequest) bool {
+		return req.ErrorDetail != nil && req.VersionInfo == "1" && req.ResponseNonce != ""
+	})
+
+	// the client reconnects when the stream drops, and subscribes with the accepted versions
+	grpcServer.Stop()
+	grpcServer, _ = startFakeADSServer(t, addr, adsServer)
+	defer grpcServer.Stop()
+
+	waitRequest(t, requests, ClusterType, func(req *xdsapi.DiscoveryRequest) bool {
+		return req.VersionInfo == "1" && req.ResponseNonce == ""
+	})
+	waitRequest(t, requests, EndpointType, func(req *xdsapi.DiscoveryRequest) bool {
+		return req.VersionInfo == "1" && req.ResponseNonce == "" && len(req.ResourceNames) == 1
+	})
+
+	// endpoints are unsubscribed once the eds cluster is removed, and the endpoints of
+	// the removed cluster sent for the empty subscription are skipped instead of NACKed
+	adsServer.setResources(ClusterType, "3", nil)
+	adsServer.setResources(EndpointType, "3", newResources(t, EndpointType, newLoadAssignment("eds_cluster", 10001)))
+
+	waitRequest(t, requests, EndpointType, func(req *xdsapi.DiscoveryRequest) bool {
+		return len(req.ResourceNames) == 0 && req.ResponseNonce != ""
+	})
+	waitRequest(t, requests, EndpointType, func(req *xdsapi.DiscoveryRequest) bool {
+		return req.VersionInfo == "3" && req.ErrorDetail == nil
+	})
+}
+
+// failStream fails to send after the number of requests
+type failStream struct {
+	ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+	sendLimit int
+	requests  []*xdsapi.DiscoveryRequest
+}
+
+func (s *failStream) Send(req *xdsapi.DiscoveryRequest) error {
+	if len(s.requests) >= s.sendLimit {
+		return errors.New("stream closed")
+	}
+	s.requests = append(s.requests, req)
+	return nil
+}
+
+func TestADSClientSendError(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	cluster.NewClusterManager(nil, nil, nil, false, false)
+
+	data, err := newEDSCluster("send_error_cluster").Marshal()
+	if err != nil {
+		t.Fatalf("marshal cluster failed: %v", err)
+	}
+
+	adsClient := &ADSClient{
+		V2Client:   &ClientV2{ServiceNode: testNode},
+		MosnConfig: &config.MOSNConfig{},
+		subscriptions: map[string]*subscription{
+			ClusterType:  {},
+			EndpointType: {},
+		},
+	}
+
+	// the clusters are ACKed, and the failure to subscribe the endpoints is returned to reconnect
+	stream := &failStream{sendLimit: 1}
+	err = adsClient.handleResponse(stream, &xdsapi.DiscoveryResponse{
+		VersionInfo: "1",
+		Nonce:       "a",
+		TypeUrl:     ClusterType,
+		Resources:   []types.Any{{TypeUrl: ClusterType, Value: data}},
+	})
+	if err == nil {
+		t.Error("expected the send error returned")
+	}
+	if len(stream.requests) != 1 || stream.requests[0].ErrorDetail != nil || stream.requests[0].VersionInfo != "1" {
+		t.Errorf("expected the clusters ACKed, got %v", stream.requests)
+	}
+	if version := adsClient.subscriptions[ClusterType].version; version != "1" {
+		t.Errorf("expected version 1 accepted, got %s", version)
+	}
+}
+
+func TestADSClientNACKInvalidResource(t *testing.T) {
+	log.InitDefaultLogger("", log.INFO)
+
+	cluster.NewClusterManager(nil, nil, nil, false, false)
+
+	listener := &xdsapi.Listener{
+		Name: "unsupported_listener",
+		Address: xdscore.Address{
+			Address: &xdscore.Address_SocketAddress{
+				SocketAddress: &xdscore.SocketAddress{
+					Address:       "127.0.0.1",
+					PortSpecifier: &xdscore.SocketAddress_PortValue{PortValue: 10002},
+				},
+			},
+		},
+		FilterChains: []xdslistener.FilterChain{
+			{Filters: []xdslistener.Filter{{Name: "unsupported_filter"}}},
+		},
+	}
+	listenerData, err := listener.Marshal()
+	if err != nil {
+		t.Fatalf("marshal listener failed: %v", err)
+	}
+	pipeCluster := &xdsapi.Cluster{
+		Name:           "pipe_cluster",
+		Type:           xdsapi.Cluster_STATIC,
+		ConnectTimeout: time.Second,
+		Hosts: []*xdscore.Address{
+			{Address: &xdscore.Address_Pipe{Pipe: &xdscore.Pipe{Path: "/tmp/pipe_cluster"}}},
+		},
+	}
+	clusterData, err := pipeCluster.Marshal()
+	if err != nil {
+		t.Fatalf("marshal cluster failed: %v", err)
+	}
+
+	adsClient := &ADSClient{
+		V2Client:   &ClientV2{ServiceNode: testNode},
+		MosnConfig: &config.MOSNConfig{},
+		subscriptions: map[string]*subscription{
+			ListenerType: {},
+			RouteType:    {},
+			ClusterType:  {},
+		},
+	}
+	stream := &failStream{sendLimit: 100}
+
+	responses := []struct {
+		resp     *xdsapi.DiscoveryResponse
+		rejected bool
+	}{
+		{&xdsapi.DiscoveryResponse{VersionInfo: "1", Nonce: "a", TypeUrl: ListenerType}, false},
+		// the listener with unsupported filter could not be converted
+		{&xdsapi.DiscoveryResponse{VersionInfo: "2", Nonce: "b", TypeUrl: ListenerType,
+			Resources: []types.Any{{TypeUrl: ListenerType, Value: listenerData}}}, true},
+		// the listener could not be decoded
+		{&xdsapi.DiscoveryResponse{VersionInfo: "3", Nonce: "c", TypeUrl: ListenerType,
+			Resources: []types.Any{{TypeUrl: ListenerType, Value: []byte{0xff}}}}, true},
+		// the cluster with pipe host could not be converted
+		{&xdsapi.DiscoveryResponse{VersionInfo: "1", Nonce: "d", TypeUrl: ClusterType,
+			Resources: []types.Any{{TypeUrl: ClusterType, Value: clusterData}}}, true},
+	}
+	for i, tc := range responses {
+		if err := adsClient.handleResponse(stream, tc.resp); err != nil {
+			t.Fatalf("#%d handle response failed: %v", i, err)
+		}
+		if len(stream.requests) != i+1 {
+			t.Fatalf("#%d expected the response ACKed or NACKed, got %d requests", i, len(stream.requests))
+		}
+		req := stream.requests[i]
+		if req.TypeUrl != tc.resp.TypeUrl || req.ResponseNonce != tc.resp.Nonce {
+			t.Errorf("#%d expected request of %s with nonce %s, got %s with nonce %s",
+				i, tc.resp.TypeUrl, tc.resp.Nonce, req.TypeUrl, req.ResponseNonce)
+		}
+		if !tc.rejected {
+			if req.ErrorDetail != nil || req.VersionInfo != tc.resp.VersionInfo {
+				t.Errorf("#%d expected version %s ACKed, got %v", i, tc.resp.VersionInfo, req)
+			}
+			continue
+		}
+		// NACKed with the previous accepted version
+		expectedVersion := ""
+		if tc.resp.TypeUrl == ListenerType {
+			expectedVersion = "1"
+		}
+		if req.ErrorDetail == nil || req.ErrorDetail.Message == "" || req.VersionInfo != expectedVersion {
+			t.Errorf("#%d expected NACK with version %q and error detail, got %v", i, expectedVersion, req)
+		}
+	}
+}
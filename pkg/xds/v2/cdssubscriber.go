@@ -19,6 +19,7 @@ package v2
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/alipay/sofa-mosn/pkg/log"
 	envoy_api_v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
@@ -37,7 +38,12 @@ func (c *ClientV2) GetClusters(streamClient ads.AggregatedDiscoveryService_Strea
 		log.DefaultLogger.Fatalf("get clusters fail: %v", err)
 		return nil
 	}
-	return c.HandleClustersResp(r)
+	resources, err := c.HandleClustersResp(r)
+	if err != nil {
+		log.DefaultLogger.Fatalf("get clusters fail: %v", err)
+		return nil
+	}
+	return resources
 }
 
 func (c *ClientV2) ReqClusters(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient) error {
@@ -47,7 +53,7 @@ func (c *ClientV2) ReqClusters(streamClient ads.AggregatedDiscoveryService_Strea
 	err := streamClient.Send(&envoy_api_v2.DiscoveryRequest{
 		VersionInfo:   "",
 		ResourceNames: []string{},
-		TypeUrl:       "type.googleapis.com/envoy.api.v2.Cluster",
+		TypeUrl:       ClusterType,
 		ResponseNonce: "",
 		ErrorDetail:   nil,
 		Node: &envoy_api_v2_core1.Node{
@@ -61,12 +67,15 @@ func (c *ClientV2) ReqClusters(streamClient ads.AggregatedDiscoveryService_Strea
 	return nil
 }
 
-func (c *ClientV2) HandleClustersResp(resp *envoy_api_v2.DiscoveryResponse) []*envoy_api_v2.Cluster {
+// HandleClustersResp decodes the resources in the response, an error is returned if any of them fails to decode
+func (c *ClientV2) HandleClustersResp(resp *envoy_api_v2.DiscoveryResponse) ([]*envoy_api_v2.Cluster, error) {
 	clusters := make([]*envoy_api_v2.Cluster, 0)
 	for _, res := range resp.Resources {
 		cluster := envoy_api_v2.Cluster{}
-		cluster.Unmarshal(res.GetValue())
+		if err := cluster.Unmarshal(res.GetValue()); err != nil {
+			return nil, fmt.Errorf("decode Cluster failed: %v", err)
+		}
 		clusters = append(clusters, &cluster)
 	}
-	return clusters
+	return clusters, nil
 }
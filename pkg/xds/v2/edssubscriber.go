@@ -19,6 +19,7 @@ package v2
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/alipay/sofa-mosn/pkg/log"
 	envoy_api_v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
@@ -38,7 +39,12 @@ func (c *ClientV2) GetEndpoints(streamClient ads.AggregatedDiscoveryService_Stre
 		return nil
 
 	}
-	return c.HandleEndpointesResp(r)
+	resources, err := c.HandleEndpointesResp(r)
+	if err != nil {
+		log.DefaultLogger.Fatalf("get endpoints fail: %v", err)
+		return nil
+	}
+	return resources
 }
 
 func (c *ClientV2) ReqEndpoints(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient, clusterNames []string) error {
@@ -48,7 +54,7 @@ func (c *ClientV2) ReqEndpoints(streamClient ads.AggregatedDiscoveryService_Stre
 	err := streamClient.Send(&envoy_api_v2.DiscoveryRequest{
 		VersionInfo:   "",
 		ResourceNames: clusterNames,
-		TypeUrl:       "type.googleapis.com/envoy.api.v2.ClusterLoadAssignment",
+		TypeUrl:       EndpointType,
 		ResponseNonce: "",
 		ErrorDetail:   nil,
 		Node: &envoy_api_v2_core1.Node{
@@ -63,12 +69,15 @@ func (c *ClientV2) ReqEndpoints(streamClient ads.AggregatedDiscoveryService_Stre
 	return nil
 }
 
-func (c *ClientV2) HandleEndpointesResp(resp *envoy_api_v2.DiscoveryResponse) []*envoy_api_v2.ClusterLoadAssignment {
+// HandleEndpointesResp decodes the resources in the response, an error is returned if any of them fails to decode
+func (c *ClientV2) HandleEndpointesResp(resp *envoy_api_v2.DiscoveryResponse) ([]*envoy_api_v2.ClusterLoadAssignment, error) {
 	lbAssignments := make([]*envoy_api_v2.ClusterLoadAssignment, 0)
 	for _, res := range resp.Resources {
 		lbAssignment := envoy_api_v2.ClusterLoadAssignment{}
-		lbAssignment.Unmarshal(res.GetValue())
+		if err := lbAssignment.Unmarshal(res.GetValue()); err != nil {
+			return nil, fmt.Errorf("decode ClusterLoadAssignment failed: %v", err)
+		}
 		lbAssignments = append(lbAssignments, &lbAssignment)
 	}
-	return lbAssignments
+	return lbAssignments, nil
 }
@@ -19,6 +19,7 @@ package v2
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/alipay/sofa-mosn/pkg/log"
 	envoy_api_v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
@@ -37,7 +38,12 @@ func (c *ClientV2) GetListeners(streamClient ads.AggregatedDiscoveryService_Stre
 		log.DefaultLogger.Fatalf("get listener fail: %v", err)
 		return nil
 	}
-	return c.HandleListersResp(r)
+	resources, err := c.HandleListersResp(r)
+	if err != nil {
+		log.DefaultLogger.Fatalf("get listener fail: %v", err)
+		return nil
+	}
+	return resources
 }
 
 func (c *ClientV2) ReqListeners(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient) error {
@@ -47,7 +53,7 @@ func (c *ClientV2) ReqListeners(streamClient ads.AggregatedDiscoveryService_Stre
 	err := streamClient.Send(&envoy_api_v2.DiscoveryRequest{
 		VersionInfo:   "",
 		ResourceNames: []string{},
-		TypeUrl:       "type.googleapis.com/envoy.api.v2.Listener",
+		TypeUrl:       ListenerType,
 		ResponseNonce: "",
 		ErrorDetail:   nil,
 		Node: &envoy_api_v2_core1.Node{
@@ -61,12 +67,15 @@ func (c *ClientV2) ReqListeners(streamClient ads.AggregatedDiscoveryService_Stre
 	return nil
 }
 
-func (c *ClientV2) HandleListersResp(resp *envoy_api_v2.DiscoveryResponse) []*envoy_api_v2.Listener {
+// HandleListersResp decodes the resources in the response, an error is returned if any of them fails to decode
+func (c *ClientV2) HandleListersResp(resp *envoy_api_v2.DiscoveryResponse) ([]*envoy_api_v2.Listener, error) {
 	listeners := make([]*envoy_api_v2.Listener, 0)
 	for _, res := range resp.Resources {
 		listener := envoy_api_v2.Listener{}
-		listener.Unmarshal(res.GetValue())
+		if err := listener.Unmarshal(res.GetValue()); err != nil {
+			return nil, fmt.Errorf("decode Listener failed: %v", err)
+		}
 		listeners = append(listeners, &listener)
 	}
-	return listeners
+	return listeners, nil
 }
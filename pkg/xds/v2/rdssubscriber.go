@@ -0,0 +1,82 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	envoy_api_v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core1 "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	ads "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
+)
+
+func (c *ClientV2) GetRoutes(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient, routeNames []string) []*envoy_api_v2.RouteConfiguration {
+	err := c.ReqRoutes(streamClient, routeNames)
+	if err != nil {
+		log.DefaultLogger.Fatalf("get routes fail: %v", err)
+		return nil
+	}
+	r, err := streamClient.Recv()
+	if err != nil {
+		log.DefaultLogger.Fatalf("get routes fail: %v", err)
+		return nil
+	}
+	resources, err := c.HandleRoutesResp(r)
+	if err != nil {
+		log.DefaultLogger.Fatalf("get routes fail: %v", err)
+		return nil
+	}
+	return resources
+}
+
+func (c *ClientV2) ReqRoutes(streamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient, routeNames []string) error {
+	if streamClient == nil {
+		return errors.New("stream client is nil")
+	}
+	err := streamClient.Send(&envoy_api_v2.DiscoveryRequest{
+		VersionInfo:   "",
+		ResourceNames: routeNames,
+		TypeUrl:       RouteType,
+		ResponseNonce: "",
+		ErrorDetail:   nil,
+		Node: &envoy_api_v2_core1.Node{
+			Id:      c.ServiceNode,
+			Cluster: c.ServiceCluster,
+		},
+	})
+	if err != nil {
+		log.DefaultLogger.Fatalf("get routes fail: %v", err)
+		return err
+	}
+	return nil
+}
+
+// HandleRoutesResp decodes the resources in the response, an error is returned if any of them fails to decode
+func (c *ClientV2) HandleRoutesResp(resp *envoy_api_v2.DiscoveryResponse) ([]*envoy_api_v2.RouteConfiguration, error) {
+	routes := make([]*envoy_api_v2.RouteConfiguration, 0)
+	for _, res := range resp.Resources {
+		route := envoy_api_v2.RouteConfiguration{}
+		if err := route.Unmarshal(res.GetValue()); err != nil {
+			return nil, fmt.Errorf("decode RouteConfiguration failed: %v", err)
+		}
+		routes = append(routes, &route)
+	}
+	return routes, nil
+}
@@ -18,6 +18,7 @@
 package v2
 
 import (
+	"sync"
 	"time"
 
 	"github.com/alipay/sofa-mosn/pkg/config"
@@ -52,14 +53,37 @@ type ADSConfig struct {
 	StreamClient *StreamClient
 }
 
+// type urls of the resources discovered by ADS
+const (
+	ListenerType = "type.googleapis.com/envoy.api.v2.Listener"
+	RouteType    = "type.googleapis.com/envoy.api.v2.RouteConfiguration"
+	ClusterType  = "type.googleapis.com/envoy.api.v2.Cluster"
+	EndpointType = "type.googleapis.com/envoy.api.v2.ClusterLoadAssignment"
+)
+
 type ADSClient struct {
-	AdsConfig       *ADSConfig
-	StreamClient    ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient
-	V2Client        *ClientV2
-	MosnConfig      *config.MOSNConfig
-	SendControlChan chan int
-	RecvControlChan chan int
-	StopChan        chan int
+	AdsConfig    *ADSConfig
+	StreamClient ads.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+	V2Client     *ClientV2
+	MosnConfig   *config.MOSNConfig
+	StopChan     chan int
+
+	// subscription state of each resource type, key: type url
+	subscriptions map[string]*subscription
+	streamMux     sync.Mutex
+	stopped       bool
+	doneChan      chan int
+}
+
+// subscription keeps the state of a resource type on the ADS stream
+type subscription struct {
+	// version of the last accepted response
+	version string
+	// nonce of the last received response
+	nonce string
+	// names of the subscribed resources, all resources are subscribed if it is empty,
+	// but endpoints and routes are only applied if subscribed by name
+	resourceNames []string
 }
 
 type ServiceConfig struct {
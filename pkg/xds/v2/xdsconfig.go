@@ -202,6 +202,8 @@ func (c *ADSConfig) GetStreamClient() ads.AggregatedDiscoveryService_StreamAggre
 	streamClient, err := client.StreamAggregatedResources(ctx)
 	if err != nil {
 		log.DefaultLogger.Errorf("fail to create stream client: %v", err)
+		cancel()
+		conn.Close()
 		return nil
 	}
 	sc.Client = streamClient